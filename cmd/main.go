@@ -1,12 +1,18 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"github.com/gin-gonic/gin"
 	"github.com/nekidaz/todolist/config"
 	"github.com/nekidaz/todolist/internal/controllers"
 	"github.com/nekidaz/todolist/internal/usecase/repo"
 	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/oidc"
+	"github.com/nekidaz/todolist/pkg/token"
 	"log"
+	"net/http"
+	"time"
 )
 
 func main() {
@@ -16,32 +22,97 @@ func main() {
 		log.Fatalf("Ошибка при настройке конфигурации: %s", err)
 	}
 
-	repo, err := repo.NewRepository(config)
+	repository, err := newRepository(config)
 
 	if err != nil {
-		log.Fatalf("Ошибка при подключении к MongoDB: %v", err)
+		log.Fatalf("Ошибка при подключении к хранилищу: %v", err)
 	}
+	defer repository.Close()
 
 	// Создание сервиса и контроллера
-	todoService := services.NewTodoService(repo)
-	todoController := controllers.NewTodoController(todoService)
+	todoService := services.NewTodoService(repository, services.SubtaskPolicies{
+		Delete:   config.SubtaskDeletePolicy,
+		Complete: config.SubtaskCompletePolicy,
+	}, config.RankWeights)
+	todoController := controllers.NewTodoController(todoService, config.LegacyPositionalIDs)
+
+	secret := []byte(config.JWTSecret)
+	if len(secret) == 0 {
+		log.Println("JWT_SECRET не задан: ключ подписи сгенерирован случайно, после перезапуска придется войти заново")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatalf("Ошибка при генерации ключа подписи: %v", err)
+		}
+	}
+	tokens := token.NewIssuer(secret, config.AccessTokenTTL, config.RefreshTokenTTL)
+	authService := services.NewAuthService(repository, tokens)
+
+	// данные, созданные до появления пользователей, переходят только к явно названному владельцу
+	if config.LegacyOwnerEmail != "" {
+		owner, err := authService.ClaimUnowned(context.Background(), config.LegacyOwnerEmail)
+		if err != nil {
+			log.Fatalf("Ошибка при передаче задач без владельца пользователю %s: %v", config.LegacyOwnerEmail, err)
+		}
+		log.Printf("Задачи и списки без владельца переданы пользователю %s", owner.Email)
+	}
+
+	var oidcService services.OIDCService
+	if config.OIDC.IssuerURL != "" {
+		provider, err := oidc.Discover(context.Background(), config.OIDC, &http.Client{Timeout: 10 * time.Second})
+		if err != nil {
+			log.Fatalf("Ошибка при подключении к провайдеру OIDC: %v", err)
+		}
+		oidcService = services.NewOIDCService(repository, provider, tokens)
+	}
+	authController := controllers.NewAuthController(authService, oidcService)
 
 	// Создание маршрутов и запуск сервера
 	r := gin.Default()
+	// владелец из RequireAuth лежит в контексте запроса, а сервисы получают gin.Context
+	r.ContextWithFallback = true
 
 	api := r.Group("/api/todo-list")
 
-	{
-		api.GET("/tasks/:ID", todoController.GetTaskByID)
-		api.GET("/tasks", todoController.GetTasksByStatusHandler)
-		api.GET("/tasks/all", todoController.GetAllTasks)
-
-		api.POST("/tasks", todoController.CreateNewTodoHandler)
-		api.DELETE("/tasks/:ID", todoController.DeleteTodoHandler)
-		api.PUT("/tasks/:ID", todoController.UpdateTodoHandler)
-		api.PATCH("/tasks/:ID/done", todoController.MarkAsCompletedHandler)
+	// с организациями любой запрос к API сначала находит свою, управляет ими только администратор
+	if tenants, ok := repository.(*repo.TenantRepository); ok {
+		tenantController := controllers.NewTenantController(services.NewTenantService(tenants, tokens), config.TenantDomain, config.AdminToken)
+		api.Use(tenantController.ResolveTenant)
 
+		admin := r.Group("/api/todo-list/admin/tenants", tenantController.RequireAdmin)
+		admin.GET("", tenantController.GetTenantsHandler)
+		admin.POST("", tenantController.CreateTenantHandler)
+		admin.DELETE("/:tenantID", tenantController.DeleteTenantHandler)
 	}
 
+	controllers.RegisterRoutes(api, todoController, authController)
+
 	r.Run(":8080")
 }
+
+// newRepository выбирает хранилище задач по config.Storage, с config.Tenancy - по базе на организацию
+func newRepository(cfg config.Config) (repo.TodoRepository, error) {
+	if cfg.Tenancy {
+		return newTenantRepository(cfg)
+	}
+
+	switch cfg.Storage {
+	case config.StorageSQLite:
+		return repo.NewSQLiteRepository(cfg)
+	case config.StorageMemory:
+		return repo.NewMemoryRepository(), nil
+	default:
+		return repo.NewRepository(cfg)
+	}
+}
+
+func newTenantRepository(cfg config.Config) (*repo.TenantRepository, error) {
+	if cfg.Storage == config.StorageMemory {
+		return repo.NewTenantRepository(repo.NewMemoryTenants()), nil
+	}
+
+	backend, err := repo.NewTenantDatabases(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return repo.NewTenantRepository(backend), nil
+}
@@ -3,32 +3,175 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/oidc"
+)
+
+// Поддерживаемые хранилища задач
+const (
+	StorageMongo  = "mongo"
+	StorageMemory = "memory"
+	StorageSQLite = "sqlite"
 )
 
 type Config struct {
+	// Storage - хранилище задач: mongo (по умолчанию), sqlite или memory
+	Storage            string
+	SQLitePath         string
 	DBConnectionString string
 	DBName             string
 	CollectionName     string
+	// LegacyPositionalIDs разрешает старым клиентам обращаться к задаче по номеру в списке вместо ObjectID
+	LegacyPositionalIDs bool
+	// SubtaskDeletePolicy и SubtaskCompletePolicy - что делать с подзадачами при удалении и завершении родителя
+	SubtaskDeletePolicy   entity.SubtaskPolicy
+	SubtaskCompletePolicy entity.SubtaskPolicy
+	// RankWeights - веса оценки задач в GET /tasks/next
+	RankWeights entity.RankWeights
+	// JWTSecret - ключ подписи токенов. Пустой - main генерирует случайный, и токены не переживают перезапуск
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// LegacyOwnerEmail - пользователь, которому при запуске передаются задачи и списки, созданные до появления
+	// пользователей. Пустой - данные без владельца никому не передаются
+	LegacyOwnerEmail string
+	// OIDC - вход через провайдера OpenID Connect, выключен без OIDC_ISSUER_URL
+	OIDC oidc.Config
+	// Tenancy - у каждой организации своя база: <DBName>_<id> в Mongo, организация запроса - из заголовка,
+	// поддомена TenantDomain или токена. Каталог организаций остается в DBName
+	Tenancy      bool
+	TenantDomain string
+	// AdminToken - ключ администратора для управления организациями, обязателен с Tenancy
+	AdminToken string
 }
 
 func ConfigSetup() (Config, error) {
 	dsn := fmt.Sprintf("mongodb://%s:%s", os.Getenv("MONGO_HOST"), os.Getenv("MONGO_PORT"))
 	config := Config{
+		Storage:            os.Getenv("STORAGE"),
+		SQLitePath:         os.Getenv("SQLITE_PATH"),
 		DBConnectionString: dsn,
 		DBName:             os.Getenv("MONGO_NAME"),
 		CollectionName:     os.Getenv("MONGO_COLLECTION"),
 	}
 
-	if config.DBConnectionString == "" {
-		return config, fmt.Errorf("DB_CONNECTION_STRING not set")
+	if v := os.Getenv("LEGACY_POSITIONAL_IDS"); v != "" {
+		legacy, err := strconv.ParseBool(v)
+		if err != nil {
+			return config, fmt.Errorf("LEGACY_POSITIONAL_IDS must be a boolean: %w", err)
+		}
+		config.LegacyPositionalIDs = legacy
+	}
+
+	var err error
+	if config.SubtaskDeletePolicy, err = entity.ParseSubtaskPolicy(os.Getenv("SUBTASK_DELETE_POLICY")); err != nil {
+		return config, fmt.Errorf("SUBTASK_DELETE_POLICY: %w", err)
 	}
-	if config.DBName == "" {
-		return config, fmt.Errorf("DB_NAME not set")
+	if config.SubtaskCompletePolicy, err = entity.ParseSubtaskPolicy(os.Getenv("SUBTASK_COMPLETE_POLICY")); err != nil {
+		return config, fmt.Errorf("SUBTASK_COMPLETE_POLICY: %w", err)
 	}
-	if config.CollectionName == "" {
-		return config, fmt.Errorf("COLLECTION_NAME not set")
+
+	config.RankWeights = entity.DefaultRankWeights()
+	for _, weight := range []struct {
+		env string
+		dst *float64
+	}{
+		{"NEXT_WEIGHT_PRIORITY", &config.RankWeights.Priority},
+		{"NEXT_WEIGHT_OVERDUE", &config.RankWeights.Overdue},
+		{"NEXT_WEIGHT_AGE", &config.RankWeights.Age},
+	} {
+		v := os.Getenv(weight.env)
+		if v == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(v, 64)
+		if err != nil || value < 0 {
+			return config, fmt.Errorf("%s must be a non-negative number", weight.env)
+		}
+		*weight.dst = value
+	}
+
+	config.JWTSecret = os.Getenv("JWT_SECRET")
+	config.AccessTokenTTL = 15 * time.Minute
+	config.RefreshTokenTTL = 30 * 24 * time.Hour
+	for _, ttl := range []struct {
+		env string
+		dst *time.Duration
+	}{
+		{"JWT_ACCESS_TTL", &config.AccessTokenTTL},
+		{"JWT_REFRESH_TTL", &config.RefreshTokenTTL},
+	} {
+		v := os.Getenv(ttl.env)
+		if v == "" {
+			continue
+		}
+		value, err := time.ParseDuration(v)
+		if err != nil || value <= 0 {
+			return config, fmt.Errorf("%s must be a positive duration", ttl.env)
+		}
+		*ttl.dst = value
+	}
+
+	config.LegacyOwnerEmail = os.Getenv("LEGACY_OWNER_EMAIL")
+
+	config.OIDC = oidc.Config{
+		IssuerURL:    os.Getenv("OIDC_ISSUER_URL"),
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		Audience:     os.Getenv("OIDC_AUDIENCE"),
+	}
+	if config.OIDC.IssuerURL != "" && (config.OIDC.ClientID == "" || config.OIDC.RedirectURL == "") {
+		return config, fmt.Errorf("OIDC_ISSUER_URL requires OIDC_CLIENT_ID and OIDC_REDIRECT_URL")
+	}
+
+	if v := os.Getenv("TENANCY"); v != "" {
+		tenancy, err := strconv.ParseBool(v)
+		if err != nil {
+			return config, fmt.Errorf("TENANCY must be a boolean: %w", err)
+		}
+		config.Tenancy = tenancy
+	}
+	config.TenantDomain = strings.TrimPrefix(os.Getenv("TENANT_DOMAIN"), ".")
+	config.AdminToken = os.Getenv("ADMIN_TOKEN")
+	if config.Tenancy && config.AdminToken == "" {
+		return config, fmt.Errorf("TENANCY requires ADMIN_TOKEN")
+	}
+	if config.Tenancy && config.LegacyOwnerEmail != "" {
+		return config, fmt.Errorf("LEGACY_OWNER_EMAIL is not supported with TENANCY")
+	}
+
+	switch config.Storage {
+	case "", StorageMongo:
+		config.Storage = StorageMongo
+		if config.DBConnectionString == "" {
+			return config, fmt.Errorf("DB_CONNECTION_STRING not set")
+		}
+		if config.DBName == "" {
+			return config, fmt.Errorf("DB_NAME not set")
+		}
+		if config.CollectionName == "" {
+			return config, fmt.Errorf("COLLECTION_NAME not set")
+		}
+
+		fmt.Println(config.DBConnectionString)
+	case StorageSQLite:
+		if config.Tenancy {
+			return config, fmt.Errorf("TENANCY is supported only with STORAGE=mongo or memory")
+		}
+		if config.SQLitePath == "" {
+			config.SQLitePath = "todolist.db"
+		}
+	case StorageMemory:
+		// в памяти настраивать нечего
+	default:
+		return config, fmt.Errorf("unknown STORAGE %q", config.Storage)
 	}
 
-	fmt.Println(config.DBConnectionString)
 	return config, nil
 }
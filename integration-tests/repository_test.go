@@ -5,118 +5,121 @@ package integration_tests
 import (
 	"context"
 	"fmt"
-	"github.com/nekidaz/todolist/internal/entity"
-	"github.com/nekidaz/todolist/internal/usecase/repo"
-	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/suite"
-	"log"
 	"os"
 	"testing"
 	"time"
+
+	"github.com/nekidaz/todolist/config"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/repo/repotest"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-type RepositorySuite struct {
-	suite.Suite
-	repository repo.TodoRepository
-	ctx        context.Context
+func mongoConfig() config.Config {
+	return config.Config{
+		Storage:            config.StorageMongo,
+		DBConnectionString: fmt.Sprintf("mongodb://%s:%s", os.Getenv("MONGO_HOST"), os.Getenv("MONGO_PORT")),
+		DBName:             os.Getenv("MONGO_NAME"),
+	}
 }
 
-func (s *RepositorySuite) SetupSuite() {
-	connectionString := fmt.Sprintf("mongodb://%s:%s", os.Getenv("MONGO_HOST"), os.Getenv("MONGO_PORT"))
-	repoName := os.Getenv("MONGO_NAME")
-	repoCollection := "test"
+// newMongoRepository создает репозиторий на отдельной коллекции, которая удаляется после теста
+func newMongoRepository(t *testing.T) repo.TodoRepository {
+	cfg := mongoConfig()
+	cfg.CollectionName = "test_" + primitive.NewObjectID().Hex()
 
-	repoInstance, err := repo.NewRepository(connectionString, repoName, repoCollection)
+	repository, err := repo.NewRepository(cfg)
 	if err != nil {
-		log.Fatalf("Ошибка подключения к базе данных: %s", err)
+		t.Fatalf("Ошибка подключения к базе данных: %s", err)
 	}
 
-	s.repository = repoInstance
-}
-
-func (s *RepositorySuite) TearDownSuite() {
-	// Очистка ресурсов и отключение от MongoDB
-	if s.repository != nil {
-		if err := s.repository.Close(); err != nil {
-			log.Fatalf("Ошибка при закрытии подключения к базе данных: %s", err)
+	t.Cleanup(func() {
+		dropCollection(t, cfg)
+		if err := repository.Close(); err != nil {
+			t.Errorf("Ошибка при закрытии подключения к базе данных: %s", err)
 		}
-	}
-}
+	})
 
-func (s *RepositorySuite) TestCreateAndRetrieveTodos() {
-	numTodos := 3
-	todos := make([]*entity.Todo, numTodos)
+	return repository
+}
 
-	for i := 0; i < numTodos; i++ {
-		newTodo := &entity.Todo{
-			Title:     fmt.Sprintf("Test Task %d", i+1),
-			ActiveAt:  time.Now(),
-			Completed: false,
-		}
+func dropCollection(t *testing.T, cfg config.Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-		createdTodo, err := s.repository.CreateNewTodo(s.ctx, newTodo)
-		assert.NoError(s.T(), err, "Ошибка создания задачи")
-		todos[i] = createdTodo
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.DBConnectionString))
+	if err != nil {
+		t.Errorf("Ошибка подключения к базе данных: %s", err)
+		return
 	}
-
-	for _, createdTodo := range todos {
-		retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
-		assert.NoError(s.T(), err, "Не удалось получить задачу из базы данных")
-
-		assert.Equal(s.T(), createdTodo.Title, retrievedTodo.Title)
-		assert.Equal(s.T(), createdTodo.ActiveAt.UTC().Format(time.RFC3339), retrievedTodo.ActiveAt.UTC().Format(time.RFC3339))
-		assert.Equal(s.T(), createdTodo.Completed, retrievedTodo.Completed)
+	defer client.Disconnect(ctx)
+
+	// рядом с коллекцией задач лежат коллекции списков, пользователей, ключей и участников, а у тестов организаций - их каталог
+	suffixes := []string{"", "_lists", "_users", "_api_keys", "_members", "_invitations", "_notifications", "_comments", "_tenants"}
+	for _, suffix := range suffixes {
+		name := cfg.CollectionName + suffix
+		if err := client.Database(cfg.DBName).Collection(name).Drop(ctx); err != nil {
+			t.Errorf("Не удалось удалить тестовую коллекцию %s: %s", name, err)
+		}
 	}
 }
 
-func (s *RepositorySuite) TestUpdateTodo() {
-	// Создание тестовой задачи
-	newTodo := &entity.Todo{
-		Title:     "Test Task",
-		ActiveAt:  time.Now(),
-		Completed: false,
-	}
-	createdTodo, err := s.repository.CreateNewTodo(s.ctx, newTodo)
-	assert.NoError(s.T(), err, "Ошибка создания задачи")
-
-	// Обновление задачи
-	updatedTodo := &entity.Todo{
-		ID:        createdTodo.ID,
-		Title:     "Updated Test Task",
-		ActiveAt:  time.Now().Add(24 * time.Hour),
-		Completed: true,
-	}
-	updatedTodo, err = s.repository.UpdateTodo(s.ctx, updatedTodo.ID, updatedTodo)
-	assert.NoError(s.T(), err, "Ошибка обновления задачи")
-
-	// Проверка обновленных данных
-	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
-	assert.NoError(s.T(), err, "Не удалось получить задачу из базы данных")
-
-	assert.Equal(s.T(), updatedTodo.Title, retrievedTodo.Title)
-	assert.Equal(s.T(), updatedTodo.ActiveAt.UTC().Format(time.RFC3339), retrievedTodo.ActiveAt.UTC().Format(time.RFC3339))
-	assert.Equal(s.T(), updatedTodo.Completed, retrievedTodo.Completed)
+func TestMongoRepositoryContract(t *testing.T) {
+	repotest.Run(t, newMongoRepository)
 }
 
-func (s *RepositorySuite) TestDeleteTodo() {
-	// Создание тестовой задачи
-	newTodo := &entity.Todo{
-		Title:     "Test Task",
-		ActiveAt:  time.Now(),
-		Completed: false,
-	}
-	createdTodo, err := s.repository.CreateNewTodo(s.ctx, newTodo)
-	assert.NoError(s.T(), err, "Ошибка создания задачи")
-
-	// Удаление задачи
-	err = s.repository.DeleteTodo(s.ctx, createdTodo.ID)
-	assert.NoError(s.T(), err, "Ошибка удаления задачи")
-
-	// Попытка получения удаленной задачи
-	_, err = s.repository.GetTaskByID(s.ctx, createdTodo.ID)
-	assert.Error(s.T(), err, "Ожидаемая ошибка при извлечении удаленной задачи")
-}
+func TestMongoTenantDatabases(t *testing.T) {
+	cfg := mongoConfig()
+	cfg.CollectionName = "test_" + primitive.NewObjectID().Hex()
 
-func TestRepositorySuite(t *testing.T) {
-	suite.Run(t, new(RepositorySuite))
+	backend, err := repo.NewTenantDatabases(cfg)
+	if err != nil {
+		t.Fatalf("Ошибка подключения к базе данных: %s", err)
+	}
+	repository := repo.NewTenantRepository(backend)
+	tenantID := "test-" + primitive.NewObjectID().Hex()[:16]
+	t.Cleanup(func() {
+		// удаление организации удаляет и ее базу, остается каталог в общей базе
+		_ = repository.DeleteTenant(context.Background(), tenantID)
+		dropCollection(t, cfg)
+		if err := repository.Close(); err != nil {
+			t.Errorf("Ошибка при закрытии подключения к базе данных: %s", err)
+		}
+	})
+
+	tenant, err := entity.NewTenant(tenantID, "Test", time.Now())
+	require.NoError(t, err)
+	_, err = repository.CreateTenant(context.Background(), tenant)
+	require.NoError(t, err)
+	_, err = repository.CreateTenant(context.Background(), tenant)
+	assert.ErrorIs(t, err, errors.ErrTenantExists)
+
+	ctx := entity.WithTenant(context.Background(), tenantID)
+	todo, err := repository.CreateNewTodo(ctx, entity.NewTodo("Отчет", time.Now()))
+	require.NoError(t, err)
+
+	// задача лежит в базе организации, а не в общей
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.DBConnectionString))
+	require.NoError(t, err)
+	defer client.Disconnect(ctx)
+	count, err := client.Database(repo.TenantDatabase(cfg.DBName, tenantID)).Collection(cfg.CollectionName).CountDocuments(ctx, bson.M{"_id": todo.ID})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+	count, err = client.Database(cfg.DBName).Collection(cfg.CollectionName).CountDocuments(ctx, bson.M{"_id": todo.ID})
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	require.NoError(t, repository.DeleteTenant(context.Background(), tenantID))
+	names, err := client.ListDatabaseNames(ctx, bson.M{"name": repo.TenantDatabase(cfg.DBName, tenantID)})
+	require.NoError(t, err)
+	assert.Empty(t, names)
+	_, err = repository.GetTaskByID(ctx, todo.ID)
+	assert.ErrorIs(t, err, errors.ErrTenantNotFound)
 }
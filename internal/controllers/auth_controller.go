@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// userKey - ключ gin.Context, под которым RequireAuth сохраняет пользователя запроса
+	userKey = "user"
+	// apiKeyKey - ключ gin.Context для ключа API, если запрос пришел с ним
+	apiKeyKey = "api_key"
+)
+
+// APIKeyHeader - заголовок с ключом API. Ключ можно передать и как Authorization: Bearer tdl_...
+const APIKeyHeader = "X-API-Key"
+
+type AuthController struct {
+	authService services.AuthService
+	// oidcService - вход через провайдера OpenID Connect, nil если он не настроен
+	oidcService services.OIDCService
+}
+
+func NewAuthController(authService services.AuthService, oidcService services.OIDCService) *AuthController {
+	return &AuthController{authService: authService, oidcService: oidcService}
+}
+
+// credentials - тело регистрации и входа
+type credentials struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func (c *AuthController) RegisterHandler(ctx *gin.Context) {
+	var requestBody credentials
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	user, err := c.authService.Register(ctx, requestBody.Email, requestBody.Password)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, user)
+}
+
+// LoginHandler возвращает пару токенов: access для заголовка Authorization и refresh для POST /auth/refresh
+func (c *AuthController) LoginHandler(ctx *gin.Context) {
+	var requestBody credentials
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	tokens, err := c.authService.Login(ctx, requestBody.Email, requestBody.Password)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+func (c *AuthController) RefreshHandler(ctx *gin.Context) {
+	var requestBody struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	tokens, err := c.authService.Refresh(ctx, requestBody.RefreshToken)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+// MeHandler - пользователь, от имени которого идет запрос. Работает за RequireAuth
+func (c *AuthController) MeHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, currentUser(ctx))
+}
+
+// UpdateMeHandler задает текущему пользователю username, по которому его упоминают в задачах как @username
+func (c *AuthController) UpdateMeHandler(ctx *gin.Context) {
+	var requestBody struct {
+		Username string `json:"username" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	user, err := c.authService.SetUsername(ctx, requestBody.Username)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, user)
+}
+
+// RequireAuth - middleware защищенных маршрутов: проверяет access-токен или ключ API
+// и кладет их владельца в контекст запроса, по нему хранилище отбирает задачи и списки.
+// Ключу API нужно право на метод запроса, см. requiredScope.
+// gin.Engine должен быть с ContextWithFallback, иначе сервисы не увидят владельца
+func (c *AuthController) RequireAuth(ctx *gin.Context) {
+	user, apiKey, err := c.authenticate(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	if apiKey != nil && !apiKey.Allows(requiredScope(ctx.Request.Method)) {
+		respondError(ctx, errors2.ErrInsufficientScope)
+		return
+	}
+
+	ctx.Request = ctx.Request.WithContext(entity.WithOwner(ctx.Request.Context(), user.ID))
+	// без ContextWithFallback запрос ушел бы в хранилище без владельца и увидел бы чужие данные
+	if owner := entity.OwnerFromContext(ctx); owner == nil || *owner != user.ID {
+		respondError(ctx, errors2.ErrInternal)
+		return
+	}
+
+	ctx.Set(userKey, user)
+	if apiKey != nil {
+		ctx.Set(apiKeyKey, apiKey)
+	}
+	ctx.Next()
+}
+
+// authenticate находит пользователя запроса: по ключу API из X-API-Key или Authorization: Bearer tdl_...,
+// иначе по access-токену из Authorization: Bearer
+func (c *AuthController) authenticate(ctx *gin.Context) (*entity.User, *entity.APIKey, error) {
+	if key := ctx.GetHeader(APIKeyHeader); key != "" {
+		return c.authService.AuthenticateAPIKey(ctx, key)
+	}
+
+	scheme, credential, found := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	credential = strings.TrimSpace(credential)
+	if !found || !strings.EqualFold(scheme, "Bearer") || credential == "" {
+		return nil, nil, errors2.ErrUnauthorized
+	}
+	if token.IsAPIKey(credential) {
+		return c.authService.AuthenticateAPIKey(ctx, credential)
+	}
+
+	user, err := c.authService.Authenticate(ctx, credential)
+	// не свой токен может быть токеном провайдера OIDC
+	if err != nil && c.oidcService != nil && stderrors.Is(err, errors2.ErrUnauthorized) {
+		user, err = c.oidcService.Authenticate(ctx, credential)
+	}
+	return user, nil, err
+}
+
+// requiredScope - право ключа API, нужное для запроса с методом method
+func requiredScope(method string) entity.Scope {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return entity.ScopeTasksRead
+	case http.MethodDelete:
+		return entity.ScopeTasksDelete
+	default:
+		return entity.ScopeTasksWrite
+	}
+}
+
+// DenyAPIKeys - middleware после RequireAuth для маршрутов, доступных только по паролю.
+// Иначе ключ с узкими правами мог бы выпустить себе ключ с широкими
+func (c *AuthController) DenyAPIKeys(ctx *gin.Context) {
+	if ctx.Value(apiKeyKey) != nil {
+		respondError(ctx, errors2.ErrInsufficientScope)
+		return
+	}
+	ctx.Next()
+}
+
+// createdAPIKey - ответ на создание ключа: единственный раз, когда ключ виден целиком
+type createdAPIKey struct {
+	*entity.APIKey
+	Key string `json:"key"`
+}
+
+func (c *AuthController) CreateAPIKeyHandler(ctx *gin.Context) {
+	var requestBody struct {
+		Name   string   `json:"name" binding:"required"`
+		Scopes []string `json:"scopes" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	key, secret, err := c.authService.CreateAPIKey(ctx, requestBody.Name, requestBody.Scopes)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, createdAPIKey{APIKey: key, Key: secret})
+}
+
+func (c *AuthController) GetAPIKeysHandler(ctx *gin.Context) {
+	keys, err := c.authService.GetAPIKeys(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKeyHandler удаляет ключ :keyID, запросы с ним сразу перестают проходить
+func (c *AuthController) RevokeAPIKeyHandler(ctx *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("keyID"))
+	if err != nil {
+		respondError(ctx, errors2.ErrInvalidID)
+		return
+	}
+
+	if err := c.authService.RevokeAPIKey(ctx, id); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// currentUser - пользователь, сохраненный RequireAuth
+func currentUser(ctx *gin.Context) *entity.User {
+	user, _ := ctx.Value(userKey).(*entity.User)
+	return user
+}
@@ -0,0 +1,236 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAuthTestRouter - API из RegisterRoutes, как в main, поверх хранилища в памяти
+func newAuthTestRouter() *gin.Engine {
+	repository := repo.NewMemoryRepository()
+	todoController := NewTodoController(services.NewTodoService(repository, services.SubtaskPolicies{}, entity.DefaultRankWeights()), false)
+	authController := NewAuthController(services.NewAuthService(repository, token.NewIssuer([]byte("secret"), time.Minute, time.Hour)), nil)
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	RegisterRoutes(&r.RouterGroup, todoController, authController)
+	return r
+}
+
+func doAuthRequest(r *gin.Engine, method, path, accessToken, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", gin.MIMEJSON)
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// login регистрирует пользователя и возвращает его пару токенов
+func login(t *testing.T, r *gin.Engine, email string) token.Pair {
+	t.Helper()
+	body := `{"email": "` + email + `", "password": "correct horse"}`
+
+	w := doAuthRequest(r, http.MethodPost, "/auth/register", "", body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "password")
+
+	w = doAuthRequest(r, http.MethodPost, "/auth/login", "", body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var pair token.Pair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+	return pair
+}
+
+func TestRequireAuth(t *testing.T) {
+	r := newAuthTestRouter()
+
+	for _, header := range []string{"", "Bearer", "Basic dXNlcjpwYXNz", "Bearer not-a-token"} {
+		req := httptest.NewRequest(http.MethodGet, "/tasks/all", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code, header)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+	}
+
+	pair := login(t, r, "alice@example.com")
+
+	w := doAuthRequest(r, http.MethodGet, "/auth/me", pair.AccessToken, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "alice@example.com")
+
+	// refresh-токен не пускает к API
+	w = doAuthRequest(r, http.MethodGet, "/tasks/all", pair.RefreshToken, "")
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodPost, "/auth/refresh", "", `{"refresh_token": "`+pair.RefreshToken+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var refreshed token.Pair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &refreshed))
+	w = doAuthRequest(r, http.MethodGet, "/tasks/all", refreshed.AccessToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestAuthErrors(t *testing.T) {
+	r := newAuthTestRouter()
+	login(t, r, "alice@example.com")
+
+	w := doAuthRequest(r, http.MethodPost, "/auth/register", "", `{"email": "ALICE@example.com", "password": "correct horse"}`)
+	assert.Equal(t, errors2.CodeUserDuplicate, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPost, "/auth/register", "", `{"email": "bob", "password": "correct horse"}`)
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodPost, "/auth/login", "", `{"email": "alice@example.com", "password": "wrong password"}`)
+	assert.Equal(t, errors2.CodeInvalidCredentials, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPost, "/auth/login", "", `{"email": "bob@example.com", "password": "correct horse"}`)
+	assert.Equal(t, errors2.CodeInvalidCredentials, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodPost, "/auth/refresh", "", `{"refresh_token": "nope"}`)
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+}
+
+func TestUsersSeeOnlyOwnTasks(t *testing.T) {
+	r := newAuthTestRouter()
+	alice := login(t, r, "alice@example.com").AccessToken
+	bob := login(t, r, "bob@example.com").AccessToken
+
+	w := doAuthRequest(r, http.MethodPost, "/tasks", alice, createBody("Отчет"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	// та же задача у другого пользователя не дубликат
+	w = doAuthRequest(r, http.MethodPost, "/tasks", bob, createBody("Отчет"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	tasks := func(accessToken string) []*entity.Todo {
+		w := doAuthRequest(r, http.MethodGet, "/tasks/all", accessToken, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page entity.TodoPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page.Tasks
+	}
+	aliceTasks := tasks(alice)
+	require.Len(t, aliceTasks, 1)
+	require.Len(t, tasks(bob), 1)
+
+	taskID := aliceTasks[0].ID.Hex()
+	w = doAuthRequest(r, http.MethodGet, "/tasks/"+taskID, bob, "")
+	assert.Equal(t, errors2.CodeTaskNotFound, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodDelete, "/tasks/"+taskID, bob, "")
+	assert.Equal(t, errors2.CodeTaskNotFound, decodeProblem(t, w).Code)
+	assert.Len(t, tasks(alice), 1)
+
+	w = doAuthRequest(r, http.MethodPost, "/lists", alice, `{"name": "Работа"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var list entity.List
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	w = doAuthRequest(r, http.MethodGet, "/lists/"+list.ID.Hex()+"/tasks/all", bob, "")
+	assert.Equal(t, errors2.CodeListNotFound, decodeProblem(t, w).Code)
+}
+
+// createAPIKey выпускает ключ с правами scopes и возвращает его целиком
+func createAPIKey(t *testing.T, r *gin.Engine, accessToken, scopes string) (id, key string) {
+	t.Helper()
+	w := doAuthRequest(r, http.MethodPost, "/auth/keys", accessToken, `{"name": "CI", "scopes": `+scopes+`}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		ID   string `json:"id"`
+		Key  string `json:"key"`
+		Hash string `json:"hash"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Empty(t, created.Hash)
+	return created.ID, created.Key
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	r := newAuthTestRouter()
+	alice := login(t, r, "alice@example.com").AccessToken
+	_, reader := createAPIKey(t, r, alice, `["tasks:read"]`)
+	_, writer := createAPIKey(t, r, alice, `["tasks:read", "tasks:write"]`)
+
+	w := doAuthRequest(r, http.MethodPost, "/tasks", writer, createBody("Отчет"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// ключ видит задачи своего владельца
+	req := httptest.NewRequest(http.MethodGet, "/tasks/all", nil)
+	req.Header.Set(APIKeyHeader, reader)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page entity.TodoPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Tasks, 1)
+	todo := page.Tasks[0]
+	w = doAuthRequest(r, http.MethodGet, "/tasks/"+todo.ID.Hex(), reader, "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doAuthRequest(r, http.MethodPost, "/tasks", reader, createBody("Еще отчет"))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, errors2.CodeInsufficientScope, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodDelete, "/tasks/"+todo.ID.Hex(), writer, "")
+	assert.Equal(t, errors2.CodeInsufficientScope, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodGet, "/tasks/all", "tdl_unknown", "")
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+}
+
+func TestAPIKeyManagement(t *testing.T) {
+	r := newAuthTestRouter()
+	alice := login(t, r, "alice@example.com").AccessToken
+	bob := login(t, r, "bob@example.com").AccessToken
+	id, key := createAPIKey(t, r, alice, `["tasks:read", "tasks:write", "tasks:delete"]`)
+
+	w := doAuthRequest(r, http.MethodPost, "/auth/keys", alice, `{"name": "CI", "scopes": ["admin"]}`)
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+
+	// ключ не управляет ключами, даже со всеми правами
+	w = doAuthRequest(r, http.MethodGet, "/auth/keys", key, "")
+	assert.Equal(t, errors2.CodeInsufficientScope, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPost, "/auth/keys", key, `{"name": "CI", "scopes": ["tasks:read"]}`)
+	assert.Equal(t, errors2.CodeInsufficientScope, decodeProblem(t, w).Code)
+	// как и профилем, участниками и приглашениями
+	w = doAuthRequest(r, http.MethodPatch, "/auth/me", key, `{"username": "alice"}`)
+	assert.Equal(t, errors2.CodeInsufficientScope, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPost, "/invitations/accept", key, `{"token": "x"}`)
+	assert.Equal(t, errors2.CodeInsufficientScope, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodGet, "/auth/me", key, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodGet, "/auth/keys", alice, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var keys []*entity.APIKey
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &keys))
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+	assert.NotContains(t, w.Body.String(), key)
+
+	w = doAuthRequest(r, http.MethodDelete, "/auth/keys/"+id, bob, "")
+	assert.Equal(t, errors2.CodeAPIKeyNotFound, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodDelete, "/auth/keys/nope", alice, "")
+	assert.Equal(t, errors2.CodeInvalidID, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodDelete, "/auth/keys/"+id, alice, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doAuthRequest(r, http.MethodGet, "/auth/me", key, "")
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetChecklistItemHandler(t *testing.T) {
+	r, todoService, owner := newTestRouter(t)
+
+	created, err := todoService.CreateNewTodo(owner, entity.TodoFields{
+		Title:       "Переезд",
+		Description: "- [ ] упаковать книги\n- [ ] заказать машину",
+		ActiveAt:    time.Now(),
+	})
+	require.NoError(t, err)
+	path := "/tasks/" + created.ID.Hex() + "/checklist/"
+
+	w := doRequest(r, http.MethodPatch, path+"1", gin.MIMEJSON, `{"done": true}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Description string            `json:"description"`
+		Checklist   *entity.Checklist `json:"checklist"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "- [ ] упаковать книги\n- [x] заказать машину", body.Description)
+	assert.Equal(t, "1/2", body.Checklist.Progress)
+
+	w = doRequest(r, http.MethodPatch, path+"2", gin.MIMEJSON, `{"done": true}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, errors2.CodeChecklistNotFound, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodPatch, path+"0", gin.MIMEJSON, `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestGetTaskRendersDescription(t *testing.T) {
+	r, todoService, owner := newTestRouter(t)
+
+	created, err := todoService.CreateNewTodo(owner, entity.TodoFields{
+		Title:       "Переезд",
+		Description: "**важно** <script>alert(1)</script>",
+		ActiveAt:    time.Now(),
+	})
+	require.NoError(t, err)
+
+	var body map[string]interface{}
+	w := doRequest(r, http.MethodGet, "/tasks/"+created.ID.Hex(), "", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotContains(t, body, "description_html")
+
+	w = doRequest(r, http.MethodGet, "/tasks/"+created.ID.Hex()+"?render=html", "", "")
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Contains(t, body["description_html"], "<strong>важно</strong>")
+	assert.NotContains(t, body["description_html"], "<script")
+}
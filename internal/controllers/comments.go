@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultCommentsLimit = 50
+	maxCommentsLimit     = 200
+)
+
+// GetCommentsHandler - страница комментариев задачи :ID по порядку написания. ?cursor= - next_cursor предыдущей страницы
+func (c *TodoController) GetCommentsHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	limit, err := parseLimit(ctx, defaultCommentsLimit, maxCommentsLimit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	page, err := c.todoService.GetComments(ctx, task.ID, ctx.Query("cursor"), limit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
+}
+
+// CreateCommentHandler оставляет комментарий под задачей :ID от имени текущего пользователя
+func (c *TodoController) CreateCommentHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	body, err := bindCommentBody(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	comment, err := c.todoService.CreateComment(ctx, task.ID, body)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, comment)
+}
+
+// UpdateCommentHandler меняет текст своего комментария :commentID под задачей :ID
+func (c *TodoController) UpdateCommentHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+	commentID, err := primitive.ObjectIDFromHex(ctx.Param("commentID"))
+	if err != nil {
+		respondError(ctx, errors2.ErrInvalidID)
+		return
+	}
+
+	body, err := bindCommentBody(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	comment, err := c.todoService.UpdateComment(ctx, task.ID, commentID, body)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, comment)
+}
+
+// DeleteCommentHandler удаляет свой комментарий :commentID под задачей :ID
+func (c *TodoController) DeleteCommentHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+	commentID, err := primitive.ObjectIDFromHex(ctx.Param("commentID"))
+	if err != nil {
+		respondError(ctx, errors2.ErrInvalidID)
+		return
+	}
+
+	if err := c.todoService.DeleteComment(ctx, task.ID, commentID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func bindCommentBody(ctx *gin.Context) (string, error) {
+	var requestBody struct {
+		Body string `json:"body" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		return "", bindingError(err)
+	}
+	return requestBody.Body, nil
+}
@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComments(t *testing.T) {
+	r := newAuthTestRouter()
+	alice := login(t, r, "alice@example.com").AccessToken
+	bob := login(t, r, "bob@example.com").AccessToken
+
+	w := doAuthRequest(r, http.MethodPost, "/lists", alice, `{"name": "Семья"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var list entity.List
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	tasksPath := "/lists/" + list.ID.Hex() + "/tasks"
+
+	w = doAuthRequest(r, http.MethodPost, "/lists/"+list.ID.Hex()+"/invitations", alice, `{"role": "editor"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var invitation struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invitation))
+	w = doAuthRequest(r, http.MethodPost, "/invitations/accept", bob, `{"token": "`+invitation.Token+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doAuthRequest(r, http.MethodPost, tasksPath, alice, createBody("Молоко"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodGet, tasksPath+"/all", alice, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var tasks entity.TodoPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tasks))
+	require.Len(t, tasks.Tasks, 1)
+	taskPath := tasksPath + "/" + tasks.Tasks[0].ID.Hex()
+	commentsPath := taskPath + "/comments"
+
+	w = doAuthRequest(r, http.MethodPost, commentsPath, alice, `{}`)
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+	for _, body := range []string{"Купи 2л", "Какой жирности?"} {
+		w = doAuthRequest(r, http.MethodPost, commentsPath, alice, `{"body": "`+body+`"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+	w = doAuthRequest(r, http.MethodPost, commentsPath, bob, `{"body": "Купил"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var reply entity.Comment
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reply))
+
+	w = doAuthRequest(r, http.MethodGet, commentsPath+"?limit=2", bob, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page entity.CommentPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Comments, 2)
+	assert.Equal(t, "Купи 2л", page.Comments[0].Body)
+	require.NotEmpty(t, page.NextCursor)
+
+	w = doAuthRequest(r, http.MethodGet, commentsPath+"?limit=2&cursor="+page.NextCursor, bob, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	page = entity.CommentPage{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Comments, 1)
+	assert.Equal(t, reply.ID, page.Comments[0].ID)
+	assert.Empty(t, page.NextCursor)
+
+	w = doAuthRequest(r, http.MethodGet, commentsPath+"?limit=0", bob, "")
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+
+	// число комментариев приходит вместе с задачами
+	w = doAuthRequest(r, http.MethodGet, tasksPath+"/all", alice, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"comment_count":3`)
+
+	// свой комментарий автор меняет и удаляет, чужой - нет
+	replyPath := commentsPath + "/" + reply.ID.Hex()
+	w = doAuthRequest(r, http.MethodPatch, replyPath, alice, `{"body": "Не тот"}`)
+	assert.Equal(t, errors2.CodeForbidden, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPatch, replyPath, bob, `{"body": "Купил, 3,2%"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Купил, 3,2%")
+	w = doAuthRequest(r, http.MethodDelete, replyPath, alice, "")
+	assert.Equal(t, errors2.CodeForbidden, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodDelete, replyPath, bob, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodDelete, replyPath, bob, "")
+	assert.Equal(t, errors2.CodeCommentNotFound, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodDelete, commentsPath+"/not-an-id", bob, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// вместе с задачей пропадают и ее комментарии
+	w = doAuthRequest(r, http.MethodDelete, taskPath, alice, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodGet, commentsPath, alice, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDependenciesHandlers(t *testing.T) {
+	r, todoService, owner := newTestRouter(t)
+
+	boxes, err := todoService.CreateNewTodo(owner, entity.TodoFields{Title: "Купить коробки", ActiveAt: time.Now()})
+	require.NoError(t, err)
+
+	w := doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, fmt.Sprintf(
+		`{"title": "Упаковать вещи", "activeAt": %q, "depends_on": [%q]}`, time.Now().Format("2006-01-02"), boxes.ID.Hex()))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = doRequest(r, http.MethodGet, "/tasks/plan?ids="+boxes.ID.Hex(), "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	page, err := todoService.GetAllTasks(owner, entity.ListOptions{})
+	require.NoError(t, err)
+	var pack *entity.Todo
+	for _, todo := range page.Tasks {
+		if todo.ID != boxes.ID {
+			pack = todo
+		}
+	}
+	require.NotNil(t, pack)
+
+	w = doRequest(r, http.MethodGet, "/tasks/plan?ids="+pack.ID.Hex(), "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Tasks []struct {
+			ID                  string   `json:"id"`
+			HasOpenDependencies bool     `json:"has_open_dependencies"`
+			BlockedBy           []string `json:"blocked_by"`
+		} `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Tasks, 2)
+	assert.Equal(t, boxes.ID.Hex(), body.Tasks[0].ID)
+	assert.False(t, body.Tasks[0].HasOpenDependencies)
+	assert.True(t, body.Tasks[1].HasOpenDependencies)
+	assert.Equal(t, []string{boxes.ID.Hex()}, body.Tasks[1].BlockedBy)
+
+	w = doRequest(r, http.MethodPatch, "/tasks/"+boxes.ID.Hex(), entity.MergePatchContentType,
+		fmt.Sprintf(`{"depends_on": [%q]}`, pack.ID.Hex()))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, errors2.CodeDependencyCycle, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodPatch, "/tasks/"+boxes.ID.Hex(), entity.MergePatchContentType,
+		fmt.Sprintf(`{"depends_on": [%q]}`, primitive.NewObjectID().Hex()))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, errors2.CodeDependencyNotFound, decodeProblem(t, w).Code)
+
+	for _, query := range []string{"", "?ids=", "?ids=nope"} {
+		w = doRequest(r, http.MethodGet, "/tasks/plan"+query, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/i18n"
+)
+
+// ProblemContentType - тип ответа об ошибке по RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// Problem - тело ответа об ошибке (RFC 7807). Code - стабильный код из pkg/errors, клиенты ветвятся по нему, а не по тексту
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// httpStatuses - единственное место, где категория доменной ошибки превращается в HTTP статус
+var httpStatuses = map[errors2.Kind]int{
+	errors2.KindInternal:         http.StatusInternalServerError,
+	errors2.KindValidation:       http.StatusBadRequest,
+	errors2.KindNotFound:         http.StatusNotFound,
+	errors2.KindConflict:         http.StatusConflict,
+	errors2.KindUnsupportedMedia: http.StatusUnsupportedMediaType,
+	errors2.KindUnauthorized:     http.StatusUnauthorized,
+	errors2.KindForbidden:        http.StatusForbidden,
+}
+
+// respondError пишет ошибку в формате problem+json на языке запроса. Ошибки вне pkg/errors считаются внутренними:
+// их текст (например, от драйвера БД) клиенту не отдается, а попадает в лог через ctx.Error
+func respondError(ctx *gin.Context, err error) {
+	lang := requestLanguage(ctx)
+
+	domainErr, ok := errors2.From(err)
+	if !ok || domainErr.Kind == errors2.KindInternal {
+		_ = ctx.Error(err)
+		domainErr, err = errors2.ErrInternal, errors2.ErrInternal
+	}
+
+	status, ok := httpStatuses[domainErr.Kind]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+
+	// RFC 7235: ответ 401 говорит, какую схему аутентификации ждет сервер
+	if status == http.StatusUnauthorized {
+		ctx.Header("WWW-Authenticate", "Bearer")
+	}
+	ctx.Header("Content-Type", ProblemContentType)
+	ctx.AbortWithStatusJSON(status, Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   localizeError(lang, err, domainErr),
+		Instance: ctx.Request.URL.Path,
+		Code:     domainErr.Code,
+	})
+}
+
+// localizeError переводит текст доменной ошибки. Уточнение после двоеточия (имя поля, значение) оставляется как есть
+func localizeError(lang i18n.Language, err error, domainErr *errors2.Error) string {
+	var bindErr *bindingErr
+	if errors.As(err, &bindErr) {
+		return domainErr.Localize(lang) + ": " + localizeBinding(lang, bindErr.cause)
+	}
+
+	text := err.Error()
+	if !strings.HasPrefix(text, domainErr.Error()) {
+		return text
+	}
+	return domainErr.Localize(lang) + strings.TrimPrefix(text, domainErr.Error())
+}
+
+// bindingErr - ошибка разбора тела запроса. Для errors.Is это validation_failed, причина нужна для перевода
+type bindingErr struct {
+	cause error
+}
+
+func (e *bindingErr) Error() string {
+	return errors2.ErrValidation.Error() + ": " + e.cause.Error()
+}
+
+func (e *bindingErr) Unwrap() error {
+	return errors2.ErrValidation
+}
+
+// bindingError оборачивает ошибку разбора тела запроса в validation_failed
+func bindingError(err error) error {
+	return &bindingErr{cause: err}
+}
+
+// localizeBinding переводит ошибки валидатора gin и ошибки разбора JSON
+func localizeBinding(lang i18n.Language, err error) string {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		messages := make([]string, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			field := jsonFieldName(fieldErr.Field())
+			switch fieldErr.Tag() {
+			case "required":
+				messages = append(messages, i18n.Message(lang, "validation.required", field))
+			case "max":
+				messages = append(messages, i18n.Message(lang, "validation.max", field, fieldErr.Param()))
+			default:
+				messages = append(messages, i18n.Message(lang, "validation.field", field, fieldErr.Tag()))
+			}
+		}
+		return strings.Join(messages, "; ")
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return i18n.Message(lang, "validation.invalid_json")
+	}
+
+	return err.Error()
+}
+
+// jsonFieldName превращает имя поля структуры (ActiveAt) в имя из JSON (activeAt)
+func jsonFieldName(name string) string {
+	r, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(r)) + name[size:]
+}
@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestRouter - API из RegisterRoutes от имени одного зарегистрированного пользователя: запросы без
+// Authorization уходят с его access-токеном. owner - контекст этого пользователя для вызовов сервиса в обход API
+func newTestRouter(t *testing.T) (r *gin.Engine, todoService services.TodoService, owner context.Context) {
+	t.Helper()
+	return newLegacyTestRouter(t, false)
+}
+
+// newLegacyTestRouter - newTestRouter с режимом LEGACY_POSITIONAL_IDS
+func newLegacyTestRouter(t *testing.T, legacyPositionalIDs bool) (r *gin.Engine, todoService services.TodoService, owner context.Context) {
+	t.Helper()
+	repository := repo.NewMemoryRepository()
+	todoService = services.NewTodoService(repository, services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	authService := services.NewAuthService(repository, token.NewIssuer([]byte("secret"), time.Minute, time.Hour))
+
+	user, err := authService.Register(context.Background(), "owner@example.com", "correct horse")
+	require.NoError(t, err)
+	pair, err := authService.Login(context.Background(), "owner@example.com", "correct horse")
+	require.NoError(t, err)
+
+	r = gin.New()
+	r.ContextWithFallback = true
+	r.Use(func(ctx *gin.Context) {
+		if ctx.GetHeader("Authorization") == "" {
+			ctx.Request.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		}
+	})
+	RegisterRoutes(&r.RouterGroup, NewTodoController(todoService, legacyPositionalIDs), NewAuthController(authService, nil))
+	return r, todoService, entity.WithOwner(context.Background(), user.ID)
+}
+
+func doRequest(r *gin.Engine, method, path, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+	var problem Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, w.Code, problem.Status)
+	return problem
+}
+
+func createBody(title string) string {
+	return fmt.Sprintf(`{"title": %q, "activeAt": %q}`, title, time.Now().Format("2006-01-02"))
+}
+
+func TestCreateDuplicateIsConflict(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+
+	w := doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, createBody("Купить книгу"))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	w = doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, createBody("Купить книгу"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	problem := decodeProblem(t, w)
+	assert.Equal(t, errors2.CodeTaskDuplicate, problem.Code)
+	assert.Equal(t, "/tasks", problem.Instance)
+}
+
+func TestErrorCodes(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		status      int
+		code        string
+	}{
+		{"invalid id", http.MethodGet, "/tasks/abc", "", "", http.StatusBadRequest, errors2.CodeInvalidID},
+		{"not found", http.MethodGet, "/tasks/000000000000000000000000", "", "", http.StatusNotFound, errors2.CodeTaskNotFound},
+		{"binding", http.MethodPost, "/tasks", gin.MIMEJSON, `{"title": ""}`, http.StatusBadRequest, errors2.CodeValidationFailed},
+		{"bad date", http.MethodPost, "/tasks", gin.MIMEJSON, `{"title": "a", "activeAt": "завтра"}`, http.StatusBadRequest, errors2.CodeValidationFailed},
+		{"past date", http.MethodPost, "/tasks", gin.MIMEJSON, `{"title": "a", "activeAt": "2000-01-01"}`, http.StatusBadRequest, errors2.CodeValidationFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(r, tt.method, tt.path, tt.contentType, tt.body)
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.code, decodeProblem(t, w).Code)
+		})
+	}
+}
+
+func TestUpdateAndPatchErrors(t *testing.T) {
+	r, todoService, owner := newTestRouter(t)
+
+	require.Equal(t, http.StatusCreated, doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, createBody("Первая")).Code)
+	created, err := todoService.CreateNewTodo(owner, entity.TodoFields{Title: "Вторая", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	id := created.ID.Hex()
+
+	w := doRequest(r, http.MethodPut, "/tasks/"+id, gin.MIMEJSON, createBody("Первая"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, errors2.CodeTaskDuplicate, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodPatch, "/tasks/"+id, "text/plain", `{"title": "x"}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, errors2.CodeUnsupportedMediaType, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodPatch, "/tasks/"+id, entity.MergePatchContentType, `{"status": "done", "id": "x"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+}
+
+func TestInternalErrorHidesDetails(t *testing.T) {
+	r := gin.New()
+	r.GET("/boom", func(ctx *gin.Context) {
+		respondError(ctx, stderrors.New("connection refused: mongodb://secret@db"))
+	})
+
+	w := doRequest(r, http.MethodGet, "/boom", "", "")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	problem := decodeProblem(t, w)
+	assert.Equal(t, errors2.CodeInternal, problem.Code)
+	assert.NotContains(t, w.Body.String(), "secret")
+}
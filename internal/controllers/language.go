@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/pkg/i18n"
+)
+
+// languageCookie - cookie, в которой клиент хранит выбранный пользователем язык
+const languageCookie = "lang"
+
+// requestLanguage выбирает язык ответа: явный ?lang=, затем выбор пользователя из cookie lang, затем
+// Accept-Language. Выбранный язык отдается в Content-Language
+func requestLanguage(ctx *gin.Context) i18n.Language {
+	lang := resolveLanguage(ctx)
+	ctx.Header("Content-Language", string(lang))
+	return lang
+}
+
+func resolveLanguage(ctx *gin.Context) i18n.Language {
+	if lang, ok := i18n.Parse(ctx.Query("lang")); ok {
+		return lang
+	}
+	if value, err := ctx.Cookie(languageCookie); err == nil {
+		if lang, ok := i18n.Parse(value); ok {
+			return lang
+		}
+	}
+	return i18n.Negotiate(ctx.GetHeader("Accept-Language"))
+}
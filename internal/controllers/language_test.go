@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func doLocalizedRequest(r *gin.Engine, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", gin.MIMEJSON)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestErrorsFollowAcceptLanguage(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+
+	w := doLocalizedRequest(r, http.MethodGet, "/tasks/000000000000000000000000", "", http.Header{"Accept-Language": {"en-US,en;q=0.9"}})
+	problem := decodeProblem(t, w)
+	assert.Equal(t, "Task not found", problem.Detail)
+	assert.Equal(t, errors2.CodeTaskNotFound, problem.Code)
+	assert.Equal(t, "en", w.Header().Get("Content-Language"))
+
+	w = doLocalizedRequest(r, http.MethodGet, "/tasks/000000000000000000000000", "", nil)
+	problem = decodeProblem(t, w)
+	assert.Equal(t, "Задача не найдена", problem.Detail)
+	assert.Equal(t, errors2.CodeTaskNotFound, problem.Code)
+}
+
+func TestLanguagePreferenceOrder(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+	path := "/tasks/000000000000000000000000"
+
+	// cookie с выбором пользователя важнее Accept-Language
+	w := doLocalizedRequest(r, http.MethodGet, path, "", http.Header{
+		"Accept-Language": {"ru"},
+		"Cookie":          {"lang=en"},
+	})
+	assert.Equal(t, "Task not found", decodeProblem(t, w).Detail)
+
+	// явный ?lang= важнее всего
+	w = doLocalizedRequest(r, http.MethodGet, path+"?lang=ru", "", http.Header{"Cookie": {"lang=en"}})
+	assert.Equal(t, "Задача не найдена", decodeProblem(t, w).Detail)
+}
+
+func TestValidationErrorsAreLocalized(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+	english := http.Header{"Accept-Language": {"en"}}
+
+	w := doLocalizedRequest(r, http.MethodPost, "/tasks", `{"title": "a"}`, english)
+	problem := decodeProblem(t, w)
+	assert.Equal(t, errors2.CodeValidationFailed, problem.Code)
+	assert.Equal(t, "Invalid request data: Field activeAt is required", problem.Detail)
+
+	w = doLocalizedRequest(r, http.MethodPost, "/tasks", `{"title": `, english)
+	assert.Equal(t, "Invalid request data: Request body is not valid JSON", decodeProblem(t, w).Detail)
+
+	w = doLocalizedRequest(r, http.MethodGet, "/tasks/abc", "", english)
+	assert.Equal(t, "Invalid ID", decodeProblem(t, w).Detail)
+}
+
+func TestSuccessMessageIsLocalized(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+
+	w := doLocalizedRequest(r, http.MethodPost, "/tasks", createBody("Read"), http.Header{"Accept-Language": {"en"}})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "Task with title: Read created successfully", body.Message)
+}
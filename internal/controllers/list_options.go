@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+)
+
+// maxPageLimit - наибольший limit страницы списка задач
+const maxPageLimit = 200
+
+// parseListOptions читает из query limit, cursor, sort, диапазоны active_from/active_to, created_from/created_to,
+// метки tag, tag_any и tag_all, а также include_blocked
+func parseListOptions(ctx *gin.Context) (entity.ListOptions, error) {
+	opts := entity.ListOptions{Cursor: ctx.Query("cursor")}
+
+	// без limit список отдается целиком, как до появления страниц: старые клиенты не знают про next_cursor
+	var err error
+	if opts.Limit, err = parseLimit(ctx, 0, maxPageLimit); err != nil {
+		return opts, err
+	}
+
+	if sort := ctx.Query("sort"); sort != "" {
+		field, descending, err := entity.ParseSort(sort)
+		if err != nil {
+			return opts, err
+		}
+		opts.Sort, opts.Descending = field, descending
+	}
+
+	if err := parseTaskFilters(ctx, &opts); err != nil {
+		return opts, err
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"active_from", &opts.ActiveFrom},
+		{"active_to", &opts.ActiveTo},
+		{"created_from", &opts.CreatedFrom},
+		{"created_to", &opts.CreatedTo},
+	} {
+		if *param.dst, err = parseTimeQuery(ctx, param.name); err != nil {
+			return opts, err
+		}
+	}
+
+	return opts, nil
+}
+
+// parseLimit читает limit от 1 до maxLimit, без параметра - defaultLimit
+func parseLimit(ctx *gin.Context, defaultLimit, maxLimit int) (int, error) {
+	limit := ctx.Query("limit")
+	if limit == "" {
+		return defaultLimit, nil
+	}
+
+	value, err := strconv.Atoi(limit)
+	if err != nil || value < 1 || value > maxLimit {
+		return 0, fmt.Errorf("%w: 1..%d", errors2.ErrInvalidLimit, maxLimit)
+	}
+	return value, nil
+}
+
+// parseTaskFilters читает фильтры, общие для списков и /tasks/next: метки tag, tag_any и tag_all и include_blocked.
+// Список задач берется из маршрута
+func parseTaskFilters(ctx *gin.Context, opts *entity.ListOptions) error {
+	opts.ListID = listScope(ctx)
+	if includeBlocked := ctx.Query("include_blocked"); includeBlocked != "" {
+		value, err := strconv.ParseBool(includeBlocked)
+		if err != nil {
+			return fmt.Errorf("%w: include_blocked", errors2.ErrInvalidFieldValue)
+		}
+		opts.IncludeBlocked = value
+	}
+
+	// tag - одна метка, которая обязательна, как в tag_all
+	var err error
+	if opts.TagsAny, err = entity.NormalizeTags(queryList(ctx, "tag_any")); err != nil {
+		return err
+	}
+	opts.TagsAll, err = entity.NormalizeTags(append(queryList(ctx, "tag_all"), queryList(ctx, "tag")...))
+	return err
+}
+
+// queryList - непустые значения параметра name через запятую, пробелы по краям отбрасываются
+func queryList(ctx *gin.Context, name string) []string {
+	var values []string
+	for _, value := range strings.Split(ctx.Query(name), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// parseTimeQuery принимает RFC 3339 или дату 2006-01-02 (полночь UTC)
+func parseTimeQuery(ctx *gin.Context, name string) (*time.Time, error) {
+	value := ctx.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return &t, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", errors2.ErrInvalidDate, name)
+}
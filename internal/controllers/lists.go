@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// listScopeKey - ключ gin.Context, под которым ListScope сохраняет список запроса
+const listScopeKey = "list_id"
+
+// ListScope - middleware для маршрутов /lists/:listID/tasks: находит список и сохраняет его для обработчиков задач.
+// В чужом списке запрос дальше идет от имени его владельца с ролью участника. Задачи архивного списка можно только читать
+func (c *TodoController) ListScope(ctx *gin.Context) {
+	listID, err := entity.ParseListID(ctx.Param("listID"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	if listID != nil {
+		scoped, list, err := c.todoService.OpenList(ctx.Request.Context(), *listID)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		ctx.Request = ctx.Request.WithContext(scoped)
+		if list.IsArchived() && ctx.Request.Method != http.MethodGet {
+			respondError(ctx, errors2.ErrListArchived)
+			return
+		}
+	}
+
+	ctx.Set(listScopeKey, listID)
+	ctx.Next()
+}
+
+// listScope - список, в котором работает запрос. Старые маршруты /tasks работают со списком по умолчанию (nil)
+func listScope(ctx *gin.Context) *primitive.ObjectID {
+	listID, _ := ctx.Value(listScopeKey).(*primitive.ObjectID)
+	return listID
+}
+
+// GetListsHandler - списки по имени, с ?include_archived=true вместе с архивными
+func (c *TodoController) GetListsHandler(ctx *gin.Context) {
+	var includeArchived bool
+	if value := ctx.Query("include_archived"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondError(ctx, fmt.Errorf("%w: include_archived", errors2.ErrInvalidFieldValue))
+			return
+		}
+		includeArchived = parsed
+	}
+
+	lists, err := c.todoService.GetLists(ctx, includeArchived)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"lists": lists})
+}
+
+func (c *TodoController) CreateListHandler(ctx *gin.Context) {
+	var requestBody struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	list, err := c.todoService.CreateList(ctx, requestBody.Name)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, list)
+}
+
+// UpdateListHandler переименовывает список (name) и переносит его в архив или из архива (archived)
+func (c *TodoController) UpdateListHandler(ctx *gin.Context) {
+	id, errReturned := processListID(ctx)
+	if errReturned {
+		return
+	}
+
+	var requestBody struct {
+		Name     *string `json:"name"`
+		Archived *bool   `json:"archived"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	list, err := c.todoService.UpdateList(ctx, id, entity.ListUpdate{Name: requestBody.Name, Archived: requestBody.Archived})
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// DeleteListHandler удаляет пустой список, с ?force=true - вместе с задачами
+func (c *TodoController) DeleteListHandler(ctx *gin.Context) {
+	id, errReturned := processListID(ctx)
+	if errReturned {
+		return
+	}
+
+	var force bool
+	if value := ctx.Query("force"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondError(ctx, fmt.Errorf("%w: force", errors2.ErrInvalidFieldValue))
+			return
+		}
+		force = parsed
+	}
+
+	if err := c.todoService.DeleteList(ctx, id, force); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// MoveTodoHandler переносит задачу :ID вместе с подзадачами в список list_id, "default" - в список по умолчанию
+func (c *TodoController) MoveTodoHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	var requestBody struct {
+		ListID string `json:"list_id" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	listID, err := entity.ParseListID(requestBody.ListID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	todo, err := c.todoService.MoveTodo(ctx, task.ID, listID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, todo)
+}
+
+// processListID читает :listID списка, который можно изменить. Список по умолчанию не хранится, менять в нем нечего
+func processListID(ctx *gin.Context) (id primitive.ObjectID, errReturned bool) {
+	listID, err := entity.ParseListID(ctx.Param("listID"))
+	if err == nil && listID == nil {
+		err = errors2.ErrDefaultList
+	}
+	if err != nil {
+		respondError(ctx, err)
+		return id, true
+	}
+	return *listID, false
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListsHandlers(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+
+	w := doRequest(r, http.MethodPost, "/lists", gin.MIMEJSON, `{"name": "Работа"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var list entity.List
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	listPath := "/lists/" + list.ID.Hex()
+
+	w = doRequest(r, http.MethodPost, "/lists", gin.MIMEJSON, `{"name": "Работа"}`)
+	assert.Equal(t, errors2.CodeListDuplicate, decodeProblem(t, w).Code)
+
+	// одна и та же задача в списке и в списке по умолчанию
+	w = doRequest(r, http.MethodPost, listPath+"/tasks", gin.MIMEJSON, createBody("Отчет"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doRequest(r, http.MethodPost, "/lists/default/tasks", gin.MIMEJSON, createBody("Отчет"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doRequest(r, http.MethodPost, listPath+"/tasks", gin.MIMEJSON, createBody("Отчет"))
+	assert.Equal(t, errors2.CodeTaskDuplicate, decodeProblem(t, w).Code)
+
+	tasks := func(path string) []*entity.Todo {
+		w := doRequest(r, http.MethodGet, path, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page entity.TodoPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		return page.Tasks
+	}
+	inList := tasks(listPath + "/tasks/all")
+	require.Len(t, inList, 1)
+	require.Len(t, tasks("/tasks/all"), 1)
+
+	// задача списка не видна по старому маршруту и из другого списка
+	taskID := inList[0].ID.Hex()
+	w = doRequest(r, http.MethodGet, "/tasks/"+taskID, "", "")
+	assert.Equal(t, errors2.CodeTaskNotFound, decodeProblem(t, w).Code)
+	w = doRequest(r, http.MethodGet, listPath+"/tasks/"+taskID, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doRequest(r, http.MethodGet, "/lists/not-an-id/tasks/all", "", "")
+	assert.Equal(t, errors2.CodeInvalidID, decodeProblem(t, w).Code)
+	w = doRequest(r, http.MethodGet, "/lists/"+entity.DefaultListID+"x/tasks/all", "", "")
+	assert.Equal(t, errors2.CodeInvalidID, decodeProblem(t, w).Code)
+
+	// архивный список доступен только для чтения
+	w = doRequest(r, http.MethodPatch, listPath, gin.MIMEJSON, `{"name": "Старая работа", "archived": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"archived":true`)
+
+	w = doRequest(r, http.MethodPost, listPath+"/tasks", gin.MIMEJSON, createBody("Деплой"))
+	assert.Equal(t, errors2.CodeListArchived, decodeProblem(t, w).Code)
+	assert.Len(t, tasks(listPath+"/tasks/all"), 1)
+
+	w = doRequest(r, http.MethodGet, "/lists", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"lists": []}`, w.Body.String())
+	w = doRequest(r, http.MethodGet, "/lists?include_archived=true", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Старая работа")
+
+	w = doRequest(r, http.MethodPatch, listPath, gin.MIMEJSON, `{"archived": false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doRequest(r, http.MethodPatch, "/lists/default", gin.MIMEJSON, `{"name": "Входящие"}`)
+	assert.Equal(t, errors2.CodeDefaultList, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodDelete, listPath, "", "")
+	assert.Equal(t, errors2.CodeListNotEmpty, decodeProblem(t, w).Code)
+	w = doRequest(r, http.MethodDelete, listPath+"?force=true", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(r, http.MethodGet, listPath+"/tasks/all", "", "")
+	assert.Equal(t, errors2.CodeListNotFound, decodeProblem(t, w).Code)
+}
+
+func TestMoveTodoHandler(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+
+	w := doRequest(r, http.MethodPost, "/lists", gin.MIMEJSON, `{"name": "Работа"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var list entity.List
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+
+	w = doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, createBody("Отчет"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doRequest(r, http.MethodGet, "/tasks/all", "", "")
+	var page entity.TodoPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Tasks, 1)
+	taskID := page.Tasks[0].ID.Hex()
+
+	w = doRequest(r, http.MethodPost, "/tasks/"+taskID+"/move", gin.MIMEJSON, `{"list_id": "`+list.ID.Hex()+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var moved entity.Todo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &moved))
+	assert.True(t, moved.InList(&list.ID))
+
+	w = doRequest(r, http.MethodPost, "/tasks/"+taskID+"/move", gin.MIMEJSON, `{"list_id": "default"}`)
+	assert.Equal(t, errors2.CodeTaskNotFound, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodPost, "/lists/"+list.ID.Hex()+"/tasks/"+taskID+"/move", gin.MIMEJSON, `{"list_id": "nope"}`)
+	assert.Equal(t, errors2.CodeInvalidID, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodPost, "/lists/"+list.ID.Hex()+"/tasks/"+taskID+"/move", gin.MIMEJSON, `{"list_id": "default"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "list_id")
+
+	// list_id меняется только переносом
+	w = doRequest(r, http.MethodPatch, "/tasks/"+taskID, entity.MergePatchContentType, `{"list_id": "`+list.ID.Hex()+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
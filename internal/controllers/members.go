@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetMembersHandler - владелец и участники списка :listID
+func (c *TodoController) GetMembersHandler(ctx *gin.Context) {
+	listID, errReturned := processListID(ctx)
+	if errReturned {
+		return
+	}
+
+	members, err := c.todoService.GetMembers(ctx, listID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// UpdateMemberHandler меняет роль участника :userID на editor или viewer
+func (c *TodoController) UpdateMemberHandler(ctx *gin.Context) {
+	listID, userID, errReturned := processMemberID(ctx)
+	if errReturned {
+		return
+	}
+
+	var requestBody struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	member, err := c.todoService.SetMemberRole(ctx, listID, userID, requestBody.Role)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, member)
+}
+
+// RemoveMemberHandler исключает участника :userID из списка. Участник может так выйти из списка сам
+func (c *TodoController) RemoveMemberHandler(ctx *gin.Context) {
+	listID, userID, errReturned := processMemberID(ctx)
+	if errReturned {
+		return
+	}
+
+	if err := c.todoService.RemoveMember(ctx, listID, userID); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// createdInvitation - ответ на создание приглашения: единственный раз, когда токен виден
+type createdInvitation struct {
+	*entity.Invitation
+	Token string `json:"token"`
+}
+
+// CreateInvitationHandler создает приглашение в список :listID с ролью role
+func (c *TodoController) CreateInvitationHandler(ctx *gin.Context) {
+	listID, errReturned := processListID(ctx)
+	if errReturned {
+		return
+	}
+
+	var requestBody struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	invitation, secret, err := c.todoService.CreateInvitation(ctx, listID, requestBody.Role)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, createdInvitation{Invitation: invitation, Token: secret})
+}
+
+// AcceptInvitationHandler добавляет пользователя в список по токену приглашения и отдает этот список
+func (c *TodoController) AcceptInvitationHandler(ctx *gin.Context) {
+	var requestBody struct {
+		Token string `json:"token" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	list, err := c.todoService.AcceptInvitation(ctx, requestBody.Token)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+// processMemberID читает :listID и :userID участника
+func processMemberID(ctx *gin.Context) (listID, userID primitive.ObjectID, errReturned bool) {
+	listID, errReturned = processListID(ctx)
+	if errReturned {
+		return listID, userID, true
+	}
+
+	userID, err := primitive.ObjectIDFromHex(ctx.Param("userID"))
+	if err != nil {
+		respondError(ctx, errors2.ErrInvalidID)
+		return listID, userID, true
+	}
+	return listID, userID, false
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// userID - id пользователя с токеном accessToken
+func userID(t *testing.T, r *gin.Engine, accessToken string) string {
+	t.Helper()
+	w := doAuthRequest(r, http.MethodGet, "/auth/me", accessToken, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var user entity.User
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &user))
+	return user.ID.Hex()
+}
+
+func TestSharedList(t *testing.T) {
+	r := newAuthTestRouter()
+	alice := login(t, r, "alice@example.com").AccessToken
+	bob := login(t, r, "bob@example.com").AccessToken
+	bobID := userID(t, r, bob)
+
+	w := doAuthRequest(r, http.MethodPost, "/lists", alice, `{"name": "Семья"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var list entity.List
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	tasksPath := "/lists/" + list.ID.Hex() + "/tasks"
+	membersPath := "/lists/" + list.ID.Hex() + "/members/"
+
+	w = doAuthRequest(r, http.MethodPost, tasksPath, alice, createBody("Купить молоко"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodGet, tasksPath+"/all", alice, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page entity.TodoPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Tasks, 1)
+	taskPath := tasksPath + "/" + page.Tasks[0].ID.Hex()
+
+	// без приглашения чужого списка не существует
+	w = doAuthRequest(r, http.MethodGet, tasksPath+"/all", bob, "")
+	assert.Equal(t, errors2.CodeListNotFound, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodPost, "/lists/"+list.ID.Hex()+"/invitations", alice, `{"role": "owner"}`)
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPost, "/lists/"+list.ID.Hex()+"/invitations", alice, `{"role": "viewer"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var invitation struct {
+		Token string      `json:"token"`
+		Role  entity.Role `json:"role"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invitation))
+	assert.Equal(t, entity.RoleViewer, invitation.Role)
+	assert.NotContains(t, w.Body.String(), "hash")
+
+	body := `{"token": "` + invitation.Token + `"}`
+	w = doAuthRequest(r, http.MethodPost, "/invitations/accept", bob, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"role":"viewer"`)
+	w = doAuthRequest(r, http.MethodPost, "/invitations/accept", bob, body)
+	assert.Equal(t, errors2.CodeInvitationNotFound, decodeProblem(t, w).Code)
+
+	// зритель читает задачи, но не меняет их
+	w = doAuthRequest(r, http.MethodGet, taskPath, bob, "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodPatch, taskPath+"/done", bob, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, errors2.CodeForbidden, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodDelete, taskPath, bob, "")
+	assert.Equal(t, errors2.CodeForbidden, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPost, tasksPath, bob, createBody("Хлеб"))
+	assert.Equal(t, errors2.CodeForbidden, decodeProblem(t, w).Code)
+
+	// роли и сам список меняет только владелец
+	w = doAuthRequest(r, http.MethodPatch, membersPath+bobID, bob, `{"role": "editor"}`)
+	assert.Equal(t, errors2.CodeForbidden, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPatch, "/lists/"+list.ID.Hex(), bob, `{"name": "Мое"}`)
+	assert.Equal(t, errors2.CodeForbidden, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodPatch, membersPath+bobID, alice, `{"role": "editor"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodPatch, taskPath+"/done", bob, "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doAuthRequest(r, http.MethodGet, "/lists/"+list.ID.Hex()+"/members", bob, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var members struct {
+		Members []*entity.Membership `json:"members"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &members))
+	require.Len(t, members.Members, 2)
+	assert.Equal(t, "alice@example.com", members.Members[0].Email)
+	assert.Equal(t, entity.RoleOwner, members.Members[0].Role)
+	assert.Equal(t, "bob@example.com", members.Members[1].Email)
+	assert.Equal(t, entity.RoleEditor, members.Members[1].Role)
+
+	w = doAuthRequest(r, http.MethodGet, "/lists", bob, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"name":"Семья"`)
+	assert.Contains(t, w.Body.String(), `"role":"editor"`)
+
+	// участник выходит из списка сам
+	w = doAuthRequest(r, http.MethodDelete, membersPath+bobID, bob, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodGet, tasksPath+"/all", bob, "")
+	assert.Equal(t, errors2.CodeListNotFound, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodDelete, membersPath+bobID, alice, "")
+	assert.Equal(t, errors2.CodeMemberNotFound, decodeProblem(t, w).Code)
+}
+
+func TestSharedListHidesOtherTasks(t *testing.T) {
+	r := newAuthTestRouter()
+	alice := login(t, r, "alice@example.com").AccessToken
+	bob := login(t, r, "bob@example.com").AccessToken
+
+	listIDs := make([]string, 0, 2)
+	for _, name := range []string{"Семья", "Работа"} {
+		w := doAuthRequest(r, http.MethodPost, "/lists", alice, `{"name": "`+name+`"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var list entity.List
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		listIDs = append(listIDs, list.ID.Hex())
+	}
+
+	w := doAuthRequest(r, http.MethodPost, "/lists/"+listIDs[1]+"/tasks", alice, createBody("Отчет"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodGet, "/lists/"+listIDs[1]+"/tasks/all", alice, "")
+	var page entity.TodoPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Tasks, 1)
+
+	w = doAuthRequest(r, http.MethodPost, "/lists/"+listIDs[0]+"/invitations", alice, `{"role": "editor"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var invitation struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invitation))
+	w = doAuthRequest(r, http.MethodPost, "/invitations/accept", bob, `{"token": "`+invitation.Token+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// задача из другого списка владельца через общий список не видна
+	w = doAuthRequest(r, http.MethodGet, "/lists/"+listIDs[0]+"/tasks/"+page.Tasks[0].ID.Hex(), bob, "")
+	assert.Equal(t, errors2.CodeTaskNotFound, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodGet, "/lists/"+listIDs[1]+"/tasks/all", bob, "")
+	assert.Equal(t, errors2.CodeListNotFound, decodeProblem(t, w).Code)
+
+	// приглашать может только владелец
+	w = doAuthRequest(r, http.MethodPost, "/lists/"+listIDs[0]+"/invitations", bob, `{"role": "editor"}`)
+	assert.Equal(t, errors2.CodeForbidden, decodeProblem(t, w).Code)
+}
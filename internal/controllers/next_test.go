@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNextTasksHandler(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+	today := time.Now().Format("2006-01-02")
+
+	for _, body := range []string{
+		`{"title": "Продукты", "activeAt": "` + today + `"}`,
+		`{"title": "Инцидент", "activeAt": "` + today + `", "priority": "urgent"}`,
+	} {
+		w := doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w := doRequest(r, http.MethodGet, "/tasks/next?limit=5", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Tasks []struct {
+			Task struct {
+				Title    string `json:"title"`
+				Priority string `json:"priority"`
+			} `json:"task"`
+			Score       float64 `json:"score"`
+			Explanation []struct {
+				Factor string  `json:"factor"`
+				Points float64 `json:"points"`
+			} `json:"explanation"`
+		} `json:"tasks"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Tasks, 2)
+	assert.Equal(t, "Инцидент", body.Tasks[0].Task.Title)
+	assert.Equal(t, "urgent", body.Tasks[0].Task.Priority)
+	assert.Equal(t, "normal", body.Tasks[1].Task.Priority)
+	assert.Greater(t, body.Tasks[0].Score, body.Tasks[1].Score)
+	require.Len(t, body.Tasks[0].Explanation, 3)
+	assert.Equal(t, "priority", body.Tasks[0].Explanation[0].Factor)
+
+	for _, query := range []string{"?limit=0", "?limit=101", "?tag=a%20b", "?include_blocked=maybe"} {
+		w = doRequest(r, http.MethodGet, "/tasks/next"+query, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	w = doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, `{"title": "Отчет", "activeAt": "`+today+`", "priority": "asap"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
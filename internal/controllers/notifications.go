@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultNotificationsLimit = 50
+	maxNotificationsLimit     = 200
+)
+
+// GetNotificationsHandler - входящие текущего пользователя, новые первыми. ?unread=true - только непрочитанные
+func (c *TodoController) GetNotificationsHandler(ctx *gin.Context) {
+	var unreadOnly bool
+	if value := ctx.Query("unread"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondError(ctx, fmt.Errorf("%w: unread", errors2.ErrInvalidFieldValue))
+			return
+		}
+		unreadOnly = parsed
+	}
+
+	limit, err := parseLimit(ctx, defaultNotificationsLimit, maxNotificationsLimit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	notifications, err := c.todoService.GetNotifications(ctx, unreadOnly, limit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"notifications": notifications})
+}
+
+// UpdateNotificationHandler отмечает уведомление :notificationID прочитанным или непрочитанным
+func (c *TodoController) UpdateNotificationHandler(ctx *gin.Context) {
+	id, err := primitive.ObjectIDFromHex(ctx.Param("notificationID"))
+	if err != nil {
+		respondError(ctx, errors2.ErrInvalidID)
+		return
+	}
+
+	var requestBody struct {
+		Read *bool `json:"read" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	notification, err := c.todoService.SetNotificationRead(ctx, id, *requestBody.Read)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, notification)
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifications(t *testing.T) {
+	r := newAuthTestRouter()
+	alice := login(t, r, "alice@example.com").AccessToken
+	bob := login(t, r, "bob@example.com").AccessToken
+	bobID := userID(t, r, bob)
+
+	w := doAuthRequest(r, http.MethodPatch, "/auth/me", bob, `{"username": "Bob"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"username":"bob"`)
+	w = doAuthRequest(r, http.MethodPatch, "/auth/me", alice, `{"username": "bob"}`)
+	assert.Equal(t, errors2.CodeUsernameDuplicate, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodPost, "/lists", alice, `{"name": "Семья"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var list entity.List
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	tasksPath := "/lists/" + list.ID.Hex() + "/tasks"
+
+	taskBody := func(title string) string {
+		return fmt.Sprintf(`{"title": %q, "activeAt": %q, "assignee_id": %q}`, title, time.Now().Format("2006-01-02"), bobID)
+	}
+
+	// пока bob не участник, назначить его нельзя
+	w = doAuthRequest(r, http.MethodPost, tasksPath, alice, taskBody("Молоко"))
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+
+	w = doAuthRequest(r, http.MethodPost, "/lists/"+list.ID.Hex()+"/invitations", alice, `{"role": "viewer"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var invitation struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &invitation))
+	w = doAuthRequest(r, http.MethodPost, "/invitations/accept", bob, `{"token": "`+invitation.Token+`"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = doAuthRequest(r, http.MethodPost, tasksPath, alice, taskBody("Молоко"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doAuthRequest(r, http.MethodPost, tasksPath, alice, createBody("Хлеб для @bob"))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = doAuthRequest(r, http.MethodGet, "/me/notifications", bob, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var inbox struct {
+		Notifications []struct {
+			ID    string                  `json:"id"`
+			Kind  entity.NotificationKind `json:"kind"`
+			Title string                  `json:"title"`
+			Read  bool                    `json:"read"`
+		} `json:"notifications"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inbox))
+	require.Len(t, inbox.Notifications, 2)
+	assert.Equal(t, entity.NotificationMentioned, inbox.Notifications[0].Kind)
+	assert.Equal(t, entity.NotificationAssigned, inbox.Notifications[1].Kind)
+	assert.Equal(t, "Молоко", inbox.Notifications[1].Title)
+	assert.False(t, inbox.Notifications[1].Read)
+
+	notificationPath := "/me/notifications/" + inbox.Notifications[1].ID
+	w = doAuthRequest(r, http.MethodPatch, notificationPath, alice, `{"read": true}`)
+	assert.Equal(t, errors2.CodeNotificationNotFound, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPatch, notificationPath, bob, `{}`)
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodPatch, notificationPath, bob, `{"read": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"read":true`)
+
+	w = doAuthRequest(r, http.MethodGet, "/me/notifications?unread=true", bob, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inbox))
+	assert.Len(t, inbox.Notifications, 1)
+
+	w = doAuthRequest(r, http.MethodGet, "/me/notifications?unread=maybe", bob, "")
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+	w = doAuthRequest(r, http.MethodGet, "/me/notifications?limit=0", bob, "")
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/oidc"
+)
+
+// oidcFlowCookie - cookie, в которой state, nonce и PKCE verifier входа ждут callback.
+// Она привязывает callback к браузеру, начавшему вход
+const oidcFlowCookie = "oidc_flow"
+
+// oidcFlowTTL - сколько секунд есть у пользователя на страницу провайдера
+const oidcFlowTTL = 10 * 60
+
+// OIDCLoginHandler отправляет пользователя на страницу входа провайдера
+func (c *AuthController) OIDCLoginHandler(ctx *gin.Context) {
+	authURL, flow, err := c.oidcService.Begin(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	value, err := json.Marshal(flow)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+	c.setFlowCookie(ctx, base64.RawURLEncoding.EncodeToString(value), oidcFlowTTL)
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallbackHandler - redirect_uri у провайдера. Меняет code на пару токенов приложения, как LoginHandler
+func (c *AuthController) OIDCCallbackHandler(ctx *gin.Context) {
+	var flow oidc.Flow
+	if value, err := ctx.Cookie(oidcFlowCookie); err == nil {
+		if data, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+			_ = json.Unmarshal(data, &flow)
+		}
+	}
+	// вход одноразовый: cookie удаляется при любом исходе
+	c.setFlowCookie(ctx, "", -1)
+
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		respondError(ctx, fmt.Errorf("%w: %s", errors2.ErrUnauthorized, providerErr))
+		return
+	}
+
+	tokens, err := c.oidcService.Complete(ctx, flow, ctx.Query("state"), ctx.Query("code"))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tokens)
+}
+
+func (c *AuthController) setFlowCookie(ctx *gin.Context, value string, maxAge int) {
+	// Lax: cookie должна прийти с переходом от провайдера обратно к приложению
+	ctx.SetSameSite(http.SameSiteLaxMode)
+	secure := ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https"
+	ctx.SetCookie(oidcFlowCookie, value, maxAge, "/", "", secure, true)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/oidc"
+	"github.com/nekidaz/todolist/pkg/oidc/oidctest"
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOIDCTestRouter - newAuthTestRouter с входом через провайдер idp
+func newOIDCTestRouter(t *testing.T, idp *oidctest.Server) *gin.Engine {
+	t.Helper()
+	provider, err := oidc.Discover(context.Background(), idp.Config("http://app.example.com/auth/oidc/callback"), nil)
+	require.NoError(t, err)
+
+	repository := repo.NewMemoryRepository()
+	tokens := token.NewIssuer([]byte("secret"), time.Minute, time.Hour)
+	todoController := NewTodoController(services.NewTodoService(repository, services.SubtaskPolicies{}, entity.DefaultRankWeights()), false)
+	authController := NewAuthController(services.NewAuthService(repository, tokens), services.NewOIDCService(repository, provider, tokens))
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	RegisterRoutes(&r.RouterGroup, todoController, authController)
+	return r
+}
+
+func TestOIDCLogin(t *testing.T) {
+	idp := oidctest.NewServer(t, "todolist", "secret")
+	r := newOIDCTestRouter(t, idp)
+	idp.Login(oidctest.User{Subject: "42", Email: "alice@example.com", EmailVerified: true})
+
+	w := doAuthRequest(r, http.MethodGet, "/auth/oidc/login", "", "")
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	location := w.Header().Get("Location")
+	assert.Contains(t, location, idp.URL+"/authorize?")
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].HttpOnly)
+
+	code, state := idp.Authorize(t, location)
+	callback := "/auth/oidc/callback?" + url.Values{"code": {code}, "state": {state}}.Encode()
+
+	// без cookie callback не принимается: вход начат не в этом браузере
+	w = doAuthRequest(r, http.MethodGet, callback, "", "")
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+
+	code, state = idp.Authorize(t, location)
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var pair token.Pair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+
+	w = doAuthRequest(r, http.MethodGet, "/auth/me", pair.AccessToken, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "alice@example.com")
+
+	w = doAuthRequest(r, http.MethodGet, "/auth/oidc/callback?error=access_denied", "", "")
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+}
+
+func TestOIDCBearerToken(t *testing.T) {
+	idp := oidctest.NewServer(t, "todolist", "secret")
+	r := newOIDCTestRouter(t, idp)
+	alice := oidctest.User{Subject: "42", Email: "alice@example.com", EmailVerified: true}
+
+	w := doAuthRequest(r, http.MethodGet, "/auth/me", idp.Token(t, alice, "todolist", time.Hour), "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "alice@example.com")
+
+	w = doAuthRequest(r, http.MethodGet, "/tasks/all", idp.Token(t, alice, "other-app", time.Hour), "")
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+}
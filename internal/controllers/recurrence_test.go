@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOccurrencesHandler(t *testing.T) {
+	r, todoService, owner := newTestRouter(t)
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	w := doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON,
+		`{"title": "Полить цветы", "activeAt": "`+today.Format("2006-01-02")+`", "rrule": "rrule:freq=daily;interval=3;count=3"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	page, err := todoService.GetAllTasks(owner, entity.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Tasks, 1)
+	todo := page.Tasks[0]
+
+	w = doRequest(r, http.MethodGet, "/tasks/"+todo.ID.Hex()+"/occurrences?limit=5", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		RRule       string      `json:"rrule"`
+		Occurrences []time.Time `json:"occurrences"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "FREQ=DAILY;INTERVAL=3;COUNT=3", body.RRule)
+	require.Len(t, body.Occurrences, 2)
+	assert.True(t, body.Occurrences[0].Equal(today.AddDate(0, 0, 3)))
+	assert.True(t, body.Occurrences[1].Equal(today.AddDate(0, 0, 6)))
+
+	for _, limit := range []string{"0", "101", "nope"} {
+		w = doRequest(r, http.MethodGet, "/tasks/"+todo.ID.Hex()+"/occurrences?limit="+limit, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, limit)
+	}
+
+	w = doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON,
+		`{"title": "Отчет", "activeAt": "`+today.Format("2006-01-02")+`", "rrule": "FREQ=HOURLY"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, errors2.CodeValidationFailed, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodPatch, "/tasks/"+todo.ID.Hex(), entity.MergePatchContentType, `{"series_start": null}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
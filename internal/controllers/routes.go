@@ -0,0 +1,79 @@
+package controllers
+
+import "github.com/gin-gonic/gin"
+
+// RegisterRoutes регистрирует маршруты API в группе api: вход и регистрацию открыто, остальное - за RequireAuth.
+// Вход через OIDC доступен, только если он настроен в authController
+func RegisterRoutes(api *gin.RouterGroup, todoController *TodoController, authController *AuthController) {
+	api.POST("/auth/register", authController.RegisterHandler)
+	api.POST("/auth/login", authController.LoginHandler)
+	api.POST("/auth/refresh", authController.RefreshHandler)
+
+	if authController.oidcService != nil {
+		api.GET("/auth/oidc/login", authController.OIDCLoginHandler)
+		api.GET("/auth/oidc/callback", authController.OIDCCallbackHandler)
+	}
+
+	// задачи, списки и метки доступны только с access-токеном или ключом API
+	protected := api.Group("", authController.RequireAuth)
+
+	protected.GET("/auth/me", authController.MeHandler)
+	protected.PATCH("/auth/me", authController.DenyAPIKeys, authController.UpdateMeHandler)
+
+	keys := protected.Group("/auth/keys", authController.DenyAPIKeys)
+	keys.GET("", authController.GetAPIKeysHandler)
+	keys.POST("", authController.CreateAPIKeyHandler)
+	keys.DELETE("/:keyID", authController.RevokeAPIKeyHandler)
+
+	// старые маршруты /tasks работают со списком по умолчанию
+	registerTaskRoutes(protected.Group("/tasks"), todoController)
+	registerTaskRoutes(protected.Group("/lists/:listID/tasks", todoController.ListScope), todoController)
+
+	protected.GET("/lists", todoController.GetListsHandler)
+	protected.POST("/lists", todoController.CreateListHandler)
+	protected.PATCH("/lists/:listID", todoController.UpdateListHandler)
+	protected.DELETE("/lists/:listID", todoController.DeleteListHandler)
+
+	// участниками и приглашениями управляет сам пользователь, ключам API это недоступно
+	members := protected.Group("", authController.DenyAPIKeys)
+	members.GET("/lists/:listID/members", todoController.GetMembersHandler)
+	members.PATCH("/lists/:listID/members/:userID", todoController.UpdateMemberHandler)
+	members.DELETE("/lists/:listID/members/:userID", todoController.RemoveMemberHandler)
+	members.POST("/lists/:listID/invitations", todoController.CreateInvitationHandler)
+	members.POST("/invitations/accept", todoController.AcceptInvitationHandler)
+
+	// входящие: назначения, упоминания и изменения задач, где пользователь исполнитель
+	protected.GET("/me/notifications", todoController.GetNotificationsHandler)
+	protected.PATCH("/me/notifications/:notificationID", todoController.UpdateNotificationHandler)
+
+	protected.GET("/tags", todoController.GetTagsHandler)
+	protected.POST("/tags/merge", todoController.MergeTagsHandler)
+	protected.POST("/tags/:tag/rename", todoController.RenameTagHandler)
+}
+
+// registerTaskRoutes регистрирует маршруты задач в группе tasks: /tasks или /lists/:listID/tasks
+func registerTaskRoutes(tasks *gin.RouterGroup, todoController *TodoController) {
+	tasks.GET("/:ID", todoController.GetTaskByID)
+	tasks.GET("", todoController.GetTasksByStatusHandler)
+	tasks.GET("/all", todoController.GetAllTasks)
+	tasks.GET("/search", todoController.SearchTasksHandler)
+	tasks.GET("/plan", todoController.PlanTasksHandler)
+	tasks.GET("/next", todoController.NextTasksHandler)
+
+	tasks.POST("", todoController.CreateNewTodoHandler)
+	tasks.DELETE("/:ID", todoController.DeleteTodoHandler)
+	tasks.PUT("/:ID", todoController.UpdateTodoHandler)
+	tasks.PATCH("/:ID", todoController.PatchTodoHandler)
+	tasks.PATCH("/:ID/done", todoController.MarkAsCompletedHandler)
+	tasks.POST("/:ID/transitions", todoController.TransitionTodoHandler)
+	tasks.POST("/:ID/reopen", todoController.ReopenTodoHandler)
+	tasks.POST("/:ID/move", todoController.MoveTodoHandler)
+	tasks.PATCH("/:ID/checklist/:item", todoController.SetChecklistItemHandler)
+	tasks.GET("/:ID/subtasks", todoController.GetSubtasksHandler)
+	tasks.POST("/:ID/subtasks", todoController.CreateSubtaskHandler)
+	tasks.GET("/:ID/occurrences", todoController.GetOccurrencesHandler)
+	tasks.GET("/:ID/comments", todoController.GetCommentsHandler)
+	tasks.POST("/:ID/comments", todoController.CreateCommentHandler)
+	tasks.PATCH("/:ID/comments/:commentID", todoController.UpdateCommentHandler)
+	tasks.DELETE("/:ID/comments/:commentID", todoController.DeleteCommentHandler)
+}
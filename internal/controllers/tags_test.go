@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagsHandlers(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+	today := time.Now().Format("2006-01-02")
+
+	for _, body := range []string{
+		`{"title": "Отчет", "activeAt": "` + today + `", "tags": ["Work", "urgent"]}`,
+		`{"title": "Деплой", "activeAt": "` + today + `", "tags": ["job"]}`,
+		`{"title": "Продукты", "activeAt": "` + today + `", "tags": ["home"]}`,
+	} {
+		w := doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, body)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	list := func(query string) []string {
+		w := doRequest(r, http.MethodGet, "/tasks/all?"+query, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page entity.TodoPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		var result []string
+		for _, todo := range page.Tasks {
+			result = append(result, todo.Title)
+		}
+		return result
+	}
+
+	assert.Equal(t, []string{"Отчет"}, list("tag=WORK"))
+	assert.ElementsMatch(t, []string{"Отчет", "Деплой"}, list("tag_any=work,job"))
+	assert.Equal(t, []string{"Отчет"}, list("tag_all=work,urgent"))
+	assert.Empty(t, list("tag_all=work,home"))
+
+	w := doRequest(r, http.MethodGet, "/tasks/all?tag=a%20b", "", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doRequest(r, http.MethodPost, "/tags/job/rename", gin.MIMEJSON, `{"name": "Work"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"name": "work", "tasks": 1}`, w.Body.String())
+
+	w = doRequest(r, http.MethodPost, "/tags/merge", gin.MIMEJSON, `{"tags": ["urgent", "home"], "into": "later"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"name": "later", "tasks": 2}`, w.Body.String())
+
+	w = doRequest(r, http.MethodGet, "/tags", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"tags": [{"name": "later", "count": 2}, {"name": "work", "count": 2}]}`, w.Body.String())
+
+	w = doRequest(r, http.MethodPost, "/tags/missing/rename", gin.MIMEJSON, `{"name": "work"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, errors2.CodeTagNotFound, decodeProblem(t, w).Code)
+
+	w = doRequest(r, http.MethodPost, "/tags/merge", gin.MIMEJSON, `{"into": "work"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+)
+
+const (
+	// TenantHeader - заголовок с id организации запроса
+	TenantHeader = "X-Tenant-ID"
+	// AdminTokenHeader - заголовок с ключом администратора для маршрутов /admin/tenants
+	AdminTokenHeader = "X-Admin-Token"
+)
+
+type TenantController struct {
+	tenantService services.TenantService
+	// domain - общий домен организаций: запрос к acme.<domain> идет в организацию acme. Пустой - поддомены не смотрятся
+	domain string
+	// adminToken - ключ администратора. Пустой - управлять организациями нельзя никому
+	adminToken string
+}
+
+func NewTenantController(tenantService services.TenantService, domain, adminToken string) *TenantController {
+	return &TenantController{tenantService: tenantService, domain: strings.ToLower(domain), adminToken: adminToken}
+}
+
+// ResolveTenant - middleware всех маршрутов API, когда организаций несколько: находит организацию запроса
+// и кладет ее в контекст, по ней хранилище выбирает базу. Организацию токена сверяет сам вход,
+// поэтому токен одной организации с заголовком другой не действует.
+// gin.Engine должен быть с ContextWithFallback, иначе хранилище не увидит организацию и откажет в доступе
+func (c *TenantController) ResolveTenant(ctx *gin.Context) {
+	tenant, err := c.tenantService.ResolveTenant(ctx, c.requestTenant(ctx))
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Request = ctx.Request.WithContext(entity.WithTenant(ctx.Request.Context(), tenant.ID))
+	ctx.Next()
+}
+
+// requestTenant - id организации из заголовка X-Tenant-ID, иначе из поддомена, иначе из access-токена
+func (c *TenantController) requestTenant(ctx *gin.Context) string {
+	if id := ctx.GetHeader(TenantHeader); id != "" {
+		return id
+	}
+	if id := subdomain(ctx.Request.Host, c.domain); id != "" {
+		return id
+	}
+
+	scheme, credential, found := strings.Cut(ctx.GetHeader("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return c.tenantService.TokenTenant(strings.TrimSpace(credential))
+}
+
+// subdomain - метка перед domain в host: acme для acme.example.com:8080 при domain example.com.
+// Сам домен и поддомены глубже одного уровня организацией не считаются
+func subdomain(host, domain string) string {
+	if domain == "" {
+		return ""
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+
+	host = strings.ToLower(host)
+	if !strings.HasSuffix(host, "."+domain) {
+		return ""
+	}
+	label := strings.TrimSuffix(host, "."+domain)
+	if strings.Contains(label, ".") {
+		return ""
+	}
+	return label
+}
+
+// RequireAdmin - middleware маршрутов управления организациями. Ключ сравнивается за постоянное время,
+// чтобы его нельзя было подобрать по времени ответа
+func (c *TenantController) RequireAdmin(ctx *gin.Context) {
+	token := ctx.GetHeader(AdminTokenHeader)
+	if c.adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(c.adminToken)) != 1 {
+		respondError(ctx, errors2.ErrUnauthorized)
+		return
+	}
+	ctx.Next()
+}
+
+// CreateTenantHandler заводит организацию и сразу готовит ее базу
+func (c *TenantController) CreateTenantHandler(ctx *gin.Context) {
+	var requestBody struct {
+		ID   string `json:"id" binding:"required"`
+		Name string `json:"name"`
+	}
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	tenant, err := c.tenantService.CreateTenant(ctx, requestBody.ID, requestBody.Name)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, tenant)
+}
+
+func (c *TenantController) GetTenantsHandler(ctx *gin.Context) {
+	tenants, err := c.tenantService.GetTenants(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, tenants)
+}
+
+// DeleteTenantHandler удаляет организацию :tenantID вместе с ее базой, вернуть данные уже нельзя
+func (c *TenantController) DeleteTenantHandler(ctx *gin.Context) {
+	if err := c.tenantService.DeleteTenant(ctx, ctx.Param("tenantID")); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "admin-secret"
+
+func newTenantTestRouter() *gin.Engine {
+	repository := repo.NewTenantRepository(repo.NewMemoryTenants())
+	tokens := token.NewIssuer([]byte("secret"), time.Minute, time.Hour)
+	todoController := NewTodoController(services.NewTodoService(repository, services.SubtaskPolicies{}, entity.DefaultRankWeights()), false)
+	authController := NewAuthController(services.NewAuthService(repository, tokens), nil)
+	tenantController := NewTenantController(services.NewTenantService(repository, tokens), "todo.example.com", testAdminToken)
+
+	r := gin.New()
+	r.ContextWithFallback = true
+	admin := r.Group("/admin/tenants", tenantController.RequireAdmin)
+	admin.GET("", tenantController.GetTenantsHandler)
+	admin.POST("", tenantController.CreateTenantHandler)
+	admin.DELETE("/:tenantID", tenantController.DeleteTenantHandler)
+
+	RegisterRoutes(r.Group("", tenantController.ResolveTenant), todoController, authController)
+	return r
+}
+
+// doTenantRequest - запрос с заголовками из headers: X-Tenant-ID, Authorization, X-Admin-Token, Host
+func doTenantRequest(r *gin.Engine, method, path string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", gin.MIMEJSON)
+	}
+	for name, value := range headers {
+		if name == "Host" {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// tenantLogin регистрирует пользователя в организации tenant и возвращает его access-токен
+func tenantLogin(t *testing.T, r *gin.Engine, tenant, email string) string {
+	t.Helper()
+	headers := map[string]string{TenantHeader: tenant}
+	body := `{"email": "` + email + `", "password": "correct horse"}`
+
+	w := doTenantRequest(r, http.MethodPost, "/auth/register", headers, body)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = doTenantRequest(r, http.MethodPost, "/auth/login", headers, body)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var pair token.Pair
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pair))
+	return pair.AccessToken
+}
+
+func TestTenantAdmin(t *testing.T) {
+	r := newTenantTestRouter()
+
+	for _, value := range []string{"", "wrong"} {
+		w := doTenantRequest(r, http.MethodGet, "/admin/tenants", map[string]string{AdminTokenHeader: value}, "")
+		assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code, value)
+	}
+
+	admin := map[string]string{AdminTokenHeader: testAdminToken}
+	w := doTenantRequest(r, http.MethodPost, "/admin/tenants", admin, `{"id": "Acme", "name": "ACME Corp"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"id":"acme"`)
+
+	w = doTenantRequest(r, http.MethodPost, "/admin/tenants", admin, `{"id": "acme"}`)
+	assert.Equal(t, errors2.CodeTenantDuplicate, decodeProblem(t, w).Code)
+	w = doTenantRequest(r, http.MethodPost, "/admin/tenants", admin, `{"id": "acme corp"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doTenantRequest(r, http.MethodGet, "/admin/tenants", admin, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var tenants []entity.Tenant
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &tenants))
+	require.Len(t, tenants, 1)
+	assert.Equal(t, "ACME Corp", tenants[0].Name)
+
+	w = doTenantRequest(r, http.MethodDelete, "/admin/tenants/acme", admin, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doTenantRequest(r, http.MethodDelete, "/admin/tenants/acme", admin, "")
+	assert.Equal(t, errors2.CodeTenantNotFound, decodeProblem(t, w).Code)
+}
+
+func TestResolveTenant(t *testing.T) {
+	r := newTenantTestRouter()
+	admin := map[string]string{AdminTokenHeader: testAdminToken}
+	for _, id := range []string{"acme", "globex"} {
+		w := doTenantRequest(r, http.MethodPost, "/admin/tenants", admin, `{"id": "`+id+`"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w := doTenantRequest(r, http.MethodPost, "/auth/register", nil, `{"email": "alice@example.com", "password": "correct horse"}`)
+	assert.Equal(t, errors2.CodeTenantRequired, decodeProblem(t, w).Code)
+	w = doTenantRequest(r, http.MethodPost, "/auth/register", map[string]string{TenantHeader: "initech"}, `{"email": "alice@example.com", "password": "correct horse"}`)
+	assert.Equal(t, errors2.CodeTenantNotFound, decodeProblem(t, w).Code)
+
+	acme := tenantLogin(t, r, "acme", "alice@example.com")
+	globex := tenantLogin(t, r, "globex", "alice@example.com")
+
+	// организацию берет из токена, если нет заголовка и поддомена
+	w = doTenantRequest(r, http.MethodPost, "/tasks", map[string]string{"Authorization": "Bearer " + acme}, `{"title": "Отчет", "activeAt": "2030-01-01"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// поддомен ведет в ту же организацию, а в соседней задачи нет
+	w = doTenantRequest(r, http.MethodGet, "/tasks/all", map[string]string{"Authorization": "Bearer " + acme, "Host": "ACME.todo.example.com:8080"}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "Отчет")
+	w = doTenantRequest(r, http.MethodGet, "/tasks/all", map[string]string{"Authorization": "Bearer " + globex}, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "Отчет")
+
+	// токен одной организации в другой не действует
+	w = doTenantRequest(r, http.MethodGet, "/tasks/all", map[string]string{"Authorization": "Bearer " + acme, TenantHeader: "globex"}, "")
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+	w = doTenantRequest(r, http.MethodGet, "/tasks/all", map[string]string{"Authorization": "Bearer " + acme, "Host": "globex.todo.example.com"}, "")
+	assert.Equal(t, errors2.CodeUnauthorized, decodeProblem(t, w).Code)
+
+	// после удаления организации ее токены никуда не ведут
+	w = doTenantRequest(r, http.MethodDelete, "/admin/tenants/acme", admin, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = doTenantRequest(r, http.MethodGet, "/tasks/all", map[string]string{"Authorization": "Bearer " + acme}, "")
+	assert.Equal(t, errors2.CodeTenantNotFound, decodeProblem(t, w).Code)
+}
+
+func TestSubdomain(t *testing.T) {
+	for host, want := range map[string]string{
+		"acme.example.com":      "acme",
+		"acme.example.com:8080": "acme",
+		"Acme.Example.com":      "acme",
+		"example.com":           "",
+		"a.acme.example.com":    "",
+		"acme.example.org":      "",
+		"acmeexample.com":       "",
+	} {
+		assert.Equal(t, want, subdomain(host, "example.com"), host)
+	}
+	assert.Empty(t, subdomain("acme.example.com", ""))
+}
@@ -1,10 +1,13 @@
 package controllers
 
 import (
-	"errors"
+	"fmt"
 	"github.com/nekidaz/todolist/internal/entity"
 	"github.com/nekidaz/todolist/internal/usecase/services"
 	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/i18n"
+	"github.com/nekidaz/todolist/pkg/markdown"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"net/http"
 	"strconv"
 	"time"
@@ -14,75 +17,193 @@ import (
 
 type TodoController struct {
 	todoService services.TodoService
+	// legacyPositionalIDs включает старый режим, где :ID - это номер задачи в списке GetAllTasks
+	legacyPositionalIDs bool
 }
 
-func NewTodoController(todoService services.TodoService) *TodoController {
+func NewTodoController(todoService services.TodoService, legacyPositionalIDs bool) *TodoController {
 	return &TodoController{
-		todoService: todoService,
+		todoService:         todoService,
+		legacyPositionalIDs: legacyPositionalIDs,
 	}
 }
 
-func (c *TodoController) CreateNewTodoHandler(ctx *gin.Context) {
+// bindTodoFields читает тело POST и PUT: title, description, priority, activeAt в формате 2006-01-02, assignee_id, depends_on, tags и rrule
+func bindTodoFields(ctx *gin.Context) (entity.TodoFields, error) {
 	var requestBody struct {
-		Title    string `json:"title" binding:"required,max=200"`
-		ActiveAt string `json:"activeAt" binding:"required"`
+		Title       string   `json:"title" binding:"required,max=200"`
+		Description string   `json:"description"`
+		Priority    string   `json:"priority"`
+		ActiveAt    string   `json:"activeAt" binding:"required"`
+		AssigneeID  string   `json:"assignee_id"`
+		DependsOn   []string `json:"depends_on"`
+		Tags        []string `json:"tags"`
+		RRule       string   `json:"rrule"`
 	}
 
 	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		return entity.TodoFields{}, bindingError(err)
 	}
 
 	// тут парсим ибо формат не такой получаем как в тз
 	activeAtTime, err := time.Parse("2006-01-02", requestBody.ActiveAt)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": errors2.ErrParseActiveAt})
+		return entity.TodoFields{}, errors2.ErrParseActiveAt
+	}
+
+	priority, err := entity.ParsePriority(requestBody.Priority)
+	if err != nil {
+		return entity.TodoFields{}, err
+	}
+
+	var assigneeID *primitive.ObjectID
+	if requestBody.AssigneeID != "" {
+		id, err := primitive.ObjectIDFromHex(requestBody.AssigneeID)
+		if err != nil {
+			return entity.TodoFields{}, fmt.Errorf("%w: %s", errors2.ErrInvalidID, requestBody.AssigneeID)
+		}
+		assigneeID = &id
+	}
+
+	dependsOn, err := entity.ParseIDs(requestBody.DependsOn)
+	if err != nil {
+		return entity.TodoFields{}, err
+	}
+
+	tags, err := entity.NormalizeTags(requestBody.Tags)
+	if err != nil {
+		return entity.TodoFields{}, err
+	}
+
+	rule, err := entity.NormalizeRRule(requestBody.RRule)
+	if err != nil {
+		return entity.TodoFields{}, err
+	}
+
+	return entity.TodoFields{
+		Title:       requestBody.Title,
+		Description: requestBody.Description,
+		Priority:    priority,
+		ActiveAt:    activeAtTime,
+		AssigneeID:  assigneeID,
+		DependsOn:   dependsOn,
+		Tags:        tags,
+		RRule:       rule,
+	}, nil
+}
+
+func (c *TodoController) CreateNewTodoHandler(ctx *gin.Context) {
+	fields, err := bindTodoFields(ctx)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
+	fields.ListID = listScope(ctx)
 
 	// создаем задачу через сервис
-	todo, err := c.todoService.CreateNewTodo(ctx, requestBody.Title, activeAtTime)
+	todo, err := c.todoService.CreateNewTodo(ctx, fields)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
 
+	ctx.JSON(http.StatusCreated, gin.H{"message": i18n.Message(requestLanguage(ctx), "tasks.created", todo.Title)})
+}
+
+// CreateSubtaskHandler создает подзадачу задачи :ID и возвращает ее целиком, вместе с id
+func (c *TodoController) CreateSubtaskHandler(ctx *gin.Context) {
+	parent, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	fields, err := bindTodoFields(ctx)
 	if err != nil {
-		switch {
-		case errors.Is(err, errors2.ErrTodoExists):
-			ctx.JSON(http.StatusNoContent, gin.H{"error": errors2.ErrAlreadyExist})
-		default:
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		}
+		respondError(ctx, err)
+		return
+	}
 
+	todo, err := c.todoService.CreateSubtask(ctx, parent.ID, fields)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{"message": "Задача с заголовком: " + todo.Title + " успешно создана"})
+	ctx.JSON(http.StatusCreated, todo)
+}
+
+// GetSubtasksHandler - страница прямых подзадач задачи :ID с теми же параметрами, что и у списков
+func (c *TodoController) GetSubtasksHandler(ctx *gin.Context) {
+	parent, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	opts, err := parseListOptions(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	page, err := c.todoService.GetSubtasks(ctx, parent.ID, opts)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, page)
 }
 
 func (c *TodoController) UpdateTodoHandler(ctx *gin.Context) {
 
-	id, tasks, errReturned := c.processRequestID(ctx)
+	task, errReturned := c.processRequestID(ctx)
 	if errReturned {
 		return
 	}
 
-	var requestBody struct {
-		Title    string `json:"title" binding:"required,max=200"`
-		ActiveAt string `json:"activeAt" binding:"required"`
+	fields, err := bindTodoFields(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
 	}
 
-	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	todo, err := c.todoService.UpdateTodo(ctx, task.ID, fields)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
-	activeAtTime, err := time.Parse("2006-01-02", requestBody.ActiveAt)
+	ctx.JSON(http.StatusOK, todo)
+}
+
+// PatchTodoHandler частично обновляет задачу по JSON Merge Patch (RFC 7396): меняются только переданные поля
+func (c *TodoController) PatchTodoHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	contentType := ctx.ContentType()
+	if contentType != entity.MergePatchContentType && contentType != gin.MIMEJSON {
+		respondError(ctx, errors2.ErrUnsupportedMedia)
+		return
+	}
+
+	body, err := ctx.GetRawData()
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": errors2.ErrParseActiveAt})
+		respondError(ctx, bindingError(err))
 		return
 	}
 
-	todo, err := c.todoService.UpdateTodo(ctx, tasks[id].ID, requestBody.Title, activeAtTime)
+	patch, err := entity.ParseTodoMergePatch(body)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
+		return
+	}
+
+	todo, err := c.todoService.PatchTodo(ctx, task.ID, patch)
+	if err != nil {
+		respondError(ctx, err)
 		return
 	}
 
@@ -90,16 +211,16 @@ func (c *TodoController) UpdateTodoHandler(ctx *gin.Context) {
 }
 
 func (c *TodoController) DeleteTodoHandler(ctx *gin.Context) {
-	id, tasks, errReturned := c.processRequestID(ctx)
+	task, errReturned := c.processRequestID(ctx)
 
 	if errReturned {
 		return
 	}
 
-	err := c.todoService.DeleteTodo(ctx, tasks[id].ID)
+	err := c.todoService.DeleteTodo(ctx, task.ID)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
@@ -107,92 +228,381 @@ func (c *TodoController) DeleteTodoHandler(ctx *gin.Context) {
 }
 
 func (c *TodoController) MarkAsCompletedHandler(ctx *gin.Context) {
-	id, tasks, errReturned := c.processRequestID(ctx)
+	task, errReturned := c.processRequestID(ctx)
 	if errReturned {
 		return
 	}
 
-	err := c.todoService.MarkAsCompleted(ctx, tasks[id].ID)
+	err := c.todoService.MarkAsCompleted(ctx, task.ID)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
 	ctx.Status(http.StatusOK)
 }
 
+// TransitionTodoHandler переводит задачу в другой статус по таблице переходов из entity
+func (c *TodoController) TransitionTodoHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	var requestBody struct {
+		Status string `json:"status" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	status, err := entity.ParseTaskStatus(requestBody.Status)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	todo, err := c.todoService.TransitionTodo(ctx, task.ID, status)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, todo)
+}
+
+// ReopenTodoHandler возвращает выполненную или отмененную задачу в todo
+func (c *TodoController) ReopenTodoHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	todo, err := c.todoService.ReopenTodo(ctx, task.ID)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, todo)
+}
+
 func (c *TodoController) GetTaskByID(ctx *gin.Context) {
 	// Получаем ID задачи из параметра в URL
-	id, tasks, errReturned := c.processRequestID(ctx)
+	task, errReturned := c.processRequestID(ctx)
 	if errReturned {
 		return
 	}
-	ctx.JSON(http.StatusOK, tasks[id])
+
+	// ?render=html добавляет описание, отрисованное в безопасный HTML
+	if ctx.Query("render") == "html" {
+		html, err := markdown.Render(task.Description)
+		if err != nil {
+			respondError(ctx, err)
+			return
+		}
+		task.DescriptionHTML = html
+	}
+
+	ctx.JSON(http.StatusOK, task)
+}
+
+// SetChecklistItemHandler отмечает пункт :item чек-листа из описания задачи выполненным или снимает отметку
+func (c *TodoController) SetChecklistItemHandler(ctx *gin.Context) {
+	task, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	index, err := strconv.Atoi(ctx.Param("item"))
+	if err != nil {
+		respondError(ctx, errors2.ErrChecklistNotFound)
+		return
+	}
+
+	var requestBody struct {
+		Done *bool `json:"done" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	todo, err := c.todoService.SetChecklistItem(ctx, task.ID, index, *requestBody.Done)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, todo)
 }
 
 func (c *TodoController) GetAllTasks(ctx *gin.Context) {
-	tasks, err := c.todoService.GetAllTasks(ctx)
+	opts, err := parseListOptions(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	page, err := c.todoService.GetAllTasks(ctx, opts)
 
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		respondError(ctx, err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, tasks)
+	ctx.JSON(http.StatusOK, page)
 }
 
 func (c *TodoController) GetTasksByStatusHandler(ctx *gin.Context) {
 	status := ctx.Query("status")
 
 	if status == "" {
-		status = "active"
+		status = entity.StatusFilterActive
 	}
 
-	tasks, err := c.todoService.GetTasksByStatus(ctx, status)
+	if status != entity.StatusFilterActive {
+		if _, err := entity.ParseTaskStatus(status); err != nil {
+			respondError(ctx, err)
+			return
+		}
+	}
 
+	opts, err := parseListOptions(ctx)
 	if err != nil {
-		// если не все ок то показываем кастомную ошибку
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(ctx, err)
 		return
 	}
 
-	// ну тут если задач нет то пустой массив
-	if len(tasks) == 0 {
-		ctx.JSON(http.StatusOK, gin.H{"tasks": tasks})
+	page, err := c.todoService.GetTasksByStatus(ctx, status, opts)
+
+	if err != nil {
+		// если не все ок то показываем кастомную ошибку
+		respondError(ctx, err)
 		return
 	}
-	for i, task := range tasks {
+
+	weekendPrefix := i18n.Message(requestLanguage(ctx), "tasks.weekend_prefix")
+	for i, task := range page.Tasks {
 		if task.ActiveAt.Weekday() == time.Saturday || task.ActiveAt.Weekday() == time.Sunday {
-			tasks[i].Title = "ВЫХОДНОЙ - " + task.Title
+			page.Tasks[i].Title = weekendPrefix + task.Title
 		}
 	}
 
+	ctx.JSON(http.StatusOK, page)
+}
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// SearchTasksHandler - полнотекстовый поиск по заголовкам с учетом русской и английской морфологии
+func (c *TodoController) SearchTasksHandler(ctx *gin.Context) {
+	limit := defaultSearchLimit
+	if value := ctx.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSearchLimit {
+			respondError(ctx, fmt.Errorf("%w: 1..%d", errors2.ErrInvalidLimit, maxSearchLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	hits, err := c.todoService.SearchTasks(ctx, listScope(ctx), ctx.Query("q"), limit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"results": hits})
+}
+
+// maxPlanTasks - сколько задач можно запросить в одном плане
+const maxPlanTasks = 100
+
+// PlanTasksHandler - задачи из ?ids=id1,id2 и их незакрытые зависимости в порядке выполнения
+func (c *TodoController) PlanTasksHandler(ctx *gin.Context) {
+	values := queryList(ctx, "ids")
+	if len(values) == 0 || len(values) > maxPlanTasks {
+		respondError(ctx, fmt.Errorf("%w: ids 1..%d", errors2.ErrInvalidFieldValue, maxPlanTasks))
+		return
+	}
+
+	ids, err := entity.ParseIDs(values)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	plan, err := c.todoService.PlanTasks(ctx, ids)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"tasks": plan})
+}
+
+const (
+	defaultNextLimit = 10
+	maxNextLimit     = 100
+)
+
+// NextTasksHandler - активные задачи по убыванию оценки, у каждой - из чего оценка сложилась
+func (c *TodoController) NextTasksHandler(ctx *gin.Context) {
+	limit, err := parseLimit(ctx, defaultNextLimit, maxNextLimit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	var opts entity.ListOptions
+	if err := parseTaskFilters(ctx, &opts); err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	tasks, err := c.todoService.NextTasks(ctx, limit, opts)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
 	ctx.JSON(http.StatusOK, gin.H{"tasks": tasks})
 }
 
-// это для того чтобы получать данные в виде массива так как выполнять разные операции будет легчо выполнять по айдишкику в массиве
-func (c *TodoController) processRequestID(ctx *gin.Context) (id int, tasks []*entity.Todo, errReturned bool) {
+// GetTagsHandler - все метки с числом задач
+func (c *TodoController) GetTagsHandler(ctx *gin.Context) {
+	tags, err := c.todoService.GetTags(ctx)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// RenameTagHandler переименовывает метку :tag во всех задачах. Если новое имя уже занято, метки сливаются
+func (c *TodoController) RenameTagHandler(ctx *gin.Context) {
+	var requestBody struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	c.renameTags(ctx, []string{ctx.Param("tag")}, requestBody.Name)
+}
+
+// MergeTagsHandler сливает метки tags в метку into во всех задачах
+func (c *TodoController) MergeTagsHandler(ctx *gin.Context) {
+	var requestBody struct {
+		Tags []string `json:"tags" binding:"required"`
+		Into string   `json:"into" binding:"required"`
+	}
+
+	if err := ctx.ShouldBindJSON(&requestBody); err != nil {
+		respondError(ctx, bindingError(err))
+		return
+	}
+
+	c.renameTags(ctx, requestBody.Tags, requestBody.Into)
+}
+
+func (c *TodoController) renameTags(ctx *gin.Context, from []string, to string) {
+	renamed, err := c.todoService.RenameTags(ctx, from, to)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	// имя в ответе нормализованное, каким его сохранил сервис
+	name, _ := entity.NormalizeTag(to)
+	ctx.JSON(http.StatusOK, gin.H{"name": name, "tasks": renamed})
+}
+
+const (
+	defaultOccurrencesLimit = 10
+	maxOccurrencesLimit     = 100
+)
+
+// GetOccurrencesHandler - ближайшие повторения задачи :ID после ее active_at
+func (c *TodoController) GetOccurrencesHandler(ctx *gin.Context) {
+	todo, errReturned := c.processRequestID(ctx)
+	if errReturned {
+		return
+	}
+
+	limit, err := parseLimit(ctx, defaultOccurrencesLimit, maxOccurrencesLimit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	occurrences, err := c.todoService.GetOccurrences(ctx, todo.ID, limit)
+	if err != nil {
+		respondError(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"rrule": todo.RRule, "occurrences": occurrences})
+}
+
+// processRequestID находит задачу по :ID из URL в списке запроса. :ID - это ObjectID задачи (поле id в JSON),
+// номер задачи в списке принимается только если включен legacyPositionalIDs
+func (c *TodoController) processRequestID(ctx *gin.Context) (task *entity.Todo, errReturned bool) {
 	idStr := ctx.Param("ID")
-	id, err := strconv.Atoi(idStr)
 
+	if c.legacyPositionalIDs {
+		if position, err := strconv.Atoi(idStr); err == nil {
+			return c.processPositionalID(ctx, position)
+		}
+	}
+
+	id, err := primitive.ObjectIDFromHex(idStr)
+	if err != nil {
+		respondError(ctx, errors2.ErrInvalidID)
+		return nil, true
+	}
+
+	task, err = c.todoService.GetTaskByID(ctx, id)
 	if err != nil {
-		defer ctx.JSON(http.StatusBadRequest, gin.H{"error": errors2.ErrInvalidID})
-		return 0, nil, true
+		respondError(ctx, err)
+		return nil, true
 	}
 
-	id = id - 1
+	// задача другого списка для этого маршрута не существует
+	if !task.InList(listScope(ctx)) {
+		respondError(ctx, errors2.ErrNotFound)
+		return nil, true
+	}
+
+	return task, false
+}
 
-	tasks, err = c.todoService.GetAllTasks(ctx)
+// processPositionalID - старый режим: задача ищется по номеру (с единицы) в списке, отсортированном по active_at.
+// Номер указывает на другую задачу, как только добавляется задача с более ранней датой, поэтому только для старых клиентов
+func (c *TodoController) processPositionalID(ctx *gin.Context, position int) (task *entity.Todo, errReturned bool) {
+	// без лимита: позиция считается по всему списку
+	page, err := c.todoService.GetAllTasks(ctx, entity.ListOptions{ListID: listScope(ctx), Sort: entity.SortActiveAt})
 	if err != nil {
-		defer ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return 0, nil, true
+		respondError(ctx, err)
+		return nil, true
 	}
+	tasks := page.Tasks
 
+	id := position - 1
 	if id < 0 || id >= len(tasks) {
-		defer ctx.JSON(http.StatusNotFound, gin.H{"error": errors2.ErrTaskNotFound})
-		return 0, nil, true
+		respondError(ctx, errors2.ErrNotFound)
+		return nil, true
 	}
 
-	return id, tasks, false
+	return tasks[id], false
 }
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nekidaz/todolist/internal/entity"
+	errors2 "github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createTask создает задачу с датой activeAt вида 2006-01-02 через API и возвращает ее
+func createTask(t *testing.T, r *gin.Engine, title, activeAt string) *entity.Todo {
+	t.Helper()
+	w := doRequest(r, http.MethodPost, "/tasks", gin.MIMEJSON, fmt.Sprintf(`{"title": %q, "activeAt": %q}`, title, activeAt))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = doRequest(r, http.MethodGet, "/tasks/all", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page entity.TodoPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	for _, todo := range page.Tasks {
+		if todo.Title == title {
+			return todo
+		}
+	}
+	t.Fatalf("Задача %q не найдена после создания", title)
+	return nil
+}
+
+func TestTaskByObjectID(t *testing.T) {
+	r, _, _ := newTestRouter(t)
+	todo := createTask(t, r, "Отчет", "2030-01-02")
+
+	w := doRequest(r, http.MethodGet, "/tasks/"+todo.ID.Hex(), "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var found entity.Todo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.Equal(t, todo.ID, found.ID)
+	assert.Equal(t, "Отчет", found.Title)
+
+	// без LEGACY_POSITIONAL_IDS номер - такой же неверный id, как любой другой
+	for _, id := range []string{"not-an-id", "1", todo.ID.Hex()[1:]} {
+		w = doRequest(r, http.MethodGet, "/tasks/"+id, "", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, id)
+		assert.Equal(t, errors2.CodeInvalidID, decodeProblem(t, w).Code, id)
+	}
+
+	w = doRequest(r, http.MethodGet, "/tasks/"+primitive.NewObjectID().Hex(), "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, errors2.CodeTaskNotFound, decodeProblem(t, w).Code)
+	w = doRequest(r, http.MethodDelete, "/tasks/"+primitive.NewObjectID().Hex(), "", "")
+	assert.Equal(t, errors2.CodeTaskNotFound, decodeProblem(t, w).Code)
+}
+
+func TestLegacyPositionalIDs(t *testing.T) {
+	r, _, _ := newLegacyTestRouter(t, true)
+	later := createTask(t, r, "Позже", "2030-01-05")
+	earlier := createTask(t, r, "Раньше", "2030-01-02")
+
+	// номер считается с единицы по active_at, а не по порядку создания
+	for position, want := range map[string]*entity.Todo{"1": earlier, "2": later} {
+		w := doRequest(r, http.MethodGet, "/tasks/"+position, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var found entity.Todo
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+		assert.Equal(t, want.ID, found.ID, position)
+	}
+
+	for _, position := range []string{"0", "3", "-1"} {
+		w := doRequest(r, http.MethodGet, "/tasks/"+position, "", "")
+		assert.Equal(t, errors2.CodeTaskNotFound, decodeProblem(t, w).Code, position)
+	}
+
+	// ObjectID в этом режиме тоже работает
+	w := doRequest(r, http.MethodGet, "/tasks/"+later.ID.Hex(), "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doRequest(r, http.MethodGet, "/tasks/not-an-id", "", "")
+	assert.Equal(t, errors2.CodeInvalidID, decodeProblem(t, w).Code)
+}
+
+func TestTasksWithoutLimit(t *testing.T) {
+	r, todoService, owner := newTestRouter(t)
+	for i := 0; i < maxPageLimit+1; i++ {
+		_, err := todoService.CreateNewTodo(owner, entity.TodoFields{Title: fmt.Sprintf("Задача %d", i), ActiveAt: time.Now()})
+		require.NoError(t, err)
+	}
+
+	// без limit - все задачи, как до появления страниц
+	for _, path := range []string{"/tasks/all", "/tasks?status=todo"} {
+		w := doRequest(r, http.MethodGet, path, "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var page entity.TodoPage
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+		assert.Len(t, page.Tasks, maxPageLimit+1, path)
+		assert.Empty(t, page.NextCursor, path)
+	}
+
+	w := doRequest(r, http.MethodGet, "/tasks/all?limit=50", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page entity.TodoPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Tasks, 50)
+	assert.NotEmpty(t, page.NextCursor)
+}
@@ -0,0 +1,111 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxAPIKeyNameLength - ограничение на длину имени ключа API в символах
+const MaxAPIKeyNameLength = 100
+
+// Scope - право ключа API. Вход по паролю дает все права
+type Scope string
+
+const (
+	ScopeTasksRead   Scope = "tasks:read"
+	ScopeTasksWrite  Scope = "tasks:write"
+	ScopeTasksDelete Scope = "tasks:delete"
+)
+
+// AllScopes - права в порядке, в котором они хранятся и отдаются
+func AllScopes() []Scope {
+	return []Scope{ScopeTasksRead, ScopeTasksWrite, ScopeTasksDelete}
+}
+
+// ParseScopes проверяет права из запроса и убирает повторы. Нужно хотя бы одно право
+func ParseScopes(values []string) ([]Scope, error) {
+	requested := make(map[Scope]bool, len(values))
+	for _, value := range values {
+		scope := Scope(value)
+		known := false
+		for _, s := range AllScopes() {
+			known = known || s == scope
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %s", errors.ErrInvalidScope, value)
+		}
+		requested[scope] = true
+	}
+
+	scopes := make([]Scope, 0, len(requested))
+	for _, scope := range AllScopes() {
+		if requested[scope] {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, errors.ErrInvalidScope
+	}
+	return scopes, nil
+}
+
+// APIKey - личный ключ для скриптов и интеграций. Сам ключ показывается один раз при создании,
+// хранится только его хеш
+type APIKey struct {
+	ID      primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	OwnerID primitive.ObjectID `bson:"owner_id" json:"-"`
+	Name    string             `bson:"name" json:"name"`
+	// Hint - начало ключа, чтобы его можно было узнать в списке
+	Hint   string  `bson:"hint" json:"hint"`
+	Hash   string  `bson:"hash" json:"-"`
+	Scopes []Scope `bson:"scopes" json:"scopes"`
+	// LastUsedAt обновляется при запросах с ключом, не чаще раза в APIKeyTouchInterval
+	LastUsedAt *time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `bson:"created_at" json:"created_at"`
+}
+
+// APIKeyTouchInterval - как часто сохраняется last_used_at: запись на каждый запрос ключа не нужна
+const APIKeyTouchInterval = time.Minute
+
+func NewAPIKey(name string, scopes []Scope) *APIKey {
+	return &APIKey{
+		Name:      strings.TrimSpace(name),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+}
+
+func (k *APIKey) Validate() error {
+	if k.Name == "" || utf8.RuneCountInString(k.Name) > MaxAPIKeyNameLength {
+		return errors.ErrInvalidAPIKeyName
+	}
+	if len(k.Scopes) == 0 {
+		return errors.ErrInvalidScope
+	}
+	return nil
+}
+
+// Allows - у ключа есть право scope
+func (k *APIKey) Allows(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// OwnedBy - ключ принадлежит ownerID. У ключа владелец есть всегда
+func (k *APIKey) OwnedBy(ownerID *primitive.ObjectID) bool {
+	return ownerID != nil && k.OwnerID == *ownerID
+}
+
+// NeedsTouch - last_used_at пора обновить
+func (k *APIKey) NeedsTouch(now time.Time) bool {
+	return k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= APIKeyTouchInterval
+}
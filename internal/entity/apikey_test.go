@@ -0,0 +1,48 @@
+package entity_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := entity.ParseScopes([]string{"tasks:delete", "tasks:read", "tasks:delete"})
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Scope{entity.ScopeTasksRead, entity.ScopeTasksDelete}, scopes)
+
+	_, err = entity.ParseScopes(nil)
+	assert.ErrorIs(t, err, errors.ErrInvalidScope)
+	_, err = entity.ParseScopes([]string{"tasks:read", "admin"})
+	assert.ErrorIs(t, err, errors.ErrInvalidScope)
+}
+
+func TestAPIKey(t *testing.T) {
+	key := entity.NewAPIKey("  CI  ", []entity.Scope{entity.ScopeTasksRead})
+	assert.Equal(t, "CI", key.Name)
+	assert.NoError(t, key.Validate())
+	assert.True(t, key.Allows(entity.ScopeTasksRead))
+	assert.False(t, key.Allows(entity.ScopeTasksWrite))
+
+	assert.ErrorIs(t, entity.NewAPIKey(" ", key.Scopes).Validate(), errors.ErrInvalidAPIKeyName)
+	assert.ErrorIs(t, entity.NewAPIKey(strings.Repeat("я", 101), key.Scopes).Validate(), errors.ErrInvalidAPIKeyName)
+	assert.ErrorIs(t, entity.NewAPIKey("CI", nil).Validate(), errors.ErrInvalidScope)
+
+	owner := primitive.NewObjectID()
+	key.OwnerID = owner
+	assert.True(t, key.OwnedBy(&owner))
+	assert.False(t, key.OwnedBy(nil))
+
+	now := time.Now()
+	assert.True(t, key.NeedsTouch(now))
+	recently := now.Add(-time.Second)
+	key.LastUsedAt = &recently
+	assert.False(t, key.NeedsTouch(now))
+	assert.True(t, key.NeedsTouch(now.Add(entity.APIKeyTouchInterval)))
+}
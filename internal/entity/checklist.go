@@ -0,0 +1,122 @@
+package entity
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+)
+
+// ChecklistItem - пункт чек-листа из описания: строка списка вида "- [ ] текст" или "- [x] текст".
+// Index - номер пункта по порядку в описании, начиная с нуля
+type ChecklistItem struct {
+	Index int    `json:"index"`
+	Text  string `json:"text"`
+	Done  bool   `json:"done"`
+}
+
+// Checklist - все пункты описания и прогресс, например "3/5"
+type Checklist struct {
+	Items    []ChecklistItem `json:"items"`
+	Done     int             `json:"done"`
+	Total    int             `json:"total"`
+	Progress string          `json:"progress"`
+}
+
+// checklistLine - пункт списка (маркированного или нумерованного) с отметкой [ ], [x] или [X] и текстом
+var checklistLine = regexp.MustCompile(`^\s*(?:[-*+]|\d{1,9}[.)])\s+\[([ xX])\]\s+(\S.*)$`)
+
+// checklistMatch - найденный пункт: номер строки в описании и позиция символа отметки в ней
+type checklistMatch struct {
+	line int
+	mark int
+	item ChecklistItem
+}
+
+// parseChecklist находит пункты чек-листа. Строки внутри блоков кода (``` и ~~~) пропускаются
+func parseChecklist(lines []string) []checklistMatch {
+	var matches []checklistMatch
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+
+		m := checklistLine.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+		matches = append(matches, checklistMatch{
+			line: i,
+			mark: m[2],
+			item: ChecklistItem{
+				Index: len(matches),
+				Text:  strings.TrimSpace(line[m[4]:m[5]]),
+				Done:  line[m[2]] != ' ',
+			},
+		})
+	}
+	return matches
+}
+
+// Checklist разбирает чек-лист из описания. Если пунктов нет, возвращает nil
+func (t *Todo) Checklist() *Checklist {
+	matches := parseChecklist(strings.Split(t.Description, "\n"))
+	if len(matches) == 0 {
+		return nil
+	}
+
+	checklist := &Checklist{Items: make([]ChecklistItem, 0, len(matches)), Total: len(matches)}
+	for _, m := range matches {
+		checklist.Items = append(checklist.Items, m.item)
+		if m.item.Done {
+			checklist.Done++
+		}
+	}
+	checklist.Progress = fmt.Sprintf("%d/%d", checklist.Done, checklist.Total)
+	return checklist
+}
+
+// SetChecklistItem отмечает пункт index выполненным или снимает отметку, меняя только символ в скобках
+func (t *Todo) SetChecklistItem(index int, done bool) error {
+	lines := strings.Split(t.Description, "\n")
+	matches := parseChecklist(lines)
+	if index < 0 || index >= len(matches) {
+		return errors.ErrChecklistNotFound
+	}
+
+	m := matches[index]
+	if m.item.Done == done {
+		return nil
+	}
+
+	mark := " "
+	if done {
+		mark = "x"
+	}
+	line := lines[m.line]
+	lines[m.line] = line[:m.mark] + mark + line[m.mark+1:]
+	t.Description = strings.Join(lines, "\n")
+	return nil
+}
+
+// resetChecklist снимает отметки со всех пунктов чек-листа
+func (t *Todo) resetChecklist() {
+	lines := strings.Split(t.Description, "\n")
+	for _, m := range parseChecklist(lines) {
+		if m.item.Done {
+			line := lines[m.line]
+			lines[m.line] = line[:m.mark] + " " + line[m.mark+1:]
+		}
+	}
+	t.Description = strings.Join(lines, "\n")
+}
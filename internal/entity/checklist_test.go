@@ -0,0 +1,87 @@
+package entity_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const checklistDescription = "# Переезд\n" +
+	"- [x] упаковать книги\n" +
+	"* [ ] заказать машину\n" +
+	"  1. [X] вложенный пункт\n" +
+	"```\n" +
+	"- [ ] это код, а не пункт\n" +
+	"```\n" +
+	"- [] без пробела не пункт\n" +
+	"+ [ ] сдать ключи"
+
+func TestChecklist(t *testing.T) {
+	todo := &entity.Todo{Description: checklistDescription}
+
+	checklist := todo.Checklist()
+	require.NotNil(t, checklist)
+	assert.Equal(t, []entity.ChecklistItem{
+		{Index: 0, Text: "упаковать книги", Done: true},
+		{Index: 1, Text: "заказать машину", Done: false},
+		{Index: 2, Text: "вложенный пункт", Done: true},
+		{Index: 3, Text: "сдать ключи", Done: false},
+	}, checklist.Items)
+	assert.Equal(t, 2, checklist.Done)
+	assert.Equal(t, 4, checklist.Total)
+	assert.Equal(t, "2/4", checklist.Progress)
+}
+
+func TestChecklistEmpty(t *testing.T) {
+	assert.Nil(t, (&entity.Todo{}).Checklist())
+	assert.Nil(t, (&entity.Todo{Description: "просто текст"}).Checklist())
+}
+
+func TestSetChecklistItem(t *testing.T) {
+	todo := &entity.Todo{Description: checklistDescription}
+
+	require.NoError(t, todo.SetChecklistItem(1, true))
+	require.NoError(t, todo.SetChecklistItem(2, false))
+	assert.Equal(t, "# Переезд\n"+
+		"- [x] упаковать книги\n"+
+		"* [x] заказать машину\n"+
+		"  1. [ ] вложенный пункт\n"+
+		"```\n"+
+		"- [ ] это код, а не пункт\n"+
+		"```\n"+
+		"- [] без пробела не пункт\n"+
+		"+ [ ] сдать ключи", todo.Description)
+
+	// повторная отметка ничего не меняет
+	before := todo.Description
+	require.NoError(t, todo.SetChecklistItem(1, true))
+	assert.Equal(t, before, todo.Description)
+
+	assert.ErrorIs(t, todo.SetChecklistItem(4, true), errors.ErrChecklistNotFound)
+	assert.ErrorIs(t, todo.SetChecklistItem(-1, true), errors.ErrChecklistNotFound)
+}
+
+func TestTodoJSONIncludesChecklist(t *testing.T) {
+	todo := entity.NewTodo("Переезд", time.Now())
+	todo.Description = "- [x] a\n- [ ] b"
+
+	data, err := json.Marshal(todo)
+	require.NoError(t, err)
+
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "Переезд", decoded["title"])
+	assert.Equal(t, "1/2", decoded["checklist"].(map[string]interface{})["progress"])
+	assert.NotContains(t, decoded, "description_html")
+}
+
+func TestDescriptionTooLong(t *testing.T) {
+	todo := entity.NewTodo("Переезд", time.Now())
+	todo.Description = string(make([]rune, entity.MaxDescriptionLength+1))
+	assert.ErrorIs(t, todo.Validate(), errors.ErrDescriptionTooLong)
+}
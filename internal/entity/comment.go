@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxCommentLength - ограничение длины комментария в символах
+const MaxCommentLength = 5000
+
+// Comment - комментарий под задачей. Хранилища отбирают комментарии по OwnerID - владельцу задачи, как и саму задачу,
+// а писать в общем списке может и участник, поэтому автор хранится отдельно.
+// Без авторизации у комментария, как и у задачи, нет ни владельца, ни автора
+type Comment struct {
+	ID        primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	TaskID    primitive.ObjectID  `bson:"task_id" json:"task_id"`
+	OwnerID   *primitive.ObjectID `bson:"owner_id,omitempty" json:"-"`
+	AuthorID  *primitive.ObjectID `bson:"author_id,omitempty" json:"author_id,omitempty"`
+	Body      string              `bson:"body" json:"body"`
+	CreatedAt time.Time           `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time           `bson:"updated_at" json:"updated_at"`
+}
+
+// CommentPage - страница комментариев задачи по порядку написания
+type CommentPage struct {
+	Comments []*Comment `json:"comments"`
+	// NextCursor пустой, если это последняя страница
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// NewComment - комментарий author к задаче todo
+func NewComment(todo *Todo, author *primitive.ObjectID, body string, now time.Time) (*Comment, error) {
+	body, err := normalizeCommentBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Comment{
+		TaskID:    todo.ID,
+		OwnerID:   todo.OwnerID,
+		AuthorID:  author,
+		Body:      body,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// Edit заменяет текст комментария
+func (c *Comment) Edit(body string, now time.Time) error {
+	body, err := normalizeCommentBody(body)
+	if err != nil {
+		return err
+	}
+	c.Body = body
+	c.UpdatedAt = now
+	return nil
+}
+
+// WrittenBy - комментарий написал userID, nil - комментарий без автора
+func (c *Comment) WrittenBy(userID *primitive.ObjectID) bool {
+	return sameID(c.AuthorID, userID)
+}
+
+// OwnedBy - комментарий под задачей ownerID, nil - под задачей без владельца
+func (c *Comment) OwnedBy(ownerID *primitive.ObjectID) bool {
+	return sameID(c.OwnerID, ownerID)
+}
+
+func normalizeCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > MaxCommentLength {
+		return "", errors.ErrInvalidComment
+	}
+	return body, nil
+}
@@ -0,0 +1,57 @@
+package entity_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNewComment(t *testing.T) {
+	ownerID, authorID := primitive.NewObjectID(), primitive.NewObjectID()
+	todo := &entity.Todo{ID: primitive.NewObjectID(), OwnerID: &ownerID}
+	now := time.Now()
+
+	comment, err := entity.NewComment(todo, &authorID, "  Купил, но не тот  ", now)
+	require.NoError(t, err)
+	assert.Equal(t, todo.ID, comment.TaskID)
+	assert.True(t, comment.OwnedBy(&ownerID))
+	assert.Equal(t, "Купил, но не тот", comment.Body)
+	assert.Equal(t, now, comment.UpdatedAt)
+	assert.True(t, comment.WrittenBy(&authorID))
+	assert.False(t, comment.WrittenBy(&ownerID))
+	assert.False(t, comment.WrittenBy(nil))
+
+	// без авторизации у комментария нет ни владельца, ни автора
+	comment, err = entity.NewComment(&entity.Todo{ID: todo.ID}, nil, "Купил", now)
+	require.NoError(t, err)
+	assert.True(t, comment.OwnedBy(nil))
+	assert.True(t, comment.WrittenBy(nil))
+	assert.False(t, comment.WrittenBy(&authorID))
+
+	for _, body := range []string{"", " \n ", strings.Repeat("я", entity.MaxCommentLength+1)} {
+		_, err := entity.NewComment(todo, &authorID, body, now)
+		assert.ErrorIs(t, err, errors.ErrInvalidComment)
+	}
+	_, err = entity.NewComment(todo, &authorID, strings.Repeat("я", entity.MaxCommentLength), now)
+	assert.NoError(t, err)
+}
+
+func TestEditComment(t *testing.T) {
+	created := time.Now()
+	comment := &entity.Comment{Body: "Купил", CreatedAt: created, UpdatedAt: created}
+
+	later := created.Add(time.Minute)
+	require.NoError(t, comment.Edit(" Купил два ", later))
+	assert.Equal(t, "Купил два", comment.Body)
+	assert.Equal(t, later, comment.UpdatedAt)
+	assert.Equal(t, created, comment.CreatedAt)
+
+	assert.ErrorIs(t, comment.Edit("", later), errors.ErrInvalidComment)
+	assert.Equal(t, "Купил два", comment.Body)
+}
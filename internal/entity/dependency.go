@@ -0,0 +1,131 @@
+package entity
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxDependencies - ограничение на число задач в depends_on
+const MaxDependencies = 50
+
+// ParseIDs разбирает hex-строки ObjectID, повторы отбрасываются с сохранением порядка
+func ParseIDs(values []string) ([]primitive.ObjectID, error) {
+	ids := make([]primitive.ObjectID, 0, len(values))
+	seen := make(map[primitive.ObjectID]bool, len(values))
+	for _, value := range values {
+		id, err := primitive.ObjectIDFromHex(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errors.ErrInvalidID, value)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// HasOpenDependencies - у задачи есть незакрытые зависимости. BlockedBy заполняет сервис при чтении
+func (t *Todo) HasOpenDependencies() bool {
+	return len(t.BlockedBy) > 0
+}
+
+// DependsOnTask - задача зависит от id
+func (t *Todo) DependsOnTask(id primitive.ObjectID) bool {
+	for _, dependency := range t.DependsOn {
+		if dependency == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Todo) validateDependsOn() error {
+	if len(t.DependsOn) > MaxDependencies {
+		return fmt.Errorf("%w: depends_on 0..%d", errors.ErrInvalidFieldValue, MaxDependencies)
+	}
+	// ID нет только у еще не созданной задачи, от нее пока никто не зависит
+	if !t.ID.IsZero() && t.DependsOnTask(t.ID) {
+		return fmt.Errorf("%w: %s -> %s", errors.ErrDependencyCycle, t.ID.Hex(), t.ID.Hex())
+	}
+	return nil
+}
+
+// PlanOrder упорядочивает задачи так, чтобы каждая шла после своих зависимостей (сортировка Кана).
+// Учитываются только зависимости внутри todos. Из нескольких готовых задач первой идет та, у которой раньше
+// active_at, при равенстве - меньше ID, поэтому порядок не зависит от порядка todos
+func PlanOrder(todos []*Todo) ([]*Todo, error) {
+	byID := make(map[primitive.ObjectID]*Todo, len(todos))
+	for _, todo := range todos {
+		byID[todo.ID] = todo
+	}
+
+	waiting := make(map[primitive.ObjectID]int, len(byID))
+	dependents := make(map[primitive.ObjectID][]*Todo, len(byID))
+	ready := &planQueue{}
+	for _, todo := range byID {
+		for _, dependency := range todo.DependsOn {
+			if _, ok := byID[dependency]; ok && dependency != todo.ID {
+				waiting[todo.ID]++
+				dependents[dependency] = append(dependents[dependency], todo)
+			}
+		}
+		if waiting[todo.ID] == 0 {
+			*ready = append(*ready, todo)
+		}
+	}
+	heap.Init(ready)
+
+	plan := make([]*Todo, 0, len(byID))
+	for ready.Len() > 0 {
+		todo := heap.Pop(ready).(*Todo)
+		plan = append(plan, todo)
+		for _, dependent := range dependents[todo.ID] {
+			waiting[dependent.ID]--
+			if waiting[dependent.ID] == 0 {
+				heap.Push(ready, dependent)
+			}
+		}
+	}
+
+	if len(plan) < len(byID) {
+		// остались задачи из цикла и те, что от него зависят
+		var stuck []string
+		for id, count := range waiting {
+			if count > 0 {
+				stuck = append(stuck, id.Hex())
+			}
+		}
+		sort.Strings(stuck)
+		return nil, fmt.Errorf("%w: %s", errors.ErrDependencyCycle, strings.Join(stuck, ", "))
+	}
+	return plan, nil
+}
+
+// planQueue - готовые к выполнению задачи по (active_at, ID)
+type planQueue []*Todo
+
+func (q planQueue) Len() int { return len(q) }
+
+func (q planQueue) Less(i, j int) bool {
+	if !q[i].ActiveAt.Equal(q[j].ActiveAt) {
+		return q[i].ActiveAt.Before(q[j].ActiveAt)
+	}
+	return q[i].ID.Hex() < q[j].ID.Hex()
+}
+
+func (q planQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *planQueue) Push(x interface{}) { *q = append(*q, x.(*Todo)) }
+
+func (q *planQueue) Pop() interface{} {
+	old := *q
+	todo := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return todo
+}
@@ -0,0 +1,84 @@
+package entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseIDs(t *testing.T) {
+	first, second := primitive.NewObjectID(), primitive.NewObjectID()
+
+	ids, err := entity.ParseIDs([]string{first.Hex(), " " + second.Hex(), first.Hex()})
+	require.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{first, second}, ids)
+
+	_, err = entity.ParseIDs([]string{first.Hex(), "1"})
+	assert.ErrorIs(t, err, errors.ErrInvalidID)
+}
+
+func TestParseDependsOnPatch(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	patch, err := entity.ParseTodoMergePatch([]byte(`{"depends_on": ["` + id.Hex() + `"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{id}, *patch.DependsOn)
+
+	// null убирает все зависимости
+	patch, err = entity.ParseTodoMergePatch([]byte(`{"depends_on": null}`))
+	require.NoError(t, err)
+	assert.Empty(t, *patch.DependsOn)
+	assert.False(t, patch.IsEmpty())
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"depends_on": ["nope"]}`))
+	assert.ErrorIs(t, err, errors.ErrInvalidID)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"blocked_by": []}`))
+	assert.ErrorIs(t, err, errors.ErrFieldImmutable)
+}
+
+func TestApplyRejectsSelfDependency(t *testing.T) {
+	todo := entity.NewTodo("Переезд", time.Now())
+	todo.ID = primitive.NewObjectID()
+
+	dependsOn := []primitive.ObjectID{todo.ID}
+	err := (&entity.TodoPatch{DependsOn: &dependsOn}).Apply(todo)
+	assert.ErrorIs(t, err, errors.ErrDependencyCycle)
+}
+
+func TestPlanOrder(t *testing.T) {
+	day := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	task := func(title string, activeAt time.Time, dependsOn ...*entity.Todo) *entity.Todo {
+		todo := entity.NewTodo(title, activeAt)
+		todo.ID = primitive.NewObjectID()
+		for _, dependency := range dependsOn {
+			todo.DependsOn = append(todo.DependsOn, dependency.ID)
+		}
+		return todo
+	}
+
+	boxes := task("Купить коробки", day.AddDate(0, 0, 2))
+	pack := task("Упаковать вещи", day, boxes)
+	truck := task("Заказать машину", day.AddDate(0, 0, 1))
+	move := task("Переезд", day, pack, truck)
+	// зависимость вне набора не учитывается
+	other := task("Сдать ключи", day, task("Не из плана", day))
+
+	plan, err := entity.PlanOrder([]*entity.Todo{move, pack, other, truck, boxes})
+	require.NoError(t, err)
+
+	var order []string
+	for _, todo := range plan {
+		order = append(order, todo.Title)
+	}
+	assert.Equal(t, []string{"Сдать ключи", "Заказать машину", "Купить коробки", "Упаковать вещи", "Переезд"}, order)
+
+	boxes.DependsOn = []primitive.ObjectID{move.ID}
+	_, err = entity.PlanOrder([]*entity.Todo{move, pack, truck, boxes})
+	assert.ErrorIs(t, err, errors.ErrDependencyCycle)
+}
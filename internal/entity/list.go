@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultListID - :listID списка по умолчанию в URL. Этот список не хранится, у его задач list_id нет
+const DefaultListID = "default"
+
+// MaxListNameLength - ограничение на длину имени списка в символах
+const MaxListNameLength = 100
+
+// List - список задач. Задачи ссылаются на него через Todo.ListID
+type List struct {
+	ID   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name string             `bson:"name" json:"name"`
+	// OwnerID - владелец списка, имя уникально среди его списков
+	OwnerID *primitive.ObjectID `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	// ArchivedAt заполнен у архивного списка. Задачи архивного списка можно только читать
+	ArchivedAt *time.Time `bson:"archived_at,omitempty" json:"archived_at,omitempty"`
+	// Role - роль текущего пользователя в списке, заполняет сервис
+	Role      Role      `bson:"-" json:"role,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// MarshalJSON добавляет к списку признак archived
+func (l List) MarshalJSON() ([]byte, error) {
+	type listJSON List
+	return json.Marshal(struct {
+		listJSON
+		Archived bool `json:"archived"`
+	}{listJSON(l), l.IsArchived()})
+}
+
+// ListUpdate - изменения списка из PATCH /lists/:listID, nil - поле не меняется
+type ListUpdate struct {
+	Name     *string
+	Archived *bool
+}
+
+func NewList(name string) *List {
+	return &List{
+		Name:      strings.TrimSpace(name),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+}
+
+func (l *List) IsArchived() bool {
+	return l.ArchivedAt != nil
+}
+
+// Apply применяет изменения к списку. Повторная архивация не сдвигает archived_at
+func (l *List) Apply(update ListUpdate, now time.Time) {
+	if update.Name != nil {
+		l.Name = strings.TrimSpace(*update.Name)
+	}
+	if update.Archived != nil {
+		switch {
+		case *update.Archived && !l.IsArchived():
+			l.ArchivedAt = &now
+		case !*update.Archived:
+			l.ArchivedAt = nil
+		}
+	}
+	l.UpdatedAt = now
+}
+
+func (l *List) Validate() error {
+	if l.Name == "" || utf8.RuneCountInString(l.Name) > MaxListNameLength {
+		return errors.ErrInvalidListName
+	}
+	return nil
+}
+
+// ParseListID разбирает :listID из URL: DefaultListID - список по умолчанию (nil), иначе ObjectID
+func ParseListID(value string) (*primitive.ObjectID, error) {
+	if value == DefaultListID {
+		return nil, nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(value)
+	if err != nil {
+		return nil, errors.ErrInvalidID
+	}
+	return &id, nil
+}
+
+// InList - задача лежит в списке listID, nil - список по умолчанию
+func (t *Todo) InList(listID *primitive.ObjectID) bool {
+	return sameID(t.ListID, listID)
+}
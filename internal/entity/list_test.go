@@ -0,0 +1,62 @@
+package entity_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseListID(t *testing.T) {
+	listID, err := entity.ParseListID(entity.DefaultListID)
+	require.NoError(t, err)
+	assert.Nil(t, listID)
+
+	id := primitive.NewObjectID()
+	listID, err = entity.ParseListID(id.Hex())
+	require.NoError(t, err)
+	assert.Equal(t, &id, listID)
+
+	_, err = entity.ParseListID("Default")
+	assert.ErrorIs(t, err, errors.ErrInvalidID)
+}
+
+func TestListApply(t *testing.T) {
+	list := entity.NewList(" Работа ")
+	require.NoError(t, list.Validate())
+	assert.Equal(t, "Работа", list.Name)
+
+	archivedAt := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	archived := true
+	list.Apply(entity.ListUpdate{Archived: &archived}, archivedAt)
+	assert.True(t, list.IsArchived())
+
+	// повторная архивация не сдвигает дату
+	list.Apply(entity.ListUpdate{Archived: &archived}, archivedAt.AddDate(0, 0, 1))
+	assert.Equal(t, archivedAt, *list.ArchivedAt)
+
+	name := strings.Repeat("я", entity.MaxListNameLength+1)
+	archived = false
+	list.Apply(entity.ListUpdate{Name: &name, Archived: &archived}, archivedAt)
+	assert.False(t, list.IsArchived())
+	assert.ErrorIs(t, list.Validate(), errors.ErrInvalidListName)
+}
+
+func TestTodoInList(t *testing.T) {
+	id := primitive.NewObjectID()
+	other := primitive.NewObjectID()
+	todo := entity.NewTodo("Отчет", time.Now())
+
+	assert.True(t, todo.InList(nil))
+	assert.False(t, todo.InList(&id))
+
+	todo.ListID = &id
+	assert.True(t, todo.InList(&id))
+	assert.False(t, todo.InList(&other))
+	assert.False(t, todo.InList(nil))
+}
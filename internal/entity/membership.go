@@ -0,0 +1,106 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Role - роль пользователя в списке
+type Role string
+
+const (
+	// RoleOwner - владелец списка. Роль не хранится: владелец записан в самом списке
+	RoleOwner Role = "owner"
+	// RoleEditor создает, меняет и удаляет задачи списка
+	RoleEditor Role = "editor"
+	// RoleViewer только читает задачи списка
+	RoleViewer Role = "viewer"
+)
+
+// InvitationTTL - сколько действует приглашение в список
+const InvitationTTL = 7 * 24 * time.Hour
+
+// ParseMemberRole проверяет роль, которую владелец выдает участнику. Владельцем участника сделать нельзя
+func ParseMemberRole(value string) (Role, error) {
+	switch role := Role(value); role {
+	case RoleEditor, RoleViewer:
+		return role, nil
+	default:
+		return "", fmt.Errorf("%w: %s", errors.ErrInvalidRole, value)
+	}
+}
+
+// CanEdit - роль позволяет менять задачи списка
+func (r Role) CanEdit() bool {
+	return r == RoleOwner || r == RoleEditor
+}
+
+// Membership - участие пользователя в чужом списке
+type Membership struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ListID primitive.ObjectID `bson:"list_id" json:"list_id"`
+	// OwnerID - владелец списка. Задачи списка принадлежат ему, участник работает с ними от его имени
+	OwnerID primitive.ObjectID `bson:"owner_id" json:"-"`
+	UserID  primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Role    Role               `bson:"role" json:"role"`
+	// Email и Username участника заполняет сервис, в хранилище их нет
+	Email     string    `bson:"-" json:"email,omitempty"`
+	Username  string    `bson:"-" json:"username,omitempty"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// OwnedBy - участие в списке владельца ownerID. У участия владелец есть всегда
+func (m *Membership) OwnedBy(ownerID *primitive.ObjectID) bool {
+	return ownerID != nil && m.OwnerID == *ownerID
+}
+
+// Invitation - приглашение в список. Как и у ключа API, хранится только хеш токена.
+// Приглашение одноразовое: при принятии оно удаляется
+type Invitation struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	ListID    primitive.ObjectID `bson:"list_id" json:"list_id"`
+	OwnerID   primitive.ObjectID `bson:"owner_id" json:"-"`
+	Role      Role               `bson:"role" json:"role"`
+	Hash      string             `bson:"hash" json:"-"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+func NewInvitation(list *List, role Role, now time.Time) *Invitation {
+	invitation := &Invitation{ListID: list.ID, Role: role, ExpiresAt: now.Add(InvitationTTL), CreatedAt: now}
+	if list.OwnerID != nil {
+		invitation.OwnerID = *list.OwnerID
+	}
+	return invitation
+}
+
+func (i *Invitation) Expired(now time.Time) bool {
+	return !now.Before(i.ExpiresAt)
+}
+
+// Access - доступ запроса к чужому списку по участию в нем. Владелец в контексте при этом - владелец списка,
+// поэтому хранилища видят его задачи, а что из них доступно участнику, решает сервис
+type Access struct {
+	ListID primitive.ObjectID
+	UserID primitive.ObjectID
+	Role   Role
+}
+
+type accessKey struct{}
+
+func WithAccess(ctx context.Context, access Access) context.Context {
+	return context.WithValue(ctx, accessKey{}, access)
+}
+
+// AccessFromContext - доступ из WithAccess, nil если запрос работает со своими данными
+func AccessFromContext(ctx context.Context) *Access {
+	access, ok := ctx.Value(accessKey{}).(Access)
+	if !ok {
+		return nil
+	}
+	return &access
+}
@@ -0,0 +1,50 @@
+package entity_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseMemberRole(t *testing.T) {
+	role, err := entity.ParseMemberRole("viewer")
+	require.NoError(t, err)
+	assert.Equal(t, entity.RoleViewer, role)
+	assert.False(t, role.CanEdit())
+
+	role, err = entity.ParseMemberRole("editor")
+	require.NoError(t, err)
+	assert.True(t, role.CanEdit())
+	assert.True(t, entity.RoleOwner.CanEdit())
+
+	_, err = entity.ParseMemberRole("owner")
+	assert.ErrorIs(t, err, errors.ErrInvalidRole)
+	_, err = entity.ParseMemberRole("")
+	assert.ErrorIs(t, err, errors.ErrInvalidRole)
+}
+
+func TestNewInvitation(t *testing.T) {
+	owner := primitive.NewObjectID()
+	list := &entity.List{ID: primitive.NewObjectID(), OwnerID: &owner}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	invitation := entity.NewInvitation(list, entity.RoleEditor, now)
+	assert.Equal(t, list.ID, invitation.ListID)
+	assert.Equal(t, owner, invitation.OwnerID)
+	assert.False(t, invitation.Expired(now.Add(entity.InvitationTTL-time.Second)))
+	assert.True(t, invitation.Expired(now.Add(entity.InvitationTTL)))
+}
+
+func TestAccessFromContext(t *testing.T) {
+	assert.Nil(t, entity.AccessFromContext(context.Background()))
+
+	access := entity.Access{ListID: primitive.NewObjectID(), UserID: primitive.NewObjectID(), Role: entity.RoleViewer}
+	ctx := entity.WithAccess(context.Background(), access)
+	assert.Equal(t, &access, entity.AccessFromContext(ctx))
+}
@@ -0,0 +1,29 @@
+package entity
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mentionPattern - @username в начале текста или после символа, который не может быть частью адреса:
+// так alice@example.com упоминанием не считается. \b не дает взять начало слишком длинного имени
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@])@([A-Za-z0-9_]{3,32})\b`)
+
+// ParseMentions возвращает имена из упоминаний @username в text: в нижнем регистре, без повторов, в порядке появления
+func ParseMentions(text string) []string {
+	var usernames []string
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.ToLower(match[1])
+		if !seen[username] {
+			seen[username] = true
+			usernames = append(usernames, username)
+		}
+	}
+	return usernames
+}
+
+// Mentions - упоминания в заголовке и описании задачи
+func (t *Todo) Mentions() []string {
+	return ParseMentions(t.Title + "\n" + t.Description)
+}
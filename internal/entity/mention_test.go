@@ -0,0 +1,36 @@
+package entity_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"@alice купи молоко", []string{"alice"}},
+		{"спроси @Bob и @alice, потом снова @bob", []string{"bob", "alice"}},
+		{"(@carol_1)", []string{"carol_1"}},
+		// адрес почты и слишком короткие или длинные имена упоминаниями не считаются
+		{"пиши на alice@example.com", nil},
+		{"@al и @" + strings.Repeat("a", 33), nil},
+		{"@@alice", nil},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, entity.ParseMentions(tt.text), tt.text)
+	}
+}
+
+func TestTodoMentions(t *testing.T) {
+	todo := entity.NewTodo("Отчет для @alice", time.Now())
+	todo.Description = "- [ ] согласовать с @bob\n- [ ] отправить @alice"
+	assert.Equal(t, []string{"alice", "bob"}, todo.Mentions())
+}
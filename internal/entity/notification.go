@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NotificationKind - событие, о котором уведомляет запись во входящих
+type NotificationKind string
+
+const (
+	// NotificationAssigned - пользователя назначили исполнителем задачи
+	NotificationAssigned NotificationKind = "assigned"
+	// NotificationMentioned - пользователя упомянули в заголовке или описании задачи
+	NotificationMentioned NotificationKind = "mentioned"
+	// NotificationTaskChanged - кто-то другой изменил задачу, исполнитель которой - пользователь
+	NotificationTaskChanged NotificationKind = "task_changed"
+	// NotificationTaskDeleted - кто-то другой удалил задачу, исполнитель которой - пользователь
+	NotificationTaskDeleted NotificationKind = "task_deleted"
+)
+
+// Notification - запись во входящих пользователя. Заголовок задачи сохраняется на момент события,
+// поэтому запись остается понятной и после переименования или удаления задачи
+type Notification struct {
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	// OwnerID - получатель уведомления
+	OwnerID primitive.ObjectID  `bson:"owner_id" json:"-"`
+	Kind    NotificationKind    `bson:"kind" json:"kind"`
+	TaskID  primitive.ObjectID  `bson:"task_id" json:"task_id"`
+	ListID  *primitive.ObjectID `bson:"list_id,omitempty" json:"list_id,omitempty"`
+	// ActorID - пользователь, который назначил, упомянул или изменил задачу
+	ActorID   primitive.ObjectID `bson:"actor_id" json:"actor_id"`
+	Title     string             `bson:"title" json:"title"`
+	ReadAt    *time.Time         `bson:"read_at,omitempty" json:"read_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// NewNotification - непрочитанное уведомление recipient о событии kind с задачей todo
+func NewNotification(kind NotificationKind, todo *Todo, recipient, actor primitive.ObjectID, now time.Time) *Notification {
+	return &Notification{
+		OwnerID:   recipient,
+		Kind:      kind,
+		TaskID:    todo.ID,
+		ListID:    todo.ListID,
+		ActorID:   actor,
+		Title:     todo.Title,
+		CreatedAt: now,
+	}
+}
+
+// MarshalJSON добавляет признак read, чтобы клиенту не приходилось проверять read_at
+func (n Notification) MarshalJSON() ([]byte, error) {
+	type notificationJSON Notification
+	return json.Marshal(struct {
+		notificationJSON
+		Read bool `json:"read"`
+	}{notificationJSON(n), n.IsRead()})
+}
+
+func (n *Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
+
+// OwnedBy - уведомление адресовано ownerID
+func (n *Notification) OwnedBy(ownerID *primitive.ObjectID) bool {
+	return ownerID != nil && n.OwnerID == *ownerID
+}
@@ -0,0 +1,35 @@
+package entity_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNotificationJSON(t *testing.T) {
+	todo := entity.NewTodo("Отчет", time.Now())
+	todo.ID = primitive.NewObjectID()
+	recipient, actor := primitive.NewObjectID(), primitive.NewObjectID()
+
+	notification := entity.NewNotification(entity.NotificationAssigned, todo, recipient, actor, time.Now())
+	assert.Equal(t, todo.ID, notification.TaskID)
+	assert.Equal(t, "Отчет", notification.Title)
+
+	data, err := json.Marshal(notification)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"kind":"assigned"`)
+	assert.Contains(t, string(data), `"read":false`)
+	// получатель и так знает, что уведомление ему
+	assert.NotContains(t, string(data), recipient.Hex())
+
+	readAt := time.Now()
+	notification.ReadAt = &readAt
+	data, err = json.Marshal(notification)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"read":true`)
+}
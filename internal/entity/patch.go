@@ -0,0 +1,242 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MergePatchContentType - media type JSON Merge Patch из RFC 7396
+const MergePatchContentType = "application/merge-patch+json"
+
+// TodoPatch - изменения задачи из JSON Merge Patch. nil означает, что поле в патче не указано и не меняется
+type TodoPatch struct {
+	Title       *string
+	Description *string
+	Status      *TaskStatus
+	Priority    *Priority
+	ActiveAt    *time.Time
+	// AssigneeID - новый исполнитель, primitive.NilObjectID снимает исполнителя
+	AssigneeID *primitive.ObjectID
+	DependsOn  *[]primitive.ObjectID
+	Tags       *[]string
+	RRule      *string
+}
+
+// ParseTodoMergePatch разбирает JSON Merge Patch поверх JSON-представления Todo.
+// Менять можно title, description, status, priority, active_at, assignee_id, depends_on, tags и rrule; id, created_at, updated_at и completed_at задает сервер.
+// null по RFC 7396 означает удаление поля: description, assignee_id, depends_on, tags и rrule при этом очищаются, для обязательных полей null - ошибка
+func ParseTodoMergePatch(data []byte) (*TodoPatch, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return nil, errors.ErrInvalidPatch
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, errors.ErrInvalidPatch
+	}
+
+	patch := &TodoPatch{}
+	for name, raw := range fields {
+		switch name {
+		case "title":
+			var title string
+			if err := unmarshalField(name, raw, &title); err != nil {
+				return nil, err
+			}
+			patch.Title = &title
+		case "description":
+			// описание необязательное, поэтому null его очищает
+			var description string
+			if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				if err := unmarshalField(name, raw, &description); err != nil {
+					return nil, err
+				}
+			}
+			patch.Description = &description
+		case "status":
+			var value string
+			if err := unmarshalField(name, raw, &value); err != nil {
+				return nil, err
+			}
+			status, err := ParseTaskStatus(value)
+			if err != nil {
+				return nil, err
+			}
+			patch.Status = &status
+		case "priority":
+			var value string
+			if err := unmarshalField(name, raw, &value); err != nil {
+				return nil, err
+			}
+			priority := Priority(value)
+			if !priority.IsValid() {
+				return nil, fmt.Errorf("%w: %s", errors.ErrInvalidPriority, value)
+			}
+			patch.Priority = &priority
+		case "active_at":
+			var value string
+			if err := unmarshalField(name, raw, &value); err != nil {
+				return nil, err
+			}
+			activeAt, err := parseActiveAt(value)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", errors.ErrInvalidFieldValue, name)
+			}
+			patch.ActiveAt = &activeAt
+		case "assignee_id":
+			// null снимает исполнителя
+			assigneeID := primitive.NilObjectID
+			if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				var value string
+				if err := unmarshalField(name, raw, &value); err != nil {
+					return nil, err
+				}
+				id, err := primitive.ObjectIDFromHex(value)
+				if err != nil || id.IsZero() {
+					return nil, fmt.Errorf("%w: %s", errors.ErrInvalidID, value)
+				}
+				assigneeID = id
+			}
+			patch.AssigneeID = &assigneeID
+		case "depends_on":
+			// массив заменяется целиком, null убирает все зависимости
+			var values []string
+			if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				if err := unmarshalField(name, raw, &values); err != nil {
+					return nil, err
+				}
+			}
+			dependsOn, err := ParseIDs(values)
+			if err != nil {
+				return nil, err
+			}
+			patch.DependsOn = &dependsOn
+		case "tags":
+			// как и depends_on, метки заменяются целиком
+			var values []string
+			if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				if err := unmarshalField(name, raw, &values); err != nil {
+					return nil, err
+				}
+			}
+			tags, err := NormalizeTags(values)
+			if err != nil {
+				return nil, err
+			}
+			patch.Tags = &tags
+		case "rrule":
+			// null или пустая строка - задача больше не повторяется
+			var value string
+			if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+				if err := unmarshalField(name, raw, &value); err != nil {
+					return nil, err
+				}
+			}
+			rule, err := NormalizeRRule(value)
+			if err != nil {
+				return nil, err
+			}
+			patch.RRule = &rule
+		case "id", "created_at", "updated_at", "completed_at", "checklist", "description_html", "parent_id", "list_id", "owner_id", "subtasks", "has_open_dependencies", "blocked_by", "series_start", "comment_count":
+			return nil, fmt.Errorf("%w: %s", errors.ErrFieldImmutable, name)
+		default:
+			return nil, fmt.Errorf("%w: %s", errors.ErrUnknownField, name)
+		}
+	}
+
+	return patch, nil
+}
+
+func unmarshalField(name string, raw json.RawMessage, dst interface{}) error {
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return fmt.Errorf("%w: %s", errors.ErrInvalidFieldValue, name)
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return fmt.Errorf("%w: %s", errors.ErrInvalidFieldValue, name)
+	}
+	return nil
+}
+
+// parseActiveAt принимает как полный RFC 3339, так и дату в формате создания задачи
+func parseActiveAt(value string) (time.Time, error) {
+	if activeAt, err := time.Parse(time.RFC3339, value); err == nil {
+		return activeAt, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// IsEmpty - в патче нет ни одного изменения
+func (p *TodoPatch) IsEmpty() bool {
+	return p.Title == nil && p.Description == nil && p.Status == nil && p.Priority == nil && p.ActiveAt == nil && p.AssigneeID == nil &&
+		p.DependsOn == nil && p.Tags == nil && p.RRule == nil
+}
+
+// Apply применяет патч к задаче и проверяет измененные поля теми же правилами, что и Validate.
+// Неизмененные поля не проверяются, иначе у задачи с прошедшей датой нельзя было бы поменять даже заголовок.
+// Статус меняется только по таблице переходов; тот же статус, что уже стоит, переходом не считается
+func (p *TodoPatch) Apply(t *Todo) error {
+	if p.Title != nil {
+		t.Title = *p.Title
+		if err := t.validateTitle(); err != nil {
+			return err
+		}
+	}
+	if p.Description != nil {
+		t.Description = *p.Description
+		if err := t.validateDescription(); err != nil {
+			return err
+		}
+	}
+	if p.Priority != nil {
+		t.Priority = *p.Priority
+		if err := t.validatePriority(); err != nil {
+			return err
+		}
+	}
+	if p.AssigneeID != nil {
+		t.AssigneeID = nil
+		if !p.AssigneeID.IsZero() {
+			assigneeID := *p.AssigneeID
+			t.AssigneeID = &assigneeID
+		}
+	}
+	if p.DependsOn != nil {
+		t.DependsOn = *p.DependsOn
+		if err := t.validateDependsOn(); err != nil {
+			return err
+		}
+	}
+	if p.Tags != nil {
+		t.Tags = *p.Tags
+		if err := t.validateTags(); err != nil {
+			return err
+		}
+	}
+	if p.ActiveAt != nil {
+		t.ActiveAt = *p.ActiveAt
+		if err := t.validateActiveAt(); err != nil {
+			return err
+		}
+	}
+	// новое правило начинает серию заново с active_at задачи
+	if p.RRule != nil && *p.RRule != t.RRule {
+		t.setRRule(*p.RRule)
+		if err := t.validateRRule(); err != nil {
+			return err
+		}
+	}
+	if p.Status != nil && *p.Status != t.Status {
+		if err := t.TransitionTo(*p.Status); err != nil {
+			return err
+		}
+	}
+
+	t.UpdatedAt = time.Now()
+	return nil
+}
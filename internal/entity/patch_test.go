@@ -0,0 +1,100 @@
+package entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseTodoMergePatch(t *testing.T) {
+	patch, err := entity.ParseTodoMergePatch([]byte(`{"title": "New title", "status": "in_progress", "active_at": "2030-01-02"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "New title", *patch.Title)
+	assert.Equal(t, entity.StatusInProgress, *patch.Status)
+	assert.True(t, patch.ActiveAt.Equal(time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)))
+
+	// отсутствующие поля не меняются
+	patch, err = entity.ParseTodoMergePatch([]byte(`{"status": "done"}`))
+	require.NoError(t, err)
+	assert.Nil(t, patch.Title)
+	assert.Nil(t, patch.ActiveAt)
+	assert.Equal(t, entity.StatusDone, *patch.Status)
+
+	// null очищает необязательное описание
+	patch, err = entity.ParseTodoMergePatch([]byte(`{"description": null}`))
+	require.NoError(t, err)
+	assert.Equal(t, "", *patch.Description)
+
+	patch, err = entity.ParseTodoMergePatch([]byte(`{}`))
+	require.NoError(t, err)
+	assert.True(t, patch.IsEmpty())
+}
+
+func TestParseTodoMergePatchErrors(t *testing.T) {
+	_, err := entity.ParseTodoMergePatch([]byte(`["title"]`))
+	assert.ErrorIs(t, err, errors.ErrInvalidPatch)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"title": null}`))
+	assert.ErrorIs(t, err, errors.ErrInvalidFieldValue)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"status": true}`))
+	assert.ErrorIs(t, err, errors.ErrInvalidFieldValue)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"status": "finished"}`))
+	assert.ErrorIs(t, err, errors.ErrInvalidStatus)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"completed_at": "2030-01-02T00:00:00Z"}`))
+	assert.ErrorIs(t, err, errors.ErrFieldImmutable)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"id": "64f0c0ffee"}`))
+	assert.ErrorIs(t, err, errors.ErrFieldImmutable)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"checklist": {}}`))
+	assert.ErrorIs(t, err, errors.ErrFieldImmutable)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"colour": "red"}`))
+	assert.ErrorIs(t, err, errors.ErrUnknownField)
+}
+
+func TestTodoPatchApply(t *testing.T) {
+	// задача с прошедшей датой: менять заголовок можно, дату проверяем только если она в патче
+	todo := &entity.Todo{Title: "Old", ActiveAt: time.Now().Add(-48 * time.Hour)}
+
+	title := "New"
+	require.NoError(t, (&entity.TodoPatch{Title: &title}).Apply(todo))
+	assert.Equal(t, "New", todo.Title)
+
+	past := time.Now().Add(-24 * time.Hour)
+	assert.ErrorIs(t, (&entity.TodoPatch{ActiveAt: &past}).Apply(todo), errors.ErrDateNotCurrent)
+
+	// статус в патче идет через таблицу переходов
+	todo.Status = entity.StatusCancelled
+	done := entity.StatusDone
+	assert.ErrorIs(t, (&entity.TodoPatch{Status: &done}).Apply(todo), errors.ErrInvalidTransition)
+}
+
+func TestTodoPatchAssignee(t *testing.T) {
+	assigneeID := primitive.NewObjectID()
+	patch, err := entity.ParseTodoMergePatch([]byte(`{"assignee_id": "` + assigneeID.Hex() + `"}`))
+	require.NoError(t, err)
+	assert.False(t, patch.IsEmpty())
+
+	todo := entity.NewTodo("Задача", time.Now())
+	require.NoError(t, patch.Apply(todo))
+	require.NotNil(t, todo.AssigneeID)
+	assert.Equal(t, assigneeID, *todo.AssigneeID)
+
+	// null снимает исполнителя
+	patch, err = entity.ParseTodoMergePatch([]byte(`{"assignee_id": null}`))
+	require.NoError(t, err)
+	require.NoError(t, patch.Apply(todo))
+	assert.Nil(t, todo.AssigneeID)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"assignee_id": "bob"}`))
+	assert.ErrorIs(t, err, errors.ErrInvalidID)
+}
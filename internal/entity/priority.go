@@ -0,0 +1,145 @@
+package entity
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+)
+
+// Priority - важность задачи
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityNormal Priority = "normal"
+	PriorityHigh   Priority = "high"
+	PriorityUrgent Priority = "urgent"
+)
+
+// priorityLevels - уровни приоритетов для ранжирования, от low = 0 до urgent = 3
+var priorityLevels = map[Priority]int{
+	PriorityLow:    0,
+	PriorityNormal: 1,
+	PriorityHigh:   2,
+	PriorityUrgent: 3,
+}
+
+// ParsePriority проверяет, что строка - один из известных приоритетов. Пустая строка - normal
+func ParsePriority(value string) (Priority, error) {
+	if value == "" {
+		return PriorityNormal, nil
+	}
+	priority := Priority(value)
+	if !priority.IsValid() {
+		return "", fmt.Errorf("%w: %s", errors.ErrInvalidPriority, value)
+	}
+	return priority, nil
+}
+
+func (p Priority) IsValid() bool {
+	_, ok := priorityLevels[p]
+	return ok
+}
+
+// Level - уровень приоритета. У задач, сохраненных до появления приоритетов, он normal
+func (p Priority) Level() int {
+	if level, ok := priorityLevels[p]; ok {
+		return level
+	}
+	return priorityLevels[PriorityNormal]
+}
+
+// validatePriority пропускает пустой приоритет: он, как и у старых задач, считается normal
+func (t *Todo) validatePriority() error {
+	if t.Priority != "" && !t.Priority.IsValid() {
+		return fmt.Errorf("%w: %s", errors.ErrInvalidPriority, t.Priority)
+	}
+	return nil
+}
+
+// RankWeights - веса слагаемых оценки задачи в GET /tasks/next
+type RankWeights struct {
+	// Priority - очков за уровень приоритета
+	Priority float64
+	// Overdue - очков за каждый день, прошедший с active_at
+	Overdue float64
+	// Age - очков за каждый день, прошедший с created_at
+	Age float64
+}
+
+// DefaultRankWeights - веса по умолчанию: шаг приоритета весит как пять дней просрочки или двадцать дней возраста
+func DefaultRankWeights() RankWeights {
+	return RankWeights{Priority: 10, Overdue: 2, Age: 0.5}
+}
+
+// Факторы оценки задачи
+const (
+	RankFactorPriority = "priority"
+	RankFactorOverdue  = "overdue"
+	RankFactorAge      = "age"
+)
+
+// RankFactor - одно слагаемое оценки: Value (уровень приоритета или число дней) умножается на Weight
+type RankFactor struct {
+	Factor string  `json:"factor"`
+	Value  float64 `json:"value"`
+	Weight float64 `json:"weight"`
+	Points float64 `json:"points"`
+}
+
+// RankedTask - задача с оценкой и ее разложением по факторам
+type RankedTask struct {
+	Task        *Todo        `json:"task"`
+	Score       float64      `json:"score"`
+	Explanation []RankFactor `json:"explanation"`
+}
+
+// Rank оценивает задачу на момент now. Дни считаются дробными и не бывают отрицательными
+func (w RankWeights) Rank(todo *Todo, now time.Time) *RankedTask {
+	days := func(since time.Time) float64 {
+		return math.Max(0, now.Sub(since).Hours()/24)
+	}
+
+	ranked := &RankedTask{Task: todo}
+	for _, factor := range []RankFactor{
+		{Factor: RankFactorPriority, Value: float64(todo.Priority.Level()), Weight: w.Priority},
+		{Factor: RankFactorOverdue, Value: days(todo.ActiveAt), Weight: w.Overdue},
+		{Factor: RankFactorAge, Value: days(todo.CreatedAt), Weight: w.Age},
+	} {
+		factor.Value = round2(factor.Value)
+		factor.Points = round2(factor.Value * factor.Weight)
+		ranked.Score += factor.Points
+		ranked.Explanation = append(ranked.Explanation, factor)
+	}
+	ranked.Score = round2(ranked.Score)
+	return ranked
+}
+
+// RankTasks оценивает задачи и сортирует их по убыванию оценки; при равной оценке раньше идет задача
+// с более ранним active_at, затем с меньшим ID
+func (w RankWeights) RankTasks(todos []*Todo, now time.Time) []*RankedTask {
+	ranked := make([]*RankedTask, 0, len(todos))
+	for _, todo := range todos {
+		ranked = append(ranked, w.Rank(todo, now))
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.Task.ActiveAt.Equal(b.Task.ActiveAt) {
+			return a.Task.ActiveAt.Before(b.Task.ActiveAt)
+		}
+		return a.Task.ID.Hex() < b.Task.ID.Hex()
+	})
+	return ranked
+}
+
+// round2 округляет до сотых, чтобы в ответе не было хвостов вроде 0.30000000000000004
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
@@ -0,0 +1,99 @@
+package entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParsePriority(t *testing.T) {
+	priority, err := entity.ParsePriority("")
+	require.NoError(t, err)
+	assert.Equal(t, entity.PriorityNormal, priority)
+
+	priority, err = entity.ParsePriority("urgent")
+	require.NoError(t, err)
+	assert.Equal(t, 3, priority.Level())
+
+	_, err = entity.ParsePriority("URGENT")
+	assert.ErrorIs(t, err, errors.ErrInvalidPriority)
+
+	// задачи без приоритета считаются normal
+	assert.Equal(t, entity.PriorityNormal.Level(), entity.Priority("").Level())
+}
+
+func TestParsePriorityPatch(t *testing.T) {
+	patch, err := entity.ParseTodoMergePatch([]byte(`{"priority": "high"}`))
+	require.NoError(t, err)
+	assert.Equal(t, entity.PriorityHigh, *patch.Priority)
+
+	todo := entity.NewTodo("Отчет", time.Now())
+	require.NoError(t, patch.Apply(todo))
+	assert.Equal(t, entity.PriorityHigh, todo.Priority)
+
+	for _, body := range []string{`{"priority": "asap"}`, `{"priority": null}`, `{"priority": 1}`} {
+		_, err = entity.ParseTodoMergePatch([]byte(body))
+		assert.Error(t, err, body)
+	}
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC)
+	todo := &entity.Todo{
+		Priority:  entity.PriorityHigh,
+		ActiveAt:  time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC),
+		CreatedAt: time.Date(2030, 1, 5, 12, 0, 0, 0, time.UTC),
+	}
+
+	ranked := entity.RankWeights{Priority: 10, Overdue: 2, Age: 0.5}.Rank(todo, now)
+	assert.Equal(t, []entity.RankFactor{
+		{Factor: entity.RankFactorPriority, Value: 2, Weight: 10, Points: 20},
+		{Factor: entity.RankFactorOverdue, Value: 3.5, Weight: 2, Points: 7},
+		{Factor: entity.RankFactorAge, Value: 5, Weight: 0.5, Points: 2.5},
+	}, ranked.Explanation)
+	assert.Equal(t, 29.5, ranked.Score)
+
+	// задача из будущего не получает отрицательных очков
+	todo.ActiveAt, todo.CreatedAt = now.AddDate(0, 0, 1), now
+	ranked = entity.RankWeights{Priority: 10, Overdue: 2, Age: 0.5}.Rank(todo, now)
+	assert.Equal(t, 20.0, ranked.Score)
+}
+
+func TestRankTasks(t *testing.T) {
+	now := time.Date(2030, 1, 10, 0, 0, 0, 0, time.UTC)
+	task := func(title string, priority entity.Priority, overdueDays int) *entity.Todo {
+		return &entity.Todo{
+			ID:        primitive.NewObjectID(),
+			Title:     title,
+			Priority:  priority,
+			ActiveAt:  now.AddDate(0, 0, -overdueDays),
+			CreatedAt: now,
+		}
+	}
+	todos := []*entity.Todo{
+		task("Старая", entity.PriorityLow, 20),
+		task("Срочная", entity.PriorityUrgent, 0),
+		task("Обычная", entity.PriorityNormal, 1),
+		task("Тоже обычная", entity.PriorityNormal, 1),
+	}
+
+	titles := func(ranked []*entity.RankedTask) []string {
+		var result []string
+		for _, task := range ranked {
+			result = append(result, task.Task.Title)
+		}
+		return result
+	}
+
+	weights := entity.RankWeights{Priority: 10, Overdue: 2}
+	assert.Equal(t, []string{"Старая", "Срочная", "Обычная", "Тоже обычная"}, titles(weights.RankTasks(todos, now)))
+
+	// без веса просрочки решает только приоритет
+	weights.Overdue = 0
+	assert.Equal(t, []string{"Срочная", "Обычная", "Тоже обычная", "Старая"}, titles(weights.RankTasks(todos, now)))
+}
@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SortField - поле, по которому сортируются списки задач. Значения совпадают с именами полей в хранилищах
+type SortField string
+
+const (
+	SortActiveAt  SortField = "active_at"
+	SortCreatedAt SortField = "created_at"
+	SortUpdatedAt SortField = "updated_at"
+	SortTitle     SortField = "title"
+)
+
+// ListOptions - страница, сортировка и фильтры для списков задач
+type ListOptions struct {
+	// Limit - размер страницы, 0 - без ограничения
+	Limit int
+	// Cursor - непрозрачный курсор из NextCursor предыдущей страницы
+	Cursor     string
+	Sort       SortField
+	Descending bool
+
+	// Границы диапазонов включительные, nil - без ограничения
+	ActiveFrom  *time.Time
+	ActiveTo    *time.Time
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+
+	// ListID - список, из которого берутся задачи, nil - список по умолчанию
+	ListID *primitive.ObjectID
+	// ParentID оставляет только подзадачи этой задачи
+	ParentID *primitive.ObjectID
+	// TagsAny оставляет задачи хотя бы с одной из меток, TagsAll - со всеми метками
+	TagsAny []string
+	TagsAll []string
+	// IncludeBlocked - показывать в списке "active" задачи с незакрытыми зависимостями, по умолчанию их там нет
+	IncludeBlocked bool
+}
+
+// TodoPage - одна страница списка задач
+type TodoPage struct {
+	Tasks []*Todo `json:"tasks"`
+	// NextCursor пустой, если это последняя страница
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// ParseSort разбирает параметр sort: имя поля, "-" в начале - по убыванию
+func ParseSort(value string) (SortField, bool, error) {
+	descending := strings.HasPrefix(value, "-")
+	field := SortField(strings.TrimPrefix(value, "-"))
+
+	switch field {
+	case SortActiveAt, SortCreatedAt, SortUpdatedAt, SortTitle:
+		return field, descending, nil
+	default:
+		return "", false, fmt.Errorf("%w: %s", errors.ErrInvalidSort, value)
+	}
+}
+
+// SortValue - значение поля сортировки у задачи: time.Time для дат, string для title
+func (t *Todo) SortValue(field SortField) interface{} {
+	switch field {
+	case SortCreatedAt:
+		return t.CreatedAt
+	case SortUpdatedAt:
+		return t.UpdatedAt
+	case SortTitle:
+		return t.Title
+	default:
+		return t.ActiveAt
+	}
+}
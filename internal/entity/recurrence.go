@@ -0,0 +1,96 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/rrule"
+)
+
+// NormalizeRRule проверяет правило повторения и приводит его к каноническому виду. Пустая строка - без повторения
+func NormalizeRRule(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	rule, err := rrule.Parse(value)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", errors.ErrInvalidRRule, err)
+	}
+	return rule.String(), nil
+}
+
+// setRRule задает правило и начинает с active_at новую серию
+func (t *Todo) setRRule(value string) {
+	t.RRule = value
+	t.SeriesStart = nil
+	if value != "" {
+		start := t.ActiveAt
+		t.SeriesStart = &start
+	}
+}
+
+func (t *Todo) validateRRule() error {
+	_, err := NormalizeRRule(t.RRule)
+	return err
+}
+
+// recurrence - разобранное правило и начало серии. nil у неповторяющихся задач
+func (t *Todo) recurrence() (*rrule.Rule, time.Time) {
+	if t.RRule == "" {
+		return nil, time.Time{}
+	}
+	rule, err := rrule.Parse(t.RRule)
+	if err != nil {
+		return nil, time.Time{}
+	}
+
+	start := t.ActiveAt
+	if t.SeriesStart != nil {
+		start = *t.SeriesStart
+	}
+	return rule, start
+}
+
+// Occurrences - до limit следующих повторений после active_at задачи
+func (t *Todo) Occurrences(limit int) []time.Time {
+	rule, start := t.recurrence()
+	if rule == nil {
+		return []time.Time{}
+	}
+	return rule.Next(start, t.ActiveAt, limit)
+}
+
+// NextOccurrence - следующее повторение задачи: копия заголовка, описания со снятыми отметками чек-листа,
+// приоритета, меток, родителя, исполнителя и правила с ближайшей датой после active_at, но не раньше дня now (прошедшие даты Validate не пропустит).
+// nil, если задача не повторяется или правило исчерпано
+func (t *Todo) NextOccurrence(now time.Time) *Todo {
+	rule, start := t.recurrence()
+	if rule == nil {
+		return nil
+	}
+
+	after := t.ActiveAt
+	today := now.UTC().Truncate(24 * time.Hour)
+	if after.Before(today) {
+		after = today.Add(-time.Nanosecond)
+	}
+	activeAt, ok := rule.After(start, after)
+	if !ok {
+		return nil
+	}
+
+	next := NewTodo(t.Title, activeAt)
+	next.Description = t.Description
+	next.resetChecklist()
+	next.Priority = t.Priority
+	next.Tags = append([]string(nil), t.Tags...)
+	next.OwnerID = t.OwnerID
+	next.ListID = t.ListID
+	next.ParentID = t.ParentID
+	next.AssigneeID = t.AssigneeID
+	next.RRule = t.RRule
+	next.SeriesStart = &start
+	return next
+}
@@ -0,0 +1,96 @@
+package entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeRRule(t *testing.T) {
+	rule, err := entity.NormalizeRRule("rrule:freq=weekly;byday=mo")
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO", rule)
+
+	rule, err = entity.NormalizeRRule("")
+	require.NoError(t, err)
+	assert.Empty(t, rule)
+
+	_, err = entity.NormalizeRRule("FREQ=HOURLY")
+	assert.ErrorIs(t, err, errors.ErrInvalidRRule)
+}
+
+func TestNextOccurrence(t *testing.T) {
+	// 2030-01-07 - понедельник
+	monday := time.Date(2030, 1, 7, 0, 0, 0, 0, time.UTC)
+	todo := entity.TodoFields{
+		Title:       "Вынести мусор",
+		Description: "- [x] кухня\n- [ ] балкон",
+		ActiveAt:    monday,
+		RRule:       "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3",
+	}.NewTodo()
+	require.NotNil(t, todo.SeriesStart)
+
+	next := todo.NextOccurrence(monday)
+	require.NotNil(t, next)
+	assert.Equal(t, monday.AddDate(0, 0, 3), next.ActiveAt)
+	assert.Equal(t, "Вынести мусор", next.Title)
+	assert.Equal(t, "- [ ] кухня\n- [ ] балкон", next.Description)
+	assert.Equal(t, todo.RRule, next.RRule)
+	assert.Equal(t, monday, *next.SeriesStart)
+
+	// третье повторение - последнее по COUNT
+	third := next.NextOccurrence(monday)
+	require.NotNil(t, third)
+	assert.Equal(t, monday.AddDate(0, 0, 7), third.ActiveAt)
+	assert.Nil(t, third.NextOccurrence(monday))
+
+	// просроченная задача продолжает серию с сегодняшнего дня, а не с прошедших дат
+	daily := entity.TodoFields{Title: "Зарядка", ActiveAt: monday, RRule: "FREQ=DAILY"}.NewTodo()
+	next = daily.NextOccurrence(monday.AddDate(0, 0, 10).Add(15 * time.Hour))
+	require.NotNil(t, next)
+	assert.Equal(t, monday.AddDate(0, 0, 10), next.ActiveAt)
+
+	assert.Nil(t, entity.NewTodo("Разовая", monday).NextOccurrence(monday))
+}
+
+func TestOccurrences(t *testing.T) {
+	start := time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC)
+	todo := entity.TodoFields{Title: "Оплатить аренду", ActiveAt: start, RRule: "FREQ=MONTHLY"}.NewTodo()
+
+	occurrences := todo.Occurrences(2)
+	require.Len(t, occurrences, 2)
+	assert.Equal(t, time.Date(2030, 3, 31, 0, 0, 0, 0, time.UTC), occurrences[0])
+	assert.Equal(t, time.Date(2030, 5, 31, 0, 0, 0, 0, time.UTC), occurrences[1])
+
+	assert.Empty(t, entity.NewTodo("Разовая", start).Occurrences(5))
+}
+
+func TestPatchRRule(t *testing.T) {
+	patch, err := entity.ParseTodoMergePatch([]byte(`{"rrule": "freq=daily;interval=2"}`))
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=DAILY;INTERVAL=2", *patch.RRule)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"rrule": "FREQ=DAILY;BYMONTH=1"}`))
+	assert.ErrorIs(t, err, errors.ErrInvalidRRule)
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"series_start": "2030-01-01"}`))
+	assert.ErrorIs(t, err, errors.ErrFieldImmutable)
+
+	// новое правило начинает серию с active_at, null убирает повторение
+	activeAt := time.Now().AddDate(0, 0, 1).UTC().Truncate(24 * time.Hour)
+	todo := entity.NewTodo("Зарядка", time.Now())
+	require.NoError(t, (&entity.TodoPatch{ActiveAt: &activeAt, RRule: patch.RRule}).Apply(todo))
+	assert.Equal(t, "FREQ=DAILY;INTERVAL=2", todo.RRule)
+	require.NotNil(t, todo.SeriesStart)
+	assert.Equal(t, activeAt, *todo.SeriesStart)
+
+	patch, err = entity.ParseTodoMergePatch([]byte(`{"rrule": null}`))
+	require.NoError(t, err)
+	require.NoError(t, patch.Apply(todo))
+	assert.Empty(t, todo.RRule)
+	assert.Nil(t, todo.SeriesStart)
+}
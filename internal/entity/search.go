@@ -0,0 +1,11 @@
+package entity
+
+// SearchHit - задача, найденная полнотекстовым поиском
+type SearchHit struct {
+	Task  *Todo   `json:"task"`
+	Score float64 `json:"score"`
+	// Highlight - заголовок, экранированный для HTML, с совпадениями в <mark>
+	Highlight string `json:"highlight"`
+	// DescriptionHighlight - так же подсвеченное описание, если совпадения есть в нем
+	DescriptionHighlight string `json:"description_highlight,omitempty"`
+}
@@ -0,0 +1,94 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+)
+
+// TaskStatus - состояние задачи в ее жизненном цикле
+type TaskStatus string
+
+const (
+	StatusTodo       TaskStatus = "todo"
+	StatusInProgress TaskStatus = "in_progress"
+	StatusBlocked    TaskStatus = "blocked"
+	StatusDone       TaskStatus = "done"
+	StatusCancelled  TaskStatus = "cancelled"
+)
+
+// StatusFilterActive - не статус, а фильтр GetTasksByStatus: незавершенные задачи, у которых наступил activeAt
+const StatusFilterActive = "active"
+
+// statusTransitions - таблица разрешенных переходов. Из done и cancelled можно только переоткрыть задачу в todo
+var statusTransitions = map[TaskStatus][]TaskStatus{
+	StatusTodo:       {StatusInProgress, StatusBlocked, StatusDone, StatusCancelled},
+	StatusInProgress: {StatusTodo, StatusBlocked, StatusDone, StatusCancelled},
+	StatusBlocked:    {StatusTodo, StatusInProgress, StatusCancelled},
+	StatusDone:       {StatusTodo},
+	StatusCancelled:  {StatusTodo},
+}
+
+// ParseTaskStatus проверяет, что строка - один из известных статусов
+func ParseTaskStatus(value string) (TaskStatus, error) {
+	status := TaskStatus(value)
+	if !status.IsValid() {
+		return "", fmt.Errorf("%w: %s", errors.ErrInvalidStatus, value)
+	}
+	return status, nil
+}
+
+func (s TaskStatus) IsValid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// IsClosed - задача завершена (выполнена или отменена) и не попадает в активные
+func (s TaskStatus) IsClosed() bool {
+	return s == StatusDone || s == StatusCancelled
+}
+
+// CanTransitionTo сверяется с таблицей statusTransitions
+func (s TaskStatus) CanTransitionTo(to TaskStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenStatuses - статусы, в которых задача еще не завершена
+func OpenStatuses() []TaskStatus {
+	return []TaskStatus{StatusTodo, StatusInProgress, StatusBlocked}
+}
+
+// TransitionTo переводит задачу в статус to по таблице переходов и ведет completed_at
+func (t *Todo) TransitionTo(to TaskStatus) error {
+	if !to.IsValid() {
+		return fmt.Errorf("%w: %s", errors.ErrInvalidStatus, to)
+	}
+	if !t.Status.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", errors.ErrInvalidTransition, t.Status, to)
+	}
+
+	now := time.Now()
+	t.Status = to
+	t.UpdatedAt = now
+	if to == StatusDone {
+		t.CompletedAt = &now
+	} else {
+		t.CompletedAt = nil
+	}
+
+	return nil
+}
+
+// Reopen возвращает выполненную или отмененную задачу в работу
+func (t *Todo) Reopen() error {
+	if !t.Status.IsClosed() {
+		return fmt.Errorf("%w: %s -> %s", errors.ErrInvalidTransition, t.Status, StatusTodo)
+	}
+	return t.TransitionTo(StatusTodo)
+}
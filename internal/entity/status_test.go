@@ -0,0 +1,45 @@
+package entity_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitionTo(t *testing.T) {
+	todo := entity.NewTodo("Test Todo", time.Now())
+
+	require.NoError(t, todo.TransitionTo(entity.StatusInProgress))
+	assert.Equal(t, entity.StatusInProgress, todo.Status)
+
+	require.NoError(t, todo.TransitionTo(entity.StatusBlocked))
+	// из blocked сразу в done нельзя, сначала задачу надо разблокировать
+	assert.ErrorIs(t, todo.TransitionTo(entity.StatusDone), errors.ErrInvalidTransition)
+
+	require.NoError(t, todo.TransitionTo(entity.StatusInProgress))
+	require.NoError(t, todo.TransitionTo(entity.StatusDone))
+	require.NotNil(t, todo.CompletedAt)
+
+	// выполненную задачу можно только переоткрыть
+	assert.ErrorIs(t, todo.TransitionTo(entity.StatusInProgress), errors.ErrInvalidTransition)
+	assert.ErrorIs(t, todo.TransitionTo("finished"), errors.ErrInvalidStatus)
+}
+
+func TestReopen(t *testing.T) {
+	todo := entity.NewTodo("Test Todo", time.Now())
+	assert.ErrorIs(t, todo.Reopen(), errors.ErrInvalidTransition)
+
+	require.NoError(t, todo.MarkAsCompleted())
+	require.NoError(t, todo.Reopen())
+	assert.Equal(t, entity.StatusTodo, todo.Status)
+	assert.Nil(t, todo.CompletedAt)
+
+	require.NoError(t, todo.TransitionTo(entity.StatusCancelled))
+	assert.ErrorIs(t, todo.MarkAsCompleted(), errors.ErrInvalidTransition)
+	require.NoError(t, todo.Reopen())
+	assert.Equal(t, entity.StatusTodo, todo.Status)
+}
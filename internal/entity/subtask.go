@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"fmt"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+)
+
+// SubtaskPolicy - что делать с подзадачами, когда удаляют или завершают родительскую задачу
+type SubtaskPolicy string
+
+const (
+	// SubtaskBlock запрещает операцию, пока у задачи есть подзадачи (при завершении - незакрытые)
+	SubtaskBlock SubtaskPolicy = "block"
+	// SubtaskCascade применяет операцию ко всем подзадачам, включая вложенные
+	SubtaskCascade SubtaskPolicy = "cascade"
+	// SubtaskOrphan отвязывает подзадачи от родителя, и они становятся обычными задачами
+	SubtaskOrphan SubtaskPolicy = "orphan"
+)
+
+// ParseSubtaskPolicy проверяет значение политики. Пустое значение - SubtaskBlock
+func ParseSubtaskPolicy(value string) (SubtaskPolicy, error) {
+	switch policy := SubtaskPolicy(value); policy {
+	case "":
+		return SubtaskBlock, nil
+	case SubtaskBlock, SubtaskCascade, SubtaskOrphan:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w: %s", errors.ErrInvalidSubtaskPolicy, value)
+	}
+}
+
+// SubtaskProgress - сводка по прямым подзадачам. Отмененные подзадачи в расчет не входят,
+// Percent - доля выполненных среди остальных, округленная вниз
+type SubtaskProgress struct {
+	Total   int `json:"total"`
+	Done    int `json:"done"`
+	Percent int `json:"percent"`
+}
+
+// NewSubtaskProgress считает прогресс по подзадачам. Без подзадач прогресса нет (nil)
+func NewSubtaskProgress(subtasks []*Todo) *SubtaskProgress {
+	if len(subtasks) == 0 {
+		return nil
+	}
+
+	progress := &SubtaskProgress{}
+	for _, subtask := range subtasks {
+		switch subtask.Status {
+		case StatusCancelled:
+			continue
+		case StatusDone:
+			progress.Done++
+		}
+		progress.Total++
+	}
+
+	// все подзадачи отменены - делать больше нечего
+	progress.Percent = 100
+	if progress.Total > 0 {
+		progress.Percent = progress.Done * 100 / progress.Total
+	}
+	return progress
+}
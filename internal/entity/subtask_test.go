@@ -0,0 +1,45 @@
+package entity_test
+
+import (
+	"testing"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSubtaskPolicy(t *testing.T) {
+	policy, err := entity.ParseSubtaskPolicy("")
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubtaskBlock, policy)
+
+	policy, err = entity.ParseSubtaskPolicy("cascade")
+	require.NoError(t, err)
+	assert.Equal(t, entity.SubtaskCascade, policy)
+
+	_, err = entity.ParseSubtaskPolicy("delete")
+	assert.ErrorIs(t, err, errors.ErrInvalidSubtaskPolicy)
+}
+
+func TestNewSubtaskProgress(t *testing.T) {
+	withStatuses := func(statuses ...entity.TaskStatus) []*entity.Todo {
+		todos := make([]*entity.Todo, 0, len(statuses))
+		for _, status := range statuses {
+			todos = append(todos, &entity.Todo{Status: status})
+		}
+		return todos
+	}
+
+	assert.Nil(t, entity.NewSubtaskProgress(nil))
+
+	assert.Equal(t, &entity.SubtaskProgress{Total: 3, Done: 1, Percent: 33},
+		entity.NewSubtaskProgress(withStatuses(entity.StatusDone, entity.StatusTodo, entity.StatusBlocked)))
+
+	// отмененные подзадачи не считаются
+	assert.Equal(t, &entity.SubtaskProgress{Total: 2, Done: 2, Percent: 100},
+		entity.NewSubtaskProgress(withStatuses(entity.StatusDone, entity.StatusCancelled, entity.StatusDone)))
+
+	assert.Equal(t, &entity.SubtaskProgress{Total: 0, Done: 0, Percent: 100},
+		entity.NewSubtaskProgress(withStatuses(entity.StatusCancelled)))
+}
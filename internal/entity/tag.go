@@ -0,0 +1,120 @@
+package entity
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+)
+
+const (
+	// MaxTags - ограничение на число меток у одной задачи
+	MaxTags = 20
+	// MaxTagLength - ограничение на длину метки в символах
+	MaxTagLength = 50
+)
+
+// TagCount - метка и число задач с ней
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// NormalizeTag приводит метку к нижнему регистру без пробелов по краям. В метке допустимы буквы, цифры и "-_/.:",
+// запятая зарезервирована под списки меток в query
+func NormalizeTag(value string) (string, error) {
+	tag := strings.ToLower(strings.TrimSpace(value))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", fmt.Errorf("%w: %q", errors.ErrInvalidTag, value)
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-_/.:", r) {
+			return "", fmt.Errorf("%w: %q", errors.ErrInvalidTag, value)
+		}
+	}
+	return tag, nil
+}
+
+// NormalizeTags нормализует метки и убирает повторы, порядок первых вхождений сохраняется. Пустой список - nil
+func NormalizeTags(values []string) ([]string, error) {
+	var tags []string
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		tag, err := NormalizeTag(value)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (t *Todo) validateTags() error {
+	if len(t.Tags) > MaxTags {
+		return fmt.Errorf("%w: tags 0..%d", errors.ErrInvalidFieldValue, MaxTags)
+	}
+	return nil
+}
+
+// HasTag - у задачи есть метка tag
+func (t *Todo) HasTag(tag string) bool {
+	for _, own := range t.Tags {
+		if own == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesTags - у задачи есть хотя бы одна метка из anyOf (если он не пуст) и все метки из allOf
+func (t *Todo) MatchesTags(anyOf, allOf []string) bool {
+	if len(anyOf) > 0 {
+		found := false
+		for _, tag := range anyOf {
+			if t.HasTag(tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, tag := range allOf {
+		if !t.HasTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// RenameTags заменяет метки from на to на месте первой из них; если to у задачи уже есть, метки сливаются.
+// Возвращает false, если ни одной метки из from у задачи не было
+func (t *Todo) RenameTags(from []string, to string) bool {
+	renamed := make(map[string]bool, len(from))
+	for _, tag := range from {
+		renamed[tag] = true
+	}
+
+	changed := false
+	tags := make([]string, 0, len(t.Tags))
+	seen := make(map[string]bool, len(t.Tags))
+	for _, tag := range t.Tags {
+		if renamed[tag] {
+			tag, changed = to, true
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if changed {
+		t.Tags = tags
+	}
+	return changed
+}
@@ -0,0 +1,75 @@
+package entity_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := entity.NormalizeTags([]string{" Work ", "дом", "work", "q3/plan"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work", "дом", "q3/plan"}, tags)
+
+	tags, err = entity.NormalizeTags(nil)
+	require.NoError(t, err)
+	assert.Nil(t, tags)
+
+	for _, value := range []string{"", "  ", "a,b", "two words", "$set", strings.Repeat("x", entity.MaxTagLength+1)} {
+		_, err := entity.NormalizeTag(value)
+		assert.ErrorIs(t, err, errors.ErrInvalidTag, value)
+	}
+}
+
+func TestValidateTags(t *testing.T) {
+	todo := entity.NewTodo("Метки", time.Now())
+	for i := 0; i <= entity.MaxTags; i++ {
+		todo.Tags = append(todo.Tags, strings.Repeat("x", i+1))
+	}
+	assert.ErrorIs(t, todo.Validate(), errors.ErrInvalidFieldValue)
+}
+
+func TestMatchesTags(t *testing.T) {
+	todo := &entity.Todo{Tags: []string{"work", "urgent"}}
+
+	assert.True(t, todo.MatchesTags(nil, nil))
+	assert.True(t, todo.MatchesTags([]string{"home", "work"}, nil))
+	assert.False(t, todo.MatchesTags([]string{"home"}, nil))
+	assert.True(t, todo.MatchesTags(nil, []string{"urgent", "work"}))
+	assert.False(t, todo.MatchesTags([]string{"work"}, []string{"home"}))
+}
+
+func TestRenameTags(t *testing.T) {
+	todo := &entity.Todo{Tags: []string{"job", "urgent", "work"}}
+
+	assert.False(t, todo.RenameTags([]string{"home"}, "work"))
+	assert.Equal(t, []string{"job", "urgent", "work"}, todo.Tags)
+
+	// job сливается с work и занимает место первой из них
+	assert.True(t, todo.RenameTags([]string{"job"}, "work"))
+	assert.Equal(t, []string{"work", "urgent"}, todo.Tags)
+
+	assert.True(t, todo.RenameTags([]string{"urgent", "work"}, "later"))
+	assert.Equal(t, []string{"later"}, todo.Tags)
+}
+
+func TestParseTagsPatch(t *testing.T) {
+	patch, err := entity.ParseTodoMergePatch([]byte(`{"tags": ["Work", "work", "home"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work", "home"}, *patch.Tags)
+
+	patch, err = entity.ParseTodoMergePatch([]byte(`{"tags": null}`))
+	require.NoError(t, err)
+	assert.Empty(t, *patch.Tags)
+	assert.False(t, patch.IsEmpty())
+
+	_, err = entity.ParseTodoMergePatch([]byte(`{"tags": ["a b"]}`))
+	assert.ErrorIs(t, err, errors.ErrInvalidTag)
+	_, err = entity.ParseTodoMergePatch([]byte(`{"tags": "work"}`))
+	assert.ErrorIs(t, err, errors.ErrInvalidFieldValue)
+}
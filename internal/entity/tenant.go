@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+)
+
+// MaxTenantNameLength - ограничение длины названия организации в символах
+const MaxTenantNameLength = 100
+
+// Tenant - организация-клиент. Данные каждой лежат в своей базе, каталог организаций - в общей
+type Tenant struct {
+	// ID - идентификатор из заголовка X-Tenant-ID, поддомена и токенов, он же часть имени базы
+	ID        string    `bson:"_id" json:"id"`
+	Name      string    `bson:"name" json:"name"`
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+}
+
+// tenantIDPattern - идентификатор организации после приведения к нижнему регистру. Годится и как метка DNS для поддомена,
+// и как часть имени базы Mongo
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,30}[a-z0-9]$`)
+
+// NormalizeTenantID приводит идентификатор к нижнему регистру: Acme.example.com и acme.example.com - одна организация
+func NormalizeTenantID(value string) (string, error) {
+	id := strings.ToLower(strings.TrimSpace(value))
+	if !tenantIDPattern.MatchString(id) {
+		return "", errors.ErrInvalidTenant
+	}
+	return id, nil
+}
+
+// NewTenant - организация id с названием name, без названия оно совпадает с id
+func NewTenant(id, name string, now time.Time) (*Tenant, error) {
+	id, err := NormalizeTenantID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = id
+	}
+	if len([]rune(name)) > MaxTenantNameLength {
+		return nil, errors.ErrInvalidTenant
+	}
+	return &Tenant{ID: id, Name: name, CreatedAt: now}, nil
+}
+
+type tenantKey struct{}
+
+// WithTenant кладет в контекст организацию запроса. Хранилище с несколькими организациями работает только с ее базой
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenantID)
+}
+
+// TenantFromContext - организация из WithTenant, пустая если ее нет
+func TenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey{}).(string)
+	return tenantID
+}
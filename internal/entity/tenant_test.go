@@ -0,0 +1,41 @@
+package entity_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeTenantID(t *testing.T) {
+	id, err := entity.NormalizeTenantID(" Acme-42 ")
+	require.NoError(t, err)
+	assert.Equal(t, "acme-42", id)
+
+	for _, value := range []string{"", "a", "-acme", "acme-", "acme_corp", "acme.corp", "ак", strings.Repeat("a", 33)} {
+		_, err := entity.NormalizeTenantID(value)
+		assert.ErrorIs(t, err, errors.ErrInvalidTenant, value)
+	}
+}
+
+func TestNewTenant(t *testing.T) {
+	now := time.Now()
+	tenant, err := entity.NewTenant("ACME", "  ", now)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenant.ID)
+	assert.Equal(t, "acme", tenant.Name)
+	assert.Equal(t, now, tenant.CreatedAt)
+
+	_, err = entity.NewTenant("acme", strings.Repeat("я", entity.MaxTenantNameLength+1), now)
+	assert.ErrorIs(t, err, errors.ErrInvalidTenant)
+}
+
+func TestTenantFromContext(t *testing.T) {
+	assert.Empty(t, entity.TenantFromContext(context.Background()))
+	assert.Equal(t, "acme", entity.TenantFromContext(entity.WithTenant(context.Background(), "acme")))
+}
@@ -1,38 +1,139 @@
 package entity
 
 import (
+	"encoding/json"
 	"github.com/nekidaz/todolist/pkg/errors"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type Todo struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	Title     string             `bson:"title" json:"title"`
-	Completed bool               `bson:"completed" json:"completed"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
-	ActiveAt  time.Time          `bson:"active_at" json:"active_at"`
+	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Title string             `bson:"title" json:"title"`
+	// Description - подробности в Markdown, пункты "- [ ]" и "- [x]" образуют чек-лист
+	Description string     `bson:"description" json:"description"`
+	Status      TaskStatus `bson:"status" json:"status"`
+	Priority    Priority   `bson:"priority" json:"priority"`
+	// CompletedAt заполнен, только пока задача в статусе done
+	CompletedAt *time.Time `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `bson:"updated_at" json:"updated_at"`
+	ActiveAt    time.Time  `bson:"active_at" json:"active_at"`
+	// OwnerID - пользователь, создавший задачу. Задачи без владельца остались с времен до появления пользователей
+	OwnerID *primitive.ObjectID `bson:"owner_id,omitempty" json:"owner_id,omitempty"`
+	// ListID - список задачи, nil у задач списка по умолчанию. PUT/PATCH его не меняют, задачи переносятся отдельно
+	ListID *primitive.ObjectID `bson:"list_id,omitempty" json:"list_id,omitempty"`
+	// ParentID - родительская задача, nil у задач верхнего уровня. Задается при создании подзадачи и PUT/PATCH не меняется
+	ParentID *primitive.ObjectID `bson:"parent_id,omitempty" json:"parent_id,omitempty"`
+	// AssigneeID - исполнитель задачи: владелец списка или его участник. nil - задача ни на кого не назначена
+	AssigneeID *primitive.ObjectID `bson:"assignee_id,omitempty" json:"assignee_id,omitempty"`
+	// DependsOn - задачи, которые надо закончить до начала этой
+	DependsOn []primitive.ObjectID `bson:"depends_on,omitempty" json:"depends_on,omitempty"`
+	// Tags - метки задачи, нормализованные NormalizeTags
+	Tags []string `bson:"tags,omitempty" json:"tags,omitempty"`
+	// RRule - правило повторения (подмножество RRULE из RFC 5545), пустое у неповторяющихся задач
+	RRule string `bson:"rrule,omitempty" json:"rrule,omitempty"`
+	// SeriesStart - active_at первого повторения серии, от него считаются INTERVAL, BYDAY и COUNT
+	SeriesStart *time.Time `bson:"series_start,omitempty" json:"series_start,omitempty"`
+	// BlockedBy - незакрытые задачи из DependsOn, их находит сервис при чтении. Не хранится
+	BlockedBy []primitive.ObjectID `bson:"-" json:"blocked_by,omitempty"`
+	// Subtasks - прогресс по подзадачам, его считает сервис при чтении. Не хранится
+	Subtasks *SubtaskProgress `bson:"-" json:"subtasks,omitempty"`
+	// DescriptionHTML - описание, отрисованное в HTML. Не хранится, заполняется только по запросу клиента
+	DescriptionHTML string `bson:"-" json:"description_html,omitempty"`
+	// CommentCount - число комментариев под задачей, его считает сервис при чтении. Не хранится
+	CommentCount int `bson:"-" json:"comment_count"`
+}
+
+// MarshalJSON добавляет к задаче чек-лист, разобранный из описания, и признак has_open_dependencies.
+// Он не называется blocked, чтобы его нельзя было спутать со статусом blocked
+func (t Todo) MarshalJSON() ([]byte, error) {
+	type todoJSON Todo
+	return json.Marshal(struct {
+		todoJSON
+		Checklist           *Checklist `json:"checklist,omitempty"`
+		HasOpenDependencies bool       `json:"has_open_dependencies"`
+	}{todoJSON(t), t.Checklist(), t.HasOpenDependencies()})
+}
+
+// TodoFields - поля, которые клиент задает при создании задачи и при ее полной замене (PUT)
+type TodoFields struct {
+	Title       string
+	Description string
+	// Priority - пустой приоритет означает normal
+	Priority Priority
+	ActiveAt time.Time
+	// ListID - список, в котором создается задача, nil - список по умолчанию
+	ListID *primitive.ObjectID
+	// ParentID задается только при создании подзадачи
+	ParentID   *primitive.ObjectID
+	AssigneeID *primitive.ObjectID
+	DependsOn  []primitive.ObjectID
+	Tags       []string
+	RRule      string
+}
+
+// NewTodo создает задачу из полей клиента
+func (f TodoFields) NewTodo() *Todo {
+	todo := NewTodo(f.Title, f.ActiveAt)
+	todo.Description = f.Description
+	if f.Priority != "" {
+		todo.Priority = f.Priority
+	}
+	todo.ListID = f.ListID
+	todo.ParentID = f.ParentID
+	todo.AssigneeID = f.AssigneeID
+	todo.DependsOn = f.DependsOn
+	todo.Tags = f.Tags
+	todo.setRRule(f.RRule)
+	return todo
 }
 
 func NewTodo(title string, activeAt time.Time) *Todo {
 	return &Todo{
 		Title:     title,
-		Completed: false,
+		Status:    StatusTodo,
+		Priority:  PriorityNormal,
 		ActiveAt:  activeAt,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 }
 
-func (t *Todo) MarkAsCompleted() {
-	t.Completed = true
-	t.UpdatedAt = time.Now()
+// MarkAsCompleted переводит задачу в done. Для уже выполненной задачи ничего не делает
+func (t *Todo) MarkAsCompleted() error {
+	if t.Status == StatusDone {
+		return nil
+	}
+	return t.TransitionTo(StatusDone)
 }
 
 func (t *Todo) Validate() error {
+	if err := t.validateTitle(); err != nil {
+		return err
+	}
+	if err := t.validateDescription(); err != nil {
+		return err
+	}
+	if err := t.validatePriority(); err != nil {
+		return err
+	}
+	if err := t.validateDependsOn(); err != nil {
+		return err
+	}
+	if err := t.validateTags(); err != nil {
+		return err
+	}
+	if err := t.validateRRule(); err != nil {
+		return err
+	}
+	return t.validateActiveAt()
+}
+
+func (t *Todo) validateTitle() error {
 	if t.Title == "" {
 		return errors.ErrTitleEmpty
 	}
@@ -44,6 +145,20 @@ func (t *Todo) Validate() error {
 		return errors.ErrTitleLengthExceeded
 	}
 
+	return nil
+}
+
+// MaxDescriptionLength - ограничение на длину описания в символах
+const MaxDescriptionLength = 10000
+
+func (t *Todo) validateDescription() error {
+	if utf8.RuneCountInString(t.Description) > MaxDescriptionLength {
+		return errors.ErrDescriptionTooLong
+	}
+	return nil
+}
+
+func (t *Todo) validateActiveAt() error {
 	//чтобы мог создавать задачи на сегодня
 	now := time.Now().UTC().Truncate(24 * time.Hour)
 
@@ -14,7 +14,8 @@ func TestCreateTodo(t *testing.T) {
 	todo := entity.NewTodo(title, activationTime)
 
 	assert.Equal(t, title, todo.Title)
-	assert.False(t, todo.Completed)
+	assert.Equal(t, entity.StatusTodo, todo.Status)
+	assert.Nil(t, todo.CompletedAt)
 	assert.True(t, todo.ActiveAt.Equal(activationTime))
 	assert.WithinDuration(t, time.Now(), todo.CreatedAt, time.Second)
 	assert.WithinDuration(t, time.Now(), todo.UpdatedAt, time.Second)
@@ -22,12 +23,17 @@ func TestCreateTodo(t *testing.T) {
 
 func TestMarkAsCompleted(t *testing.T) {
 	todo := entity.NewTodo("Test Todo", time.Now())
-	assert.False(t, todo.Completed)
+	assert.Equal(t, entity.StatusTodo, todo.Status)
 	assert.WithinDuration(t, time.Now(), todo.UpdatedAt, time.Second)
 
-	todo.MarkAsCompleted()
-	assert.True(t, todo.Completed)
+	assert.NoError(t, todo.MarkAsCompleted())
+	assert.Equal(t, entity.StatusDone, todo.Status)
 	assert.WithinDuration(t, time.Now(), todo.UpdatedAt, time.Second)
+	assert.NotNil(t, todo.CompletedAt)
+
+	// повторная отметка ничего не меняет
+	assert.NoError(t, todo.MarkAsCompleted())
+	assert.Equal(t, entity.StatusDone, todo.Status)
 }
 
 // валидность
@@ -0,0 +1,104 @@
+package entity
+
+import (
+	"context"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MaxEmailLength - ограничение длины адреса из RFC 5321
+const MaxEmailLength = 254
+
+// Границы длины пароля в байтах. Больше 72 байт bcrypt не учитывает
+const (
+	MinPasswordLength = 8
+	MaxPasswordLength = 72
+)
+
+// User - учетная запись. Задачи и списки принадлежат пользователю через OwnerID
+type User struct {
+	ID    primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Email string             `bson:"email" json:"email"`
+	// Username - имя для упоминаний @username, необязательное и уникальное
+	Username string `bson:"username,omitempty" json:"username,omitempty"`
+	// PasswordHash - bcrypt-хеш пароля, наружу не отдается. Пустой у пользователей, созданных входом через OIDC:
+	// с паролем они войти не могут
+	PasswordHash string `bson:"password_hash" json:"-"`
+	// OIDCIssuer и OIDCSubject - учетная запись у провайдера OpenID Connect, пустые у локальных пользователей
+	OIDCIssuer  string    `bson:"oidc_issuer,omitempty" json:"-"`
+	OIDCSubject string    `bson:"oidc_subject,omitempty" json:"-"`
+	CreatedAt   time.Time `bson:"created_at" json:"created_at"`
+}
+
+// NormalizeEmail приводит адрес к нижнему регистру и проверяет, что это один адрес без имени
+func NormalizeEmail(value string) (string, error) {
+	email := strings.ToLower(strings.TrimSpace(value))
+	if email == "" || len(email) > MaxEmailLength {
+		return "", errors.ErrInvalidEmail
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", errors.ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// usernamePattern - имя пользователя после приведения к нижнему регистру
+var usernamePattern = regexp.MustCompile(`^[a-z0-9_]{3,32}$`)
+
+// NormalizeUsername приводит имя к нижнему регистру: @Alice и @alice - один пользователь
+func NormalizeUsername(value string) (string, error) {
+	username := strings.ToLower(strings.TrimSpace(value))
+	if !usernamePattern.MatchString(username) {
+		return "", errors.ErrInvalidUsername
+	}
+	return username, nil
+}
+
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return errors.ErrInvalidPassword
+	}
+	return nil
+}
+
+type ownerKey struct{}
+
+// WithOwner кладет в контекст пользователя, от имени которого идет запрос. Хранилища видят только его задачи и списки
+func WithOwner(ctx context.Context, ownerID primitive.ObjectID) context.Context {
+	return context.WithValue(ctx, ownerKey{}, ownerID)
+}
+
+// OwnerFromContext - владелец из WithOwner, nil если его нет. Без владельца видны только данные,
+// созданные до появления пользователей
+func OwnerFromContext(ctx context.Context) *primitive.ObjectID {
+	ownerID, ok := ctx.Value(ownerKey{}).(primitive.ObjectID)
+	if !ok {
+		return nil
+	}
+	return &ownerID
+}
+
+// OwnedBy - задача принадлежит ownerID, nil - задача без владельца
+func (t *Todo) OwnedBy(ownerID *primitive.ObjectID) bool {
+	return sameID(t.OwnerID, ownerID)
+}
+
+// OwnedBy - список принадлежит ownerID, nil - список без владельца
+func (l *List) OwnedBy(ownerID *primitive.ObjectID) bool {
+	return sameID(l.OwnerID, ownerID)
+}
+
+// sameID сравнивает необязательные ID: nil равен только nil
+func sameID(a, b *primitive.ObjectID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
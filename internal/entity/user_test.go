@@ -0,0 +1,57 @@
+package entity_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	email, err := entity.NormalizeEmail("  Alice@Example.COM ")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", email)
+
+	for _, value := range []string{"", "alice", "Alice <alice@example.com>", "a@b.c, d@e.f", strings.Repeat("a", 250) + "@b.cd"} {
+		_, err := entity.NormalizeEmail(value)
+		assert.ErrorIs(t, err, errors.ErrInvalidEmail, value)
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	assert.NoError(t, entity.ValidatePassword("12345678"))
+	assert.ErrorIs(t, entity.ValidatePassword("1234567"), errors.ErrInvalidPassword)
+	// пароль длиннее 72 байт bcrypt обрезал бы молча
+	assert.ErrorIs(t, entity.ValidatePassword(strings.Repeat("я", 37)), errors.ErrInvalidPassword)
+}
+
+func TestOwnerFromContext(t *testing.T) {
+	assert.Nil(t, entity.OwnerFromContext(context.Background()))
+
+	id := primitive.NewObjectID()
+	ctx := entity.WithOwner(context.Background(), id)
+	assert.Equal(t, &id, entity.OwnerFromContext(ctx))
+
+	todo := entity.NewTodo("Отчет", time.Now())
+	assert.True(t, todo.OwnedBy(nil))
+	todo.OwnerID = &id
+	assert.True(t, todo.OwnedBy(entity.OwnerFromContext(ctx)))
+	assert.False(t, todo.OwnedBy(nil))
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	username, err := entity.NormalizeUsername(" Alice_1 ")
+	require.NoError(t, err)
+	assert.Equal(t, "alice_1", username)
+
+	for _, value := range []string{"", "al", "alice bob", "алиса", "@alice", strings.Repeat("a", 33)} {
+		_, err := entity.NormalizeUsername(value)
+		assert.ErrorIs(t, err, errors.ErrInvalidUsername, value)
+	}
+}
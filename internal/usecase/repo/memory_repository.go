@@ -0,0 +1,1238 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/textsearch"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryRepository хранит задачи в памяти процесса. Нужен для тестов и локального запуска без MongoDB,
+// поэтому повторяет поведение repository: те же проверки, те же ошибки, та же точность времени
+type memoryRepository struct {
+	mu    sync.RWMutex
+	todos map[primitive.ObjectID]*entity.Todo
+	// order - порядок вставки, как natural order в коллекции Mongo
+	order []primitive.ObjectID
+	lists map[primitive.ObjectID]*entity.List
+	users map[primitive.ObjectID]*entity.User
+	// apiKeys - ключи API в порядке создания
+	apiKeys []*entity.APIKey
+	// members - участники списков в порядке добавления
+	members     []*entity.Membership
+	invitations []*entity.Invitation
+	// notifications - входящие всех пользователей в порядке создания
+	notifications []*entity.Notification
+	// comments - комментарии ко всем задачам в порядке создания
+	comments []*entity.Comment
+}
+
+func NewMemoryRepository() TodoRepository {
+	return &memoryRepository{
+		todos: make(map[primitive.ObjectID]*entity.Todo),
+		lists: make(map[primitive.ObjectID]*entity.List),
+		users: make(map[primitive.ObjectID]*entity.User),
+	}
+}
+
+func (r *memoryRepository) CreateNewTodo(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
+	if err := todo.Validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Проверка уникальности записи по полям title и activeAt в списке
+	todo.OwnerID = entity.OwnerFromContext(ctx)
+	if r.existsLocked(todo.OwnerID, todo.ListID, todo.Title, todo.ActiveAt, primitive.NilObjectID) {
+		return nil, errors.ErrTodoExists
+	}
+
+	if todo.Status == "" {
+		todo.Status = entity.StatusTodo
+	}
+	if todo.Priority == "" {
+		todo.Priority = entity.PriorityNormal
+	}
+	todo.CreatedAt = time.Now()
+	todo.UpdatedAt = time.Now()
+	todo.ID = primitive.NewObjectID()
+
+	r.todos[todo.ID] = storedCopy(todo)
+	r.order = append(r.order, todo.ID)
+
+	return todo, nil
+}
+
+func (r *memoryRepository) UpdateTodo(ctx context.Context, id primitive.ObjectID, todo *entity.Todo) (*entity.Todo, error) {
+	if err := todo.Validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Проверка существования задачи по ID
+	existingTodo, ok := r.todoLocked(ctx, id)
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+
+	// Проверка уникальности записи по полям title и activeAt (за исключением текущей задачи)
+	if r.existsLocked(existingTodo.OwnerID, existingTodo.ListID, todo.Title, todo.ActiveAt, id) {
+		return nil, errors.ErrTodoExists
+	}
+
+	// статус меняется только переходами, а родитель - только при создании, список - только переносом, PUT их не трогает
+	todo.ID = id
+	todo.OwnerID = copyID(existingTodo.OwnerID)
+	todo.ListID = copyID(existingTodo.ListID)
+	todo.ParentID = existingTodo.ParentID
+	todo.Status = existingTodo.Status
+	todo.CompletedAt = existingTodo.CompletedAt
+	todo.CreatedAt = existingTodo.CreatedAt
+	todo.UpdatedAt = time.Now()
+
+	r.todos[id] = storedCopy(todo)
+
+	return todo, nil
+}
+
+func (r *memoryRepository) PatchTodo(ctx context.Context, id primitive.ObjectID, patch *entity.TodoPatch) (*entity.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existingTodo, ok := r.todoLocked(ctx, id)
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+
+	patched := copyTodo(existingTodo)
+	if err := patch.Apply(patched); err != nil {
+		return nil, err
+	}
+
+	// Проверка уникальности, только если меняется title или activeAt
+	if (patch.Title != nil || patch.ActiveAt != nil) && r.existsLocked(patched.OwnerID, patched.ListID, patched.Title, patched.ActiveAt, id) {
+		return nil, errors.ErrTodoExists
+	}
+
+	r.todos[id] = storedCopy(patched)
+
+	return copyTodo(r.todos[id]), nil
+}
+
+func (r *memoryRepository) DeleteTodo(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.todoLocked(ctx, id); !ok {
+		return errors.ErrNotFound
+	}
+
+	delete(r.todos, id)
+	for i, orderedID := range r.order {
+		if orderedID == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+
+	comments := r.comments[:0]
+	for _, comment := range r.comments {
+		if comment.TaskID != id {
+			comments = append(comments, comment)
+		}
+	}
+	r.comments = comments
+
+	return nil
+}
+
+func (r *memoryRepository) SetStatus(ctx context.Context, id primitive.ObjectID, from entity.TaskStatus, todo *entity.Todo) (*entity.Todo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existingTodo, ok := r.todoLocked(ctx, id)
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+	if existingTodo.Status != from {
+		return nil, errors.ErrStatusConflict
+	}
+
+	updated := copyTodo(existingTodo)
+	updated.Status = todo.Status
+	updated.CompletedAt = todo.CompletedAt
+	updated.UpdatedAt = todo.UpdatedAt
+	r.todos[id] = storedCopy(updated)
+
+	return copyTodo(r.todos[id]), nil
+}
+
+func (r *memoryRepository) GetTasksByStatus(ctx context.Context, status string, opts entity.ListOptions) (*entity.TodoPage, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	return r.findPage(ctx, opts, func(todo *entity.Todo) bool {
+		if entity.TaskStatus(status).IsValid() {
+			return todo.Status == entity.TaskStatus(status)
+		}
+		// Нужны задачи, которые не завершены, имеют activeAt <= today и не ждут незакрытых зависимостей
+		return !todo.Status.IsClosed() && !todo.ActiveAt.After(today) && (opts.IncludeBlocked || !r.blockedLocked(todo))
+	})
+}
+
+func (r *memoryRepository) GetTaskByID(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todoLocked(ctx, id)
+	if !ok {
+		return nil, errors.ErrNotFound
+	}
+
+	return copyTodo(todo), nil
+}
+
+func (r *memoryRepository) GetAllTasks(ctx context.Context, opts entity.ListOptions) (*entity.TodoPage, error) {
+	return r.findPage(ctx, opts, func(*entity.Todo) bool { return true })
+}
+
+// findPage повторяет запрос Mongo: фильтр, диапазоны дат, сортировка по (поле, ID), продолжение после курсора
+func (r *memoryRepository) findPage(ctx context.Context, opts entity.ListOptions, match func(todo *entity.Todo) bool) (*entity.TodoPage, error) {
+	opts, err := normalizeListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	var todos []*entity.Todo
+	for _, id := range r.order {
+		todo := r.todos[id]
+		if !todo.OwnedBy(ownerID) ||
+			!match(todo) ||
+			!todo.InList(opts.ListID) ||
+			(opts.ParentID != nil && !sameParent(todo, *opts.ParentID)) ||
+			!todo.MatchesTags(opts.TagsAny, opts.TagsAll) ||
+			!inRange(todo.ActiveAt, opts.ActiveFrom, opts.ActiveTo) ||
+			!inRange(todo.CreatedAt, opts.CreatedFrom, opts.CreatedTo) ||
+			(cursor != nil && !afterCursor(todo, cursor)) {
+			continue
+		}
+		todos = append(todos, copyTodo(todo))
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		c := compareTodos(todos[i], todos[j], opts.Sort)
+		if opts.Descending {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	if opts.Limit > 0 && len(todos) > opts.Limit+1 {
+		todos = todos[:opts.Limit+1]
+	}
+
+	return newPage(todos, opts), nil
+}
+
+func (r *memoryRepository) SearchTasks(ctx context.Context, listID *primitive.ObjectID, q string, limit int) ([]*entity.SearchHit, error) {
+	query := textsearch.ParseQuery(q)
+	if query.IsEmpty() {
+		return []*entity.SearchHit{}, nil
+	}
+
+	ownerID := entity.OwnerFromContext(ctx)
+	r.mu.RLock()
+	candidates := make([]*entity.Todo, 0, len(r.todos))
+	for _, id := range r.order {
+		if todo := r.todos[id]; todo.OwnedBy(ownerID) && todo.InList(listID) {
+			candidates = append(candidates, copyTodo(todo))
+		}
+	}
+	r.mu.RUnlock()
+
+	return rankHits(query, candidates, limit), nil
+}
+
+func (r *memoryRepository) GetSubtasks(ctx context.Context, parentIDs []primitive.ObjectID) ([]*entity.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	todos := []*entity.Todo{}
+	for _, id := range r.order {
+		todo := r.todos[id]
+		if !todo.OwnedBy(ownerID) {
+			continue
+		}
+		for _, parentID := range parentIDs {
+			if sameParent(todo, parentID) {
+				todos = append(todos, copyTodo(todo))
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(todos, func(i, j int) bool {
+		return compareTodos(todos[i], todos[j], entity.SortActiveAt) < 0
+	})
+	return todos, nil
+}
+
+func (r *memoryRepository) SetParent(ctx context.Context, ids []primitive.ObjectID, parentID *primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if todo, ok := r.todoLocked(ctx, id); ok {
+			todo.ParentID = copyID(parentID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) GetTasksByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*entity.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := []*entity.Todo{}
+	for _, id := range ids {
+		if todo, ok := r.todoLocked(ctx, id); ok {
+			todos = append(todos, copyTodo(todo))
+		}
+	}
+
+	sort.SliceStable(todos, func(i, j int) bool {
+		return compareTodos(todos[i], todos[j], entity.SortActiveAt) < 0
+	})
+	return todos, nil
+}
+
+func (r *memoryRepository) RemoveDependency(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	for _, todo := range r.todos {
+		if !todo.OwnedBy(ownerID) || !todo.DependsOnTask(id) {
+			continue
+		}
+		dependsOn := make([]primitive.ObjectID, 0, len(todo.DependsOn)-1)
+		for _, dependency := range todo.DependsOn {
+			if dependency != id {
+				dependsOn = append(dependsOn, dependency)
+			}
+		}
+		todo.DependsOn = dependsOn
+	}
+	return nil
+}
+
+func (r *memoryRepository) GetTags(ctx context.Context) ([]*entity.TagCount, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	counts := make(map[string]int)
+	for _, todo := range r.todos {
+		if !todo.OwnedBy(ownerID) {
+			continue
+		}
+		for _, tag := range todo.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]*entity.TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, &entity.TagCount{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (r *memoryRepository) RenameTags(ctx context.Context, from []string, to string) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	now := time.Now()
+	renamed := 0
+	for _, todo := range r.todos {
+		if todo.OwnedBy(ownerID) && todo.RenameTags(from, to) {
+			todo.UpdatedAt = normalizeTime(now)
+			renamed++
+		}
+	}
+	return renamed, nil
+}
+
+func (r *memoryRepository) MoveTasks(ctx context.Context, ids []primitive.ObjectID, listID *primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	moving := make(map[primitive.ObjectID]*entity.Todo, len(ids))
+	for _, id := range ids {
+		if todo, ok := r.todoLocked(ctx, id); ok {
+			moving[id] = todo
+		}
+	}
+
+	// сначала проверяем все задачи, чтобы не перенести часть и упасть на дубликате
+	for _, todo := range moving {
+		for otherID, other := range r.todos {
+			if moving[otherID] == nil && other.OwnedBy(todo.OwnerID) && other.InList(listID) &&
+				other.Title == todo.Title && other.ActiveAt.Equal(todo.ActiveAt) {
+				return errors.ErrTodoExists
+			}
+		}
+	}
+
+	updatedAt := normalizeTime(time.Now())
+	for _, todo := range moving {
+		todo.ListID = copyID(listID)
+		todo.UpdatedAt = updatedAt
+	}
+	return nil
+}
+
+func (r *memoryRepository) CreateList(ctx context.Context, list *entity.List) (*entity.List, error) {
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list.OwnerID = entity.OwnerFromContext(ctx)
+	if r.listExistsLocked(list.OwnerID, list.Name, primitive.NilObjectID) {
+		return nil, errors.ErrListExists
+	}
+
+	list.ID = primitive.NewObjectID()
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	r.lists[list.ID] = storedListCopy(list)
+
+	return list, nil
+}
+
+func (r *memoryRepository) GetList(ctx context.Context, id primitive.ObjectID) (*entity.List, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list, ok := r.listLocked(ctx, id)
+	if !ok {
+		return nil, errors.ErrListNotFound
+	}
+	return copyList(list), nil
+}
+
+func (r *memoryRepository) GetLists(ctx context.Context, includeArchived bool) ([]*entity.List, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	lists := []*entity.List{}
+	for _, list := range r.lists {
+		if list.OwnedBy(ownerID) && (includeArchived || !list.IsArchived()) {
+			lists = append(lists, copyList(list))
+		}
+	}
+
+	sort.Slice(lists, func(i, j int) bool {
+		if lists[i].Name != lists[j].Name {
+			return lists[i].Name < lists[j].Name
+		}
+		return lists[i].ID.Hex() < lists[j].ID.Hex()
+	})
+	return lists, nil
+}
+
+func (r *memoryRepository) UpdateList(ctx context.Context, list *entity.List) (*entity.List, error) {
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.listLocked(ctx, list.ID)
+	if !ok {
+		return nil, errors.ErrListNotFound
+	}
+	if r.listExistsLocked(existing.OwnerID, list.Name, list.ID) {
+		return nil, errors.ErrListExists
+	}
+
+	updated := copyList(existing)
+	updated.Name = list.Name
+	updated.ArchivedAt = list.ArchivedAt
+	updated.UpdatedAt = list.UpdatedAt
+	r.lists[list.ID] = storedListCopy(updated)
+
+	return copyList(r.lists[list.ID]), nil
+}
+
+func (r *memoryRepository) DeleteList(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.listLocked(ctx, id); !ok {
+		return errors.ErrListNotFound
+	}
+	delete(r.lists, id)
+	return nil
+}
+
+func (r *memoryRepository) CreateUser(ctx context.Context, user *entity.User) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.users {
+		if existing.Email == user.Email || sameIdentity(existing, user.OIDCIssuer, user.OIDCSubject) {
+			return nil, errors.ErrUserExists
+		}
+		if user.Username != "" && existing.Username == user.Username {
+			return nil, errors.ErrUsernameExists
+		}
+	}
+
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	stored := *user
+	stored.CreatedAt = normalizeTime(stored.CreatedAt)
+	r.users[user.ID] = &stored
+
+	return user, nil
+}
+
+func (r *memoryRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errors.ErrUserNotFound
+	}
+	c := *user
+	return &c, nil
+}
+
+func (r *memoryRepository) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email {
+			c := *user
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrUserNotFound
+}
+
+func (r *memoryRepository) GetUserByUsername(ctx context.Context, username string) (*entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if username != "" && user.Username == username {
+			c := *user
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrUserNotFound
+}
+
+func (r *memoryRepository) SetUsername(ctx context.Context, id primitive.ObjectID, username string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, errors.ErrUserNotFound
+	}
+	for _, other := range r.users {
+		if other.ID != id && other.Username == username {
+			return nil, errors.ErrUsernameExists
+		}
+	}
+	user.Username = username
+	c := *user
+	return &c, nil
+}
+
+func (r *memoryRepository) CountUsers(ctx context.Context) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.users), nil
+}
+
+func (r *memoryRepository) GetUserByIdentity(ctx context.Context, issuer, subject string) (*entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if sameIdentity(user, issuer, subject) {
+			c := *user
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrUserNotFound
+}
+
+func (r *memoryRepository) LinkIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return errors.ErrUserNotFound
+	}
+	for _, other := range r.users {
+		if other.ID != id && sameIdentity(other, issuer, subject) {
+			return errors.ErrUserExists
+		}
+	}
+	user.OIDCIssuer, user.OIDCSubject = issuer, subject
+	return nil
+}
+
+// sameIdentity - user привязан к учетной записи провайдера. Пустой subject ни с чем не совпадает
+func sameIdentity(user *entity.User, issuer, subject string) bool {
+	return subject != "" && user.OIDCIssuer == issuer && user.OIDCSubject == subject
+}
+
+func (r *memoryRepository) ClaimUnowned(ctx context.Context, ownerID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, list := range r.lists {
+		if list.OwnerID == nil {
+			list.OwnerID = copyID(&ownerID)
+		}
+	}
+	for _, todo := range r.todos {
+		if todo.OwnerID == nil {
+			todo.OwnerID = copyID(&ownerID)
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) CreateAPIKey(ctx context.Context, key *entity.APIKey) (*entity.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key.ID = primitive.NewObjectID()
+	stored := copyAPIKey(key)
+	stored.CreatedAt = normalizeTime(stored.CreatedAt)
+	r.apiKeys = append(r.apiKeys, stored)
+	return key, nil
+}
+
+func (r *memoryRepository) GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	keys := []*entity.APIKey{}
+	for _, key := range r.apiKeys {
+		if key.OwnedBy(ownerID) {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+	return keys, nil
+}
+
+func (r *memoryRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.apiKeys {
+		if key.Hash == hash {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, errors.ErrAPIKeyNotFound
+}
+
+func (r *memoryRepository) DeleteAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	for i, key := range r.apiKeys {
+		if key.ID == id && key.OwnedBy(ownerID) {
+			r.apiKeys = append(r.apiKeys[:i], r.apiKeys[i+1:]...)
+			return nil
+		}
+	}
+	return errors.ErrAPIKeyNotFound
+}
+
+func (r *memoryRepository) TouchAPIKey(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range r.apiKeys {
+		if key.ID == id {
+			usedAt = normalizeTime(usedAt)
+			key.LastUsedAt = &usedAt
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepository) AddMember(ctx context.Context, member *entity.Membership) (*entity.Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range r.members {
+		if m.ListID == member.ListID && m.UserID == member.UserID {
+			return nil, errors.ErrMemberExists
+		}
+	}
+
+	member.ID = primitive.NewObjectID()
+	stored := *member
+	stored.Email, stored.Username = "", ""
+	stored.CreatedAt = normalizeTime(stored.CreatedAt)
+	r.members = append(r.members, &stored)
+	return member, nil
+}
+
+func (r *memoryRepository) GetMembership(ctx context.Context, listID, userID primitive.ObjectID) (*entity.Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, m := range r.members {
+		if m.ListID == listID && m.UserID == userID {
+			c := *m
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrMemberNotFound
+}
+
+func (r *memoryRepository) GetMemberships(ctx context.Context, userID primitive.ObjectID) ([]*entity.Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	members := []*entity.Membership{}
+	for _, m := range r.members {
+		if m.UserID == userID {
+			c := *m
+			members = append(members, &c)
+		}
+	}
+	return members, nil
+}
+
+func (r *memoryRepository) GetMembers(ctx context.Context, listID primitive.ObjectID) ([]*entity.Membership, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	members := []*entity.Membership{}
+	for _, m := range r.members {
+		if m.ListID == listID && m.OwnedBy(ownerID) {
+			c := *m
+			members = append(members, &c)
+		}
+	}
+	return members, nil
+}
+
+func (r *memoryRepository) SetMemberRole(ctx context.Context, listID, userID primitive.ObjectID, role entity.Role) (*entity.Membership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	for _, m := range r.members {
+		if m.ListID == listID && m.UserID == userID && m.OwnedBy(ownerID) {
+			m.Role = role
+			c := *m
+			return &c, nil
+		}
+	}
+	return nil, errors.ErrMemberNotFound
+}
+
+func (r *memoryRepository) RemoveMember(ctx context.Context, listID, userID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	for i, m := range r.members {
+		if m.ListID == listID && m.UserID == userID && m.OwnedBy(ownerID) {
+			r.members = append(r.members[:i], r.members[i+1:]...)
+			return nil
+		}
+	}
+	return errors.ErrMemberNotFound
+}
+
+func (r *memoryRepository) RemoveMembers(ctx context.Context, listID primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	members := r.members[:0]
+	for _, m := range r.members {
+		if m.ListID != listID || !m.OwnedBy(ownerID) {
+			members = append(members, m)
+		}
+	}
+	r.members = members
+
+	invitations := r.invitations[:0]
+	for _, invitation := range r.invitations {
+		if invitation.ListID != listID || ownerID == nil || invitation.OwnerID != *ownerID {
+			invitations = append(invitations, invitation)
+		}
+	}
+	r.invitations = invitations
+	return nil
+}
+
+func (r *memoryRepository) CreateInvitation(ctx context.Context, invitation *entity.Invitation) (*entity.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitation.ID = primitive.NewObjectID()
+	stored := *invitation
+	stored.ExpiresAt = normalizeTime(stored.ExpiresAt)
+	stored.CreatedAt = normalizeTime(stored.CreatedAt)
+	r.invitations = append(r.invitations, &stored)
+	return invitation, nil
+}
+
+func (r *memoryRepository) TakeInvitation(ctx context.Context, hash string) (*entity.Invitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, invitation := range r.invitations {
+		if invitation.Hash == hash {
+			r.invitations = append(r.invitations[:i], r.invitations[i+1:]...)
+			return invitation, nil
+		}
+	}
+	return nil, errors.ErrInvitationNotFound
+}
+
+// todoLocked - задача id, если она принадлежит владельцу из контекста. Вызывается под r.mu
+func (r *memoryRepository) CreateNotifications(ctx context.Context, notifications []*entity.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, notification := range notifications {
+		notification.ID = primitive.NewObjectID()
+		stored := copyNotification(notification)
+		stored.CreatedAt = normalizeTime(stored.CreatedAt)
+		r.notifications = append(r.notifications, stored)
+	}
+	return nil
+}
+
+func (r *memoryRepository) GetNotifications(ctx context.Context, unreadOnly bool, limit int) ([]*entity.Notification, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	notifications := []*entity.Notification{}
+	// новые первыми: с конца порядка создания
+	for i := len(r.notifications) - 1; i >= 0 && len(notifications) < limit; i-- {
+		notification := r.notifications[i]
+		if notification.OwnedBy(ownerID) && (!unreadOnly || !notification.IsRead()) {
+			notifications = append(notifications, copyNotification(notification))
+		}
+	}
+	return notifications, nil
+}
+
+func (r *memoryRepository) SetNotificationRead(ctx context.Context, id primitive.ObjectID, readAt *time.Time) (*entity.Notification, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	for _, notification := range r.notifications {
+		if notification.ID == id && notification.OwnedBy(ownerID) {
+			notification.ReadAt = nil
+			if readAt != nil {
+				t := normalizeTime(*readAt)
+				notification.ReadAt = &t
+			}
+			return copyNotification(notification), nil
+		}
+	}
+	return nil, errors.ErrNotificationNotFound
+}
+
+func (r *memoryRepository) CreateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored := *comment
+	stored.ID = primitive.NewObjectID()
+	stored.CreatedAt = normalizeTime(stored.CreatedAt)
+	stored.UpdatedAt = normalizeTime(stored.UpdatedAt)
+	r.comments = append(r.comments, &stored)
+
+	result := stored
+	return &result, nil
+}
+
+func (r *memoryRepository) GetComment(ctx context.Context, id primitive.ObjectID) (*entity.Comment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	comment, ok := r.commentLocked(ctx, id)
+	if !ok {
+		return nil, errors.ErrCommentNotFound
+	}
+	result := *comment
+	return &result, nil
+}
+
+func (r *memoryRepository) GetComments(ctx context.Context, taskID primitive.ObjectID, after *primitive.ObjectID, limit int) ([]*entity.Comment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ownerID := entity.OwnerFromContext(ctx)
+	comments := []*entity.Comment{}
+	for _, comment := range r.comments {
+		if comment.TaskID != taskID || !comment.OwnedBy(ownerID) {
+			continue
+		}
+		if after != nil && bytes.Compare(comment.ID[:], after[:]) <= 0 {
+			continue
+		}
+		c := *comment
+		comments = append(comments, &c)
+	}
+
+	// по _id, как в Mongo
+	sort.Slice(comments, func(i, j int) bool {
+		return bytes.Compare(comments[i].ID[:], comments[j].ID[:]) < 0
+	})
+	if len(comments) > limit {
+		comments = comments[:limit]
+	}
+	return comments, nil
+}
+
+func (r *memoryRepository) UpdateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.commentLocked(ctx, comment.ID)
+	if !ok {
+		return nil, errors.ErrCommentNotFound
+	}
+	stored.Body = comment.Body
+	stored.UpdatedAt = normalizeTime(comment.UpdatedAt)
+
+	result := *stored
+	return &result, nil
+}
+
+func (r *memoryRepository) DeleteComment(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, comment := range r.comments {
+		if comment.ID == id && comment.OwnedBy(entity.OwnerFromContext(ctx)) {
+			r.comments = append(r.comments[:i], r.comments[i+1:]...)
+			return nil
+		}
+	}
+	return errors.ErrCommentNotFound
+}
+
+func (r *memoryRepository) CountComments(ctx context.Context, taskIDs []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	requested := make(map[primitive.ObjectID]bool, len(taskIDs))
+	for _, id := range taskIDs {
+		requested[id] = true
+	}
+
+	ownerID := entity.OwnerFromContext(ctx)
+	counts := make(map[primitive.ObjectID]int)
+	for _, comment := range r.comments {
+		if requested[comment.TaskID] && comment.OwnedBy(ownerID) {
+			counts[comment.TaskID]++
+		}
+	}
+	return counts, nil
+}
+
+// commentLocked - комментарий id, если он под задачей владельца из контекста. Вызывается под r.mu
+func (r *memoryRepository) commentLocked(ctx context.Context, id primitive.ObjectID) (*entity.Comment, bool) {
+	for _, comment := range r.comments {
+		if comment.ID == id && comment.OwnedBy(entity.OwnerFromContext(ctx)) {
+			return comment, true
+		}
+	}
+	return nil, false
+}
+
+func (r *memoryRepository) todoLocked(ctx context.Context, id primitive.ObjectID) (*entity.Todo, bool) {
+	todo, ok := r.todos[id]
+	if !ok || !todo.OwnedBy(entity.OwnerFromContext(ctx)) {
+		return nil, false
+	}
+	return todo, true
+}
+
+// listLocked - список id, если он принадлежит владельцу из контекста. Вызывается под r.mu
+func (r *memoryRepository) listLocked(ctx context.Context, id primitive.ObjectID) (*entity.List, bool) {
+	list, ok := r.lists[id]
+	if !ok || !list.OwnedBy(entity.OwnerFromContext(ctx)) {
+		return nil, false
+	}
+	return list, true
+}
+
+// listExistsLocked ищет у владельца ownerID список с именем name, кроме списка exceptID. Вызывается под r.mu
+func (r *memoryRepository) listExistsLocked(ownerID *primitive.ObjectID, name string, exceptID primitive.ObjectID) bool {
+	for id, list := range r.lists {
+		if id != exceptID && list.OwnedBy(ownerID) && list.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedLocked - у задачи есть незакрытые зависимости. Вызывается под r.mu
+func (r *memoryRepository) blockedLocked(todo *entity.Todo) bool {
+	for _, dependency := range todo.DependsOn {
+		if dependsOn, ok := r.todos[dependency]; ok && !dependsOn.Status.IsClosed() {
+			return true
+		}
+	}
+	return false
+}
+
+func sameParent(todo *entity.Todo, parentID primitive.ObjectID) bool {
+	return todo.ParentID != nil && *todo.ParentID == parentID
+}
+
+func (r *memoryRepository) Close() error {
+	return nil
+}
+
+// existsLocked ищет у владельца ownerID задачу с тем же title и activeAt в списке listID, кроме задачи exceptID.
+// Вызывается под r.mu
+func (r *memoryRepository) existsLocked(ownerID, listID *primitive.ObjectID, title string, activeAt time.Time, exceptID primitive.ObjectID) bool {
+	activeAt = normalizeTime(activeAt)
+	for id, todo := range r.todos {
+		if id != exceptID && todo.OwnedBy(ownerID) && todo.InList(listID) && todo.Title == title && todo.ActiveAt.Equal(activeAt) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyTodo(todo *entity.Todo) *entity.Todo {
+	c := *todo
+	c.OwnerID = copyID(todo.OwnerID)
+	c.ListID = copyID(todo.ListID)
+	c.ParentID = copyID(todo.ParentID)
+	c.AssigneeID = copyID(todo.AssigneeID)
+	c.DependsOn = append([]primitive.ObjectID(nil), todo.DependsOn...)
+	c.Tags = append([]string(nil), todo.Tags...)
+	if todo.SeriesStart != nil {
+		seriesStart := *todo.SeriesStart
+		c.SeriesStart = &seriesStart
+	}
+	return &c
+}
+
+func copyID(id *primitive.ObjectID) *primitive.ObjectID {
+	if id == nil {
+		return nil
+	}
+	c := *id
+	return &c
+}
+
+// storedCopy - копия задачи в том виде, в котором ее вернула бы MongoDB
+func storedCopy(todo *entity.Todo) *entity.Todo {
+	c := copyTodo(todo)
+	c.ActiveAt = normalizeTime(c.ActiveAt)
+	c.CreatedAt = normalizeTime(c.CreatedAt)
+	c.UpdatedAt = normalizeTime(c.UpdatedAt)
+	if c.CompletedAt != nil {
+		completedAt := normalizeTime(*c.CompletedAt)
+		c.CompletedAt = &completedAt
+	}
+	if c.SeriesStart != nil {
+		seriesStart := normalizeTime(*c.SeriesStart)
+		c.SeriesStart = &seriesStart
+	}
+	return c
+}
+
+func copyList(list *entity.List) *entity.List {
+	c := *list
+	c.OwnerID = copyID(list.OwnerID)
+	if list.ArchivedAt != nil {
+		archivedAt := *list.ArchivedAt
+		c.ArchivedAt = &archivedAt
+	}
+	return &c
+}
+
+// storedListCopy - копия списка с точностью времени BSON, как у storedCopy
+func storedListCopy(list *entity.List) *entity.List {
+	c := copyList(list)
+	c.CreatedAt = normalizeTime(c.CreatedAt)
+	c.UpdatedAt = normalizeTime(c.UpdatedAt)
+	if c.ArchivedAt != nil {
+		archivedAt := normalizeTime(*c.ArchivedAt)
+		c.ArchivedAt = &archivedAt
+	}
+	return c
+}
+
+func copyAPIKey(key *entity.APIKey) *entity.APIKey {
+	c := *key
+	c.Scopes = append([]entity.Scope(nil), key.Scopes...)
+	if key.LastUsedAt != nil {
+		lastUsedAt := *key.LastUsedAt
+		c.LastUsedAt = &lastUsedAt
+	}
+	return &c
+}
+
+func copyNotification(notification *entity.Notification) *entity.Notification {
+	c := *notification
+	c.ListID = copyID(notification.ListID)
+	if notification.ReadAt != nil {
+		readAt := *notification.ReadAt
+		c.ReadAt = &readAt
+	}
+	return &c
+}
+
+// normalizeTime приводит время к точности BSON datetime: миллисекунды в UTC
+func normalizeTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Millisecond)
+}
+
+// memoryTenants - организации в памяти процесса, у каждой свой memoryRepository
+type memoryTenants struct {
+	mu      sync.Mutex
+	tenants map[string]*entity.Tenant
+	data    map[string]TodoRepository
+}
+
+// NewMemoryTenants - TenantBackend в памяти, для тестов и локального запуска без MongoDB
+func NewMemoryTenants() TenantBackend {
+	return &memoryTenants{
+		tenants: make(map[string]*entity.Tenant),
+		data:    make(map[string]TodoRepository),
+	}
+}
+
+func (t *memoryTenants) CreateTenant(ctx context.Context, tenant *entity.Tenant) (*entity.Tenant, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.tenants[tenant.ID]; ok {
+		return nil, errors.ErrTenantExists
+	}
+	c := *tenant
+	c.CreatedAt = normalizeTime(c.CreatedAt)
+	t.tenants[c.ID] = &c
+	result := c
+	return &result, nil
+}
+
+func (t *memoryTenants) GetTenant(ctx context.Context, id string) (*entity.Tenant, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tenant, ok := t.tenants[id]
+	if !ok {
+		return nil, errors.ErrTenantNotFound
+	}
+	c := *tenant
+	return &c, nil
+}
+
+func (t *memoryTenants) GetTenants(ctx context.Context) ([]*entity.Tenant, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tenants := make([]*entity.Tenant, 0, len(t.tenants))
+	for _, tenant := range t.tenants {
+		c := *tenant
+		tenants = append(tenants, &c)
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+func (t *memoryTenants) DeleteTenant(ctx context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.tenants[id]; !ok {
+		return errors.ErrTenantNotFound
+	}
+	delete(t.tenants, id)
+	return nil
+}
+
+// Open возвращает одно и то же хранилище организации, пока его не удалит Drop: данные в памяти закрытием не теряются
+func (t *memoryTenants) Open(ctx context.Context, tenantID string) (TodoRepository, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	repository, ok := t.data[tenantID]
+	if !ok {
+		repository = NewMemoryRepository()
+		t.data[tenantID] = repository
+	}
+	return repository, nil
+}
+
+func (t *memoryTenants) Drop(ctx context.Context, tenantID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.data, tenantID)
+	return nil
+}
+
+func (t *memoryTenants) Close() error {
+	return nil
+}
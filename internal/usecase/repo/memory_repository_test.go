@@ -0,0 +1,14 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/repo/repotest"
+)
+
+func TestMemoryRepositoryContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repo.TodoRepository {
+		return repo.NewMemoryRepository()
+	})
+}
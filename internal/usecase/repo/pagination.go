@@ -0,0 +1,157 @@
+package repo
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// pageCursor - позиция последней задачи страницы. Сортировка всегда идет по (поле, _id),
+// поэтому следующая страница начинается строго после этой пары и не зависит от вставок и удалений
+type pageCursor struct {
+	Sort       entity.SortField   `json:"s"`
+	Descending bool               `json:"d,omitempty"`
+	Time       *time.Time         `json:"t,omitempty"`
+	Title      *string            `json:"v,omitempty"`
+	ID         primitive.ObjectID `json:"id"`
+}
+
+// normalizeListOptions подставляет сортировку по умолчанию и проверяет поле сортировки:
+// оно попадает в запрос как имя поля, поэтому неизвестные значения не пропускаются
+func normalizeListOptions(opts entity.ListOptions) (entity.ListOptions, error) {
+	if opts.Sort == "" {
+		opts.Sort = entity.SortActiveAt
+	}
+	if _, _, err := entity.ParseSort(string(opts.Sort)); err != nil {
+		return opts, err
+	}
+	if opts.Limit < 0 {
+		return opts, errors.ErrInvalidLimit
+	}
+	return opts, nil
+}
+
+// decodeCursor возвращает nil, если курсора нет. Курсор от другой сортировки считается некорректным
+func decodeCursor(opts entity.ListOptions) (*pageCursor, error) {
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	if cursor.Sort != opts.Sort || cursor.Descending != opts.Descending || cursor.ID.IsZero() {
+		return nil, errors.ErrInvalidCursor
+	}
+	if (opts.Sort == entity.SortTitle && cursor.Title == nil) || (opts.Sort != entity.SortTitle && cursor.Time == nil) {
+		return nil, errors.ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+func encodeCursor(opts entity.ListOptions, last *entity.Todo) string {
+	cursor := pageCursor{
+		Sort:       opts.Sort,
+		Descending: opts.Descending,
+		ID:         last.ID,
+	}
+
+	switch value := last.SortValue(opts.Sort).(type) {
+	case time.Time:
+		cursor.Time = &value
+	case string:
+		cursor.Title = &value
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// value - значение поля сортировки из курсора
+func (c *pageCursor) value() interface{} {
+	if c.Title != nil {
+		return *c.Title
+	}
+	return *c.Time
+}
+
+// newPage собирает страницу из выборки, запрошенной с лимитом Limit+1: лишняя задача значит, что есть следующая страница
+func newPage(todos []*entity.Todo, opts entity.ListOptions) *entity.TodoPage {
+	page := &entity.TodoPage{Tasks: todos}
+	if page.Tasks == nil {
+		page.Tasks = []*entity.Todo{}
+	}
+
+	if opts.Limit > 0 && len(page.Tasks) > opts.Limit {
+		page.Tasks = page.Tasks[:opts.Limit]
+		page.NextCursor = encodeCursor(opts, page.Tasks[opts.Limit-1])
+	}
+
+	return page
+}
+
+// compareTodos сравнивает задачи по (поле сортировки, ID), как это делают индексы хранилищ
+func compareTodos(a, b *entity.Todo, field entity.SortField) int {
+	if c := compareValues(a.SortValue(field), b.SortValue(field)); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.ID[:], b.ID[:])
+}
+
+// afterCursor - задача идет строго после позиции курсора в порядке сортировки
+func afterCursor(todo *entity.Todo, cursor *pageCursor) bool {
+	c := compareValues(todo.SortValue(cursor.Sort), cursor.value())
+	if c == 0 {
+		c = bytes.Compare(todo.ID[:], cursor.ID[:])
+	}
+	if cursor.Descending {
+		return c < 0
+	}
+	return c > 0
+}
+
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		b := b.(time.Time)
+		switch {
+		case a.Before(b):
+			return -1
+		case a.After(b):
+			return 1
+		}
+	case string:
+		b := b.(string)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+// inRange проверяет включительные границы фильтра
+func inRange(t time.Time, from, to *time.Time) bool {
+	if from != nil && t.Before(*from) {
+		return false
+	}
+	if to != nil && t.After(*to) {
+		return false
+	}
+	return true
+}
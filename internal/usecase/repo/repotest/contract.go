@@ -0,0 +1,1557 @@
+// Package repotest содержит общий контрактный набор тестов для реализаций repo.TodoRepository.
+// Каждое хранилище прогоняет один и тот же набор, чтобы поведение всех бэкендов совпадало
+package repotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Factory создает пустой изолированный репозиторий для одного теста.
+// Очистку (удаление коллекции, закрытие подключения) фабрика регистрирует через t.Cleanup
+type Factory func(t *testing.T) repo.TodoRepository
+
+// Run прогоняет контрактный набор против репозиториев, созданных newRepo
+func Run(t *testing.T, newRepo Factory) {
+	suite.Run(t, &ContractSuite{newRepo: newRepo})
+}
+
+type ContractSuite struct {
+	suite.Suite
+	newRepo    Factory
+	repository repo.TodoRepository
+	ctx        context.Context
+}
+
+func (s *ContractSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.repository = s.newRepo(s.T())
+}
+
+// today - полночь текущего дня в UTC, такие задачи уже считаются активными
+func today() time.Time {
+	now := time.Now().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (s *ContractSuite) create(title string, activeAt time.Time) *entity.Todo {
+	todo, err := s.repository.CreateNewTodo(s.ctx, entity.NewTodo(title, activeAt))
+	s.Require().NoError(err, "Ошибка создания задачи")
+	return todo
+}
+
+// transition переводит задачу в статус to так же, как это делает сервис
+func (s *ContractSuite) transition(todo *entity.Todo, to entity.TaskStatus) *entity.Todo {
+	from := todo.Status
+	s.Require().NoError(todo.TransitionTo(to))
+
+	updatedTodo, err := s.repository.SetStatus(s.ctx, todo.ID, from, todo)
+	s.Require().NoError(err, "Ошибка смены статуса задачи")
+	return updatedTodo
+}
+
+func titles(todos []*entity.Todo) []string {
+	result := make([]string, 0, len(todos))
+	for _, todo := range todos {
+		result = append(result, todo.Title)
+	}
+	return result
+}
+
+func (s *ContractSuite) TestCreateAndRetrieveTodos() {
+	numTodos := 3
+	todos := make([]*entity.Todo, numTodos)
+
+	for i := 0; i < numTodos; i++ {
+		todos[i] = s.create(fmt.Sprintf("Test Task %d", i+1), today())
+		s.False(todos[i].ID.IsZero(), "Репозиторий должен присвоить ID")
+	}
+
+	for _, createdTodo := range todos {
+		retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+		s.Require().NoError(err, "Не удалось получить задачу из хранилища")
+
+		s.Equal(createdTodo.ID, retrievedTodo.ID)
+		s.Equal(createdTodo.Title, retrievedTodo.Title)
+		s.True(createdTodo.ActiveAt.Equal(retrievedTodo.ActiveAt))
+		s.Equal(entity.StatusTodo, retrievedTodo.Status)
+		s.Nil(retrievedTodo.CompletedAt)
+		s.WithinDuration(createdTodo.CreatedAt, retrievedTodo.CreatedAt, time.Millisecond)
+	}
+}
+
+func (s *ContractSuite) TestCreateValidates() {
+	_, err := s.repository.CreateNewTodo(s.ctx, entity.NewTodo("", today()))
+	s.ErrorIs(err, errors.ErrTitleEmpty)
+
+	_, err = s.repository.CreateNewTodo(s.ctx, entity.NewTodo("Past Task", today().AddDate(0, 0, -1)))
+	s.ErrorIs(err, errors.ErrDateNotCurrent)
+}
+
+func (s *ContractSuite) TestCreateDuplicate() {
+	s.create("Duplicate Task", today())
+
+	_, err := s.repository.CreateNewTodo(s.ctx, entity.NewTodo("Duplicate Task", today()))
+	s.ErrorIs(err, errors.ErrTodoExists)
+
+	// тот же заголовок на другую дату - уже другая задача
+	s.create("Duplicate Task", today().AddDate(0, 0, 1))
+}
+
+func (s *ContractSuite) TestConcurrentCreateDuplicate() {
+	const attempts = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.repository.CreateNewTodo(s.ctx, entity.NewTodo("Concurrent Task", today()))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// ровно одна вставка проходит, остальные видят дубликат
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		s.ErrorIs(err, errors.ErrTodoExists)
+	}
+	s.Equal(1, created)
+
+	page, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Len(page.Tasks, 1)
+}
+
+func (s *ContractSuite) TestGetUnknownTask() {
+	_, err := s.repository.GetTaskByID(s.ctx, primitive.NewObjectID())
+	s.ErrorIs(err, errors.ErrNotFound)
+}
+
+func (s *ContractSuite) TestUpdateTodo() {
+	createdTodo := s.transition(s.create("Test Task", today()), entity.StatusInProgress)
+
+	updatedTodo := &entity.Todo{
+		Title:    "Updated Test Task",
+		ActiveAt: today().Add(24 * time.Hour),
+		Status:   entity.StatusDone,
+	}
+	updatedTodo, err := s.repository.UpdateTodo(s.ctx, createdTodo.ID, updatedTodo)
+	s.Require().NoError(err, "Ошибка обновления задачи")
+	s.Equal(createdTodo.ID, updatedTodo.ID)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err, "Не удалось получить задачу из хранилища")
+
+	s.Equal(updatedTodo.Title, retrievedTodo.Title)
+	s.True(updatedTodo.ActiveAt.Equal(retrievedTodo.ActiveAt))
+	// статус меняется только переходами, полная замена его не трогает
+	s.Equal(entity.StatusInProgress, retrievedTodo.Status)
+	s.WithinDuration(createdTodo.CreatedAt, retrievedTodo.CreatedAt, time.Millisecond)
+}
+
+func (s *ContractSuite) TestUpdateUnknownTask() {
+	_, err := s.repository.UpdateTodo(s.ctx, primitive.NewObjectID(), entity.NewTodo("Task", today()))
+	s.ErrorIs(err, errors.ErrNotFound)
+}
+
+func (s *ContractSuite) TestUpdateToDuplicate() {
+	s.create("First Task", today())
+	second := s.create("Second Task", today())
+
+	_, err := s.repository.UpdateTodo(s.ctx, second.ID, entity.NewTodo("First Task", today()))
+	s.ErrorIs(err, errors.ErrTodoExists)
+
+	// обновление задачи теми же title и activeAt дубликатом не считается
+	_, err = s.repository.UpdateTodo(s.ctx, second.ID, entity.NewTodo("Second Task", today()))
+	s.NoError(err)
+}
+
+func (s *ContractSuite) TestPatchTodo() {
+	createdTodo := s.transition(s.create("Test Task", today()), entity.StatusDone)
+
+	title := "Patched Task"
+	patchedTodo, err := s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{Title: &title})
+	s.Require().NoError(err, "Ошибка частичного обновления задачи")
+	s.Equal(title, patchedTodo.Title)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal(title, retrievedTodo.Title)
+	// поля, которых нет в патче, не меняются
+	s.Equal(entity.StatusDone, retrievedTodo.Status)
+	s.NotNil(retrievedTodo.CompletedAt)
+	s.True(createdTodo.ActiveAt.Equal(retrievedTodo.ActiveAt))
+	s.WithinDuration(createdTodo.CreatedAt, retrievedTodo.CreatedAt, time.Millisecond)
+
+	status := entity.StatusTodo
+	activeAt := today().AddDate(0, 0, 3)
+	patchedTodo, err = s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{Status: &status, ActiveAt: &activeAt})
+	s.Require().NoError(err)
+	s.Equal(entity.StatusTodo, patchedTodo.Status)
+	s.Nil(patchedTodo.CompletedAt)
+	s.True(activeAt.Equal(patchedTodo.ActiveAt))
+	s.Equal(title, patchedTodo.Title)
+}
+
+func (s *ContractSuite) TestDescription() {
+	todo := entity.NewTodo("Test Task", today())
+	todo.Description = "# План\n- [ ] купить\n- [x] приготовить"
+	createdTodo, err := s.repository.CreateNewTodo(s.ctx, todo)
+	s.Require().NoError(err)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal(todo.Description, retrievedTodo.Description)
+
+	description := "- [x] купить\n- [x] приготовить"
+	patchedTodo, err := s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{Description: &description})
+	s.Require().NoError(err)
+	s.Equal(description, patchedTodo.Description)
+	s.Equal("Test Task", patchedTodo.Title)
+
+	// PUT заменяет задачу целиком, описание без значения очищается
+	_, err = s.repository.UpdateTodo(s.ctx, createdTodo.ID, entity.NewTodo("Test Task", today()))
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Empty(retrievedTodo.Description)
+}
+
+// createSubtask создает подзадачу parent так же, как это делает сервис
+func (s *ContractSuite) createSubtask(parent *entity.Todo, title string) *entity.Todo {
+	todo, err := s.repository.CreateNewTodo(s.ctx, entity.TodoFields{
+		Title:    title,
+		ActiveAt: today(),
+		ParentID: &parent.ID,
+	}.NewTodo())
+	s.Require().NoError(err, "Ошибка создания подзадачи")
+	return todo
+}
+
+func (s *ContractSuite) TestSubtasks() {
+	parent := s.create("Parent", today())
+	other := s.create("Other Parent", today())
+	first := s.createSubtask(parent, "First Subtask")
+	s.createSubtask(parent, "Second Subtask")
+	s.createSubtask(other, "Other Subtask")
+	s.createSubtask(first, "Nested Subtask")
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, first.ID)
+	s.Require().NoError(err)
+	s.Require().NotNil(retrievedTodo.ParentID)
+	s.Equal(parent.ID, *retrievedTodo.ParentID)
+
+	subtasks, err := s.repository.GetSubtasks(s.ctx, []primitive.ObjectID{parent.ID})
+	s.Require().NoError(err)
+	s.ElementsMatch([]string{"First Subtask", "Second Subtask"}, titles(subtasks))
+
+	subtasks, err = s.repository.GetSubtasks(s.ctx, []primitive.ObjectID{parent.ID, other.ID})
+	s.Require().NoError(err)
+	s.Len(subtasks, 3)
+
+	subtasks, err = s.repository.GetSubtasks(s.ctx, nil)
+	s.Require().NoError(err)
+	s.Empty(subtasks)
+
+	page, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{ParentID: &parent.ID, Sort: entity.SortTitle})
+	s.Require().NoError(err)
+	s.Equal([]string{"First Subtask", "Second Subtask"}, titles(page.Tasks))
+
+	// PUT и PATCH родителя не меняют
+	_, err = s.repository.UpdateTodo(s.ctx, first.ID, entity.NewTodo("First Subtask", today()))
+	s.Require().NoError(err)
+	title := "Renamed Subtask"
+	_, err = s.repository.PatchTodo(s.ctx, first.ID, &entity.TodoPatch{Title: &title})
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, first.ID)
+	s.Require().NoError(err)
+	s.Require().NotNil(retrievedTodo.ParentID)
+	s.Equal(parent.ID, *retrievedTodo.ParentID)
+}
+
+func (s *ContractSuite) TestSetParent() {
+	parent := s.create("Parent", today())
+	other := s.create("Other Parent", today())
+	first := s.createSubtask(parent, "First Subtask")
+	second := s.createSubtask(parent, "Second Subtask")
+
+	s.Require().NoError(s.repository.SetParent(s.ctx, []primitive.ObjectID{first.ID}, &other.ID))
+	s.Require().NoError(s.repository.SetParent(s.ctx, []primitive.ObjectID{second.ID}, nil))
+	s.Require().NoError(s.repository.SetParent(s.ctx, nil, nil))
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, first.ID)
+	s.Require().NoError(err)
+	s.Require().NotNil(retrievedTodo.ParentID)
+	s.Equal(other.ID, *retrievedTodo.ParentID)
+
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, second.ID)
+	s.Require().NoError(err)
+	s.Nil(retrievedTodo.ParentID)
+
+	subtasks, err := s.repository.GetSubtasks(s.ctx, []primitive.ObjectID{parent.ID})
+	s.Require().NoError(err)
+	s.Empty(subtasks)
+}
+
+// createDependent создает задачу, зависящую от dependsOn
+func (s *ContractSuite) createDependent(title string, dependsOn ...*entity.Todo) *entity.Todo {
+	todo, err := s.repository.CreateNewTodo(s.ctx, entity.TodoFields{
+		Title:     title,
+		ActiveAt:  today(),
+		DependsOn: todoIDs(dependsOn),
+	}.NewTodo())
+	s.Require().NoError(err, "Ошибка создания задачи с зависимостями")
+	return todo
+}
+
+func todoIDs(todos []*entity.Todo) []primitive.ObjectID {
+	var ids []primitive.ObjectID
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func (s *ContractSuite) TestDependencies() {
+	first := s.create("First", today())
+	second := s.create("Second", today())
+	dependent := s.createDependent("Dependent", first, second)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, dependent.ID)
+	s.Require().NoError(err)
+	s.Equal([]primitive.ObjectID{first.ID, second.ID}, retrievedTodo.DependsOn)
+
+	active := func(opts entity.ListOptions) []string {
+		page, err := s.repository.GetTasksByStatus(s.ctx, entity.StatusFilterActive, opts)
+		s.Require().NoError(err)
+		return titles(page.Tasks)
+	}
+
+	// пока зависимости открыты, задачи нет среди активных, если не попросить явно
+	s.ElementsMatch([]string{"First", "Second"}, active(entity.ListOptions{}))
+	s.ElementsMatch([]string{"First", "Second", "Dependent"}, active(entity.ListOptions{IncludeBlocked: true}))
+
+	// отмененная зависимость тоже считается закрытой
+	s.transition(first, entity.StatusDone)
+	s.ElementsMatch([]string{"Second"}, active(entity.ListOptions{}))
+	s.transition(second, entity.StatusCancelled)
+	s.ElementsMatch([]string{"Dependent"}, active(entity.ListOptions{}))
+
+	// статусы конкретных задач зависимости не фильтруют
+	page, err := s.repository.GetTasksByStatus(s.ctx, string(entity.StatusTodo), entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Equal([]string{"Dependent"}, titles(page.Tasks))
+}
+
+func (s *ContractSuite) TestUpdateDependencies() {
+	first := s.create("First", today())
+	second := s.create("Second", today())
+	dependent := s.createDependent("Dependent", first)
+
+	// PATCH заменяет список целиком
+	dependsOn := []primitive.ObjectID{second.ID}
+	patchedTodo, err := s.repository.PatchTodo(s.ctx, dependent.ID, &entity.TodoPatch{DependsOn: &dependsOn})
+	s.Require().NoError(err)
+	s.Equal(dependsOn, patchedTodo.DependsOn)
+
+	// PUT без зависимостей их очищает
+	_, err = s.repository.UpdateTodo(s.ctx, dependent.ID, entity.NewTodo("Dependent", today()))
+	s.Require().NoError(err)
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, dependent.ID)
+	s.Require().NoError(err)
+	s.Empty(retrievedTodo.DependsOn)
+
+	_, err = s.repository.UpdateTodo(s.ctx, dependent.ID, entity.TodoFields{
+		Title:     "Dependent",
+		ActiveAt:  today(),
+		DependsOn: []primitive.ObjectID{first.ID, second.ID},
+	}.NewTodo())
+	s.Require().NoError(err)
+
+	title := "Renamed"
+	_, err = s.repository.PatchTodo(s.ctx, dependent.ID, &entity.TodoPatch{Title: &title})
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, dependent.ID)
+	s.Require().NoError(err)
+	s.Equal([]primitive.ObjectID{first.ID, second.ID}, retrievedTodo.DependsOn)
+
+	var empty []primitive.ObjectID
+	_, err = s.repository.PatchTodo(s.ctx, dependent.ID, &entity.TodoPatch{DependsOn: &empty})
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, dependent.ID)
+	s.Require().NoError(err)
+	s.Empty(retrievedTodo.DependsOn)
+}
+
+func (s *ContractSuite) TestRemoveDependency() {
+	first := s.create("First", today())
+	second := s.create("Second", today())
+	dependent := s.createDependent("Dependent", first, second)
+	other := s.createDependent("Other", first)
+
+	s.Require().NoError(s.repository.RemoveDependency(s.ctx, first.ID))
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, dependent.ID)
+	s.Require().NoError(err)
+	s.Equal([]primitive.ObjectID{second.ID}, retrievedTodo.DependsOn)
+
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, other.ID)
+	s.Require().NoError(err)
+	s.Empty(retrievedTodo.DependsOn)
+}
+
+func (s *ContractSuite) TestGetTasksByIDs() {
+	later := s.create("Later", today().AddDate(0, 0, 1))
+	first := s.create("First", today())
+
+	todos, err := s.repository.GetTasksByIDs(s.ctx, []primitive.ObjectID{later.ID, primitive.NewObjectID(), first.ID})
+	s.Require().NoError(err)
+	s.Equal([]string{"First", "Later"}, titles(todos))
+
+	todos, err = s.repository.GetTasksByIDs(s.ctx, nil)
+	s.Require().NoError(err)
+	s.NotNil(todos)
+	s.Empty(todos)
+}
+
+func (s *ContractSuite) TestRecurrence() {
+	createdTodo, err := s.repository.CreateNewTodo(s.ctx, entity.TodoFields{
+		Title:    "Weekly Review",
+		ActiveAt: today(),
+		RRule:    "FREQ=WEEKLY;BYDAY=FR",
+	}.NewTodo())
+	s.Require().NoError(err)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal("FREQ=WEEKLY;BYDAY=FR", retrievedTodo.RRule)
+	s.Require().NotNil(retrievedTodo.SeriesStart)
+	s.True(retrievedTodo.SeriesStart.Equal(today()))
+
+	// PATCH других полей правило не трогает
+	title := "Friday Review"
+	_, err = s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{Title: &title})
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal("FREQ=WEEKLY;BYDAY=FR", retrievedTodo.RRule)
+
+	rule := "FREQ=MONTHLY"
+	patchedTodo, err := s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{RRule: &rule})
+	s.Require().NoError(err)
+	s.Equal(rule, patchedTodo.RRule)
+	s.NotNil(patchedTodo.SeriesStart)
+
+	rule = ""
+	_, err = s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{RRule: &rule})
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Empty(retrievedTodo.RRule)
+	s.Nil(retrievedTodo.SeriesStart)
+
+	// PUT без правила его очищает
+	_, err = s.repository.UpdateTodo(s.ctx, createdTodo.ID, entity.TodoFields{Title: "Review", ActiveAt: today(), RRule: "FREQ=DAILY"}.NewTodo())
+	s.Require().NoError(err)
+	_, err = s.repository.UpdateTodo(s.ctx, createdTodo.ID, entity.NewTodo("Review", today()))
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Empty(retrievedTodo.RRule)
+	s.Nil(retrievedTodo.SeriesStart)
+}
+
+// list - заголовки всех задач, подходящих под opts
+func (s *ContractSuite) list(opts entity.ListOptions) []string {
+	page, err := s.repository.GetAllTasks(s.ctx, opts)
+	s.Require().NoError(err)
+	return titles(page.Tasks)
+}
+
+func (s *ContractSuite) createTagged(title string, tags ...string) *entity.Todo {
+	todo, err := s.repository.CreateNewTodo(s.ctx, entity.TodoFields{Title: title, ActiveAt: today(), Tags: tags}.NewTodo())
+	s.Require().NoError(err, "Ошибка создания задачи с метками")
+	return todo
+}
+
+func (s *ContractSuite) TestTags() {
+	s.createTagged("Report", "work", "urgent")
+	s.createTagged("Groceries", "home")
+	s.createTagged("Deploy", "work")
+	s.create("Untagged", today())
+
+	s.ElementsMatch([]string{"Report", "Deploy"}, s.list(entity.ListOptions{TagsAll: []string{"work"}}))
+	s.ElementsMatch([]string{"Report"}, s.list(entity.ListOptions{TagsAll: []string{"work", "urgent"}}))
+	s.ElementsMatch([]string{"Report", "Groceries"}, s.list(entity.ListOptions{TagsAny: []string{"urgent", "home"}}))
+	s.ElementsMatch([]string{"Report"}, s.list(entity.ListOptions{TagsAny: []string{"urgent", "home"}, TagsAll: []string{"work"}}))
+	s.Empty(s.list(entity.ListOptions{TagsAny: []string{"missing"}}))
+
+	page, err := s.repository.GetTasksByStatus(s.ctx, entity.StatusFilterActive, entity.ListOptions{TagsAll: []string{"home"}})
+	s.Require().NoError(err)
+	s.Equal([]string{"Groceries"}, titles(page.Tasks))
+
+	tags, err := s.repository.GetTags(s.ctx)
+	s.Require().NoError(err)
+	s.Equal([]*entity.TagCount{{Name: "work", Count: 2}, {Name: "home", Count: 1}, {Name: "urgent", Count: 1}}, tags)
+}
+
+func (s *ContractSuite) TestUpdateTags() {
+	todo := s.createTagged("Report", "work")
+
+	tags := []string{"work", "q3"}
+	patchedTodo, err := s.repository.PatchTodo(s.ctx, todo.ID, &entity.TodoPatch{Tags: &tags})
+	s.Require().NoError(err)
+	s.Equal(tags, patchedTodo.Tags)
+	s.ElementsMatch([]string{"Report"}, s.list(entity.ListOptions{TagsAll: []string{"q3"}}))
+
+	// PUT без меток их очищает, и задача пропадает из фильтра
+	_, err = s.repository.UpdateTodo(s.ctx, todo.ID, entity.NewTodo("Report", today()))
+	s.Require().NoError(err)
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, todo.ID)
+	s.Require().NoError(err)
+	s.Empty(retrievedTodo.Tags)
+	s.Empty(s.list(entity.ListOptions{TagsAll: []string{"work"}}))
+
+	tagCounts, err := s.repository.GetTags(s.ctx)
+	s.Require().NoError(err)
+	s.NotNil(tagCounts)
+	s.Empty(tagCounts)
+}
+
+func (s *ContractSuite) TestRenameTags() {
+	report := s.createTagged("Report", "job", "urgent", "work")
+	deploy := s.createTagged("Deploy", "urgent", "job")
+	groceries := s.createTagged("Groceries", "home")
+
+	// job сливается с уже существующей work и встает на место первой из них
+	renamed, err := s.repository.RenameTags(s.ctx, []string{"job"}, "work")
+	s.Require().NoError(err)
+	s.Equal(2, renamed)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, report.ID)
+	s.Require().NoError(err)
+	s.Equal([]string{"work", "urgent"}, retrievedTodo.Tags)
+	s.False(retrievedTodo.UpdatedAt.Before(report.UpdatedAt.Truncate(time.Millisecond)))
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, deploy.ID)
+	s.Require().NoError(err)
+	s.Equal([]string{"urgent", "work"}, retrievedTodo.Tags)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, groceries.ID)
+	s.Require().NoError(err)
+	s.Equal([]string{"home"}, retrievedTodo.Tags)
+
+	s.ElementsMatch([]string{"Report", "Deploy"}, s.list(entity.ListOptions{TagsAll: []string{"work"}}))
+	s.Empty(s.list(entity.ListOptions{TagsAll: []string{"job"}}))
+
+	renamed, err = s.repository.RenameTags(s.ctx, []string{"urgent", "home"}, "later")
+	s.Require().NoError(err)
+	s.Equal(3, renamed)
+
+	tags, err := s.repository.GetTags(s.ctx)
+	s.Require().NoError(err)
+	s.Equal([]*entity.TagCount{{Name: "later", Count: 3}, {Name: "work", Count: 2}}, tags)
+
+	renamed, err = s.repository.RenameTags(s.ctx, []string{"missing"}, "work")
+	s.Require().NoError(err)
+	s.Zero(renamed)
+}
+
+func (s *ContractSuite) TestPriority() {
+	createdTodo, err := s.repository.CreateNewTodo(s.ctx, entity.TodoFields{
+		Title:    "Incident",
+		ActiveAt: today(),
+		Priority: entity.PriorityUrgent,
+	}.NewTodo())
+	s.Require().NoError(err)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal(entity.PriorityUrgent, retrievedTodo.Priority)
+
+	priority := entity.PriorityLow
+	patchedTodo, err := s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{Priority: &priority})
+	s.Require().NoError(err)
+	s.Equal(entity.PriorityLow, patchedTodo.Priority)
+
+	// PUT без приоритета возвращает normal
+	_, err = s.repository.UpdateTodo(s.ctx, createdTodo.ID, entity.NewTodo("Incident", today()))
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal(entity.PriorityNormal, retrievedTodo.Priority)
+
+	// задача без приоритета сохраняется как normal
+	todo := entity.NewTodo("Legacy", today())
+	todo.Priority = ""
+	createdTodo, err = s.repository.CreateNewTodo(s.ctx, todo)
+	s.Require().NoError(err)
+	retrievedTodo, err = s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal(entity.PriorityNormal, retrievedTodo.Priority)
+}
+
+func (s *ContractSuite) createList(name string) *entity.List {
+	list, err := s.repository.CreateList(s.ctx, entity.NewList(name))
+	s.Require().NoError(err, "Ошибка создания списка")
+	return list
+}
+
+func (s *ContractSuite) createInList(listID *primitive.ObjectID, title string) *entity.Todo {
+	todo, err := s.repository.CreateNewTodo(s.ctx, entity.TodoFields{Title: title, ActiveAt: today(), ListID: listID}.NewTodo())
+	s.Require().NoError(err, "Ошибка создания задачи в списке")
+	return todo
+}
+
+func (s *ContractSuite) TestLists() {
+	work := s.createList("Work")
+	home := s.createList("Home")
+
+	_, err := s.repository.CreateList(s.ctx, entity.NewList("Work"))
+	s.ErrorIs(err, errors.ErrListExists)
+	_, err = s.repository.CreateList(s.ctx, entity.NewList("  "))
+	s.ErrorIs(err, errors.ErrInvalidListName)
+
+	retrieved, err := s.repository.GetList(s.ctx, work.ID)
+	s.Require().NoError(err)
+	s.Equal("Work", retrieved.Name)
+	s.False(retrieved.IsArchived())
+
+	_, err = s.repository.GetList(s.ctx, primitive.NewObjectID())
+	s.ErrorIs(err, errors.ErrListNotFound)
+
+	// переименование в занятое имя отклоняется, архивный список пропадает из GetLists без includeArchived
+	home.Name = "Work"
+	_, err = s.repository.UpdateList(s.ctx, home)
+	s.ErrorIs(err, errors.ErrListExists)
+
+	archivedAt := time.Now()
+	home.Name = "Personal"
+	home.ArchivedAt = &archivedAt
+	home.UpdatedAt = archivedAt
+	updated, err := s.repository.UpdateList(s.ctx, home)
+	s.Require().NoError(err)
+	s.Equal("Personal", updated.Name)
+	s.True(updated.IsArchived())
+
+	lists, err := s.repository.GetLists(s.ctx, false)
+	s.Require().NoError(err)
+	s.Equal([]string{"Work"}, listNames(lists))
+	lists, err = s.repository.GetLists(s.ctx, true)
+	s.Require().NoError(err)
+	s.Equal([]string{"Personal", "Work"}, listNames(lists))
+
+	home.ArchivedAt = nil
+	updated, err = s.repository.UpdateList(s.ctx, home)
+	s.Require().NoError(err)
+	s.False(updated.IsArchived())
+
+	s.Require().NoError(s.repository.DeleteList(s.ctx, work.ID))
+	_, err = s.repository.GetList(s.ctx, work.ID)
+	s.ErrorIs(err, errors.ErrListNotFound)
+	s.ErrorIs(s.repository.DeleteList(s.ctx, work.ID), errors.ErrListNotFound)
+}
+
+func (s *ContractSuite) TestTasksInLists() {
+	work := s.createList("Work")
+
+	// одинаковые задачи в разных списках не дубликаты, в одном списке - дубликаты
+	inDefault := s.createInList(nil, "Купить молоко")
+	inWork := s.createInList(&work.ID, "Купить молоко")
+	s.createInList(&work.ID, "Deploy")
+	_, err := s.repository.CreateNewTodo(s.ctx, entity.TodoFields{Title: "Deploy", ActiveAt: today(), ListID: &work.ID}.NewTodo())
+	s.ErrorIs(err, errors.ErrTodoExists)
+
+	retrieved, err := s.repository.GetTaskByID(s.ctx, inWork.ID)
+	s.Require().NoError(err)
+	s.Require().NotNil(retrieved.ListID)
+	s.Equal(work.ID, *retrieved.ListID)
+
+	s.Equal([]string{"Купить молоко"}, s.list(entity.ListOptions{}))
+	s.ElementsMatch([]string{"Купить молоко", "Deploy"}, s.list(entity.ListOptions{ListID: &work.ID}))
+
+	active, err := s.repository.GetTasksByStatus(s.ctx, entity.StatusFilterActive, entity.ListOptions{ListID: &work.ID})
+	s.Require().NoError(err)
+	s.Len(active.Tasks, 2)
+
+	hits, err := s.repository.SearchTasks(s.ctx, &work.ID, "молоко", 10)
+	s.Require().NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(inWork.ID, hits[0].Task.ID)
+
+	// PUT и PATCH список не меняют, а переименование проверяет дубликаты внутри списка
+	_, err = s.repository.UpdateTodo(s.ctx, inWork.ID, entity.NewTodo("Купить хлеб", today()))
+	s.Require().NoError(err)
+	retrieved, err = s.repository.GetTaskByID(s.ctx, inWork.ID)
+	s.Require().NoError(err)
+	s.True(retrieved.InList(&work.ID))
+
+	title := "Deploy"
+	_, err = s.repository.PatchTodo(s.ctx, inWork.ID, &entity.TodoPatch{Title: &title})
+	s.ErrorIs(err, errors.ErrTodoExists)
+	_, err = s.repository.PatchTodo(s.ctx, inDefault.ID, &entity.TodoPatch{Title: &title})
+	s.Require().NoError(err)
+}
+
+func (s *ContractSuite) TestMoveTasks() {
+	work := s.createList("Work")
+	report := s.createInList(nil, "Report")
+	s.createInList(&work.ID, "Deploy")
+	deploy := s.createInList(nil, "Deploy")
+
+	s.Require().NoError(s.repository.MoveTasks(s.ctx, []primitive.ObjectID{report.ID}, &work.ID))
+	s.ElementsMatch([]string{"Report", "Deploy"}, s.list(entity.ListOptions{ListID: &work.ID}))
+
+	// дубликат в целевом списке отменяет перенос целиком
+	err := s.repository.MoveTasks(s.ctx, []primitive.ObjectID{deploy.ID}, &work.ID)
+	s.ErrorIs(err, errors.ErrTodoExists)
+	s.Equal([]string{"Deploy"}, s.list(entity.ListOptions{}))
+
+	s.Require().NoError(s.repository.MoveTasks(s.ctx, []primitive.ObjectID{report.ID}, nil))
+	retrieved, err := s.repository.GetTaskByID(s.ctx, report.ID)
+	s.Require().NoError(err)
+	s.Nil(retrieved.ListID)
+	s.ElementsMatch([]string{"Report", "Deploy"}, s.list(entity.ListOptions{}))
+}
+
+func listNames(lists []*entity.List) []string {
+	names := make([]string, 0, len(lists))
+	for _, list := range lists {
+		names = append(names, list.Name)
+	}
+	return names
+}
+
+func (s *ContractSuite) createUser(email string) *entity.User {
+	user, err := s.repository.CreateUser(s.ctx, &entity.User{Email: email, PasswordHash: "hash"})
+	s.Require().NoError(err, "Ошибка создания пользователя")
+	return user
+}
+
+func (s *ContractSuite) TestUsers() {
+	count, err := s.repository.CountUsers(s.ctx)
+	s.Require().NoError(err)
+	s.Zero(count)
+
+	alice := s.createUser("alice@example.com")
+	s.False(alice.ID.IsZero())
+	_, err = s.repository.CreateUser(s.ctx, &entity.User{Email: "alice@example.com", PasswordHash: "other"})
+	s.ErrorIs(err, errors.ErrUserExists)
+
+	byEmail, err := s.repository.GetUserByEmail(s.ctx, "alice@example.com")
+	s.Require().NoError(err)
+	s.Equal(alice.ID, byEmail.ID)
+	s.Equal("hash", byEmail.PasswordHash)
+
+	byID, err := s.repository.GetUserByID(s.ctx, alice.ID)
+	s.Require().NoError(err)
+	s.Equal("alice@example.com", byID.Email)
+
+	_, err = s.repository.GetUserByEmail(s.ctx, "bob@example.com")
+	s.ErrorIs(err, errors.ErrUserNotFound)
+	_, err = s.repository.GetUserByID(s.ctx, primitive.NewObjectID())
+	s.ErrorIs(err, errors.ErrUserNotFound)
+
+	count, err = s.repository.CountUsers(s.ctx)
+	s.Require().NoError(err)
+	s.Equal(1, count)
+}
+
+func (s *ContractSuite) TestUserIdentity() {
+	const issuer = "https://idp.example.com"
+
+	created, err := s.repository.CreateUser(s.ctx, &entity.User{Email: "alice@example.com", OIDCIssuer: issuer, OIDCSubject: "42"})
+	s.Require().NoError(err)
+	found, err := s.repository.GetUserByIdentity(s.ctx, issuer, "42")
+	s.Require().NoError(err)
+	s.Equal(created.ID, found.ID)
+	s.Empty(found.PasswordHash)
+
+	_, err = s.repository.GetUserByIdentity(s.ctx, "https://other.example.com", "42")
+	s.ErrorIs(err, errors.ErrUserNotFound)
+	_, err = s.repository.CreateUser(s.ctx, &entity.User{Email: "alice2@example.com", OIDCIssuer: issuer, OIDCSubject: "42"})
+	s.ErrorIs(err, errors.ErrUserExists)
+
+	// локальные пользователи без учетной записи провайдера друг другу не мешают
+	bob := s.createUser("bob@example.com")
+	s.createUser("carol@example.com")
+	_, err = s.repository.GetUserByIdentity(s.ctx, "", "")
+	s.ErrorIs(err, errors.ErrUserNotFound)
+
+	s.Require().NoError(s.repository.LinkIdentity(s.ctx, bob.ID, issuer, "7"))
+	found, err = s.repository.GetUserByIdentity(s.ctx, issuer, "7")
+	s.Require().NoError(err)
+	s.Equal(bob.ID, found.ID)
+	s.Equal("hash", found.PasswordHash)
+
+	s.ErrorIs(s.repository.LinkIdentity(s.ctx, bob.ID, issuer, "42"), errors.ErrUserExists)
+	s.ErrorIs(s.repository.LinkIdentity(s.ctx, primitive.NewObjectID(), issuer, "8"), errors.ErrUserNotFound)
+}
+
+func (s *ContractSuite) TestOwnership() {
+	alice := entity.WithOwner(s.ctx, s.createUser("alice@example.com").ID)
+	bob := entity.WithOwner(s.ctx, s.createUser("bob@example.com").ID)
+
+	// одинаковые задачи и списки у разных пользователей не дубликаты
+	todo, err := s.repository.CreateNewTodo(alice, entity.TodoFields{Title: "Отчет", ActiveAt: today(), Tags: []string{"work"}}.NewTodo())
+	s.Require().NoError(err)
+	s.Equal(entity.OwnerFromContext(alice), todo.OwnerID)
+	_, err = s.repository.CreateNewTodo(bob, entity.NewTodo("Отчет", today()))
+	s.Require().NoError(err)
+	_, err = s.repository.CreateNewTodo(alice, entity.NewTodo("Отчет", today()))
+	s.ErrorIs(err, errors.ErrTodoExists)
+
+	list, err := s.repository.CreateList(alice, entity.NewList("Work"))
+	s.Require().NoError(err)
+	_, err = s.repository.CreateList(bob, entity.NewList("Work"))
+	s.Require().NoError(err)
+
+	// чужие задачи и списки не видны и не меняются
+	_, err = s.repository.GetTaskByID(bob, todo.ID)
+	s.ErrorIs(err, errors.ErrNotFound)
+	_, err = s.repository.GetTaskByID(s.ctx, todo.ID)
+	s.ErrorIs(err, errors.ErrNotFound)
+	_, err = s.repository.UpdateTodo(bob, todo.ID, entity.NewTodo("Чужой отчет", today()))
+	s.ErrorIs(err, errors.ErrNotFound)
+	title := "Чужой отчет"
+	_, err = s.repository.PatchTodo(bob, todo.ID, &entity.TodoPatch{Title: &title})
+	s.ErrorIs(err, errors.ErrNotFound)
+	s.ErrorIs(s.repository.DeleteTodo(bob, todo.ID), errors.ErrNotFound)
+	s.Require().NoError(s.repository.MoveTasks(bob, []primitive.ObjectID{todo.ID}, &list.ID))
+	_, err = s.repository.GetList(bob, list.ID)
+	s.ErrorIs(err, errors.ErrListNotFound)
+	s.ErrorIs(s.repository.DeleteList(bob, list.ID), errors.ErrListNotFound)
+
+	page, err := s.repository.GetAllTasks(bob, entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Len(page.Tasks, 1)
+	s.NotEqual(todo.ID, page.Tasks[0].ID)
+	byIDs, err := s.repository.GetTasksByIDs(bob, []primitive.ObjectID{todo.ID})
+	s.Require().NoError(err)
+	s.Empty(byIDs)
+	tags, err := s.repository.GetTags(bob)
+	s.Require().NoError(err)
+	s.Empty(tags)
+	renamed, err := s.repository.RenameTags(bob, []string{"work"}, "job")
+	s.Require().NoError(err)
+	s.Zero(renamed)
+	hits, err := s.repository.SearchTasks(bob, nil, "отчет", 10)
+	s.Require().NoError(err)
+	s.Len(hits, 1)
+	lists, err := s.repository.GetLists(alice, true)
+	s.Require().NoError(err)
+	s.Equal([]string{"Work"}, listNames(lists))
+
+	retrieved, err := s.repository.GetTaskByID(alice, todo.ID)
+	s.Require().NoError(err)
+	s.Equal("Отчет", retrieved.Title)
+	s.True(retrieved.InList(nil), "чужой MoveTasks задачу не переносит")
+	s.Equal([]string{"work"}, retrieved.Tags)
+}
+
+func (s *ContractSuite) TestClaimUnowned() {
+	legacy := s.create("Задача без владельца", today())
+	_, err := s.repository.CreateList(s.ctx, entity.NewList("Work"))
+	s.Require().NoError(err)
+
+	user := s.createUser("alice@example.com")
+	s.Require().NoError(s.repository.ClaimUnowned(s.ctx, user.ID))
+	owner := entity.WithOwner(s.ctx, user.ID)
+
+	retrieved, err := s.repository.GetTaskByID(owner, legacy.ID)
+	s.Require().NoError(err)
+	s.Equal(&user.ID, retrieved.OwnerID)
+	lists, err := s.repository.GetLists(owner, false)
+	s.Require().NoError(err)
+	s.Equal([]string{"Work"}, listNames(lists))
+
+	page, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Empty(page.Tasks)
+}
+
+func (s *ContractSuite) TestAPIKeys() {
+	aliceID := s.createUser("alice@example.com").ID
+	alice := entity.WithOwner(s.ctx, aliceID)
+	bob := entity.WithOwner(s.ctx, s.createUser("bob@example.com").ID)
+
+	newKey := func(name, hash string) *entity.APIKey {
+		key := entity.NewAPIKey(name, []entity.Scope{entity.ScopeTasksRead, entity.ScopeTasksWrite})
+		key.OwnerID = aliceID
+		key.Hint = "tdl_" + hash
+		key.Hash = hash
+		created, err := s.repository.CreateAPIKey(alice, key)
+		s.Require().NoError(err)
+		s.False(created.ID.IsZero())
+		return created
+	}
+	ci := newKey("CI", "hash-ci")
+	newKey("Bot", "hash-bot")
+
+	keys, err := s.repository.GetAPIKeys(alice)
+	s.Require().NoError(err)
+	s.Require().Len(keys, 2)
+	s.Equal("CI", keys[0].Name)
+	s.Equal([]entity.Scope{entity.ScopeTasksRead, entity.ScopeTasksWrite}, keys[0].Scopes)
+	s.Nil(keys[0].LastUsedAt)
+
+	keys, err = s.repository.GetAPIKeys(bob)
+	s.Require().NoError(err)
+	s.Empty(keys)
+	keys, err = s.repository.GetAPIKeys(s.ctx)
+	s.Require().NoError(err)
+	s.Empty(keys)
+
+	// по хешу ключ находится без владельца в контексте
+	found, err := s.repository.GetAPIKeyByHash(s.ctx, "hash-ci")
+	s.Require().NoError(err)
+	s.Equal(ci.ID, found.ID)
+	s.Equal(aliceID, found.OwnerID)
+	_, err = s.repository.GetAPIKeyByHash(s.ctx, "unknown")
+	s.ErrorIs(err, errors.ErrAPIKeyNotFound)
+
+	usedAt := time.Now()
+	s.Require().NoError(s.repository.TouchAPIKey(s.ctx, ci.ID, usedAt))
+	found, err = s.repository.GetAPIKeyByHash(s.ctx, "hash-ci")
+	s.Require().NoError(err)
+	s.Require().NotNil(found.LastUsedAt)
+	s.WithinDuration(usedAt, *found.LastUsedAt, time.Millisecond)
+
+	s.ErrorIs(s.repository.DeleteAPIKey(bob, ci.ID), errors.ErrAPIKeyNotFound)
+	s.Require().NoError(s.repository.DeleteAPIKey(alice, ci.ID))
+	s.ErrorIs(s.repository.DeleteAPIKey(alice, ci.ID), errors.ErrAPIKeyNotFound)
+	_, err = s.repository.GetAPIKeyByHash(s.ctx, "hash-ci")
+	s.ErrorIs(err, errors.ErrAPIKeyNotFound)
+}
+
+func (s *ContractSuite) TestMembers() {
+	aliceID := s.createUser("alice@example.com").ID
+	alice := entity.WithOwner(s.ctx, aliceID)
+	bobID := s.createUser("bob@example.com").ID
+	bob := entity.WithOwner(s.ctx, bobID)
+	carolID := s.createUser("carol@example.com").ID
+
+	list, err := s.repository.CreateList(alice, entity.NewList("Семья"))
+	s.Require().NoError(err)
+
+	addMember := func(userID primitive.ObjectID, role entity.Role) *entity.Membership {
+		member := &entity.Membership{ListID: list.ID, OwnerID: aliceID, UserID: userID, Role: role, CreatedAt: time.Now()}
+		added, err := s.repository.AddMember(alice, member)
+		s.Require().NoError(err)
+		s.False(added.ID.IsZero())
+		return added
+	}
+	addMember(bobID, entity.RoleEditor)
+	addMember(carolID, entity.RoleViewer)
+
+	_, err = s.repository.AddMember(alice, &entity.Membership{
+		ListID: list.ID, OwnerID: aliceID, UserID: bobID, Role: entity.RoleViewer, CreatedAt: time.Now(),
+	})
+	s.ErrorIs(err, errors.ErrMemberExists)
+
+	members, err := s.repository.GetMembers(alice, list.ID)
+	s.Require().NoError(err)
+	s.Require().Len(members, 2)
+	s.Equal(bobID, members[0].UserID)
+	s.Equal(entity.RoleEditor, members[0].Role)
+	s.Equal(carolID, members[1].UserID)
+
+	// участники видны только владельцу списка
+	members, err = s.repository.GetMembers(bob, list.ID)
+	s.Require().NoError(err)
+	s.Empty(members)
+
+	// участие находится без владельца в контексте: по нему участник и получает доступ
+	membership, err := s.repository.GetMembership(bob, list.ID, bobID)
+	s.Require().NoError(err)
+	s.Equal(aliceID, membership.OwnerID)
+	_, err = s.repository.GetMembership(s.ctx, list.ID, aliceID)
+	s.ErrorIs(err, errors.ErrMemberNotFound)
+
+	memberships, err := s.repository.GetMemberships(s.ctx, carolID)
+	s.Require().NoError(err)
+	s.Require().Len(memberships, 1)
+	s.Equal(list.ID, memberships[0].ListID)
+
+	_, err = s.repository.SetMemberRole(bob, list.ID, carolID, entity.RoleEditor)
+	s.ErrorIs(err, errors.ErrMemberNotFound)
+	updated, err := s.repository.SetMemberRole(alice, list.ID, carolID, entity.RoleEditor)
+	s.Require().NoError(err)
+	s.Equal(entity.RoleEditor, updated.Role)
+	s.Equal(carolID, updated.UserID)
+
+	s.ErrorIs(s.repository.RemoveMember(bob, list.ID, carolID), errors.ErrMemberNotFound)
+	s.Require().NoError(s.repository.RemoveMember(alice, list.ID, carolID))
+	s.ErrorIs(s.repository.RemoveMember(alice, list.ID, carolID), errors.ErrMemberNotFound)
+
+	s.Require().NoError(s.repository.RemoveMembers(alice, list.ID))
+	members, err = s.repository.GetMembers(alice, list.ID)
+	s.Require().NoError(err)
+	s.Empty(members)
+}
+
+func (s *ContractSuite) TestInvitations() {
+	aliceID := s.createUser("alice@example.com").ID
+	alice := entity.WithOwner(s.ctx, aliceID)
+
+	list, err := s.repository.CreateList(alice, entity.NewList("Семья"))
+	s.Require().NoError(err)
+
+	newInvitation := func(hash string) *entity.Invitation {
+		invitation := entity.NewInvitation(list, entity.RoleViewer, time.Now())
+		invitation.Hash = hash
+		created, err := s.repository.CreateInvitation(alice, invitation)
+		s.Require().NoError(err)
+		s.False(created.ID.IsZero())
+		return created
+	}
+	first := newInvitation("hash-first")
+	newInvitation("hash-second")
+
+	taken, err := s.repository.TakeInvitation(s.ctx, "hash-first")
+	s.Require().NoError(err)
+	s.Equal(first.ID, taken.ID)
+	s.Equal(list.ID, taken.ListID)
+	s.Equal(aliceID, taken.OwnerID)
+	s.Equal(entity.RoleViewer, taken.Role)
+	s.WithinDuration(first.ExpiresAt, taken.ExpiresAt, time.Millisecond)
+
+	// приглашение одноразовое
+	_, err = s.repository.TakeInvitation(s.ctx, "hash-first")
+	s.ErrorIs(err, errors.ErrInvitationNotFound)
+
+	s.Require().NoError(s.repository.RemoveMembers(alice, list.ID))
+	_, err = s.repository.TakeInvitation(s.ctx, "hash-second")
+	s.ErrorIs(err, errors.ErrInvitationNotFound)
+}
+
+func (s *ContractSuite) TestUsernames() {
+	alice := s.createUser("alice@example.com")
+	bob := s.createUser("bob@example.com")
+	_, err := s.repository.GetUserByUsername(s.ctx, "")
+	s.ErrorIs(err, errors.ErrUserNotFound)
+
+	updated, err := s.repository.SetUsername(s.ctx, alice.ID, "alice")
+	s.Require().NoError(err)
+	s.Equal("alice", updated.Username)
+	s.Equal("alice@example.com", updated.Email)
+
+	found, err := s.repository.GetUserByUsername(s.ctx, "alice")
+	s.Require().NoError(err)
+	s.Equal(alice.ID, found.ID)
+
+	_, err = s.repository.SetUsername(s.ctx, bob.ID, "alice")
+	s.ErrorIs(err, errors.ErrUsernameExists)
+	_, err = s.repository.SetUsername(s.ctx, primitive.NewObjectID(), "carol")
+	s.ErrorIs(err, errors.ErrUserNotFound)
+
+	// свое имя можно сохранить повторно, а старое освобождается при смене
+	_, err = s.repository.SetUsername(s.ctx, alice.ID, "alice")
+	s.Require().NoError(err)
+	_, err = s.repository.SetUsername(s.ctx, alice.ID, "alice2")
+	s.Require().NoError(err)
+	_, err = s.repository.SetUsername(s.ctx, bob.ID, "alice")
+	s.Require().NoError(err)
+}
+
+func (s *ContractSuite) TestAssignee() {
+	assigneeID := primitive.NewObjectID()
+	todo := entity.NewTodo("Test Task", today())
+	todo.AssigneeID = &assigneeID
+	created, err := s.repository.CreateNewTodo(s.ctx, todo)
+	s.Require().NoError(err)
+
+	found, err := s.repository.GetTaskByID(s.ctx, created.ID)
+	s.Require().NoError(err)
+	s.Require().NotNil(found.AssigneeID)
+	s.Equal(assigneeID, *found.AssigneeID)
+
+	// PUT без исполнителя его снимает
+	_, err = s.repository.UpdateTodo(s.ctx, created.ID, entity.NewTodo("Test Task", today()))
+	s.Require().NoError(err)
+	found, err = s.repository.GetTaskByID(s.ctx, created.ID)
+	s.Require().NoError(err)
+	s.Nil(found.AssigneeID)
+
+	patched, err := s.repository.PatchTodo(s.ctx, created.ID, &entity.TodoPatch{AssigneeID: &assigneeID})
+	s.Require().NoError(err)
+	s.Require().NotNil(patched.AssigneeID)
+	s.Equal(assigneeID, *patched.AssigneeID)
+
+	title := "Renamed"
+	patched, err = s.repository.PatchTodo(s.ctx, created.ID, &entity.TodoPatch{Title: &title})
+	s.Require().NoError(err)
+	s.NotNil(patched.AssigneeID)
+
+	none := primitive.NilObjectID
+	patched, err = s.repository.PatchTodo(s.ctx, created.ID, &entity.TodoPatch{AssigneeID: &none})
+	s.Require().NoError(err)
+	s.Nil(patched.AssigneeID)
+	found, err = s.repository.GetTaskByID(s.ctx, created.ID)
+	s.Require().NoError(err)
+	s.Nil(found.AssigneeID)
+}
+
+func (s *ContractSuite) TestNotifications() {
+	aliceID := s.createUser("alice@example.com").ID
+	alice := entity.WithOwner(s.ctx, aliceID)
+	bobID := s.createUser("bob@example.com").ID
+	bob := entity.WithOwner(s.ctx, bobID)
+
+	todo := s.create("Test Task", today())
+	listID := primitive.NewObjectID()
+	todo.ListID = &listID
+	now := time.Now()
+	older := entity.NewNotification(entity.NotificationAssigned, todo, aliceID, bobID, now.Add(-time.Minute))
+	newer := entity.NewNotification(entity.NotificationMentioned, todo, aliceID, bobID, now)
+	s.Require().NoError(s.repository.CreateNotifications(bob, []*entity.Notification{older, newer}))
+	s.False(older.ID.IsZero())
+	s.NotEqual(older.ID, newer.ID)
+	s.Require().NoError(s.repository.CreateNotifications(bob, nil))
+
+	notifications, err := s.repository.GetNotifications(alice, false, 10)
+	s.Require().NoError(err)
+	s.Require().Len(notifications, 2)
+	s.Equal(newer.ID, notifications[0].ID)
+	s.Equal(entity.NotificationMentioned, notifications[0].Kind)
+	s.Equal(todo.ID, notifications[0].TaskID)
+	s.Equal(&listID, notifications[0].ListID)
+	s.Equal(bobID, notifications[0].ActorID)
+	s.Equal("Test Task", notifications[0].Title)
+	s.False(notifications[0].IsRead())
+	s.Equal(older.ID, notifications[1].ID)
+
+	notifications, err = s.repository.GetNotifications(alice, false, 1)
+	s.Require().NoError(err)
+	s.Len(notifications, 1)
+
+	// входящие видит и отмечает только получатель
+	notifications, err = s.repository.GetNotifications(bob, false, 10)
+	s.Require().NoError(err)
+	s.Empty(notifications)
+	_, err = s.repository.SetNotificationRead(bob, newer.ID, &now)
+	s.ErrorIs(err, errors.ErrNotificationNotFound)
+
+	read, err := s.repository.SetNotificationRead(alice, newer.ID, &now)
+	s.Require().NoError(err)
+	s.Require().NotNil(read.ReadAt)
+	s.WithinDuration(now, *read.ReadAt, time.Millisecond)
+
+	notifications, err = s.repository.GetNotifications(alice, true, 10)
+	s.Require().NoError(err)
+	s.Require().Len(notifications, 1)
+	s.Equal(older.ID, notifications[0].ID)
+
+	unread, err := s.repository.SetNotificationRead(alice, newer.ID, nil)
+	s.Require().NoError(err)
+	s.False(unread.IsRead())
+	notifications, err = s.repository.GetNotifications(alice, true, 10)
+	s.Require().NoError(err)
+	s.Len(notifications, 2)
+
+	_, err = s.repository.SetNotificationRead(alice, primitive.NewObjectID(), &now)
+	s.ErrorIs(err, errors.ErrNotificationNotFound)
+}
+
+func (s *ContractSuite) TestComments() {
+	todo := s.create("Test Task", today())
+	other := s.create("Other Task", today())
+	authorID := primitive.NewObjectID()
+	now := time.Now()
+
+	var created []*entity.Comment
+	for _, body := range []string{"Первый", "Второй", "Третий"} {
+		comment, err := entity.NewComment(todo, &authorID, body, now)
+		s.Require().NoError(err)
+		comment, err = s.repository.CreateComment(s.ctx, comment)
+		s.Require().NoError(err)
+		s.False(comment.ID.IsZero())
+		created = append(created, comment)
+	}
+	comment, err := entity.NewComment(other, nil, "Чужой", now)
+	s.Require().NoError(err)
+	_, err = s.repository.CreateComment(s.ctx, comment)
+	s.Require().NoError(err)
+
+	found, err := s.repository.GetComment(s.ctx, created[0].ID)
+	s.Require().NoError(err)
+	s.Equal(todo.ID, found.TaskID)
+	s.Equal(&authorID, found.AuthorID)
+	s.Equal("Первый", found.Body)
+	s.WithinDuration(now, found.CreatedAt, time.Millisecond)
+
+	// страницы идут по порядку написания, следующая начинается после последнего комментария предыдущей
+	page, err := s.repository.GetComments(s.ctx, todo.ID, nil, 2)
+	s.Require().NoError(err)
+	s.Require().Len(page, 2)
+	s.Equal(created[0].ID, page[0].ID)
+	s.Equal(created[1].ID, page[1].ID)
+	page, err = s.repository.GetComments(s.ctx, todo.ID, &page[1].ID, 2)
+	s.Require().NoError(err)
+	s.Require().Len(page, 1)
+	s.Equal(created[2].ID, page[0].ID)
+
+	later := now.Add(time.Minute)
+	s.Require().NoError(found.Edit("Первый, исправленный", later))
+	updated, err := s.repository.UpdateComment(s.ctx, found)
+	s.Require().NoError(err)
+	s.Equal("Первый, исправленный", updated.Body)
+	s.WithinDuration(later, updated.UpdatedAt, time.Millisecond)
+	s.WithinDuration(now, updated.CreatedAt, time.Millisecond)
+
+	s.Require().NoError(s.repository.DeleteComment(s.ctx, created[1].ID))
+	s.ErrorIs(s.repository.DeleteComment(s.ctx, created[1].ID), errors.ErrCommentNotFound)
+	_, err = s.repository.GetComment(s.ctx, created[1].ID)
+	s.ErrorIs(err, errors.ErrCommentNotFound)
+	_, err = s.repository.UpdateComment(s.ctx, &entity.Comment{ID: created[1].ID, Body: "Удален"})
+	s.ErrorIs(err, errors.ErrCommentNotFound)
+
+	counts, err := s.repository.CountComments(s.ctx, []primitive.ObjectID{todo.ID, other.ID, primitive.NewObjectID()})
+	s.Require().NoError(err)
+	s.Equal(map[primitive.ObjectID]int{todo.ID: 2, other.ID: 1}, counts)
+
+	// задача уносит с собой свои комментарии
+	s.Require().NoError(s.repository.DeleteTodo(s.ctx, todo.ID))
+	_, err = s.repository.GetComment(s.ctx, created[0].ID)
+	s.ErrorIs(err, errors.ErrCommentNotFound)
+	counts, err = s.repository.CountComments(s.ctx, []primitive.ObjectID{todo.ID, other.ID})
+	s.Require().NoError(err)
+	s.Equal(map[primitive.ObjectID]int{other.ID: 1}, counts)
+}
+
+func (s *ContractSuite) TestCommentsOfAnotherOwner() {
+	aliceID := s.createUser("alice@example.com").ID
+	alice := entity.WithOwner(s.ctx, aliceID)
+	bob := entity.WithOwner(s.ctx, s.createUser("bob@example.com").ID)
+
+	todo, err := s.repository.CreateNewTodo(alice, entity.NewTodo("Test Task", today()))
+	s.Require().NoError(err)
+	comment, err := entity.NewComment(todo, &aliceID, "Купил", time.Now())
+	s.Require().NoError(err)
+	comment, err = s.repository.CreateComment(alice, comment)
+	s.Require().NoError(err)
+
+	_, err = s.repository.GetComment(bob, comment.ID)
+	s.ErrorIs(err, errors.ErrCommentNotFound)
+	comments, err := s.repository.GetComments(bob, todo.ID, nil, 10)
+	s.Require().NoError(err)
+	s.Empty(comments)
+	counts, err := s.repository.CountComments(bob, []primitive.ObjectID{todo.ID})
+	s.Require().NoError(err)
+	s.Empty(counts)
+	s.ErrorIs(s.repository.DeleteComment(bob, comment.ID), errors.ErrCommentNotFound)
+
+	// и без владельца в контексте чужих комментариев не видно
+	_, err = s.repository.GetComment(s.ctx, comment.ID)
+	s.ErrorIs(err, errors.ErrCommentNotFound)
+}
+
+func (s *ContractSuite) TestPatchTodoValidates() {
+	createdTodo := s.create("Test Task", today())
+
+	empty := ""
+	_, err := s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{Title: &empty})
+	s.ErrorIs(err, errors.ErrTitleEmpty)
+
+	past := today().AddDate(0, 0, -1)
+	_, err = s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{ActiveAt: &past})
+	s.ErrorIs(err, errors.ErrDateNotCurrent)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal("Test Task", retrievedTodo.Title)
+}
+
+func (s *ContractSuite) TestPatchTodoDuplicate() {
+	s.create("First Task", today())
+	second := s.create("Second Task", today())
+
+	title := "First Task"
+	_, err := s.repository.PatchTodo(s.ctx, second.ID, &entity.TodoPatch{Title: &title})
+	s.ErrorIs(err, errors.ErrTodoExists)
+
+	_, err = s.repository.PatchTodo(s.ctx, primitive.NewObjectID(), &entity.TodoPatch{Title: &title})
+	s.ErrorIs(err, errors.ErrNotFound)
+}
+
+func (s *ContractSuite) TestDeleteTodo() {
+	createdTodo := s.create("Test Task", today())
+
+	err := s.repository.DeleteTodo(s.ctx, createdTodo.ID)
+	s.Require().NoError(err, "Ошибка удаления задачи")
+
+	_, err = s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.ErrorIs(err, errors.ErrNotFound)
+
+	err = s.repository.DeleteTodo(s.ctx, createdTodo.ID)
+	s.ErrorIs(err, errors.ErrNotFound)
+}
+
+func (s *ContractSuite) TestSetStatus() {
+	createdTodo := s.create("Test Task", today())
+
+	doneTodo := s.transition(createdTodo, entity.StatusDone)
+	s.Equal(entity.StatusDone, doneTodo.Status)
+	s.Require().NotNil(doneTodo.CompletedAt)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal(entity.StatusDone, retrievedTodo.Status)
+	s.Require().NotNil(retrievedTodo.CompletedAt)
+
+	// переоткрытие убирает completed_at
+	reopenedTodo := s.transition(retrievedTodo, entity.StatusTodo)
+	s.Equal(entity.StatusTodo, reopenedTodo.Status)
+	s.Nil(reopenedTodo.CompletedAt)
+}
+
+func (s *ContractSuite) TestSetStatusConflict() {
+	createdTodo := s.create("Test Task", today())
+
+	// статус в базе уже не тот, от которого считался переход
+	stale := *createdTodo
+	s.Require().NoError(stale.TransitionTo(entity.StatusInProgress))
+	_, err := s.repository.SetStatus(s.ctx, createdTodo.ID, entity.StatusBlocked, &stale)
+	s.ErrorIs(err, errors.ErrStatusConflict)
+
+	retrievedTodo, err := s.repository.GetTaskByID(s.ctx, createdTodo.ID)
+	s.Require().NoError(err)
+	s.Equal(entity.StatusTodo, retrievedTodo.Status)
+
+	_, err = s.repository.SetStatus(s.ctx, primitive.NewObjectID(), entity.StatusTodo, &stale)
+	s.ErrorIs(err, errors.ErrNotFound)
+}
+
+func (s *ContractSuite) TestGetTasksByStatus() {
+	active := s.create("Active Task", today())
+	inProgress := s.transition(s.create("In Progress Task", today()), entity.StatusInProgress)
+	s.create("Future Task", today().AddDate(0, 0, 1))
+	done := s.transition(s.create("Done Task", today()), entity.StatusDone)
+	s.transition(s.create("Cancelled Task", today()), entity.StatusCancelled)
+
+	activeTodos, err := s.repository.GetTasksByStatus(s.ctx, entity.StatusFilterActive, entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Equal([]string{active.Title, inProgress.Title}, titles(activeTodos.Tasks))
+
+	doneTodos, err := s.repository.GetTasksByStatus(s.ctx, string(entity.StatusDone), entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Equal([]string{done.Title}, titles(doneTodos.Tasks))
+
+	inProgressTodos, err := s.repository.GetTasksByStatus(s.ctx, string(entity.StatusInProgress), entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Equal([]string{inProgress.Title}, titles(inProgressTodos.Tasks))
+}
+
+func (s *ContractSuite) TestGetAllTasksOrderedByActiveAt() {
+	s.create("Day 3", today().AddDate(0, 0, 2))
+	s.create("Day 1", today())
+	s.create("Day 2", today().AddDate(0, 0, 1))
+
+	page, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Equal([]string{"Day 1", "Day 2", "Day 3"}, titles(page.Tasks))
+	s.Empty(page.NextCursor)
+}
+
+func (s *ContractSuite) TestGetAllTasksEmpty() {
+	page, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{})
+	s.Require().NoError(err)
+	s.Empty(page.Tasks)
+	s.Empty(page.NextCursor)
+}
+
+// allPages проходит список по курсорам до конца и возвращает заголовки по страницам
+func (s *ContractSuite) allPages(opts entity.ListOptions) [][]string {
+	var pages [][]string
+	for i := 0; i < 100; i++ {
+		page, err := s.repository.GetAllTasks(s.ctx, opts)
+		s.Require().NoError(err)
+		pages = append(pages, titles(page.Tasks))
+
+		if page.NextCursor == "" {
+			return pages
+		}
+		opts.Cursor = page.NextCursor
+	}
+	s.FailNow("Курсоры не заканчиваются")
+	return nil
+}
+
+func (s *ContractSuite) TestPagination() {
+	for i := 5; i >= 1; i-- {
+		s.create(fmt.Sprintf("Day %d", i), today().AddDate(0, 0, i-1))
+	}
+
+	s.Equal([][]string{{"Day 1", "Day 2"}, {"Day 3", "Day 4"}, {"Day 5"}}, s.allPages(entity.ListOptions{Limit: 2}))
+
+	s.Equal([][]string{{"Day 5", "Day 4", "Day 3"}, {"Day 2", "Day 1"}},
+		s.allPages(entity.ListOptions{Limit: 3, Sort: entity.SortActiveAt, Descending: true}))
+
+	s.Equal([][]string{{"Day 1", "Day 2", "Day 3", "Day 4", "Day 5"}},
+		s.allPages(entity.ListOptions{Limit: 5, Sort: entity.SortTitle}))
+}
+
+func (s *ContractSuite) TestPaginationWithEqualSortValues() {
+	// одинаковый active_at: порядок и страницы держатся на ID
+	for i := 1; i <= 5; i++ {
+		s.create(fmt.Sprintf("Task %d", i), today())
+	}
+
+	var seen []string
+	for _, page := range s.allPages(entity.ListOptions{Limit: 2}) {
+		seen = append(seen, page...)
+	}
+	s.Equal([]string{"Task 1", "Task 2", "Task 3", "Task 4", "Task 5"}, seen)
+}
+
+func (s *ContractSuite) TestPaginationIsStableUnderInserts() {
+	s.create("Day 2", today().AddDate(0, 0, 1))
+	s.create("Day 3", today().AddDate(0, 0, 2))
+	s.create("Day 4", today().AddDate(0, 0, 3))
+
+	first, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{Limit: 2})
+	s.Require().NoError(err)
+	s.Equal([]string{"Day 2", "Day 3"}, titles(first.Tasks))
+
+	// задача раньше курсора не сдвигает следующую страницу
+	s.create("Day 1", today())
+
+	second, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{Limit: 2, Cursor: first.NextCursor})
+	s.Require().NoError(err)
+	s.Equal([]string{"Day 4"}, titles(second.Tasks))
+	s.Empty(second.NextCursor)
+}
+
+func (s *ContractSuite) TestRangeFilters() {
+	for i := 1; i <= 4; i++ {
+		s.create(fmt.Sprintf("Day %d", i), today().AddDate(0, 0, i-1))
+	}
+
+	from, to := today().AddDate(0, 0, 1), today().AddDate(0, 0, 2)
+	page, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{ActiveFrom: &from, ActiveTo: &to})
+	s.Require().NoError(err)
+	s.Equal([]string{"Day 2", "Day 3"}, titles(page.Tasks))
+
+	createdTo := time.Now().Add(-time.Hour)
+	page, err = s.repository.GetAllTasks(s.ctx, entity.ListOptions{CreatedTo: &createdTo})
+	s.Require().NoError(err)
+	s.Empty(page.Tasks)
+
+	active, err := s.repository.GetTasksByStatus(s.ctx, entity.StatusFilterActive, entity.ListOptions{ActiveFrom: &from})
+	s.Require().NoError(err)
+	s.Empty(active.Tasks)
+}
+
+func (s *ContractSuite) TestInvalidCursor() {
+	s.create("Day 1", today())
+	s.create("Day 2", today().AddDate(0, 0, 1))
+
+	_, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{Cursor: "not a cursor"})
+	s.ErrorIs(err, errors.ErrInvalidCursor)
+
+	page, err := s.repository.GetAllTasks(s.ctx, entity.ListOptions{Limit: 1})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(page.NextCursor)
+
+	// курсор привязан к сортировке, с которой он выдан
+	_, err = s.repository.GetAllTasks(s.ctx, entity.ListOptions{Limit: 1, Sort: entity.SortTitle, Cursor: page.NextCursor})
+	s.ErrorIs(err, errors.ErrInvalidCursor)
+}
+
+func (s *ContractSuite) TestSearchTasks() {
+	milk := s.create("Купить молоко", today())
+	s.create("Купить хлеб и сыр на неделю", today())
+	groceries := s.create("Buying groceries for the party", today())
+	s.create("Позвонить маме", today())
+
+	// морфология: "молока" находит "молоко"
+	hits, err := s.repository.SearchTasks(s.ctx, nil, "молока", 10)
+	s.Require().NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(milk.ID, hits[0].Task.ID)
+	s.Greater(hits[0].Score, 0.0)
+	s.Equal("Купить <mark>молоко</mark>", hits[0].Highlight)
+
+	hits, err = s.repository.SearchTasks(s.ctx, nil, "grocery buy", 10)
+	s.Require().NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(groceries.ID, hits[0].Task.ID)
+
+	// больше совпадений - выше в выдаче
+	hits, err = s.repository.SearchTasks(s.ctx, nil, "купить молоко", 10)
+	s.Require().NoError(err)
+	s.Require().Len(hits, 2)
+	s.Equal(milk.ID, hits[0].Task.ID)
+	s.Greater(hits[0].Score, hits[1].Score)
+
+	hits, err = s.repository.SearchTasks(s.ctx, nil, "купить", 1)
+	s.Require().NoError(err)
+	s.Len(hits, 1)
+}
+
+func (s *ContractSuite) TestSearchDescription() {
+	todo := entity.NewTodo("Купить подарок", today())
+	todo.Description = "Маме нравятся **тюльпаны**"
+	inDescription, err := s.repository.CreateNewTodo(s.ctx, todo)
+	s.Require().NoError(err)
+	inTitle := s.create("Полить тюльпаны", today())
+
+	// слово только из описания находит задачу, совпадение в заголовке весит больше
+	hits, err := s.repository.SearchTasks(s.ctx, nil, "тюльпан", 10)
+	s.Require().NoError(err)
+	s.Require().Len(hits, 2)
+	s.Equal(inTitle.ID, hits[0].Task.ID)
+	s.Empty(hits[0].DescriptionHighlight)
+	s.Equal(inDescription.ID, hits[1].Task.ID)
+	s.Greater(hits[0].Score, hits[1].Score)
+	s.Equal("Купить подарок", hits[1].Highlight)
+	s.Equal("Маме нравятся **<mark>тюльпаны</mark>**", hits[1].DescriptionHighlight)
+
+	// новое описание заменяет старое и в поиске
+	description := "Маме нравятся розы"
+	_, err = s.repository.PatchTodo(s.ctx, inDescription.ID, &entity.TodoPatch{Description: &description})
+	s.Require().NoError(err)
+	hits, err = s.repository.SearchTasks(s.ctx, nil, "розы", 10)
+	s.Require().NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(inDescription.ID, hits[0].Task.ID)
+	hits, err = s.repository.SearchTasks(s.ctx, nil, "тюльпан", 10)
+	s.Require().NoError(err)
+	s.Len(hits, 1)
+}
+
+func (s *ContractSuite) TestSearchFollowsUpdates() {
+	createdTodo := s.create("Купить молоко", today())
+
+	title := "Починить велосипед"
+	_, err := s.repository.PatchTodo(s.ctx, createdTodo.ID, &entity.TodoPatch{Title: &title})
+	s.Require().NoError(err)
+
+	hits, err := s.repository.SearchTasks(s.ctx, nil, "молоко", 10)
+	s.Require().NoError(err)
+	s.Empty(hits)
+
+	hits, err = s.repository.SearchTasks(s.ctx, nil, "велосипеды", 10)
+	s.Require().NoError(err)
+	s.Len(hits, 1)
+
+	s.Require().NoError(s.repository.DeleteTodo(s.ctx, createdTodo.ID))
+	hits, err = s.repository.SearchTasks(s.ctx, nil, "велосипеды", 10)
+	s.Require().NoError(err)
+	s.Empty(hits)
+}
@@ -0,0 +1,48 @@
+package repo
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/textsearch"
+)
+
+// Веса полей в оценке: совпадение в заголовке важнее совпадения в описании. Те же веса у text index Mongo
+const (
+	titleWeight       = 3
+	descriptionWeight = 1
+)
+
+// searchHit - найденная задача с подсветкой совпадений в заголовке и, если они там есть, в описании
+func searchHit(query textsearch.Query, todo *entity.Todo, score float64) *entity.SearchHit {
+	hit := &entity.SearchHit{Task: todo, Score: score, Highlight: query.Highlight(todo.Title)}
+	if query.Score(todo.Description) > 0 {
+		hit.DescriptionHighlight = query.Highlight(todo.Description)
+	}
+	return hit
+}
+
+// rankHits оценивает кандидатов запросом и сортирует их как Mongo: по убыванию релевантности, затем по ID.
+// Нужен хранилищам без своего полнотекстового индекса
+func rankHits(query textsearch.Query, candidates []*entity.Todo, limit int) []*entity.SearchHit {
+	hits := make([]*entity.SearchHit, 0, len(candidates))
+	for _, todo := range candidates {
+		score := titleWeight*query.Score(todo.Title) + descriptionWeight*query.Score(todo.Description)
+		if score > 0 {
+			hits = append(hits, searchHit(query, todo, score))
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return bytes.Compare(hits[i].Task.ID[:], hits[j].Task.ID[:]) < 0
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
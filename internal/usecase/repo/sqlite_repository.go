@@ -0,0 +1,1889 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nekidaz/todolist/config"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/textsearch"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// sqliteMigration - один шаг схемы, выполняется в транзакции вместе с записью в schema_migrations
+type sqliteMigration func(ctx context.Context, tx *sql.Tx) error
+
+// execMigration - миграция из одного SQL-скрипта
+func execMigration(script string) sqliteMigration {
+	return func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, script)
+		return err
+	}
+}
+
+// sqliteMigrations - схема базы по версиям. Уже примененные миграции не меняются, новые только дописываются в конец
+var sqliteMigrations = []sqliteMigration{
+	// 1: задачи. Время хранится в миллисекундах UTC, как BSON datetime в Mongo
+	execMigration(`CREATE TABLE todos (
+		id         TEXT    PRIMARY KEY,
+		title      TEXT    NOT NULL,
+		completed  INTEGER NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL,
+		active_at  INTEGER NOT NULL,
+		UNIQUE (title, active_at)
+	);
+	CREATE INDEX todos_active_at ON todos (active_at);`),
+	// 2: completed заменен на status, completed: true становится done
+	execMigration(`ALTER TABLE todos ADD COLUMN status TEXT NOT NULL DEFAULT 'todo';
+	ALTER TABLE todos ADD COLUMN completed_at INTEGER;
+	UPDATE todos SET status = 'done', completed_at = updated_at WHERE completed = 1;
+	ALTER TABLE todos DROP COLUMN completed;
+	CREATE INDEX todos_status ON todos (status);`),
+	// 3: поисковый индекс - основы слов заголовка, стеммер тот же, что и для подсветки
+	migrateSearchTerms,
+	// 4: описание задачи в Markdown
+	execMigration(`ALTER TABLE todos ADD COLUMN description TEXT NOT NULL DEFAULT '';`),
+	// 5: подзадачи. Внешнего ключа нет: что делать с подзадачами при удалении родителя, решает сервис
+	execMigration(`ALTER TABLE todos ADD COLUMN parent_id TEXT;
+	CREATE INDEX todos_parent_id ON todos (parent_id);`),
+	// 6: зависимости - JSON-массив ID, как массив depends_on в документе Mongo
+	execMigration(`ALTER TABLE todos ADD COLUMN depends_on TEXT NOT NULL DEFAULT '[]';`),
+	// 7: повторяющиеся задачи
+	execMigration(`ALTER TABLE todos ADD COLUMN rrule TEXT NOT NULL DEFAULT '';
+	ALTER TABLE todos ADD COLUMN series_start INTEGER;`),
+	// 8: метки. Колонка tags хранит их порядок, а todo_tags - индекс для фильтров и подсчета, как todo_terms для поиска
+	execMigration(`ALTER TABLE todos ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+	CREATE TABLE todo_tags (
+		tag     TEXT NOT NULL,
+		todo_id TEXT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		PRIMARY KEY (tag, todo_id)
+	);
+	CREATE INDEX todo_tags_todo_id ON todo_tags (todo_id);`),
+	// 9: приоритет
+	execMigration(`ALTER TABLE todos ADD COLUMN priority TEXT NOT NULL DEFAULT 'normal';`),
+	// 10: списки
+	migrateLists,
+	// 11: пользователи и владельцы задач
+	migrateOwners,
+	// 12: ключи API. Права - JSON-массив, как depends_on
+	execMigration(`CREATE TABLE api_keys (
+		id           TEXT    PRIMARY KEY,
+		owner_id     TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		name         TEXT    NOT NULL,
+		hint         TEXT    NOT NULL,
+		hash         TEXT    NOT NULL UNIQUE,
+		scopes       TEXT    NOT NULL DEFAULT '[]',
+		last_used_at INTEGER,
+		created_at   INTEGER NOT NULL
+	);
+	CREATE INDEX api_keys_owner_id ON api_keys (owner_id, created_at);`),
+	// 13: вход через OIDC. У локальных пользователей колонки пустые и в уникальный индекс не попадают
+	execMigration(`ALTER TABLE users ADD COLUMN oidc_issuer TEXT NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN oidc_subject TEXT NOT NULL DEFAULT '';
+	CREATE UNIQUE INDEX users_oidc_identity ON users (oidc_issuer, oidc_subject) WHERE oidc_subject != '';`),
+	// 14: участники списков и приглашения
+	execMigration(`CREATE TABLE list_members (
+		id         TEXT    PRIMARY KEY,
+		list_id    TEXT    NOT NULL REFERENCES lists (id) ON DELETE CASCADE,
+		owner_id   TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		user_id    TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		role       TEXT    NOT NULL,
+		created_at INTEGER NOT NULL,
+		UNIQUE (list_id, user_id)
+	);
+	CREATE INDEX list_members_user_id ON list_members (user_id);
+	CREATE TABLE list_invitations (
+		id         TEXT    PRIMARY KEY,
+		list_id    TEXT    NOT NULL REFERENCES lists (id) ON DELETE CASCADE,
+		owner_id   TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		role       TEXT    NOT NULL,
+		hash       TEXT    NOT NULL UNIQUE,
+		expires_at INTEGER NOT NULL,
+		created_at INTEGER NOT NULL
+	);`),
+	// 15: исполнители задач, имена пользователей для упоминаний и входящие. Пустые значения, как и у oidc_subject,
+	// в уникальный индекс не попадают
+	execMigration(`ALTER TABLE todos ADD COLUMN assignee_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE users ADD COLUMN username TEXT NOT NULL DEFAULT '';
+	CREATE UNIQUE INDEX users_username ON users (username) WHERE username != '';
+	CREATE TABLE notifications (
+		id         TEXT    PRIMARY KEY,
+		owner_id   TEXT    NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		kind       TEXT    NOT NULL,
+		task_id    TEXT    NOT NULL,
+		list_id    TEXT    NOT NULL DEFAULT '',
+		actor_id   TEXT    NOT NULL,
+		title      TEXT    NOT NULL,
+		read_at    INTEGER,
+		created_at INTEGER NOT NULL
+	);
+	CREATE INDEX notifications_owner_id ON notifications (owner_id, created_at);`),
+	// 16: комментарии к задачам, удаляются вместе с задачей. У комментариев без авторизации owner_id и author_id пустые
+	execMigration(`CREATE TABLE comments (
+		id         TEXT    PRIMARY KEY,
+		task_id    TEXT    NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		owner_id   TEXT    NOT NULL DEFAULT '',
+		author_id  TEXT    NOT NULL DEFAULT '',
+		body       TEXT    NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	CREATE INDEX comments_task_id ON comments (task_id, id);`),
+	// 17: поисковый индекс включает основы слов описания
+	migrateDescriptionTerms,
+}
+
+// migrateOwners добавляет пользователей и владельца задачам и спискам. Уникальность задач и имен списков
+// теперь в пределах владельца, поэтому обе таблицы пересоздаются, как в migrateLists.
+// У данных без владельца owner_id - пустая строка, по той же причине, что и list_id
+func migrateOwners(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE users (
+		id            TEXT    PRIMARY KEY,
+		email         TEXT    NOT NULL UNIQUE,
+		password_hash TEXT    NOT NULL,
+		created_at    INTEGER NOT NULL
+	);
+	CREATE TABLE lists_new (
+		id          TEXT    PRIMARY KEY,
+		owner_id    TEXT    NOT NULL DEFAULT '',
+		name        TEXT    NOT NULL,
+		archived_at INTEGER,
+		created_at  INTEGER NOT NULL,
+		updated_at  INTEGER NOT NULL,
+		UNIQUE (owner_id, name)
+	);
+	INSERT INTO lists_new (id, name, archived_at, created_at, updated_at)
+	SELECT id, name, archived_at, created_at, updated_at FROM lists;
+	DROP TABLE lists;
+	ALTER TABLE lists_new RENAME TO lists;
+	CREATE TABLE todos_new (
+		id           TEXT    PRIMARY KEY,
+		owner_id     TEXT    NOT NULL DEFAULT '',
+		list_id      TEXT    NOT NULL DEFAULT '',
+		title        TEXT    NOT NULL,
+		description  TEXT    NOT NULL DEFAULT '',
+		status       TEXT    NOT NULL DEFAULT 'todo',
+		priority     TEXT    NOT NULL DEFAULT 'normal',
+		completed_at INTEGER,
+		created_at   INTEGER NOT NULL,
+		updated_at   INTEGER NOT NULL,
+		active_at    INTEGER NOT NULL,
+		parent_id    TEXT,
+		depends_on   TEXT    NOT NULL DEFAULT '[]',
+		tags         TEXT    NOT NULL DEFAULT '[]',
+		rrule        TEXT    NOT NULL DEFAULT '',
+		series_start INTEGER,
+		UNIQUE (owner_id, list_id, title, active_at)
+	);
+	INSERT INTO todos_new (id, list_id, title, description, status, priority, completed_at, created_at, updated_at,
+		active_at, parent_id, depends_on, tags, rrule, series_start)
+	SELECT id, list_id, title, description, status, priority, completed_at, created_at, updated_at,
+		active_at, parent_id, depends_on, tags, rrule, series_start FROM todos;
+	CREATE TABLE todo_terms_old AS SELECT term, todo_id FROM todo_terms;
+	CREATE TABLE todo_tags_old AS SELECT tag, todo_id FROM todo_tags;
+	DROP TABLE todo_terms;
+	DROP TABLE todo_tags;
+	DROP TABLE todos;
+	ALTER TABLE todos_new RENAME TO todos;
+	CREATE INDEX todos_active_at ON todos (active_at);
+	CREATE INDEX todos_status ON todos (status);
+	CREATE INDEX todos_parent_id ON todos (parent_id);
+	CREATE INDEX todos_owner_list_id ON todos (owner_id, list_id, active_at);
+	CREATE TABLE todo_terms (
+		term    TEXT NOT NULL,
+		todo_id TEXT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		PRIMARY KEY (term, todo_id)
+	);
+	CREATE INDEX todo_terms_todo_id ON todo_terms (todo_id);
+	CREATE TABLE todo_tags (
+		tag     TEXT NOT NULL,
+		todo_id TEXT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		PRIMARY KEY (tag, todo_id)
+	);
+	CREATE INDEX todo_tags_todo_id ON todo_tags (todo_id);
+	INSERT INTO todo_terms (term, todo_id) SELECT term, todo_id FROM todo_terms_old;
+	INSERT INTO todo_tags (tag, todo_id) SELECT tag, todo_id FROM todo_tags_old;
+	DROP TABLE todo_terms_old;
+	DROP TABLE todo_tags_old;`)
+	return err
+}
+
+// migrateLists добавляет списки и переносит уникальность задач с (title, active_at) на (list_id, title, active_at).
+// Ограничение UNIQUE в SQLite меняется только пересозданием таблицы, а DROP TABLE todos при включенных внешних ключах
+// каскадом очистил бы todo_terms и todo_tags, поэтому они пересоздаются вместе с ней.
+// У задач списка по умолчанию list_id - пустая строка: NULL в UNIQUE не равны друг другу и дубликаты бы прошли
+func migrateLists(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE lists (
+		id          TEXT    PRIMARY KEY,
+		name        TEXT    NOT NULL UNIQUE,
+		archived_at INTEGER,
+		created_at  INTEGER NOT NULL,
+		updated_at  INTEGER NOT NULL
+	);
+	CREATE TABLE todos_new (
+		id           TEXT    PRIMARY KEY,
+		list_id      TEXT    NOT NULL DEFAULT '',
+		title        TEXT    NOT NULL,
+		description  TEXT    NOT NULL DEFAULT '',
+		status       TEXT    NOT NULL DEFAULT 'todo',
+		priority     TEXT    NOT NULL DEFAULT 'normal',
+		completed_at INTEGER,
+		created_at   INTEGER NOT NULL,
+		updated_at   INTEGER NOT NULL,
+		active_at    INTEGER NOT NULL,
+		parent_id    TEXT,
+		depends_on   TEXT    NOT NULL DEFAULT '[]',
+		tags         TEXT    NOT NULL DEFAULT '[]',
+		rrule        TEXT    NOT NULL DEFAULT '',
+		series_start INTEGER,
+		UNIQUE (list_id, title, active_at)
+	);
+	INSERT INTO todos_new (id, title, description, status, priority, completed_at, created_at, updated_at, active_at,
+		parent_id, depends_on, tags, rrule, series_start)
+	SELECT id, title, description, status, priority, completed_at, created_at, updated_at, active_at,
+		parent_id, depends_on, tags, rrule, series_start FROM todos;
+	CREATE TABLE todo_terms_old AS SELECT term, todo_id FROM todo_terms;
+	CREATE TABLE todo_tags_old AS SELECT tag, todo_id FROM todo_tags;
+	DROP TABLE todo_terms;
+	DROP TABLE todo_tags;
+	DROP TABLE todos;
+	ALTER TABLE todos_new RENAME TO todos;
+	CREATE INDEX todos_active_at ON todos (active_at);
+	CREATE INDEX todos_status ON todos (status);
+	CREATE INDEX todos_parent_id ON todos (parent_id);
+	CREATE INDEX todos_list_id ON todos (list_id, active_at);
+	CREATE TABLE todo_terms (
+		term    TEXT NOT NULL,
+		todo_id TEXT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		PRIMARY KEY (term, todo_id)
+	);
+	CREATE INDEX todo_terms_todo_id ON todo_terms (todo_id);
+	CREATE TABLE todo_tags (
+		tag     TEXT NOT NULL,
+		todo_id TEXT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		PRIMARY KEY (tag, todo_id)
+	);
+	CREATE INDEX todo_tags_todo_id ON todo_tags (todo_id);
+	INSERT INTO todo_terms (term, todo_id) SELECT term, todo_id FROM todo_terms_old;
+	INSERT INTO todo_tags (tag, todo_id) SELECT tag, todo_id FROM todo_tags_old;
+	DROP TABLE todo_terms_old;
+	DROP TABLE todo_tags_old;`)
+	return err
+}
+
+func migrateSearchTerms(ctx context.Context, tx *sql.Tx) error {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE todo_terms (
+		term    TEXT NOT NULL,
+		todo_id TEXT NOT NULL REFERENCES todos (id) ON DELETE CASCADE,
+		PRIMARY KEY (term, todo_id)
+	);
+	CREATE INDEX todo_terms_todo_id ON todo_terms (todo_id);`)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT id, title FROM todos`)
+	if err != nil {
+		return err
+	}
+
+	titles := make(map[string]string)
+	for rows.Next() {
+		var id, title string
+		if err := rows.Scan(&id, &title); err != nil {
+			rows.Close()
+			return err
+		}
+		titles[id] = title
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// описания на этом шаге миграций еще нет
+	for id, title := range titles {
+		if err := writeSearchTerms(ctx, tx, id, title, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateDescriptionTerms дописывает в todo_terms основы слов описаний уже созданных задач
+func migrateDescriptionTerms(ctx context.Context, tx *sql.Tx) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id, title, description FROM todos WHERE description != ''`)
+	if err != nil {
+		return err
+	}
+
+	texts := make(map[string][2]string)
+	for rows.Next() {
+		var id, title, description string
+		if err := rows.Scan(&id, &title, &description); err != nil {
+			rows.Close()
+			return err
+		}
+		texts[id] = [2]string{title, description}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for id, text := range texts {
+		if err := writeSearchTerms(ctx, tx, id, text[0], text[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSearchTerms заново записывает основы слов заголовка и описания задачи
+func writeSearchTerms(ctx context.Context, tx *sql.Tx, id, title, description string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM todo_terms WHERE todo_id = ?`, id); err != nil {
+		return err
+	}
+
+	// основа из заголовка и из описания - одна строка: PRIMARY KEY (term, todo_id)
+	seen := make(map[string]bool)
+	for _, text := range []string{title, description} {
+		for _, term := range textsearch.ParseQuery(text).Terms {
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+			if _, err := tx.ExecContext(ctx, `INSERT INTO todo_terms (term, todo_id) VALUES (?, ?)`, term, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeTags заново записывает метки задачи в колонку tags и в индекс todo_tags
+func writeTags(ctx context.Context, tx *sql.Tx, id string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE todos SET tags = ? WHERE id = ?`, tagsJSON(tags), id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM todo_tags WHERE todo_id = ?`, id); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO todo_tags (tag, todo_id) VALUES (?, ?)`, tag, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const todoColumns = "id, title, description, status, completed_at, created_at, updated_at, active_at, parent_id, depends_on, rrule, series_start, tags, priority, list_id, owner_id, assignee_id"
+
+const listColumns = "id, name, archived_at, created_at, updated_at, owner_id"
+
+const userColumns = "id, email, password_hash, created_at, oidc_issuer, oidc_subject, username"
+
+const apiKeyColumns = "id, owner_id, name, hint, hash, scopes, last_used_at, created_at"
+
+const memberColumns = "id, list_id, owner_id, user_id, role, created_at"
+
+const invitationColumns = "id, list_id, owner_id, role, hash, expires_at, created_at"
+
+const notificationColumns = "id, owner_id, kind, task_id, list_id, actor_id, title, read_at, created_at"
+
+const commentColumns = "id, task_id, owner_id, author_id, body, created_at, updated_at"
+
+// sqliteRepository хранит задачи в SQLite для небольших установок без MongoDB.
+// ID остаются ObjectID в hex-виде, поэтому поле id в JSON выглядит так же, как с Mongo
+type sqliteRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepository(config config.Config) (TodoRepository, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", config.SQLitePath)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite не любит параллельных писателей, одного соединения достаточно
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(context.Background(), db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteRepository{db: db}, nil
+}
+
+// migrateSQLite применяет миграции, которых еще нет в schema_migrations
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for version := current + 1; version <= len(sqliteMigrations); version++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		if err := sqliteMigrations[version-1](ctx, tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("sqlite migration %d: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *sqliteRepository) CreateNewTodo(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
+	if err := todo.Validate(); err != nil {
+		return nil, err
+	}
+
+	if todo.Status == "" {
+		todo.Status = entity.StatusTodo
+	}
+	if todo.Priority == "" {
+		todo.Priority = entity.PriorityNormal
+	}
+	todo.OwnerID = entity.OwnerFromContext(ctx)
+	todo.CreatedAt = time.Now()
+	todo.UpdatedAt = time.Now()
+	id := primitive.NewObjectID()
+
+	// Уникальность title и activeAt в списке проверяет сама база
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO todos (`+todoColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id.Hex(), todo.Title, todo.Description, todo.Status, nullableMillis(todo.CompletedAt),
+			toMillis(todo.CreatedAt), toMillis(todo.UpdatedAt), toMillis(todo.ActiveAt), nullableID(todo.ParentID),
+			idsJSON(todo.DependsOn), todo.RRule, nullableMillis(todo.SeriesStart), tagsJSON(todo.Tags),
+			todo.Priority, listColumn(todo.ListID), ownerColumn(ctx), listColumn(todo.AssigneeID),
+		)
+		if err != nil {
+			return err
+		}
+		if err := writeTags(ctx, tx, id.Hex(), todo.Tags); err != nil {
+			return err
+		}
+		return writeSearchTerms(ctx, tx, id.Hex(), todo.Title, todo.Description)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.ErrTodoExists
+		}
+		return nil, err
+	}
+
+	todo.ID = id
+	return todo, nil
+}
+
+func (r *sqliteRepository) UpdateTodo(ctx context.Context, id primitive.ObjectID, todo *entity.Todo) (*entity.Todo, error) {
+	if err := todo.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Проверка существования задачи по ID
+	existingTodo, err := r.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// статус меняется только переходами, а родитель - только при создании, список - только переносом, PUT их не трогает
+	todo.ID = id
+	todo.OwnerID = existingTodo.OwnerID
+	todo.ListID = existingTodo.ListID
+	todo.ParentID = existingTodo.ParentID
+	todo.Status = existingTodo.Status
+	todo.CompletedAt = existingTodo.CompletedAt
+	todo.CreatedAt = existingTodo.CreatedAt
+	todo.UpdatedAt = time.Now()
+
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE todos SET title = ?, description = ?, priority = ?, updated_at = ?, active_at = ?, depends_on = ?, rrule = ?,
+			series_start = ?, assignee_id = ? WHERE id = ?`,
+			todo.Title, todo.Description, todo.Priority, toMillis(todo.UpdatedAt), toMillis(todo.ActiveAt),
+			idsJSON(todo.DependsOn), todo.RRule, nullableMillis(todo.SeriesStart), listColumn(todo.AssigneeID), id.Hex(),
+		)
+		if err != nil {
+			return err
+		}
+		if err := writeTags(ctx, tx, id.Hex(), todo.Tags); err != nil {
+			return err
+		}
+		return writeSearchTerms(ctx, tx, id.Hex(), todo.Title, todo.Description)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.ErrTodoExists
+		}
+		return nil, err
+	}
+
+	return todo, nil
+}
+
+func (r *sqliteRepository) PatchTodo(ctx context.Context, id primitive.ObjectID, patch *entity.TodoPatch) (*entity.Todo, error) {
+	existingTodo, err := r.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := *existingTodo
+	if err := patch.Apply(&patched); err != nil {
+		return nil, err
+	}
+
+	query := `UPDATE todos SET updated_at = ?`
+	args := []interface{}{toMillis(patched.UpdatedAt)}
+	if patch.Title != nil {
+		query += `, title = ?`
+		args = append(args, patched.Title)
+	}
+	if patch.Description != nil {
+		query += `, description = ?`
+		args = append(args, patched.Description)
+	}
+	if patch.Priority != nil {
+		query += `, priority = ?`
+		args = append(args, patched.Priority)
+	}
+	if patch.ActiveAt != nil {
+		query += `, active_at = ?`
+		args = append(args, toMillis(patched.ActiveAt))
+	}
+	if patch.AssigneeID != nil {
+		query += `, assignee_id = ?`
+		args = append(args, listColumn(patched.AssigneeID))
+	}
+	if patch.DependsOn != nil {
+		query += `, depends_on = ?`
+		args = append(args, idsJSON(patched.DependsOn))
+	}
+	if patch.RRule != nil {
+		query += `, rrule = ?, series_start = ?`
+		args = append(args, patched.RRule, nullableMillis(patched.SeriesStart))
+	}
+	if patch.Status != nil {
+		query += `, status = ?, completed_at = ?`
+		args = append(args, patched.Status, nullableMillis(patched.CompletedAt))
+	}
+	query += ` WHERE id = ? AND status = ?`
+	// переход проверен для статуса, который мы прочитали
+	args = append(args, id.Hex(), existingTodo.Status)
+
+	var affected int64
+	err = r.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if affected, err = result.RowsAffected(); err != nil || affected == 0 {
+			return err
+		}
+		if patch.Tags != nil {
+			if err := writeTags(ctx, tx, id.Hex(), patched.Tags); err != nil {
+				return err
+			}
+		}
+		if patch.Title == nil && patch.Description == nil {
+			return nil
+		}
+		return writeSearchTerms(ctx, tx, id.Hex(), patched.Title, patched.Description)
+	})
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.ErrTodoExists
+		}
+		return nil, err
+	}
+	if err := r.requireStatusUpdated(ctx, id, affected); err != nil {
+		return nil, err
+	}
+
+	return r.GetTaskByID(ctx, id)
+}
+
+func (r *sqliteRepository) DeleteTodo(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM todos WHERE id = ? AND owner_id = ?`, id.Hex(), ownerColumn(ctx))
+	if err != nil {
+		return err
+	}
+
+	return requireAffected(result)
+}
+
+func (r *sqliteRepository) SetStatus(ctx context.Context, id primitive.ObjectID, from entity.TaskStatus, todo *entity.Todo) (*entity.Todo, error) {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE todos SET status = ?, completed_at = ?, updated_at = ? WHERE id = ? AND owner_id = ? AND status = ?`,
+		todo.Status, nullableMillis(todo.CompletedAt), toMillis(todo.UpdatedAt), id.Hex(), ownerColumn(ctx), from,
+	)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.requireStatusUpdated(ctx, id, affected); err != nil {
+		return nil, err
+	}
+
+	return r.GetTaskByID(ctx, id)
+}
+
+// requireStatusUpdated объясняет, почему условный UPDATE ничего не изменил: задачи нет или у нее уже другой статус
+func (r *sqliteRepository) requireStatusUpdated(ctx context.Context, id primitive.ObjectID, affected int64) error {
+	if affected > 0 {
+		return nil
+	}
+
+	if _, err := r.GetTaskByID(ctx, id); err != nil {
+		return err
+	}
+	return errors.ErrStatusConflict
+}
+
+func (r *sqliteRepository) GetTasksByStatus(ctx context.Context, status string, opts entity.ListOptions) (*entity.TodoPage, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if entity.TaskStatus(status).IsValid() {
+		return r.findPage(ctx, `status = ?`, []interface{}{status}, opts)
+	}
+
+	// Получить задачи, которые не завершены и имеют activeAt <= today
+	open := entity.OpenStatuses()
+	where := `status IN (?, ?, ?) AND active_at <= ?`
+	args := []interface{}{open[0], open[1], open[2], toMillis(today)}
+
+	// и не ждут незакрытых зависимостей
+	if !opts.IncludeBlocked {
+		where += ` AND NOT EXISTS (SELECT 1 FROM json_each(todos.depends_on) d JOIN todos dep ON dep.id = d.value
+			WHERE dep.status IN (?, ?, ?))`
+		args = append(args, open[0], open[1], open[2])
+	}
+
+	return r.findPage(ctx, where, args, opts)
+}
+
+func (r *sqliteRepository) GetTaskByID(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+todoColumns+` FROM todos WHERE id = ? AND owner_id = ?`, id.Hex(), ownerColumn(ctx))
+
+	todo, err := scanTodo(row)
+	if err != nil {
+		if stderrors.Is(err, sql.ErrNoRows) {
+			return nil, errors.ErrNotFound
+		}
+		return nil, err
+	}
+	return todo, nil
+}
+
+func (r *sqliteRepository) GetAllTasks(ctx context.Context, opts entity.ListOptions) (*entity.TodoPage, error) {
+	return r.findPage(ctx, `1 = 1`, nil, opts)
+}
+
+// findPage достает одну страницу задач: where - условие выборки, к нему добавляются диапазоны дат и курсор
+func (r *sqliteRepository) findPage(ctx context.Context, where string, args []interface{}, opts entity.ListOptions) (*entity.TodoPage, error) {
+	opts, err := normalizeListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	addRange := func(column string, from, to *time.Time) {
+		if from != nil {
+			where += ` AND ` + column + ` >= ?`
+			args = append(args, toMillis(*from))
+		}
+		if to != nil {
+			where += ` AND ` + column + ` <= ?`
+			args = append(args, toMillis(*to))
+		}
+	}
+	where += ` AND owner_id = ? AND list_id = ?`
+	args = append(args, ownerColumn(ctx), listColumn(opts.ListID))
+	if opts.ParentID != nil {
+		where += ` AND parent_id = ?`
+		args = append(args, opts.ParentID.Hex())
+	}
+	if len(opts.TagsAny) > 0 {
+		where += ` AND id IN (SELECT todo_id FROM todo_tags WHERE tag IN (` + placeholders(len(opts.TagsAny)) + `))`
+		args = append(args, stringArgs(opts.TagsAny)...)
+	}
+	for _, tag := range opts.TagsAll {
+		where += ` AND EXISTS (SELECT 1 FROM todo_tags WHERE todo_id = todos.id AND tag = ?)`
+		args = append(args, tag)
+	}
+	addRange("active_at", opts.ActiveFrom, opts.ActiveTo)
+	addRange("created_at", opts.CreatedFrom, opts.CreatedTo)
+
+	// имя колонки совпадает с entity.SortField, а ParseSort пропускает только известные поля
+	column := string(opts.Sort)
+	direction, after := "ASC", ">"
+	if opts.Descending {
+		direction, after = "DESC", "<"
+	}
+
+	// продолжаем строго после пары (поле, id) из курсора
+	if cursor != nil {
+		value := cursor.value()
+		if t, ok := value.(time.Time); ok {
+			value = toMillis(t)
+		}
+		where += ` AND (` + column + ` ` + after + ` ? OR (` + column + ` = ? AND id ` + after + ` ?))`
+		args = append(args, value, value, cursor.ID.Hex())
+	}
+
+	query := `SELECT ` + todoColumns + ` FROM todos WHERE ` + where +
+		` ORDER BY ` + column + ` ` + direction + `, id ` + direction
+	if opts.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, opts.Limit+1)
+	}
+
+	todos, err := r.queryTodos(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPage(todos, opts), nil
+}
+
+func (r *sqliteRepository) SearchTasks(ctx context.Context, listID *primitive.ObjectID, q string, limit int) ([]*entity.SearchHit, error) {
+	query := textsearch.ParseQuery(q)
+	if query.IsEmpty() {
+		return []*entity.SearchHit{}, nil
+	}
+
+	// кандидаты - задачи, в заголовке или описании которых есть хотя бы одна основа из запроса; оценка та же, что и в памяти
+	args := append([]interface{}{ownerColumn(ctx), listColumn(listID)}, stringArgs(query.Terms)...)
+
+	candidates, err := r.queryTodos(ctx,
+		`SELECT `+todoColumns+` FROM todos WHERE owner_id = ? AND list_id = ?
+		AND id IN (SELECT todo_id FROM todo_terms WHERE term IN (`+placeholders(len(query.Terms))+`))`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return rankHits(query, candidates, limit), nil
+}
+
+func (r *sqliteRepository) GetSubtasks(ctx context.Context, parentIDs []primitive.ObjectID) ([]*entity.Todo, error) {
+	if len(parentIDs) == 0 {
+		return []*entity.Todo{}, nil
+	}
+
+	todos, err := r.queryTodos(ctx,
+		`SELECT `+todoColumns+` FROM todos WHERE owner_id = ? AND parent_id IN (`+placeholders(len(parentIDs))+`)
+		ORDER BY active_at, id`,
+		append([]interface{}{ownerColumn(ctx)}, hexIDs(parentIDs)...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if todos == nil {
+		todos = []*entity.Todo{}
+	}
+	return todos, nil
+}
+
+func (r *sqliteRepository) SetParent(ctx context.Context, ids []primitive.ObjectID, parentID *primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := append([]interface{}{nullableID(parentID), ownerColumn(ctx)}, hexIDs(ids)...)
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE todos SET parent_id = ? WHERE owner_id = ? AND id IN (`+placeholders(len(ids))+`)`, args...)
+	return err
+}
+
+func (r *sqliteRepository) GetTasksByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*entity.Todo, error) {
+	if len(ids) == 0 {
+		return []*entity.Todo{}, nil
+	}
+
+	todos, err := r.queryTodos(ctx,
+		`SELECT `+todoColumns+` FROM todos WHERE owner_id = ? AND id IN (`+placeholders(len(ids))+`) ORDER BY active_at, id`,
+		append([]interface{}{ownerColumn(ctx)}, hexIDs(ids)...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if todos == nil {
+		todos = []*entity.Todo{}
+	}
+	return todos, nil
+}
+
+func (r *sqliteRepository) RemoveDependency(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE todos SET depends_on = (SELECT json_group_array(value) FROM json_each(todos.depends_on) WHERE value <> ?)
+		WHERE owner_id = ? AND EXISTS (SELECT 1 FROM json_each(todos.depends_on) WHERE value = ?)`,
+		id.Hex(), ownerColumn(ctx), id.Hex(),
+	)
+	return err
+}
+
+func (r *sqliteRepository) GetTags(ctx context.Context) ([]*entity.TagCount, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT tag, COUNT(*) AS count FROM todo_tags JOIN todos ON todos.id = todo_tags.todo_id WHERE todos.owner_id = ?
+		GROUP BY tag ORDER BY count DESC, tag`,
+		ownerColumn(ctx),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*entity.TagCount{}
+	for rows.Next() {
+		var tag entity.TagCount
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, &tag)
+	}
+	return tags, rows.Err()
+}
+
+// RenameTags переписывает метки всех затронутых задач в одной транзакции
+func (r *sqliteRepository) RenameTags(ctx context.Context, from []string, to string) (int, error) {
+	if len(from) == 0 {
+		return 0, nil
+	}
+
+	renamed := 0
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx,
+			`SELECT id, tags FROM todos WHERE owner_id = ?
+			AND id IN (SELECT todo_id FROM todo_tags WHERE tag IN (`+placeholders(len(from))+`))`,
+			append([]interface{}{ownerColumn(ctx)}, stringArgs(from)...)...,
+		)
+		if err != nil {
+			return err
+		}
+
+		tagged := make(map[string]*entity.Todo)
+		for rows.Next() {
+			var id, tags string
+			if err := rows.Scan(&id, &tags); err != nil {
+				rows.Close()
+				return err
+			}
+			todo := &entity.Todo{}
+			if err := json.Unmarshal([]byte(tags), &todo.Tags); err != nil {
+				rows.Close()
+				return err
+			}
+			tagged[id] = todo
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		updatedAt := toMillis(time.Now())
+		for id, todo := range tagged {
+			if !todo.RenameTags(from, to) {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `UPDATE todos SET updated_at = ? WHERE id = ?`, updatedAt, id); err != nil {
+				return err
+			}
+			if err := writeTags(ctx, tx, id, todo.Tags); err != nil {
+				return err
+			}
+			renamed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return renamed, nil
+}
+
+// MoveTasks переносит задачи одним UPDATE: дубликат в списке откатывает его целиком
+func (r *sqliteRepository) MoveTasks(ctx context.Context, ids []primitive.ObjectID, listID *primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	args := append([]interface{}{listColumn(listID), toMillis(time.Now()), ownerColumn(ctx)}, hexIDs(ids)...)
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE todos SET list_id = ?, updated_at = ? WHERE owner_id = ? AND id IN (`+placeholders(len(ids))+`)`, args...)
+	if isUniqueViolation(err) {
+		return errors.ErrTodoExists
+	}
+	return err
+}
+
+func (r *sqliteRepository) CreateList(ctx context.Context, list *entity.List) (*entity.List, error) {
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+
+	list.OwnerID = entity.OwnerFromContext(ctx)
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	id := primitive.NewObjectID()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO lists (`+listColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		id.Hex(), list.Name, nullableMillis(list.ArchivedAt), toMillis(list.CreatedAt), toMillis(list.UpdatedAt),
+		ownerColumn(ctx),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.ErrListExists
+		}
+		return nil, err
+	}
+
+	list.ID = id
+	return list, nil
+}
+
+func (r *sqliteRepository) GetList(ctx context.Context, id primitive.ObjectID) (*entity.List, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+listColumns+` FROM lists WHERE id = ? AND owner_id = ?`, id.Hex(), ownerColumn(ctx))
+
+	list, err := scanList(row)
+	if err != nil {
+		if stderrors.Is(err, sql.ErrNoRows) {
+			return nil, errors.ErrListNotFound
+		}
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *sqliteRepository) GetLists(ctx context.Context, includeArchived bool) ([]*entity.List, error) {
+	query := `SELECT ` + listColumns + ` FROM lists WHERE owner_id = ?`
+	if !includeArchived {
+		query += ` AND archived_at IS NULL`
+	}
+
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY name, id`, ownerColumn(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := []*entity.List{}
+	for rows.Next() {
+		list, err := scanList(rows)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	return lists, rows.Err()
+}
+
+func (r *sqliteRepository) UpdateList(ctx context.Context, list *entity.List) (*entity.List, error) {
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE lists SET name = ?, archived_at = ?, updated_at = ? WHERE id = ? AND owner_id = ?`,
+		list.Name, nullableMillis(list.ArchivedAt), toMillis(list.UpdatedAt), list.ID.Hex(), ownerColumn(ctx),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.ErrListExists
+		}
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		return nil, listNotFound(err)
+	}
+
+	return r.GetList(ctx, list.ID)
+}
+
+func (r *sqliteRepository) DeleteList(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM lists WHERE id = ? AND owner_id = ?`, id.Hex(), ownerColumn(ctx))
+	if err != nil {
+		return err
+	}
+	return listNotFound(requireAffected(result))
+}
+
+func (r *sqliteRepository) CreateUser(ctx context.Context, user *entity.User) (*entity.User, error) {
+	user.CreatedAt = time.Now()
+	id := primitive.NewObjectID()
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(), user.Email, user.PasswordHash, toMillis(user.CreatedAt), user.OIDCIssuer, user.OIDCSubject, user.Username,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.ErrUserExists
+		}
+		return nil, err
+	}
+
+	user.ID = id
+	return user, nil
+}
+
+func (r *sqliteRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*entity.User, error) {
+	return r.findUser(ctx, `id = ?`, id.Hex())
+}
+
+func (r *sqliteRepository) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return r.findUser(ctx, `email = ?`, email)
+}
+
+func (r *sqliteRepository) GetUserByUsername(ctx context.Context, username string) (*entity.User, error) {
+	if username == "" {
+		return nil, errors.ErrUserNotFound
+	}
+	return r.findUser(ctx, `username = ?`, username)
+}
+
+func (r *sqliteRepository) SetUsername(ctx context.Context, id primitive.ObjectID, username string) (*entity.User, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE users SET username = ? WHERE id = ?`, username, id.Hex())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.ErrUsernameExists
+		}
+		return nil, err
+	}
+	if err := requireAffected(result); err != nil {
+		if stderrors.Is(err, errors.ErrNotFound) {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return r.GetUserByID(ctx, id)
+}
+
+func (r *sqliteRepository) GetUserByIdentity(ctx context.Context, issuer, subject string) (*entity.User, error) {
+	if subject == "" {
+		return nil, errors.ErrUserNotFound
+	}
+	return r.findUser(ctx, `oidc_issuer = ? AND oidc_subject = ?`, issuer, subject)
+}
+
+func (r *sqliteRepository) LinkIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error {
+	result, err := r.db.ExecContext(ctx,
+		`UPDATE users SET oidc_issuer = ?, oidc_subject = ? WHERE id = ?`, issuer, subject, id.Hex())
+	if err != nil {
+		if isUniqueViolation(err) {
+			return errors.ErrUserExists
+		}
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if stderrors.Is(err, errors.ErrNotFound) {
+			return errors.ErrUserNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *sqliteRepository) findUser(ctx context.Context, where string, args ...interface{}) (*entity.User, error) {
+	var (
+		user      entity.User
+		id        string
+		createdAt int64
+	)
+	err := r.db.QueryRowContext(ctx, `SELECT `+userColumns+` FROM users WHERE `+where, args...).
+		Scan(&id, &user.Email, &user.PasswordHash, &createdAt, &user.OIDCIssuer, &user.OIDCSubject, &user.Username)
+	if err != nil {
+		if stderrors.Is(err, sql.ErrNoRows) {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if user.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	user.CreatedAt = fromMillis(createdAt)
+	return &user, nil
+}
+
+func (r *sqliteRepository) CountUsers(ctx context.Context) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users`).Scan(&count)
+	return count, err
+}
+
+func (r *sqliteRepository) ClaimUnowned(ctx context.Context, ownerID primitive.ObjectID) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `UPDATE lists SET owner_id = ? WHERE owner_id = ''`, ownerID.Hex()); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE todos SET owner_id = ? WHERE owner_id = ''`, ownerID.Hex())
+		return err
+	})
+}
+
+func (r *sqliteRepository) CreateAPIKey(ctx context.Context, key *entity.APIKey) (*entity.APIKey, error) {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	id := primitive.NewObjectID()
+	_, err = r.db.ExecContext(ctx,
+		`INSERT INTO api_keys (`+apiKeyColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(), key.OwnerID.Hex(), key.Name, key.Hint, key.Hash, string(scopes),
+		nullableMillis(key.LastUsedAt), toMillis(key.CreatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	key.ID = id
+	return key, nil
+}
+
+func (r *sqliteRepository) GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT `+apiKeyColumns+` FROM api_keys WHERE owner_id = ? ORDER BY created_at, id`, ownerColumn(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*entity.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *sqliteRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = ?`, hash))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, errors.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+func (r *sqliteRepository) DeleteAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = ? AND owner_id = ?`, id.Hex(), ownerColumn(ctx))
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if stderrors.Is(err, errors.ErrNotFound) {
+			return errors.ErrAPIKeyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *sqliteRepository) TouchAPIKey(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, toMillis(usedAt), id.Hex())
+	return err
+}
+
+func (r *sqliteRepository) AddMember(ctx context.Context, member *entity.Membership) (*entity.Membership, error) {
+	id := primitive.NewObjectID()
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO list_members (`+memberColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		id.Hex(), member.ListID.Hex(), member.OwnerID.Hex(), member.UserID.Hex(), string(member.Role), toMillis(member.CreatedAt),
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, errors.ErrMemberExists
+		}
+		return nil, err
+	}
+
+	member.ID = id
+	return member, nil
+}
+
+func (r *sqliteRepository) GetMembership(ctx context.Context, listID, userID primitive.ObjectID) (*entity.Membership, error) {
+	member, err := scanMember(r.db.QueryRowContext(ctx,
+		`SELECT `+memberColumns+` FROM list_members WHERE list_id = ? AND user_id = ?`, listID.Hex(), userID.Hex()))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, errors.ErrMemberNotFound
+	}
+	return member, err
+}
+
+func (r *sqliteRepository) GetMemberships(ctx context.Context, userID primitive.ObjectID) ([]*entity.Membership, error) {
+	return r.findMembers(ctx, `user_id = ?`, userID.Hex())
+}
+
+func (r *sqliteRepository) GetMembers(ctx context.Context, listID primitive.ObjectID) ([]*entity.Membership, error) {
+	return r.findMembers(ctx, `list_id = ? AND owner_id = ?`, listID.Hex(), ownerColumn(ctx))
+}
+
+func (r *sqliteRepository) findMembers(ctx context.Context, where string, args ...interface{}) ([]*entity.Membership, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+memberColumns+` FROM list_members WHERE `+where+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []*entity.Membership{}
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (r *sqliteRepository) SetMemberRole(ctx context.Context, listID, userID primitive.ObjectID, role entity.Role) (*entity.Membership, error) {
+	member, err := scanMember(r.db.QueryRowContext(ctx,
+		`UPDATE list_members SET role = ? WHERE list_id = ? AND user_id = ? AND owner_id = ? RETURNING `+memberColumns,
+		string(role), listID.Hex(), userID.Hex(), ownerColumn(ctx)))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, errors.ErrMemberNotFound
+	}
+	return member, err
+}
+
+func (r *sqliteRepository) RemoveMember(ctx context.Context, listID, userID primitive.ObjectID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM list_members WHERE list_id = ? AND user_id = ? AND owner_id = ?`,
+		listID.Hex(), userID.Hex(), ownerColumn(ctx))
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if stderrors.Is(err, errors.ErrNotFound) {
+			return errors.ErrMemberNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *sqliteRepository) RemoveMembers(ctx context.Context, listID primitive.ObjectID) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		args := []interface{}{listID.Hex(), ownerColumn(ctx)}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM list_members WHERE list_id = ? AND owner_id = ?`, args...); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM list_invitations WHERE list_id = ? AND owner_id = ?`, args...)
+		return err
+	})
+}
+
+func (r *sqliteRepository) CreateInvitation(ctx context.Context, invitation *entity.Invitation) (*entity.Invitation, error) {
+	id := primitive.NewObjectID()
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO list_invitations (`+invitationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		id.Hex(), invitation.ListID.Hex(), invitation.OwnerID.Hex(), string(invitation.Role), invitation.Hash,
+		toMillis(invitation.ExpiresAt), toMillis(invitation.CreatedAt),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation.ID = id
+	return invitation, nil
+}
+
+func (r *sqliteRepository) TakeInvitation(ctx context.Context, hash string) (*entity.Invitation, error) {
+	invitation, err := scanInvitation(r.db.QueryRowContext(ctx,
+		`DELETE FROM list_invitations WHERE hash = ? RETURNING `+invitationColumns, hash))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, errors.ErrInvitationNotFound
+	}
+	return invitation, err
+}
+
+func (r *sqliteRepository) CreateNotifications(ctx context.Context, notifications []*entity.Notification) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		for _, notification := range notifications {
+			id := primitive.NewObjectID()
+			_, err := tx.ExecContext(ctx,
+				`INSERT INTO notifications (`+notificationColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				id.Hex(), notification.OwnerID.Hex(), string(notification.Kind), notification.TaskID.Hex(),
+				listColumn(notification.ListID), notification.ActorID.Hex(), notification.Title,
+				nullableMillis(notification.ReadAt), toMillis(notification.CreatedAt),
+			)
+			if err != nil {
+				return err
+			}
+			notification.ID = id
+		}
+		return nil
+	})
+}
+
+func (r *sqliteRepository) GetNotifications(ctx context.Context, unreadOnly bool, limit int) ([]*entity.Notification, error) {
+	query := `SELECT ` + notificationColumns + ` FROM notifications WHERE owner_id = ?`
+	if unreadOnly {
+		query += ` AND read_at IS NULL`
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY created_at DESC, id DESC LIMIT ?`, ownerColumn(ctx), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []*entity.Notification{}
+	for rows.Next() {
+		notification, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+	return notifications, rows.Err()
+}
+
+func (r *sqliteRepository) SetNotificationRead(ctx context.Context, id primitive.ObjectID, readAt *time.Time) (*entity.Notification, error) {
+	notification, err := scanNotification(r.db.QueryRowContext(ctx,
+		`UPDATE notifications SET read_at = ? WHERE id = ? AND owner_id = ? RETURNING `+notificationColumns,
+		nullableMillis(readAt), id.Hex(), ownerColumn(ctx)))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, errors.ErrNotificationNotFound
+	}
+	return notification, err
+}
+
+func (r *sqliteRepository) CreateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error) {
+	return scanComment(r.db.QueryRowContext(ctx,
+		`INSERT INTO comments (`+commentColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING `+commentColumns,
+		primitive.NewObjectID().Hex(), comment.TaskID.Hex(), listColumn(comment.OwnerID), listColumn(comment.AuthorID),
+		comment.Body, toMillis(comment.CreatedAt), toMillis(comment.UpdatedAt),
+	))
+}
+
+func (r *sqliteRepository) GetComment(ctx context.Context, id primitive.ObjectID) (*entity.Comment, error) {
+	comment, err := scanComment(r.db.QueryRowContext(ctx,
+		`SELECT `+commentColumns+` FROM comments WHERE id = ? AND owner_id = ?`, id.Hex(), ownerColumn(ctx)))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, errors.ErrCommentNotFound
+	}
+	return comment, err
+}
+
+func (r *sqliteRepository) GetComments(ctx context.Context, taskID primitive.ObjectID, after *primitive.ObjectID, limit int) ([]*entity.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE task_id = ? AND owner_id = ?`
+	args := []interface{}{taskID.Hex(), ownerColumn(ctx)}
+	if after != nil {
+		// hex ObjectID сравнивается как строка в том же порядке, что и сам ID
+		query += ` AND id > ?`
+		args = append(args, after.Hex())
+	}
+	rows, err := r.db.QueryContext(ctx, query+` ORDER BY id LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*entity.Comment{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+func (r *sqliteRepository) UpdateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error) {
+	updated, err := scanComment(r.db.QueryRowContext(ctx,
+		`UPDATE comments SET body = ?, updated_at = ? WHERE id = ? AND owner_id = ? RETURNING `+commentColumns,
+		comment.Body, toMillis(comment.UpdatedAt), comment.ID.Hex(), ownerColumn(ctx)))
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return nil, errors.ErrCommentNotFound
+	}
+	return updated, err
+}
+
+func (r *sqliteRepository) DeleteComment(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM comments WHERE id = ? AND owner_id = ?`, id.Hex(), ownerColumn(ctx))
+	if err != nil {
+		return err
+	}
+	if err := requireAffected(result); err != nil {
+		if stderrors.Is(err, errors.ErrNotFound) {
+			return errors.ErrCommentNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *sqliteRepository) CountComments(ctx context.Context, taskIDs []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	counts := make(map[primitive.ObjectID]int)
+	if len(taskIDs) == 0 {
+		return counts, nil
+	}
+
+	args := append([]interface{}{ownerColumn(ctx)}, hexIDs(taskIDs)...)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT task_id, COUNT(*) FROM comments WHERE owner_id = ? AND task_id IN (`+placeholders(len(taskIDs))+`)
+		GROUP BY task_id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			taskID string
+			count  int
+		)
+		if err := rows.Scan(&taskID, &count); err != nil {
+			return nil, err
+		}
+		id, err := primitive.ObjectIDFromHex(taskID)
+		if err != nil {
+			return nil, err
+		}
+		counts[id] = count
+	}
+	return counts, rows.Err()
+}
+
+// listNotFound - requireAffected для списков
+func listNotFound(err error) error {
+	if stderrors.Is(err, errors.ErrNotFound) {
+		return errors.ErrListNotFound
+	}
+	return err
+}
+
+// placeholders - "?, ?, ?" для IN из n значений
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func hexIDs(ids []primitive.ObjectID) []interface{} {
+	args := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		args = append(args, id.Hex())
+	}
+	return args
+}
+
+// idsJSON - ID в виде JSON-массива hex-строк для колонки depends_on
+func idsJSON(ids []primitive.ObjectID) string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.Hex())
+	}
+	data, _ := json.Marshal(values)
+	return string(data)
+}
+
+// tagsJSON - метки в виде JSON-массива для колонки tags
+func tagsJSON(tags []string) string {
+	if tags == nil {
+		tags = []string{}
+	}
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return args
+}
+
+func nullableID(id *primitive.ObjectID) interface{} {
+	if id == nil {
+		return nil
+	}
+	return id.Hex()
+}
+
+// listColumn - значение колонки list_id: у задач списка по умолчанию пустая строка
+func listColumn(listID *primitive.ObjectID) string {
+	if listID == nil {
+		return ""
+	}
+	return listID.Hex()
+}
+
+// ownerColumn - значение колонки owner_id для владельца из контекста: у данных без владельца пустая строка
+func ownerColumn(ctx context.Context) string {
+	return listColumn(entity.OwnerFromContext(ctx))
+}
+
+// inTx выполняет fn в транзакции: commit, если fn без ошибки, иначе rollback
+func (r *sqliteRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqliteRepository) Close() error {
+	return r.db.Close()
+}
+
+func (r *sqliteRepository) queryTodos(ctx context.Context, query string, args ...interface{}) ([]*entity.Todo, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var todos []*entity.Todo
+	for rows.Next() {
+		todo, err := scanTodo(rows)
+		if err != nil {
+			return nil, err
+		}
+		todos = append(todos, todo)
+	}
+
+	return todos, rows.Err()
+}
+
+// rowScanner - общее у *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTodo(row rowScanner) (*entity.Todo, error) {
+	var (
+		todo                           entity.Todo
+		id                             string
+		completedAt                    sql.NullInt64
+		createdAt, updatedAt, activeAt int64
+		parentID                       sql.NullString
+		dependsOn, tags, listID        string
+		ownerID, assigneeID            string
+		seriesStart                    sql.NullInt64
+	)
+
+	if err := row.Scan(&id, &todo.Title, &todo.Description, &todo.Status, &completedAt, &createdAt, &updatedAt, &activeAt,
+		&parentID, &dependsOn, &todo.RRule, &seriesStart, &tags, &todo.Priority, &listID, &ownerID, &assigneeID); err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+
+	todo.ID = objectID
+	todo.CreatedAt = fromMillis(createdAt)
+	todo.UpdatedAt = fromMillis(updatedAt)
+	todo.ActiveAt = fromMillis(activeAt)
+	if completedAt.Valid {
+		t := fromMillis(completedAt.Int64)
+		todo.CompletedAt = &t
+	}
+	if seriesStart.Valid {
+		t := fromMillis(seriesStart.Int64)
+		todo.SeriesStart = &t
+	}
+	if parentID.Valid {
+		parent, err := primitive.ObjectIDFromHex(parentID.String)
+		if err != nil {
+			return nil, err
+		}
+		todo.ParentID = &parent
+	}
+	if todo.ListID, err = optionalID(listID); err != nil {
+		return nil, err
+	}
+	if todo.OwnerID, err = optionalID(ownerID); err != nil {
+		return nil, err
+	}
+	if todo.AssigneeID, err = optionalID(assigneeID); err != nil {
+		return nil, err
+	}
+
+	var dependencies []string
+	if err := json.Unmarshal([]byte(dependsOn), &dependencies); err != nil {
+		return nil, err
+	}
+	for _, dependency := range dependencies {
+		dependencyID, err := primitive.ObjectIDFromHex(dependency)
+		if err != nil {
+			return nil, err
+		}
+		todo.DependsOn = append(todo.DependsOn, dependencyID)
+	}
+	if err := json.Unmarshal([]byte(tags), &todo.Tags); err != nil {
+		return nil, err
+	}
+	if len(todo.Tags) == 0 {
+		todo.Tags = nil
+	}
+
+	return &todo, nil
+}
+
+func scanAPIKey(row rowScanner) (*entity.APIKey, error) {
+	var (
+		key         entity.APIKey
+		id, ownerID string
+		scopes      string
+		lastUsedAt  sql.NullInt64
+		createdAt   int64
+	)
+
+	if err := row.Scan(&id, &ownerID, &key.Name, &key.Hint, &key.Hash, &scopes, &lastUsedAt, &createdAt); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if key.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+		return nil, err
+	}
+	if key.OwnerID, err = primitive.ObjectIDFromHex(ownerID); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &key.Scopes); err != nil {
+		return nil, err
+	}
+
+	key.CreatedAt = fromMillis(createdAt)
+	if lastUsedAt.Valid {
+		t := fromMillis(lastUsedAt.Int64)
+		key.LastUsedAt = &t
+	}
+	return &key, nil
+}
+
+func scanMember(row rowScanner) (*entity.Membership, error) {
+	var (
+		member                      entity.Membership
+		id, listID, ownerID, userID string
+		role                        string
+		createdAt                   int64
+	)
+
+	if err := row.Scan(&id, &listID, &ownerID, &userID, &role, &createdAt); err != nil {
+		return nil, err
+	}
+
+	err := parseIDs([]string{id, listID, ownerID, userID}, &member.ID, &member.ListID, &member.OwnerID, &member.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	member.Role = entity.Role(role)
+	member.CreatedAt = fromMillis(createdAt)
+	return &member, nil
+}
+
+func scanInvitation(row rowScanner) (*entity.Invitation, error) {
+	var (
+		invitation           entity.Invitation
+		id, listID, ownerID  string
+		role                 string
+		expiresAt, createdAt int64
+	)
+
+	if err := row.Scan(&id, &listID, &ownerID, &role, &invitation.Hash, &expiresAt, &createdAt); err != nil {
+		return nil, err
+	}
+
+	if err := parseIDs([]string{id, listID, ownerID}, &invitation.ID, &invitation.ListID, &invitation.OwnerID); err != nil {
+		return nil, err
+	}
+
+	invitation.Role = entity.Role(role)
+	invitation.ExpiresAt = fromMillis(expiresAt)
+	invitation.CreatedAt = fromMillis(createdAt)
+	return &invitation, nil
+}
+
+func scanNotification(row rowScanner) (*entity.Notification, error) {
+	var (
+		notification                 entity.Notification
+		id, ownerID, taskID, actorID string
+		kind, listID                 string
+		readAt                       sql.NullInt64
+		createdAt                    int64
+	)
+
+	if err := row.Scan(&id, &ownerID, &kind, &taskID, &listID, &actorID, &notification.Title, &readAt, &createdAt); err != nil {
+		return nil, err
+	}
+
+	err := parseIDs([]string{id, ownerID, taskID, actorID},
+		&notification.ID, &notification.OwnerID, &notification.TaskID, &notification.ActorID)
+	if err != nil {
+		return nil, err
+	}
+	if notification.ListID, err = optionalID(listID); err != nil {
+		return nil, err
+	}
+
+	notification.Kind = entity.NotificationKind(kind)
+	notification.CreatedAt = fromMillis(createdAt)
+	if readAt.Valid {
+		t := fromMillis(readAt.Int64)
+		notification.ReadAt = &t
+	}
+	return &notification, nil
+}
+
+func scanComment(row rowScanner) (*entity.Comment, error) {
+	var (
+		comment                     entity.Comment
+		id, taskID, ownerID, author string
+		createdAt, updatedAt        int64
+	)
+
+	if err := row.Scan(&id, &taskID, &ownerID, &author, &comment.Body, &createdAt, &updatedAt); err != nil {
+		return nil, err
+	}
+
+	err := parseIDs([]string{id, taskID}, &comment.ID, &comment.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.OwnerID, err = optionalID(ownerID); err != nil {
+		return nil, err
+	}
+	if comment.AuthorID, err = optionalID(author); err != nil {
+		return nil, err
+	}
+
+	comment.CreatedAt = fromMillis(createdAt)
+	comment.UpdatedAt = fromMillis(updatedAt)
+	return &comment, nil
+}
+
+// parseIDs разбирает hex-строки values в dsts по порядку
+func parseIDs(values []string, dsts ...*primitive.ObjectID) error {
+	for i, value := range values {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return err
+		}
+		*dsts[i] = id
+	}
+	return nil
+}
+
+func scanList(row rowScanner) (*entity.List, error) {
+	var (
+		list                 entity.List
+		id, ownerID          string
+		archivedAt           sql.NullInt64
+		createdAt, updatedAt int64
+	)
+
+	if err := row.Scan(&id, &list.Name, &archivedAt, &createdAt, &updatedAt, &ownerID); err != nil {
+		return nil, err
+	}
+
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, err
+	}
+	if list.OwnerID, err = optionalID(ownerID); err != nil {
+		return nil, err
+	}
+
+	list.ID = objectID
+	list.CreatedAt = fromMillis(createdAt)
+	list.UpdatedAt = fromMillis(updatedAt)
+	if archivedAt.Valid {
+		t := fromMillis(archivedAt.Int64)
+		list.ArchivedAt = &t
+	}
+	return &list, nil
+}
+
+// optionalID разбирает колонку list_id, owner_id или assignee_id: пустая строка - nil
+func optionalID(value string) (*primitive.ObjectID, error) {
+	if value == "" {
+		return nil, nil
+	}
+	id, err := primitive.ObjectIDFromHex(value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return errors.ErrNotFound
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return stderrors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+func nullableMillis(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return toMillis(*t)
+}
+
+func fromMillis(ms int64) time.Time {
+	return time.UnixMilli(ms).UTC()
+}
@@ -0,0 +1,156 @@
+package repo_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/nekidaz/todolist/config"
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/repo/repotest"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSQLiteRepositoryContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repo.TodoRepository {
+		repository, err := repo.NewSQLiteRepository(config.Config{
+			Storage:    config.StorageSQLite,
+			SQLitePath: filepath.Join(t.TempDir(), "todolist.db"),
+		})
+		if err != nil {
+			t.Fatalf("Ошибка открытия SQLite: %s", err)
+		}
+		t.Cleanup(func() { repository.Close() })
+		return repository
+	})
+}
+
+func TestSQLiteMigrationsAreIdempotent(t *testing.T) {
+	cfg := config.Config{
+		Storage:    config.StorageSQLite,
+		SQLitePath: filepath.Join(t.TempDir(), "todolist.db"),
+	}
+
+	for i := 0; i < 2; i++ {
+		repository, err := repo.NewSQLiteRepository(cfg)
+		if err != nil {
+			t.Fatalf("Ошибка открытия SQLite (попытка %d): %s", i+1, err)
+		}
+		repository.Close()
+	}
+}
+
+func TestSQLiteMigratesCompletedToStatus(t *testing.T) {
+	cfg := config.Config{
+		Storage:    config.StorageSQLite,
+		SQLitePath: filepath.Join(t.TempDir(), "todolist.db"),
+	}
+
+	// база в состоянии первой миграции, с задачами в старом формате
+	db, err := sql.Open("sqlite", cfg.SQLitePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doneID, todoID := primitive.NewObjectID(), primitive.NewObjectID()
+	_, err = db.Exec(`
+		CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY);
+		INSERT INTO schema_migrations (version) VALUES (1);
+		CREATE TABLE todos (
+			id TEXT PRIMARY KEY, title TEXT NOT NULL, completed INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL, updated_at INTEGER NOT NULL, active_at INTEGER NOT NULL,
+			UNIQUE (title, active_at)
+		);
+		CREATE INDEX todos_active_at ON todos (active_at);
+		INSERT INTO todos VALUES (?, 'Done', 1, 1000, 2000, 3000), (?, 'Open', 0, 1000, 2000, 3000);`,
+		doneID.Hex(), todoID.Hex(),
+	)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repository, err := repo.NewSQLiteRepository(cfg)
+	if err != nil {
+		t.Fatalf("Ошибка миграции SQLite: %s", err)
+	}
+	defer repository.Close()
+
+	doneTodo, err := repository.GetTaskByID(context.Background(), doneID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doneTodo.Status != entity.StatusDone || doneTodo.CompletedAt == nil || !doneTodo.CompletedAt.Equal(doneTodo.UpdatedAt) {
+		t.Errorf("completed = 1 должна стать done с completed_at = updated_at, получили %+v", doneTodo)
+	}
+
+	openTodo, err := repository.GetTaskByID(context.Background(), todoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if openTodo.Status != entity.StatusTodo || openTodo.CompletedAt != nil {
+		t.Errorf("completed = 0 должна стать todo, получили %+v", openTodo)
+	}
+}
+
+func TestSQLiteListsMigrationKeepsTasks(t *testing.T) {
+	cfg := config.Config{
+		Storage:    config.StorageSQLite,
+		SQLitePath: filepath.Join(t.TempDir(), "todolist.db"),
+	}
+
+	db, err := sql.Open("sqlite", cfg.SQLitePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := primitive.NewObjectID()
+	_, err = db.Exec(`
+		CREATE TABLE schema_migrations (version INTEGER PRIMARY KEY);
+		INSERT INTO schema_migrations (version) VALUES (1);
+		CREATE TABLE todos (
+			id TEXT PRIMARY KEY, title TEXT NOT NULL, completed INTEGER NOT NULL DEFAULT 0,
+			created_at INTEGER NOT NULL, updated_at INTEGER NOT NULL, active_at INTEGER NOT NULL,
+			UNIQUE (title, active_at)
+		);
+		CREATE INDEX todos_active_at ON todos (active_at);
+		INSERT INTO todos VALUES (?, 'Купить молоко', 0, 1000, 2000, 3000);`,
+		id.Hex(),
+	)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repository, err := repo.NewSQLiteRepository(cfg)
+	if err != nil {
+		t.Fatalf("Ошибка миграции SQLite: %s", err)
+	}
+	defer repository.Close()
+	ctx := context.Background()
+
+	// поисковый индекс пересоздан вместе с таблицей задач
+	hits, err := repository.SearchTasks(ctx, nil, "молоко", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Task.ID != id || hits[0].Task.ListID != nil {
+		t.Errorf("задача должна остаться в списке по умолчанию и находиться поиском, получили %+v", hits)
+	}
+
+	// внешние ключи todo_tags снова ссылаются на todos
+	tags := []string{"home"}
+	if _, err := repository.PatchTodo(ctx, id, &entity.TodoPatch{Tags: &tags}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.DeleteTodo(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	counts, err := repository.GetTags(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 0 {
+		t.Errorf("метки удаленной задачи должны уйти каскадом, получили %+v", counts)
+	}
+}
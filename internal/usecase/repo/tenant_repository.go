@@ -0,0 +1,677 @@
+package repo
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TenantCatalog - каталог организаций. Сам он лежит в общей базе, данные организаций - каждая в своей
+type TenantCatalog interface {
+	// CreateTenant возвращает ErrTenantExists, если организация с таким id уже есть
+	CreateTenant(ctx context.Context, tenant *entity.Tenant) (*entity.Tenant, error)
+	GetTenant(ctx context.Context, id string) (*entity.Tenant, error)
+	// GetTenants возвращает организации по id
+	GetTenants(ctx context.Context) ([]*entity.Tenant, error)
+	// DeleteTenant убирает организацию из каталога. Возвращает ErrTenantNotFound, если ее нет
+	DeleteTenant(ctx context.Context, id string) error
+}
+
+// TenantBackend - хранилище, в котором у каждой организации своя база
+type TenantBackend interface {
+	TenantCatalog
+	// Open открывает базу организации, при первом обращении создавая ее индексы
+	Open(ctx context.Context, tenantID string) (TodoRepository, error)
+	// Drop удаляет базу организации со всеми данными
+	Drop(ctx context.Context, tenantID string) error
+	Close() error
+}
+
+// TenantRepository направляет каждый вызов в базу организации из entity.TenantFromContext, поэтому данные
+// организаций не смешиваются, даже если сервис ошибется с владельцем. Без организации в контексте
+// данных нет: ErrTenantRequired. Найденные в каталоге организации и открытые базы держатся в кеше, так что
+// запрос к известной организации не ходит в каталог, а индексы каждой базы создаются один раз за запуск.
+// Кеш свой у каждого процесса: организацию удаляют на том же экземпляре, который ее обслуживает
+type TenantRepository struct {
+	backend TenantBackend
+
+	// lifecycle - создание и удаление организаций идут по одному, чтобы удаление базы не задело созданную заново
+	lifecycle sync.Mutex
+
+	// mu защищает только кеши: сетевые вызовы каталога и баз идут без него, чтобы открытие одной базы
+	// не задерживало запросы к остальным
+	mu      sync.Mutex
+	tenants map[string]*entity.Tenant
+	opened  map[string]TodoRepository
+	// deletions - сколько раз организацию удаляли за запуск. Ответ каталога и базу, полученные запросом,
+	// который начался до удаления, GetTenant и open не сохраняют
+	deletions map[string]int
+}
+
+func NewTenantRepository(backend TenantBackend) *TenantRepository {
+	return &TenantRepository{
+		backend:   backend,
+		tenants:   make(map[string]*entity.Tenant),
+		opened:    make(map[string]TodoRepository),
+		deletions: make(map[string]int),
+	}
+}
+
+// CreateTenant заносит организацию в каталог и сразу открывает ее базу, чтобы индексы были готовы до первого запроса
+func (r *TenantRepository) CreateTenant(ctx context.Context, tenant *entity.Tenant) (*entity.Tenant, error) {
+	r.lifecycle.Lock()
+	defer r.lifecycle.Unlock()
+
+	created, err := r.backend.CreateTenant(ctx, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	cached := *created
+	r.tenants[created.ID] = &cached
+	r.mu.Unlock()
+
+	// удалить организацию, пока держим lifecycle, нельзя, поэтому база сохраняется без проверок open
+	repository, err := r.backend.Open(ctx, created.ID)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	_, ok := r.opened[created.ID]
+	if !ok {
+		r.opened[created.ID] = repository
+	}
+	r.mu.Unlock()
+	if ok {
+		if err := repository.Close(); err != nil {
+			return nil, err
+		}
+	}
+	return created, nil
+}
+
+// GetTenant - организация из кеша, а если ее там нет - из каталога
+func (r *TenantRepository) GetTenant(ctx context.Context, id string) (*entity.Tenant, error) {
+	r.mu.Lock()
+	cached, ok := r.tenants[id]
+	deletions := r.deletions[id]
+	r.mu.Unlock()
+	if ok {
+		tenant := *cached
+		return &tenant, nil
+	}
+
+	tenant, err := r.backend.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	// ответ каталога, полученный до удаления организации, в кеш не попадает
+	if r.deletions[id] == deletions {
+		cached := *tenant
+		r.tenants[id] = &cached
+	}
+	r.mu.Unlock()
+	return tenant, nil
+}
+
+func (r *TenantRepository) GetTenants(ctx context.Context) ([]*entity.Tenant, error) {
+	return r.backend.GetTenants(ctx)
+}
+
+// DeleteTenant убирает организацию из каталога и кеша, закрывает и удаляет ее базу. Сначала каталог: после него
+// новые запросы организации не проходят, а запрос, который успел найти организацию раньше, сам закроет
+// открытую им базу в open
+func (r *TenantRepository) DeleteTenant(ctx context.Context, id string) error {
+	r.lifecycle.Lock()
+	defer r.lifecycle.Unlock()
+
+	if err := r.backend.DeleteTenant(ctx, id); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.deletions[id]++
+	delete(r.tenants, id)
+	repository, ok := r.opened[id]
+	delete(r.opened, id)
+	r.mu.Unlock()
+	if ok {
+		if err := repository.Close(); err != nil {
+			return err
+		}
+	}
+	return r.backend.Drop(ctx, id)
+}
+
+// tenant - база организации из контекста. Организации не из каталога не открываются:
+// иначе Mongo создала бы базу для любого id из заголовка
+func (r *TenantRepository) tenant(ctx context.Context) (TodoRepository, error) {
+	id := entity.TenantFromContext(ctx)
+	if id == "" {
+		return nil, errors.ErrTenantRequired
+	}
+
+	r.mu.Lock()
+	repository, ok := r.opened[id]
+	r.mu.Unlock()
+	if ok {
+		return repository, nil
+	}
+
+	if _, err := r.GetTenant(ctx, id); err != nil {
+		return nil, err
+	}
+	return r.open(ctx, id)
+}
+
+// open открывает базу организации и проверяет каталог без блокировки остальных: миграции и индексы новой базы
+// могут идти долго. Если базу успел открыть параллельный запрос, остается его. Пока база открывалась, организацию
+// могли удалить, и Open создал бы ее базу заново, поэтому такую базу open не сохраняет, а закрывает и удаляет
+func (r *TenantRepository) open(ctx context.Context, id string) (TodoRepository, error) {
+	r.mu.Lock()
+	deletions := r.deletions[id]
+	r.mu.Unlock()
+
+	repository, err := r.backend.Open(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.backend.GetTenant(ctx, id)
+	if err != nil && !stderrors.Is(err, errors.ErrTenantNotFound) {
+		repository.Close()
+		return nil, err
+	}
+	found := err == nil
+
+	r.mu.Lock()
+	opened, ok := r.opened[id]
+	if !ok && found && r.deletions[id] == deletions {
+		r.opened[id] = repository
+		r.mu.Unlock()
+		return repository, nil
+	}
+	r.mu.Unlock()
+
+	if err := repository.Close(); err != nil {
+		return nil, err
+	}
+	if ok {
+		return opened, nil
+	}
+	if err := r.dropOrphan(ctx, id); err != nil {
+		return nil, err
+	}
+	return nil, errors.ErrTenantNotFound
+}
+
+// dropOrphan удаляет базу, которую open создал для организации не из каталога. Под lifecycle организацию
+// не создадут заново между проверкой и удалением, а созданную заново за это время база остается
+func (r *TenantRepository) dropOrphan(ctx context.Context, id string) error {
+	r.lifecycle.Lock()
+	defer r.lifecycle.Unlock()
+
+	_, err := r.backend.GetTenant(ctx, id)
+	if err == nil {
+		return nil
+	}
+	if !stderrors.Is(err, errors.ErrTenantNotFound) {
+		return err
+	}
+
+	r.mu.Lock()
+	delete(r.tenants, id)
+	r.mu.Unlock()
+	return r.backend.Drop(ctx, id)
+}
+
+// Close закрывает все открытые базы организаций и само хранилище
+func (r *TenantRepository) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var firstErr error
+	for id, repository := range r.opened {
+		if err := repository.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(r.opened, id)
+	}
+	if err := r.backend.Close(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// остальные методы TodoRepository выполняются в базе организации из контекста
+
+func (r *TenantRepository) CreateNewTodo(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.CreateNewTodo(ctx, todo)
+}
+
+func (r *TenantRepository) UpdateTodo(ctx context.Context, id primitive.ObjectID, todo *entity.Todo) (*entity.Todo, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.UpdateTodo(ctx, id, todo)
+}
+
+func (r *TenantRepository) PatchTodo(ctx context.Context, id primitive.ObjectID, patch *entity.TodoPatch) (*entity.Todo, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.PatchTodo(ctx, id, patch)
+}
+
+func (r *TenantRepository) DeleteTodo(ctx context.Context, id primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.DeleteTodo(ctx, id)
+}
+
+func (r *TenantRepository) SetStatus(ctx context.Context, id primitive.ObjectID, from entity.TaskStatus, todo *entity.Todo) (*entity.Todo, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.SetStatus(ctx, id, from, todo)
+}
+
+func (r *TenantRepository) GetTasksByStatus(ctx context.Context, status string, opts entity.ListOptions) (*entity.TodoPage, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetTasksByStatus(ctx, status, opts)
+}
+
+func (r *TenantRepository) GetAllTasks(ctx context.Context, opts entity.ListOptions) (*entity.TodoPage, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetAllTasks(ctx, opts)
+}
+
+func (r *TenantRepository) GetTaskByID(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetTaskByID(ctx, id)
+}
+
+func (r *TenantRepository) SearchTasks(ctx context.Context, listID *primitive.ObjectID, query string, limit int) ([]*entity.SearchHit, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.SearchTasks(ctx, listID, query, limit)
+}
+
+func (r *TenantRepository) GetSubtasks(ctx context.Context, parentIDs []primitive.ObjectID) ([]*entity.Todo, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetSubtasks(ctx, parentIDs)
+}
+
+func (r *TenantRepository) SetParent(ctx context.Context, ids []primitive.ObjectID, parentID *primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.SetParent(ctx, ids, parentID)
+}
+
+func (r *TenantRepository) GetTasksByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*entity.Todo, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetTasksByIDs(ctx, ids)
+}
+
+func (r *TenantRepository) RemoveDependency(ctx context.Context, id primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.RemoveDependency(ctx, id)
+}
+
+func (r *TenantRepository) GetTags(ctx context.Context) ([]*entity.TagCount, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetTags(ctx)
+}
+
+func (r *TenantRepository) RenameTags(ctx context.Context, from []string, to string) (int, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return repository.RenameTags(ctx, from, to)
+}
+
+func (r *TenantRepository) MoveTasks(ctx context.Context, ids []primitive.ObjectID, listID *primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.MoveTasks(ctx, ids, listID)
+}
+
+func (r *TenantRepository) CreateList(ctx context.Context, list *entity.List) (*entity.List, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.CreateList(ctx, list)
+}
+
+func (r *TenantRepository) GetList(ctx context.Context, id primitive.ObjectID) (*entity.List, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetList(ctx, id)
+}
+
+func (r *TenantRepository) GetLists(ctx context.Context, includeArchived bool) ([]*entity.List, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetLists(ctx, includeArchived)
+}
+
+func (r *TenantRepository) UpdateList(ctx context.Context, list *entity.List) (*entity.List, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.UpdateList(ctx, list)
+}
+
+func (r *TenantRepository) DeleteList(ctx context.Context, id primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.DeleteList(ctx, id)
+}
+
+func (r *TenantRepository) CreateUser(ctx context.Context, user *entity.User) (*entity.User, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.CreateUser(ctx, user)
+}
+
+func (r *TenantRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*entity.User, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetUserByID(ctx, id)
+}
+
+func (r *TenantRepository) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetUserByEmail(ctx, email)
+}
+
+func (r *TenantRepository) GetUserByUsername(ctx context.Context, username string) (*entity.User, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetUserByUsername(ctx, username)
+}
+
+func (r *TenantRepository) SetUsername(ctx context.Context, id primitive.ObjectID, username string) (*entity.User, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.SetUsername(ctx, id, username)
+}
+
+func (r *TenantRepository) CountUsers(ctx context.Context) (int, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return repository.CountUsers(ctx)
+}
+
+func (r *TenantRepository) GetUserByIdentity(ctx context.Context, issuer, subject string) (*entity.User, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetUserByIdentity(ctx, issuer, subject)
+}
+
+func (r *TenantRepository) LinkIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.LinkIdentity(ctx, id, issuer, subject)
+}
+
+func (r *TenantRepository) ClaimUnowned(ctx context.Context, ownerID primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.ClaimUnowned(ctx, ownerID)
+}
+
+func (r *TenantRepository) CreateAPIKey(ctx context.Context, key *entity.APIKey) (*entity.APIKey, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.CreateAPIKey(ctx, key)
+}
+
+func (r *TenantRepository) GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetAPIKeys(ctx)
+}
+
+func (r *TenantRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetAPIKeyByHash(ctx, hash)
+}
+
+func (r *TenantRepository) DeleteAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.DeleteAPIKey(ctx, id)
+}
+
+func (r *TenantRepository) TouchAPIKey(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.TouchAPIKey(ctx, id, usedAt)
+}
+
+func (r *TenantRepository) AddMember(ctx context.Context, member *entity.Membership) (*entity.Membership, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.AddMember(ctx, member)
+}
+
+func (r *TenantRepository) GetMembership(ctx context.Context, listID, userID primitive.ObjectID) (*entity.Membership, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetMembership(ctx, listID, userID)
+}
+
+func (r *TenantRepository) GetMemberships(ctx context.Context, userID primitive.ObjectID) ([]*entity.Membership, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetMemberships(ctx, userID)
+}
+
+func (r *TenantRepository) GetMembers(ctx context.Context, listID primitive.ObjectID) ([]*entity.Membership, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetMembers(ctx, listID)
+}
+
+func (r *TenantRepository) SetMemberRole(ctx context.Context, listID, userID primitive.ObjectID, role entity.Role) (*entity.Membership, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.SetMemberRole(ctx, listID, userID, role)
+}
+
+func (r *TenantRepository) RemoveMember(ctx context.Context, listID, userID primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.RemoveMember(ctx, listID, userID)
+}
+
+func (r *TenantRepository) RemoveMembers(ctx context.Context, listID primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.RemoveMembers(ctx, listID)
+}
+
+func (r *TenantRepository) CreateInvitation(ctx context.Context, invitation *entity.Invitation) (*entity.Invitation, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.CreateInvitation(ctx, invitation)
+}
+
+func (r *TenantRepository) TakeInvitation(ctx context.Context, hash string) (*entity.Invitation, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.TakeInvitation(ctx, hash)
+}
+
+func (r *TenantRepository) CreateNotifications(ctx context.Context, notifications []*entity.Notification) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.CreateNotifications(ctx, notifications)
+}
+
+func (r *TenantRepository) GetNotifications(ctx context.Context, unreadOnly bool, limit int) ([]*entity.Notification, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetNotifications(ctx, unreadOnly, limit)
+}
+
+func (r *TenantRepository) SetNotificationRead(ctx context.Context, id primitive.ObjectID, readAt *time.Time) (*entity.Notification, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.SetNotificationRead(ctx, id, readAt)
+}
+
+func (r *TenantRepository) CreateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.CreateComment(ctx, comment)
+}
+
+func (r *TenantRepository) GetComment(ctx context.Context, id primitive.ObjectID) (*entity.Comment, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetComment(ctx, id)
+}
+
+func (r *TenantRepository) GetComments(ctx context.Context, taskID primitive.ObjectID, after *primitive.ObjectID, limit int) ([]*entity.Comment, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.GetComments(ctx, taskID, after, limit)
+}
+
+func (r *TenantRepository) UpdateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.UpdateComment(ctx, comment)
+}
+
+func (r *TenantRepository) DeleteComment(ctx context.Context, id primitive.ObjectID) error {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return err
+	}
+	return repository.DeleteComment(ctx, id)
+}
+
+func (r *TenantRepository) CountComments(ctx context.Context, taskIDs []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	repository, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return repository.CountComments(ctx, taskIDs)
+}
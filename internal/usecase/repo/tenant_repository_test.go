@@ -0,0 +1,201 @@
+package repo_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantRepositoryIsolation(t *testing.T) {
+	repository := repo.NewTenantRepository(repo.NewMemoryTenants())
+	defer repository.Close()
+
+	for _, id := range []string{"acme", "globex"} {
+		_, err := repository.CreateTenant(context.Background(), &entity.Tenant{ID: id, Name: id, CreatedAt: time.Now()})
+		require.NoError(t, err)
+	}
+	_, err := repository.CreateTenant(context.Background(), &entity.Tenant{ID: "acme", Name: "acme"})
+	assert.ErrorIs(t, err, errors.ErrTenantExists)
+
+	tenants, err := repository.GetTenants(context.Background())
+	require.NoError(t, err)
+	require.Len(t, tenants, 2)
+	assert.Equal(t, "acme", tenants[0].ID)
+
+	acme := entity.WithTenant(context.Background(), "acme")
+	globex := entity.WithTenant(context.Background(), "globex")
+	todo, err := repository.CreateNewTodo(acme, entity.NewTodo("Отчет", time.Now()))
+	require.NoError(t, err)
+
+	// задачи и пользователи одной организации другой не видны, даже по id
+	_, err = repository.GetTaskByID(globex, todo.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	user, err := repository.CreateUser(acme, &entity.User{Email: "alice@example.com"})
+	require.NoError(t, err)
+	_, err = repository.GetUserByID(globex, user.ID)
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	_, err = repository.CreateUser(globex, &entity.User{Email: "alice@example.com"})
+	require.NoError(t, err)
+
+	// без организации в контексте и с организацией не из каталога данных нет
+	_, err = repository.GetTaskByID(context.Background(), todo.ID)
+	assert.ErrorIs(t, err, errors.ErrTenantRequired)
+	_, err = repository.CountUsers(entity.WithTenant(context.Background(), "initech"))
+	assert.ErrorIs(t, err, errors.ErrTenantNotFound)
+
+	// удаленная организация уносит свои данные: созданная заново с тем же id начинает с пустой базы
+	require.NoError(t, repository.DeleteTenant(context.Background(), "acme"))
+	assert.ErrorIs(t, repository.DeleteTenant(context.Background(), "acme"), errors.ErrTenantNotFound)
+	_, err = repository.GetTaskByID(acme, todo.ID)
+	assert.ErrorIs(t, err, errors.ErrTenantNotFound)
+
+	_, err = repository.CreateTenant(context.Background(), &entity.Tenant{ID: "acme", Name: "acme"})
+	require.NoError(t, err)
+	_, err = repository.GetTaskByID(acme, todo.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	count, err := repository.CountUsers(globex)
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
+
+// slowBackend - TenantBackend, который помнит, какие базы сейчас существуют. С opening Open ждет release,
+// с checking так же ждет проверка в каталоге организации, база которой уже открыта
+type slowBackend struct {
+	repo.TenantBackend
+	opening  chan struct{}
+	checking chan struct{}
+	release  chan struct{}
+
+	mu    sync.Mutex
+	bases map[string]bool
+}
+
+func (b *slowBackend) Open(ctx context.Context, tenantID string) (repo.TodoRepository, error) {
+	if b.opening != nil {
+		b.opening <- struct{}{}
+		<-b.release
+	}
+	b.mu.Lock()
+	b.bases[tenantID] = true
+	b.mu.Unlock()
+	return b.TenantBackend.Open(ctx, tenantID)
+}
+
+func (b *slowBackend) GetTenant(ctx context.Context, id string) (*entity.Tenant, error) {
+	b.mu.Lock()
+	opened := b.bases[id]
+	b.mu.Unlock()
+	if opened && b.checking != nil {
+		b.checking <- struct{}{}
+		<-b.release
+	}
+	return b.TenantBackend.GetTenant(ctx, id)
+}
+
+func (b *slowBackend) Drop(ctx context.Context, tenantID string) error {
+	b.mu.Lock()
+	delete(b.bases, tenantID)
+	b.mu.Unlock()
+	return b.TenantBackend.Drop(ctx, tenantID)
+}
+
+func TestTenantDeletedWhileOpening(t *testing.T) {
+	backend := &slowBackend{
+		TenantBackend: repo.NewMemoryTenants(),
+		opening:       make(chan struct{}),
+		release:       make(chan struct{}),
+		bases:         make(map[string]bool),
+	}
+	repository := repo.NewTenantRepository(backend)
+	defer repository.Close()
+
+	// организация есть в каталоге, но ее база в этом процессе еще не открыта
+	_, err := backend.CreateTenant(context.Background(), &entity.Tenant{ID: "acme", Name: "acme"})
+	require.NoError(t, err)
+	acme := entity.WithTenant(context.Background(), "acme")
+
+	// запрос нашел организацию в каталоге и открывает базу, а ее в это время удаляют
+	done := make(chan error)
+	go func() {
+		_, err := repository.CountUsers(acme)
+		done <- err
+	}()
+	<-backend.opening
+	require.NoError(t, repository.DeleteTenant(context.Background(), "acme"))
+	close(backend.release)
+
+	assert.ErrorIs(t, <-done, errors.ErrTenantNotFound)
+	assert.Empty(t, backend.bases, "база удаленной организации не должна остаться")
+	_, err = repository.CountUsers(acme)
+	assert.ErrorIs(t, err, errors.ErrTenantNotFound)
+}
+
+func TestTenantOpenDoesNotBlockOthers(t *testing.T) {
+	backend := &slowBackend{
+		TenantBackend: repo.NewMemoryTenants(),
+		checking:      make(chan struct{}),
+		release:       make(chan struct{}),
+		bases:         make(map[string]bool),
+	}
+	repository := repo.NewTenantRepository(backend)
+	defer repository.Close()
+
+	_, err := repository.CreateTenant(context.Background(), &entity.Tenant{ID: "globex", Name: "globex"})
+	require.NoError(t, err)
+	_, err = backend.CreateTenant(context.Background(), &entity.Tenant{ID: "acme", Name: "acme"})
+	require.NoError(t, err)
+
+	// база acme открыта, а каталог отвечает долго
+	done := make(chan error)
+	go func() {
+		_, err := repository.CountUsers(entity.WithTenant(context.Background(), "acme"))
+		done <- err
+	}()
+	<-backend.checking
+
+	// запросы к уже открытой базе globex этого не ждут
+	counted := make(chan error)
+	go func() {
+		_, err := repository.CountUsers(entity.WithTenant(context.Background(), "globex"))
+		counted <- err
+	}()
+	select {
+	case err := <-counted:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Error("запрос к globex ждет, пока откроется база acme")
+		defer func() { <-counted }()
+	}
+
+	close(backend.release)
+	assert.NoError(t, <-done)
+}
+
+func TestTenantCache(t *testing.T) {
+	backend := repo.NewMemoryTenants()
+	repository := repo.NewTenantRepository(backend)
+	defer repository.Close()
+
+	_, err := repository.CreateTenant(context.Background(), &entity.Tenant{ID: "acme", Name: "ACME Corp"})
+	require.NoError(t, err)
+
+	// организация уже в кеше: ее находит и тот, кто не дошел бы до каталога
+	require.NoError(t, backend.DeleteTenant(context.Background(), "acme"))
+	tenant, err := repository.GetTenant(context.Background(), "acme")
+	require.NoError(t, err)
+	assert.Equal(t, "ACME Corp", tenant.Name)
+
+	// удаление через хранилище чистит кеш
+	_, err = backend.CreateTenant(context.Background(), &entity.Tenant{ID: "acme", Name: "ACME Corp"})
+	require.NoError(t, err)
+	require.NoError(t, repository.DeleteTenant(context.Background(), "acme"))
+	_, err = repository.GetTenant(context.Background(), "acme")
+	assert.ErrorIs(t, err, errors.ErrTenantNotFound)
+}
@@ -2,35 +2,207 @@ package repo
 
 import (
 	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/nekidaz/todolist/config"
 	"github.com/nekidaz/todolist/internal/entity"
 	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/textsearch"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// TodoRepository видит только задачи и списки владельца из entity.OwnerFromContext,
+// без владельца в контексте - только данные без владельца
 type TodoRepository interface {
+	ListRepository
+	UserRepository
+	APIKeyRepository
+	MembershipRepository
+	NotificationRepository
+	CommentRepository
 	CreateNewTodo(ctx context.Context, todo *entity.Todo) (*entity.Todo, error)
 	UpdateTodo(ctx context.Context, id primitive.ObjectID, todo *entity.Todo) (*entity.Todo, error)
+	// PatchTodo меняет только поля, указанные в патче, остальные поля документа не трогает
+	PatchTodo(ctx context.Context, id primitive.ObjectID, patch *entity.TodoPatch) (*entity.Todo, error)
+	// DeleteTodo удаляет задачу вместе с ее комментариями
 	DeleteTodo(ctx context.Context, id primitive.ObjectID) error
-	MarkAsCompleted(ctx context.Context, id primitive.ObjectID) error
-	GetTasksByStatus(ctx context.Context, status string) ([]*entity.Todo, error)
-	GetAllTasks(ctx context.Context) ([]*entity.Todo, error)
+	// SetStatus сохраняет status, completed_at и updated_at задачи, только если ее статус в базе все еще from.
+	// Иначе задачу успели поменять между чтением и записью, и возвращается ErrStatusConflict
+	SetStatus(ctx context.Context, id primitive.ObjectID, from entity.TaskStatus, todo *entity.Todo) (*entity.Todo, error)
+	// GetTasksByStatus принимает один из entity.TaskStatus или "active" - незавершенные задачи с наступившим activeAt
+	GetTasksByStatus(ctx context.Context, status string, opts entity.ListOptions) (*entity.TodoPage, error)
+	GetAllTasks(ctx context.Context, opts entity.ListOptions) (*entity.TodoPage, error)
 	GetTaskByID(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error)
+	// SearchTasks - полнотекстовый поиск по заголовку в списке listID, результаты по убыванию релевантности
+	SearchTasks(ctx context.Context, listID *primitive.ObjectID, query string, limit int) ([]*entity.SearchHit, error)
+	// GetSubtasks возвращает прямые подзадачи всех задач parentIDs
+	GetSubtasks(ctx context.Context, parentIDs []primitive.ObjectID) ([]*entity.Todo, error)
+	// SetParent переносит задачи ids под parentID, nil делает их задачами верхнего уровня
+	SetParent(ctx context.Context, ids []primitive.ObjectID, parentID *primitive.ObjectID) error
+	// GetTasksByIDs возвращает найденные задачи из ids, отсутствующие пропускает
+	GetTasksByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*entity.Todo, error)
+	// RemoveDependency убирает id из depends_on всех задач
+	RemoveDependency(ctx context.Context, id primitive.ObjectID) error
+	// GetTags возвращает все метки с числом задач, по убыванию числа задач, затем по имени
+	GetTags(ctx context.Context) ([]*entity.TagCount, error)
+	// RenameTags заменяет метки from на to во всех задачах, метки сливаются, если to уже есть.
+	// Возвращает число измененных задач
+	RenameTags(ctx context.Context, from []string, to string) (int, error)
+	// MoveTasks переносит задачи ids в список listID, nil - в список по умолчанию.
+	// Если в списке уже есть задача с тем же title и activeAt, ничего не переносится и возвращается ErrTodoExists
+	MoveTasks(ctx context.Context, ids []primitive.ObjectID, listID *primitive.ObjectID) error
 	Close() error
 }
 
+// ListRepository хранит списки задач. Список по умолчанию не хранится
+type ListRepository interface {
+	// CreateList возвращает ErrListExists, если список с таким именем уже есть
+	CreateList(ctx context.Context, list *entity.List) (*entity.List, error)
+	GetList(ctx context.Context, id primitive.ObjectID) (*entity.List, error)
+	// GetLists возвращает списки по имени, архивные - только с includeArchived
+	GetLists(ctx context.Context, includeArchived bool) ([]*entity.List, error)
+	// UpdateList сохраняет name, archived_at и updated_at списка
+	UpdateList(ctx context.Context, list *entity.List) (*entity.List, error)
+	// DeleteList удаляет только сам список, что делать с его задачами, решает сервис
+	DeleteList(ctx context.Context, id primitive.ObjectID) error
+}
+
+// UserRepository хранит учетные записи. Пользователи от владельца в контексте не зависят
+type UserRepository interface {
+	// CreateUser возвращает ErrUserExists, если email уже занят
+	CreateUser(ctx context.Context, user *entity.User) (*entity.User, error)
+	GetUserByID(ctx context.Context, id primitive.ObjectID) (*entity.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*entity.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*entity.User, error)
+	// SetUsername меняет имя пользователя. Возвращает ErrUsernameExists, если имя занято другим
+	SetUsername(ctx context.Context, id primitive.ObjectID, username string) (*entity.User, error)
+	CountUsers(ctx context.Context) (int, error)
+	// GetUserByIdentity ищет пользователя по учетной записи провайдера OIDC
+	GetUserByIdentity(ctx context.Context, issuer, subject string) (*entity.User, error)
+	// LinkIdentity привязывает учетную запись провайдера к пользователю. Возвращает ErrUserExists,
+	// если она уже привязана к другому
+	LinkIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error
+	// ClaimUnowned передает ownerID все задачи и списки без владельца
+	ClaimUnowned(ctx context.Context, ownerID primitive.ObjectID) error
+}
+
+// APIKeyRepository хранит ключи API. Списки и удаление - в пределах владельца из контекста
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *entity.APIKey) (*entity.APIKey, error)
+	// GetAPIKeys возвращает ключи владельца по дате создания
+	GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error)
+	// GetAPIKeyByHash ищет ключ среди всех владельцев: по ключу запрос и узнает своего владельца
+	GetAPIKeyByHash(ctx context.Context, hash string) (*entity.APIKey, error)
+	// DeleteAPIKey возвращает ErrAPIKeyNotFound, если у владельца нет такого ключа
+	DeleteAPIKey(ctx context.Context, id primitive.ObjectID) error
+	// TouchAPIKey сохраняет last_used_at ключа
+	TouchAPIKey(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error
+}
+
+// MembershipRepository хранит участников чужих списков и приглашения в списки. Участники списка и приглашения
+// в него - в пределах владельца списка из контекста, поиск по пользователю и токену - среди всех владельцев
+type MembershipRepository interface {
+	// AddMember возвращает ErrMemberExists, если пользователь уже участвует в списке
+	AddMember(ctx context.Context, member *entity.Membership) (*entity.Membership, error)
+	// GetMembership ищет участие userID в списке listID: по нему запрос и получает доступ к чужому списку
+	GetMembership(ctx context.Context, listID, userID primitive.ObjectID) (*entity.Membership, error)
+	// GetMemberships возвращает все участия userID в чужих списках
+	GetMemberships(ctx context.Context, userID primitive.ObjectID) ([]*entity.Membership, error)
+	// GetMembers возвращает участников списка по дате добавления
+	GetMembers(ctx context.Context, listID primitive.ObjectID) ([]*entity.Membership, error)
+	// SetMemberRole возвращает ErrMemberNotFound, если userID не участвует в списке
+	SetMemberRole(ctx context.Context, listID, userID primitive.ObjectID, role entity.Role) (*entity.Membership, error)
+	// RemoveMember возвращает ErrMemberNotFound, если userID не участвует в списке
+	RemoveMember(ctx context.Context, listID, userID primitive.ObjectID) error
+	// RemoveMembers удаляет всех участников и приглашения списка
+	RemoveMembers(ctx context.Context, listID primitive.ObjectID) error
+	CreateInvitation(ctx context.Context, invitation *entity.Invitation) (*entity.Invitation, error)
+	// TakeInvitation находит приглашение по хешу токена и удаляет его одной операцией, чтобы одним токеном
+	// нельзя было войти дважды. Срок действия проверяет сервис
+	TakeInvitation(ctx context.Context, hash string) (*entity.Invitation, error)
+}
+
+// NotificationRepository хранит входящие пользователей. Уведомления создаются для любых получателей,
+// читаются и отмечаются - в пределах владельца из контекста
+type NotificationRepository interface {
+	CreateNotifications(ctx context.Context, notifications []*entity.Notification) error
+	// GetNotifications возвращает до limit уведомлений владельца, новые первыми. С unreadOnly - только непрочитанные
+	GetNotifications(ctx context.Context, unreadOnly bool, limit int) ([]*entity.Notification, error)
+	// SetNotificationRead сохраняет read_at, nil - уведомление снова непрочитанное.
+	// Возвращает ErrNotificationNotFound, если у владельца нет такого уведомления
+	SetNotificationRead(ctx context.Context, id primitive.ObjectID, readAt *time.Time) (*entity.Notification, error)
+}
+
+// CommentRepository хранит комментарии к задачам в пределах владельца задачи из контекста
+type CommentRepository interface {
+	CreateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error)
+	// GetComment возвращает ErrCommentNotFound, если у владельца нет такого комментария
+	GetComment(ctx context.Context, id primitive.ObjectID) (*entity.Comment, error)
+	// GetComments возвращает до limit комментариев задачи taskID по порядку написания, после комментария after, если он задан
+	GetComments(ctx context.Context, taskID primitive.ObjectID, after *primitive.ObjectID, limit int) ([]*entity.Comment, error)
+	// UpdateComment сохраняет body и updated_at. Возвращает ErrCommentNotFound, если у владельца нет такого комментария
+	UpdateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error)
+	// DeleteComment возвращает ErrCommentNotFound, если у владельца нет такого комментария
+	DeleteComment(ctx context.Context, id primitive.ObjectID) error
+	// CountComments - число комментариев каждой задачи из taskIDs. Задач без комментариев в ответе нет
+	CountComments(ctx context.Context, taskIDs []primitive.ObjectID) (map[primitive.ObjectID]int, error)
+}
+
+// todoDocument - задача в том виде, в котором она лежит в коллекции.
+// language нужен text index: по нему Mongo выбирает стеммер для документа
+type todoDocument struct {
+	entity.Todo `bson:",inline"`
+	Language    string `bson:"language"`
+}
+
+func newTodoDocument(todo *entity.Todo) todoDocument {
+	return todoDocument{Todo: *todo, Language: textsearch.DetectLanguage(todo.Title)}
+}
+
 type repository struct {
+	// client - nil у баз организаций: подключение у них общее, его закрывает TenantBackend
 	client     *mongo.Client
 	database   *mongo.Database
 	collection *mongo.Collection
+	// lists - коллекция списков рядом с коллекцией задач: <collection>_lists
+	lists *mongo.Collection
+	// users - коллекция пользователей: <collection>_users
+	users *mongo.Collection
+	// apiKeys - коллекция ключей API: <collection>_api_keys
+	apiKeys *mongo.Collection
+	// members - коллекция участников списков: <collection>_members
+	members *mongo.Collection
+	// invitations - коллекция приглашений в списки: <collection>_invitations
+	invitations *mongo.Collection
+	// notifications - коллекция входящих пользователей: <collection>_notifications
+	notifications *mongo.Collection
+	// comments - коллекция комментариев к задачам: <collection>_comments
+	comments *mongo.Collection
+	// transactions - развертывание поддерживает транзакции: это набор реплик или шардированный кластер
+	transactions bool
 }
 
 func NewRepository(config config.Config) (TodoRepository, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := openRepository(context.Background(), client.Database(config.DBName), config.CollectionName)
+	if err != nil {
+		return nil, err
+	}
+	r.client = client
+	return r, nil
+}
+
+func connect(config config.Config) (*mongo.Client, error) {
 	// Подключение к MongoDB
 	clientOptions := options.Client().ApplyURI(config.DBConnectionString)
 	client, err := mongo.Connect(context.Background(), clientOptions)
@@ -43,43 +215,400 @@ func NewRepository(config config.Config) (TodoRepository, error) {
 	if err != nil {
 		return nil, err
 	}
+	return client, nil
+}
+
+// openRepository готовит коллекции в database: переносит старые документы и создает индексы.
+// Подключение не закрывает, это делает владелец client
+func openRepository(ctx context.Context, database *mongo.Database, collectionName string) (*repository, error) {
+	r := &repository{
+		database:      database,
+		collection:    database.Collection(collectionName),
+		lists:         database.Collection(collectionName + "_lists"),
+		users:         database.Collection(collectionName + "_users"),
+		apiKeys:       database.Collection(collectionName + "_api_keys"),
+		members:       database.Collection(collectionName + "_members"),
+		invitations:   database.Collection(collectionName + "_invitations"),
+		notifications: database.Collection(collectionName + "_notifications"),
+		comments:      database.Collection(collectionName + "_comments"),
+	}
+
+	if err := r.migrateCompletedToStatus(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := r.migratePriority(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := r.ensureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	transactions, err := supportsTransactions(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+	r.transactions = transactions
+
+	return r, nil
+}
+
+// supportsTransactions - транзакции есть у набора реплик и у mongos, у одиночного сервера их нет
+func supportsTransactions(ctx context.Context, database *mongo.Database) (bool, error) {
+	var reply struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	if err := database.RunCommand(ctx, bson.D{{Key: "isMaster", Value: 1}}).Decode(&reply); err != nil {
+		return false, err
+	}
+	return reply.SetName != "" || reply.Msg == "isdbgrid", nil
+}
+
+// inTransaction выполняет fn в транзакции, если развертывание их поддерживает. На одиночном сервере fn выполняется
+// без нее, и запись в несколько документов атомарна только для каждого документа в отдельности
+func (r *repository) inTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !r.transactions {
+		return fn(ctx)
+	}
+
+	session, err := r.database.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+// TenantDatabase - имя базы организации tenantID рядом с общей базой dbName
+func TenantDatabase(dbName, tenantID string) string {
+	return dbName + "_" + tenantID
+}
+
+// tenantDatabases - организации в MongoDB на одном подключении: каталог - коллекция <collection>_tenants
+// в общей базе, данные организации - в базе TenantDatabase с теми же коллекциями, что у NewRepository
+type tenantDatabases struct {
+	client         *mongo.Client
+	dbName         string
+	collectionName string
+	tenants        *mongo.Collection
+}
 
-	database := client.Database(config.DBName)
-	collection := database.Collection(config.CollectionName)
+// NewTenantDatabases - TenantBackend, который отводит каждой организации свою базу MongoDB
+func NewTenantDatabases(config config.Config) (TenantBackend, error) {
+	client, err := connect(config)
+	if err != nil {
+		return nil, err
+	}
 
-	return &repository{
-		client:     client,
-		database:   database,
-		collection: collection,
+	return &tenantDatabases{
+		client:         client,
+		dbName:         config.DBName,
+		collectionName: config.CollectionName,
+		tenants:        client.Database(config.DBName).Collection(config.CollectionName + "_tenants"),
 	}, nil
 }
 
-func (r *repository) CreateNewTodo(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
-	if err := todo.Validate(); err != nil {
+func (t *tenantDatabases) CreateTenant(ctx context.Context, tenant *entity.Tenant) (*entity.Tenant, error) {
+	if _, err := t.tenants.InsertOne(ctx, tenant); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.ErrTenantExists
+		}
 		return nil, err
 	}
+	return t.GetTenant(ctx, tenant.ID)
+}
 
-	// Проверка уникальности записи по полям title и activeAt
-	filter := bson.D{
-		{"title", todo.Title},
-		{"active_at", todo.ActiveAt},
+func (t *tenantDatabases) GetTenant(ctx context.Context, id string) (*entity.Tenant, error) {
+	var tenant entity.Tenant
+	err := t.tenants.FindOne(ctx, bson.M{"_id": id}).Decode(&tenant)
+	if stderrors.Is(err, mongo.ErrNoDocuments) {
+		return nil, errors.ErrTenantNotFound
+	}
+	if err != nil {
+		return nil, err
 	}
+	return &tenant, nil
+}
 
-	count, err := r.collection.CountDocuments(ctx, filter)
+func (t *tenantDatabases) GetTenants(ctx context.Context) ([]*entity.Tenant, error) {
+	cursor, err := t.tenants.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
+
+	tenants := make([]*entity.Tenant, 0)
+	if err := cursor.All(ctx, &tenants); err != nil {
+		return nil, err
+	}
+	return tenants, nil
+}
+
+func (t *tenantDatabases) DeleteTenant(ctx context.Context, id string) error {
+	result, err := t.tenants.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.ErrTenantNotFound
+	}
+	return nil
+}
+
+func (t *tenantDatabases) Open(ctx context.Context, tenantID string) (TodoRepository, error) {
+	return openRepository(ctx, t.client.Database(TenantDatabase(t.dbName, tenantID)), t.collectionName)
+}
+
+func (t *tenantDatabases) Drop(ctx context.Context, tenantID string) error {
+	return t.client.Database(TenantDatabase(t.dbName, tenantID)).Drop(ctx)
+}
+
+func (t *tenantDatabases) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return t.client.Disconnect(ctx)
+}
+
+// ensureIndexes создает индексы, если их еще нет
+func (r *repository) ensureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		// дубликаты по title и activeAt внутри списка пользователя отсекает сама база, без гонки между проверкой и записью.
+		// У задач списка по умолчанию list_id нет, индекс считает его null, поэтому они тоже уникальны между собой.
+		// Так же и с owner_id у задач без владельца
+		Keys: bson.D{
+			{Key: "owner_id", Value: 1}, {Key: "list_id", Value: 1}, {Key: "title", Value: 1}, {Key: "active_at", Value: 1},
+		},
+		Options: options.Index().SetName("owner_list_title_active_at_unique").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("уникальный индекс (owner_id, list_id, title, active_at), в коллекции есть дубликаты?: %w", err)
+	}
+
+	// старые индексы без владельца запрещали одинаковые задачи у разных пользователей. Удаляются после создания
+	// нового, чтобы уникальность не пропадала ни на минуту
+	for _, name := range []string{"title_active_at_unique", "list_title_active_at_unique", "list_id_active_at_id"} {
+		if err := dropIndex(ctx, r.collection, name); err != nil {
+			return err
+		}
+	}
+
+	_, err = r.lists.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetName("owner_name_unique").SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	if err := dropIndex(ctx, r.lists, "name_unique"); err != nil {
+		return err
+	}
+
+	_, err = r.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetName("email_unique").SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "oidc_issuer", Value: 1}, {Key: "oidc_subject", Value: 1}},
+		// у локальных пользователей полей нет, в индекс они не попадают
+		Options: options.Index().SetName("oidc_identity_unique").SetUnique(true).
+			SetPartialFilterExpression(bson.M{"oidc_subject": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.users.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "username", Value: 1}},
+		// имя необязательное, пользователи без него в индекс не попадают
+		Options: options.Index().SetName("username_unique").SetUnique(true).
+			SetPartialFilterExpression(bson.M{"username": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.apiKeys.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetName("hash_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("owner_created_at"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.members.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "list_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("list_user_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("user_id"),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.invitations.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "hash", Value: 1}},
+			Options: options.Index().SetName("hash_unique").SetUnique(true),
+		},
+		{
+			// истекшие приглашения Mongo удаляет сама
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("expires_at_ttl").SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = r.notifications.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}},
+		Options: options.Index().SetName("owner_created_at"),
+	})
+	if err != nil {
+		return err
+	}
+
+	// страницы комментариев задачи по _id и их подсчет для списков задач
+	_, err = r.comments.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "owner_id", Value: 1}, {Key: "task_id", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetName("owner_task_id"),
+	})
+	if err != nil {
+		return err
+	}
+
+	// text index в коллекции может быть только один: старый, по одному заголовку, удаляется до создания нового
+	if err := dropIndex(ctx, r.collection, "title_text"); err != nil {
+		return err
+	}
+
+	_, err = r.collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// страницы списков: сортировка по (поле, _id)
+			Keys:    bson.D{{Key: "active_at", Value: 1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("active_at_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "active_at", Value: 1}},
+			Options: options.Index().SetName("status_active_at"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "owner_id", Value: 1}, {Key: "list_id", Value: 1}, {Key: "active_at", Value: 1}, {Key: "_id", Value: 1},
+			},
+			Options: options.Index().SetName("owner_list_active_at_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "parent_id", Value: 1}},
+			Options: options.Index().SetName("parent_id"),
+		},
+		{
+			Keys:    bson.D{{Key: "depends_on", Value: 1}},
+			Options: options.Index().SetName("depends_on"),
+		},
+		{
+			Keys:    bson.D{{Key: "tags", Value: 1}},
+			Options: options.Index().SetName("tags"),
+		},
+		{
+			// язык документа берется из поля language, у старых документов без него - русский
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().
+				SetName("title_description_text").
+				SetWeights(bson.M{"title": titleWeight, "description": descriptionWeight}).
+				SetDefaultLanguage(textsearch.LanguageRussian).
+				SetLanguageOverride("language"),
+		},
+	})
+	return err
+}
+
+// dropIndex удаляет индекс name, если он есть
+func dropIndex(ctx context.Context, collection *mongo.Collection, name string) error {
+	_, err := collection.Indexes().DropOne(ctx, name)
+	var commandErr mongo.CommandError
+	// 26 - коллекции еще нет, 27 - индекса нет
+	if stderrors.As(err, &commandErr) && (commandErr.Code == 26 || commandErr.Code == 27) {
+		return nil
+	}
+	return err
+}
+
+// migrateCompletedToStatus переводит документы со старым полем completed на status:
+// completed: true становится done с completed_at = updated_at, остальные - todo
+func (r *repository) migrateCompletedToStatus(ctx context.Context) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$exists": false}, "completed": true},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: entity.StatusDone},
+				{Key: "completed_at", Value: "$updated_at"},
+			}}},
+			{{Key: "$unset", Value: "completed"}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.collection.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$exists": false}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.D{{Key: "status", Value: entity.StatusTodo}}}},
+			{{Key: "$unset", Value: "completed"}},
+		},
+	)
+	return err
+}
 
-	if count > 0 {
-		return nil, errors.ErrTodoExists
+// migratePriority проставляет normal документам, созданным до появления приоритетов
+func (r *repository) migratePriority(ctx context.Context) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"priority": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"priority": entity.PriorityNormal}},
+	)
+	return err
+}
+
+func (r *repository) CreateNewTodo(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
+	if err := todo.Validate(); err != nil {
+		return nil, err
 	}
 
+	if todo.Status == "" {
+		todo.Status = entity.StatusTodo
+	}
+	if todo.Priority == "" {
+		todo.Priority = entity.PriorityNormal
+	}
+	todo.OwnerID = entity.OwnerFromContext(ctx)
 	todo.CreatedAt = time.Now()
 	todo.UpdatedAt = time.Now()
 
-	result, err := r.collection.InsertOne(ctx, todo)
+	// Уникальность title и activeAt в списке обеспечивает уникальный индекс, проверка и вставка - одна операция
+	result, err := r.collection.InsertOne(ctx, newTodoDocument(todo))
 	if err != nil {
-		return nil, err
+		return nil, translateWriteError(err)
 	}
 
 	insertedID, ok := result.InsertedID.(primitive.ObjectID)
@@ -102,35 +631,129 @@ func (r *repository) UpdateTodo(ctx context.Context, id primitive.ObjectID, todo
 		return nil, err
 	}
 
-	// Проверка уникальности записи по полям title и activeAt (за исключением текущей задачи)
-	filter := bson.D{
-		{"title", todo.Title},
-		{"active_at", todo.ActiveAt},
-		{"_id", bson.D{{"$ne", id}}},
+	// статус меняется только переходами, а родитель - только при создании, список - только переносом, PUT их не трогает
+	todo.ID = id
+	todo.OwnerID = existingTodo.OwnerID
+	todo.ListID = existingTodo.ListID
+	todo.ParentID = existingTodo.ParentID
+	todo.Status = existingTodo.Status
+	todo.CompletedAt = existingTodo.CompletedAt
+	todo.CreatedAt = existingTodo.CreatedAt
+	todo.UpdatedAt = time.Now()
+
+	update := bson.M{
+		"$set": newTodoDocument(todo),
+	}
+	// пустые assignee_id, depends_on, tags и rrule в $set не попадают, а PUT заменяет их целиком
+	unset := bson.M{}
+	if todo.AssigneeID == nil {
+		unset["assignee_id"] = ""
+	}
+	if len(todo.DependsOn) == 0 {
+		unset["depends_on"] = ""
+	}
+	if len(todo.Tags) == 0 {
+		unset["tags"] = ""
+	}
+	if todo.RRule == "" {
+		unset["rrule"] = ""
+		unset["series_start"] = ""
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	_, err = r.collection.UpdateOne(ctx, scoped(ctx, bson.M{"_id": id}), update)
+	if err != nil {
+		return nil, translateWriteError(err)
 	}
-	count, err := r.collection.CountDocuments(ctx, filter)
+
+	return todo, nil
+}
+
+func (r *repository) PatchTodo(ctx context.Context, id primitive.ObjectID, patch *entity.TodoPatch) (*entity.Todo, error) {
+	existingTodo, err := r.GetTaskByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 
-	if count > 0 {
-		return nil, errors.ErrNotFound
+	patched := *existingTodo
+	if err := patch.Apply(&patched); err != nil {
+		return nil, err
 	}
 
-	todo.ID = id
-	todo.CreatedAt = existingTodo.CreatedAt
-	todo.UpdatedAt = time.Now()
-
-	update := bson.M{
-		"$set": todo,
+	set := bson.M{"updated_at": patched.UpdatedAt}
+	if patch.Title != nil {
+		set["title"] = patched.Title
+		set["language"] = textsearch.DetectLanguage(patched.Title)
+	}
+	if patch.Description != nil {
+		set["description"] = patched.Description
+	}
+	if patch.Priority != nil {
+		set["priority"] = patched.Priority
+	}
+	if patch.ActiveAt != nil {
+		set["active_at"] = patched.ActiveAt
+	}
+	unset := bson.M{}
+	if patch.AssigneeID != nil {
+		if patched.AssigneeID != nil {
+			set["assignee_id"] = patched.AssigneeID
+		} else {
+			unset["assignee_id"] = ""
+		}
+	}
+	if patch.DependsOn != nil {
+		if len(patched.DependsOn) > 0 {
+			set["depends_on"] = patched.DependsOn
+		} else {
+			unset["depends_on"] = ""
+		}
+	}
+	if patch.Tags != nil {
+		if len(patched.Tags) > 0 {
+			set["tags"] = patched.Tags
+		} else {
+			unset["tags"] = ""
+		}
+	}
+	if patch.RRule != nil {
+		if patched.RRule != "" {
+			set["rrule"] = patched.RRule
+			set["series_start"] = patched.SeriesStart
+		} else {
+			unset["rrule"] = ""
+			unset["series_start"] = ""
+		}
+	}
+	update := bson.M{"$set": set}
+	filter := scoped(ctx, bson.M{"_id": id})
+	if patch.Status != nil {
+		set["status"] = patched.Status
+		if patched.CompletedAt != nil {
+			set["completed_at"] = patched.CompletedAt
+		} else {
+			unset["completed_at"] = ""
+		}
+		// переход проверен для статуса, который мы прочитали
+		filter["status"] = existingTodo.Status
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
 	}
 
-	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	var updatedTodo entity.Todo
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err = r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedTodo)
 	if err != nil {
-		return nil, err
+		if err == mongo.ErrNoDocuments {
+			return nil, r.missingOrConflict(ctx, id)
+		}
+		return nil, translateWriteError(err)
 	}
 
-	return todo, nil
+	return &updatedTodo, nil
 }
 
 func (r *repository) DeleteTodo(ctx context.Context, id primitive.ObjectID) error {
@@ -139,68 +762,101 @@ func (r *repository) DeleteTodo(ctx context.Context, id primitive.ObjectID) erro
 		return err
 	}
 
-	_, err = r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	_, err = r.collection.DeleteOne(ctx, scoped(ctx, bson.M{"_id": id}))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	_, err = r.comments.DeleteMany(ctx, scoped(ctx, bson.M{"task_id": id}))
+	return err
 }
 
-func (r *repository) MarkAsCompleted(ctx context.Context, id primitive.ObjectID) error {
-	existingTodo, err := r.GetTaskByID(ctx, id)
-	if err != nil {
-		return err
+func (r *repository) SetStatus(ctx context.Context, id primitive.ObjectID, from entity.TaskStatus, todo *entity.Todo) (*entity.Todo, error) {
+	update := bson.M{
+		"$set": bson.M{"status": todo.Status, "updated_at": todo.UpdatedAt},
+	}
+	if todo.CompletedAt != nil {
+		update["$set"].(bson.M)["completed_at"] = todo.CompletedAt
+	} else {
+		update["$unset"] = bson.M{"completed_at": ""}
 	}
 
-	// Если задача уже выполнена, ничего не делаем
-	if existingTodo.Completed {
-		return nil
+	var updatedTodo entity.Todo
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.collection.FindOneAndUpdate(ctx, scoped(ctx, bson.M{"_id": id, "status": from}), update, opts).Decode(&updatedTodo)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, r.missingOrConflict(ctx, id)
+		}
+		return nil, err
 	}
 
-	// Помечаем задачу как выполненную
-	update := bson.M{
-		"$set": bson.M{"completed": true, "updated_at": time.Now()},
+	return &updatedTodo, nil
+}
+
+// translateWriteError переводит нарушение уникального индекса в ErrTodoExists
+func translateWriteError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.ErrTodoExists
 	}
+	return err
+}
 
-	_, err = r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
-	if err != nil {
+// missingOrConflict объясняет, почему условное обновление не нашло документ: задачи нет или у нее уже другой статус
+func (r *repository) missingOrConflict(ctx context.Context, id primitive.ObjectID) error {
+	if _, err := r.GetTaskByID(ctx, id); err != nil {
 		return err
 	}
-
-	return nil
+	return errors.ErrStatusConflict
 }
 
-func (r *repository) GetTasksByStatus(ctx context.Context, status string) ([]*entity.Todo, error) {
+func (r *repository) GetTasksByStatus(ctx context.Context, status string, opts entity.ListOptions) (*entity.TodoPage, error) {
 	now := time.Now()
 	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
 
 	var filter bson.M
-	if status == "done" {
-		filter = bson.M{"completed": true}
+	if entity.TaskStatus(status).IsValid() {
+		filter = bson.M{"status": status}
 	} else {
 		// Получить задачи, которые не завершены и имеют activeAt <= today
-		filter = bson.M{"completed": false, "active_at": bson.M{"$lte": today}}
-	}
+		filter = bson.M{"status": bson.M{"$in": entity.OpenStatuses()}, "active_at": bson.M{"$lte": today}}
 
-	cursor, err := r.collection.Find(ctx, filter)
-	if err != nil {
-		return nil, err
+		// и не ждут незакрытых зависимостей
+		if !opts.IncludeBlocked {
+			open, err := r.openDependencies(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if len(open) > 0 {
+				filter["depends_on"] = bson.M{"$nin": open}
+			}
+		}
 	}
-	defer cursor.Close(ctx)
 
-	var todos []*entity.Todo
-	if err = cursor.All(ctx, &todos); err != nil {
-		return nil, err
+	return r.findPage(ctx, filter, opts)
+}
+
+// listFilter - условие на список задач. null совпадает и с отсутствующим полем, то есть со списком по умолчанию
+func listFilter(listID *primitive.ObjectID) bson.M {
+	if listID == nil {
+		return bson.M{"list_id": nil}
 	}
+	return bson.M{"list_id": *listID}
+}
 
-	return todos, nil
+// scoped добавляет к filter владельца из контекста. null совпадает и с отсутствующим полем, то есть с данными без владельца
+func scoped(ctx context.Context, filter bson.M) bson.M {
+	filter["owner_id"] = nil
+	if ownerID := entity.OwnerFromContext(ctx); ownerID != nil {
+		filter["owner_id"] = *ownerID
+	}
+	return filter
 }
 
 // Вспомогательный метод для поиска задачи по ID
 func (r *repository) GetTaskByID(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error) {
 	var todo entity.Todo
-	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&todo)
+	err := r.collection.FindOne(ctx, scoped(ctx, bson.M{"_id": id})).Decode(&todo)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return nil, errors.ErrNotFound
@@ -210,26 +866,817 @@ func (r *repository) GetTaskByID(ctx context.Context, id primitive.ObjectID) (*e
 	return &todo, nil
 }
 
-func (r *repository) GetAllTasks(ctx context.Context) ([]*entity.Todo, error) {
-	filter := bson.M{}
+func (r *repository) SearchTasks(ctx context.Context, listID *primitive.ObjectID, q string, limit int) ([]*entity.SearchHit, error) {
+	query := textsearch.ParseQuery(q)
+	if query.IsEmpty() {
+		return []*entity.SearchHit{}, nil
+	}
+
+	// стемминг запроса по его языку, документы Mongo стеммит по их полю language
+	filter := scoped(ctx, listFilter(listID))
+	filter["$text"] = bson.M{"$search": query.String(), "$language": query.Language()}
+	score := bson.M{"$meta": "textScore"}
+	findOptions := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}})
+	if limit > 0 {
+		findOptions.SetLimit(int64(limit))
+	}
 
-	// Получаем список всех задач
-	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.M{"active_at": 1}))
+	cursor, err := r.collection.Find(ctx, filter, findOptions)
 	if err != nil {
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
-	var todos []*entity.Todo
-	for cursor.Next(ctx) {
-		var todo entity.Todo
-		if err := cursor.Decode(&todo); err != nil {
-			return nil, err
-		}
-		todos = append(todos, &todo)
+	var results []struct {
+		entity.Todo `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
 	}
 
-	return todos, nil
+	hits := make([]*entity.SearchHit, 0, len(results))
+	for i := range results {
+		todo := results[i].Todo
+		hits = append(hits, searchHit(query, &todo, results[i].Score))
+	}
+
+	return hits, nil
+}
+
+func (r *repository) GetAllTasks(ctx context.Context, opts entity.ListOptions) (*entity.TodoPage, error) {
+	return r.findPage(ctx, bson.M{}, opts)
+}
+
+// findPage достает одну страницу задач: фильтры, курсор, сортировка и лимит выполняются на стороне Mongo
+func (r *repository) findPage(ctx context.Context, filter bson.M, opts entity.ListOptions) (*entity.TodoPage, error) {
+	opts, err := normalizeListOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	pageCursor, err := decodeCursor(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := bson.A{filter, scoped(ctx, listFilter(opts.ListID))}
+	if opts.ParentID != nil {
+		conditions = append(conditions, bson.M{"parent_id": *opts.ParentID})
+	}
+	if len(opts.TagsAny) > 0 {
+		conditions = append(conditions, bson.M{"tags": bson.M{"$in": opts.TagsAny}})
+	}
+	if len(opts.TagsAll) > 0 {
+		conditions = append(conditions, bson.M{"tags": bson.M{"$all": opts.TagsAll}})
+	}
+	if rangeFilter := timeRange(opts.ActiveFrom, opts.ActiveTo); rangeFilter != nil {
+		conditions = append(conditions, bson.M{"active_at": rangeFilter})
+	}
+	if rangeFilter := timeRange(opts.CreatedFrom, opts.CreatedTo); rangeFilter != nil {
+		conditions = append(conditions, bson.M{"created_at": rangeFilter})
+	}
+
+	field := string(opts.Sort)
+	direction, after := 1, "$gt"
+	if opts.Descending {
+		direction, after = -1, "$lt"
+	}
+
+	// продолжаем строго после пары (поле, _id) из курсора
+	if pageCursor != nil {
+		value := pageCursor.value()
+		conditions = append(conditions, bson.M{"$or": bson.A{
+			bson.M{field: bson.M{after: value}},
+			bson.M{field: value, "_id": bson.M{after: pageCursor.ID}},
+		}})
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: field, Value: direction}, {Key: "_id", Value: direction}})
+	if opts.Limit > 0 {
+		findOptions.SetLimit(int64(opts.Limit + 1))
+	}
+
+	cursor, err := r.collection.Find(ctx, bson.M{"$and": conditions}, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var todos []*entity.Todo
+	if err = cursor.All(ctx, &todos); err != nil {
+		return nil, err
+	}
+
+	return newPage(todos, opts), nil
+}
+
+func (r *repository) GetSubtasks(ctx context.Context, parentIDs []primitive.ObjectID) ([]*entity.Todo, error) {
+	if len(parentIDs) == 0 {
+		return []*entity.Todo{}, nil
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "active_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, scoped(ctx, bson.M{"parent_id": bson.M{"$in": parentIDs}}), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	todos := []*entity.Todo{}
+	if err = cursor.All(ctx, &todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *repository) SetParent(ctx context.Context, ids []primitive.ObjectID, parentID *primitive.ObjectID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	update := bson.M{"$unset": bson.M{"parent_id": ""}}
+	if parentID != nil {
+		update = bson.M{"$set": bson.M{"parent_id": *parentID}}
+	}
+	_, err := r.collection.UpdateMany(ctx, scoped(ctx, bson.M{"_id": bson.M{"$in": ids}}), update)
+	return err
+}
+
+// openDependencies - ID незакрытых задач, от которых зависит хотя бы одна задача
+func (r *repository) openDependencies(ctx context.Context) ([]interface{}, error) {
+	referenced, err := r.collection.Distinct(ctx, "depends_on", scoped(ctx, bson.M{"depends_on": bson.M{"$exists": true}}))
+	if err != nil || len(referenced) == 0 {
+		return nil, err
+	}
+
+	return r.collection.Distinct(ctx, "_id", scoped(ctx, bson.M{
+		"_id":    bson.M{"$in": referenced},
+		"status": bson.M{"$in": entity.OpenStatuses()},
+	}))
+}
+
+func (r *repository) GetTasksByIDs(ctx context.Context, ids []primitive.ObjectID) ([]*entity.Todo, error) {
+	if len(ids) == 0 {
+		return []*entity.Todo{}, nil
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "active_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, scoped(ctx, bson.M{"_id": bson.M{"$in": ids}}), findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	todos := []*entity.Todo{}
+	if err = cursor.All(ctx, &todos); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func (r *repository) RemoveDependency(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(ctx, scoped(ctx, bson.M{"depends_on": id}), bson.M{"$pull": bson.M{"depends_on": id}})
+	return err
+}
+
+func (r *repository) GetTags(ctx context.Context) ([]*entity.TagCount, error) {
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: scoped(ctx, bson.M{})}},
+		{{Key: "$unwind", Value: "$tags"}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$tags"}, {Key: "count", Value: bson.M{"$sum": 1}}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []struct {
+		Name  string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	tags := make([]*entity.TagCount, 0, len(results))
+	for _, result := range results {
+		tags = append(tags, &entity.TagCount{Name: result.Name, Count: result.Count})
+	}
+	return tags, nil
+}
+
+// RenameTags меняет метки одним UpdateMany с конвейером в транзакции, если она доступна. На одиночном сервере
+// атомарен только каждый документ: сбой посередине оставит часть задач со старыми метками, а повторный запуск
+// доделает оставшиеся и не тронет уже переименованные
+func (r *repository) RenameTags(ctx context.Context, from []string, to string) (int, error) {
+	// from заменяется на to, затем повторы убираются с сохранением порядка, как в entity.Todo.RenameTags
+	renamed := bson.M{"$map": bson.M{
+		"input": "$tags",
+		"in":    bson.M{"$cond": bson.A{bson.M{"$in": bson.A{"$$this", from}}, to, "$$this"}},
+	}}
+	unique := bson.M{"$reduce": bson.M{
+		"input":        renamed,
+		"initialValue": bson.A{},
+		"in": bson.M{"$cond": bson.A{
+			bson.M{"$in": bson.A{"$$this", "$$value"}},
+			"$$value",
+			bson.M{"$concatArrays": bson.A{"$$value", bson.A{"$$this"}}},
+		}},
+	}}
+
+	var modified int
+	err := r.inTransaction(ctx, func(ctx context.Context) error {
+		result, err := r.collection.UpdateMany(ctx,
+			scoped(ctx, bson.M{"tags": bson.M{"$in": from}}),
+			mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "tags", Value: unique}, {Key: "updated_at", Value: time.Now()}}}}},
+		)
+		if err != nil {
+			return err
+		}
+		modified = int(result.ModifiedCount)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return modified, nil
+}
+
+// MoveTasks переносит задачи одним UpdateMany, а дубликаты находит сам уникальный индекс. В транзакции дубликат
+// отменяет перенос целиком. На одиночном сервере UpdateMany успевает перенести задачи до дубликата, поэтому
+// ошибка перечисляет те, что остались на месте
+func (r *repository) MoveTasks(ctx context.Context, ids []primitive.ObjectID, listID *primitive.ObjectID) error {
+	update := bson.M{"$set": bson.M{"updated_at": time.Now()}, "$unset": bson.M{"list_id": ""}}
+	if listID != nil {
+		update = bson.M{"$set": bson.M{"list_id": *listID, "updated_at": time.Now()}}
+	}
+
+	err := r.inTransaction(ctx, func(ctx context.Context) error {
+		_, err := r.collection.UpdateMany(ctx, scoped(ctx, bson.M{"_id": bson.M{"$in": ids}}), update)
+		return err
+	})
+	if err == nil || r.transactions || !mongo.IsDuplicateKeyError(err) {
+		return translateWriteError(err)
+	}
+
+	filter := scoped(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	filter["$nor"] = bson.A{listFilter(listID)}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return err
+	}
+	var left []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &left); err != nil {
+		return err
+	}
+	hexIDs := make([]string, 0, len(left))
+	for _, todo := range left {
+		hexIDs = append(hexIDs, todo.ID.Hex())
+	}
+	return fmt.Errorf("%w: not moved: %s", errors.ErrTodoExists, strings.Join(hexIDs, ", "))
+}
+
+func (r *repository) CreateList(ctx context.Context, list *entity.List) (*entity.List, error) {
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+
+	list.OwnerID = entity.OwnerFromContext(ctx)
+	list.CreatedAt = time.Now()
+	list.UpdatedAt = list.CreatedAt
+	result, err := r.lists.InsertOne(ctx, list)
+	if err != nil {
+		return nil, translateListWriteError(err)
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, errors.ErrFailedToGetRecordID
+	}
+
+	list.ID = insertedID
+	return list, nil
+}
+
+func (r *repository) GetList(ctx context.Context, id primitive.ObjectID) (*entity.List, error) {
+	var list entity.List
+	err := r.lists.FindOne(ctx, scoped(ctx, bson.M{"_id": id})).Decode(&list)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrListNotFound
+		}
+		return nil, err
+	}
+	return &list, nil
+}
+
+func (r *repository) GetLists(ctx context.Context, includeArchived bool) ([]*entity.List, error) {
+	filter := scoped(ctx, bson.M{})
+	if !includeArchived {
+		filter["archived_at"] = nil
+	}
+
+	findOptions := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.lists.Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	lists := []*entity.List{}
+	if err = cursor.All(ctx, &lists); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+func (r *repository) UpdateList(ctx context.Context, list *entity.List) (*entity.List, error) {
+	if err := list.Validate(); err != nil {
+		return nil, err
+	}
+
+	update := bson.M{"$set": bson.M{"name": list.Name, "updated_at": list.UpdatedAt}}
+	if list.ArchivedAt != nil {
+		update["$set"].(bson.M)["archived_at"] = list.ArchivedAt
+	} else {
+		update["$unset"] = bson.M{"archived_at": ""}
+	}
+
+	var updated entity.List
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.lists.FindOneAndUpdate(ctx, scoped(ctx, bson.M{"_id": list.ID}), update, opts).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrListNotFound
+		}
+		return nil, translateListWriteError(err)
+	}
+	return &updated, nil
+}
+
+func (r *repository) DeleteList(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.lists.DeleteOne(ctx, scoped(ctx, bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.ErrListNotFound
+	}
+	return nil
+}
+
+// translateListWriteError переводит нарушение уникального индекса по имени в ErrListExists
+func translateListWriteError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.ErrListExists
+	}
+	return err
+}
+
+func (r *repository) CreateUser(ctx context.Context, user *entity.User) (*entity.User, error) {
+	user.CreatedAt = time.Now()
+	result, err := r.users.InsertOne(ctx, user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.ErrUserExists
+		}
+		return nil, err
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, errors.ErrFailedToGetRecordID
+	}
+
+	user.ID = insertedID
+	return user, nil
+}
+
+func (r *repository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*entity.User, error) {
+	return r.findUser(ctx, bson.M{"_id": id})
+}
+
+func (r *repository) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	return r.findUser(ctx, bson.M{"email": email})
+}
+
+func (r *repository) GetUserByUsername(ctx context.Context, username string) (*entity.User, error) {
+	return r.findUser(ctx, bson.M{"username": username})
+}
+
+func (r *repository) SetUsername(ctx context.Context, id primitive.ObjectID, username string) (*entity.User, error) {
+	var user entity.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.users.FindOneAndUpdate(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"username": username}}, opts).Decode(&user)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.ErrUsernameExists
+		}
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *repository) findUser(ctx context.Context, filter bson.M) (*entity.User, error) {
+	var user entity.User
+	err := r.users.FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *repository) CountUsers(ctx context.Context) (int, error) {
+	count, err := r.users.CountDocuments(ctx, bson.M{})
+	return int(count), err
+}
+
+func (r *repository) GetUserByIdentity(ctx context.Context, issuer, subject string) (*entity.User, error) {
+	return r.findUser(ctx, bson.M{"oidc_issuer": issuer, "oidc_subject": subject})
+}
+
+func (r *repository) LinkIdentity(ctx context.Context, id primitive.ObjectID, issuer, subject string) error {
+	result, err := r.users.UpdateByID(ctx, id, bson.M{"$set": bson.M{"oidc_issuer": issuer, "oidc_subject": subject}})
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return errors.ErrUserExists
+		}
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.ErrUserNotFound
+	}
+	return nil
+}
+
+func (r *repository) ClaimUnowned(ctx context.Context, ownerID primitive.ObjectID) error {
+	unowned := bson.M{"owner_id": nil}
+	update := bson.M{"$set": bson.M{"owner_id": ownerID}}
+	if _, err := r.lists.UpdateMany(ctx, unowned, update); err != nil {
+		return err
+	}
+	_, err := r.collection.UpdateMany(ctx, unowned, update)
+	return err
+}
+
+func (r *repository) CreateAPIKey(ctx context.Context, key *entity.APIKey) (*entity.APIKey, error) {
+	result, err := r.apiKeys.InsertOne(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, errors.ErrFailedToGetRecordID
+	}
+
+	key.ID = insertedID
+	return key, nil
+}
+
+func (r *repository) GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.apiKeys.Find(ctx, scoped(ctx, bson.M{}), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := make([]*entity.APIKey, 0)
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *repository) GetAPIKeyByHash(ctx context.Context, hash string) (*entity.APIKey, error) {
+	var key entity.APIKey
+	err := r.apiKeys.FindOne(ctx, bson.M{"hash": hash}).Decode(&key)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *repository) DeleteAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.apiKeys.DeleteOne(ctx, scoped(ctx, bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func (r *repository) TouchAPIKey(ctx context.Context, id primitive.ObjectID, usedAt time.Time) error {
+	_, err := r.apiKeys.UpdateByID(ctx, id, bson.M{"$set": bson.M{"last_used_at": usedAt}})
+	return err
+}
+
+func (r *repository) AddMember(ctx context.Context, member *entity.Membership) (*entity.Membership, error) {
+	result, err := r.members.InsertOne(ctx, member)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, errors.ErrMemberExists
+		}
+		return nil, err
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, errors.ErrFailedToGetRecordID
+	}
+
+	member.ID = insertedID
+	return member, nil
+}
+
+func (r *repository) GetMembership(ctx context.Context, listID, userID primitive.ObjectID) (*entity.Membership, error) {
+	var member entity.Membership
+	err := r.members.FindOne(ctx, bson.M{"list_id": listID, "user_id": userID}).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *repository) GetMemberships(ctx context.Context, userID primitive.ObjectID) ([]*entity.Membership, error) {
+	return r.findMembers(ctx, bson.M{"user_id": userID})
+}
+
+func (r *repository) GetMembers(ctx context.Context, listID primitive.ObjectID) ([]*entity.Membership, error) {
+	return r.findMembers(ctx, scoped(ctx, bson.M{"list_id": listID}))
+}
+
+func (r *repository) findMembers(ctx context.Context, filter bson.M) ([]*entity.Membership, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.members.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	members := make([]*entity.Membership, 0)
+	if err := cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (r *repository) SetMemberRole(ctx context.Context, listID, userID primitive.ObjectID, role entity.Role) (*entity.Membership, error) {
+	var member entity.Membership
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	filter := scoped(ctx, bson.M{"list_id": listID, "user_id": userID})
+	err := r.members.FindOneAndUpdate(ctx, filter, bson.M{"$set": bson.M{"role": role}}, opts).Decode(&member)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrMemberNotFound
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *repository) RemoveMember(ctx context.Context, listID, userID primitive.ObjectID) error {
+	result, err := r.members.DeleteOne(ctx, scoped(ctx, bson.M{"list_id": listID, "user_id": userID}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.ErrMemberNotFound
+	}
+	return nil
+}
+
+func (r *repository) RemoveMembers(ctx context.Context, listID primitive.ObjectID) error {
+	if _, err := r.members.DeleteMany(ctx, scoped(ctx, bson.M{"list_id": listID})); err != nil {
+		return err
+	}
+	_, err := r.invitations.DeleteMany(ctx, scoped(ctx, bson.M{"list_id": listID}))
+	return err
+}
+
+func (r *repository) CreateInvitation(ctx context.Context, invitation *entity.Invitation) (*entity.Invitation, error) {
+	result, err := r.invitations.InsertOne(ctx, invitation)
+	if err != nil {
+		return nil, err
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, errors.ErrFailedToGetRecordID
+	}
+
+	invitation.ID = insertedID
+	return invitation, nil
+}
+
+func (r *repository) TakeInvitation(ctx context.Context, hash string) (*entity.Invitation, error) {
+	var invitation entity.Invitation
+	err := r.invitations.FindOneAndDelete(ctx, bson.M{"hash": hash}).Decode(&invitation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrInvitationNotFound
+		}
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (r *repository) CreateNotifications(ctx context.Context, notifications []*entity.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	documents := make([]interface{}, 0, len(notifications))
+	for _, notification := range notifications {
+		documents = append(documents, notification)
+	}
+	result, err := r.notifications.InsertMany(ctx, documents)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range result.InsertedIDs {
+		insertedID, ok := id.(primitive.ObjectID)
+		if !ok {
+			return errors.ErrFailedToGetRecordID
+		}
+		notifications[i].ID = insertedID
+	}
+	return nil
+}
+
+func (r *repository) GetNotifications(ctx context.Context, unreadOnly bool, limit int) ([]*entity.Notification, error) {
+	filter := scoped(ctx, bson.M{})
+	if unreadOnly {
+		filter["read_at"] = nil
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit))
+	cursor, err := r.notifications.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	notifications := make([]*entity.Notification, 0)
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *repository) SetNotificationRead(ctx context.Context, id primitive.ObjectID, readAt *time.Time) (*entity.Notification, error) {
+	update := bson.M{"$unset": bson.M{"read_at": ""}}
+	if readAt != nil {
+		update = bson.M{"$set": bson.M{"read_at": readAt}}
+	}
+
+	var notification entity.Notification
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.notifications.FindOneAndUpdate(ctx, scoped(ctx, bson.M{"_id": id}), update, opts).Decode(&notification)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrNotificationNotFound
+		}
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *repository) CreateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error) {
+	result, err := r.comments.InsertOne(ctx, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	insertedID, ok := result.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return nil, errors.ErrFailedToGetRecordID
+	}
+	return r.GetComment(ctx, insertedID)
+}
+
+func (r *repository) GetComment(ctx context.Context, id primitive.ObjectID) (*entity.Comment, error) {
+	var comment entity.Comment
+	err := r.comments.FindOne(ctx, scoped(ctx, bson.M{"_id": id})).Decode(&comment)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrCommentNotFound
+		}
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (r *repository) GetComments(ctx context.Context, taskID primitive.ObjectID, after *primitive.ObjectID, limit int) ([]*entity.Comment, error) {
+	filter := scoped(ctx, bson.M{"task_id": taskID})
+	if after != nil {
+		filter["_id"] = bson.M{"$gt": *after}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := r.comments.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	comments := make([]*entity.Comment, 0)
+	if err := cursor.All(ctx, &comments); err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+func (r *repository) UpdateComment(ctx context.Context, comment *entity.Comment) (*entity.Comment, error) {
+	update := bson.M{"$set": bson.M{"body": comment.Body, "updated_at": comment.UpdatedAt}}
+
+	var updated entity.Comment
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := r.comments.FindOneAndUpdate(ctx, scoped(ctx, bson.M{"_id": comment.ID}), update, opts).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errors.ErrCommentNotFound
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (r *repository) DeleteComment(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.comments.DeleteOne(ctx, scoped(ctx, bson.M{"_id": id}))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.ErrCommentNotFound
+	}
+	return nil
+}
+
+func (r *repository) CountComments(ctx context.Context, taskIDs []primitive.ObjectID) (map[primitive.ObjectID]int, error) {
+	counts := make(map[primitive.ObjectID]int)
+	if len(taskIDs) == 0 {
+		return counts, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: scoped(ctx, bson.M{"task_id": bson.M{"$in": taskIDs}})}},
+		{{Key: "$group", Value: bson.M{"_id": "$task_id", "count": bson.M{"$sum": 1}}}},
+	}
+	cursor, err := r.comments.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		TaskID primitive.ObjectID `bson:"_id"`
+		Count  int                `bson:"count"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		counts[group.TaskID] = group.Count
+	}
+	return counts, nil
+}
+
+// timeRange - условие на включительный диапазон дат, nil если границ нет
+func timeRange(from, to *time.Time) bson.M {
+	if from == nil && to == nil {
+		return nil
+	}
+
+	condition := bson.M{}
+	if from != nil {
+		condition["$gte"] = *from
+	}
+	if to != nil {
+		condition["$lte"] = *to
+	}
+	return condition
 }
 
 func (r *repository) Close() error {
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type AuthService interface {
+	Register(ctx context.Context, email, password string) (*entity.User, error)
+	Login(ctx context.Context, email, password string) (*token.Pair, error)
+	// Refresh выпускает новую пару по refresh-токену
+	Refresh(ctx context.Context, refreshToken string) (*token.Pair, error)
+	// Authenticate возвращает владельца access-токена
+	Authenticate(ctx context.Context, accessToken string) (*entity.User, error)
+	// SetUsername задает пользователю из контекста имя для упоминаний
+	SetUsername(ctx context.Context, username string) (*entity.User, error)
+	// ClaimUnowned передает задачи и списки, созданные до появления пользователей, зарегистрированному
+	// пользователю email. Сама регистрация ничего не передает: иначе данные забрал бы первый, кто достучался до порта
+	ClaimUnowned(ctx context.Context, email string) (*entity.User, error)
+
+	// CreateAPIKey выпускает ключ API владельцу из контекста. Сам ключ возвращается только здесь
+	CreateAPIKey(ctx context.Context, name string, scopes []string) (*entity.APIKey, string, error)
+	GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id primitive.ObjectID) error
+	// AuthenticateAPIKey возвращает владельца ключа и сам ключ, заодно отмечает время его использования
+	AuthenticateAPIKey(ctx context.Context, key string) (*entity.User, *entity.APIKey, error)
+}
+
+// AuthRepository - хранилище пользователей и их ключей API
+type AuthRepository interface {
+	repo.UserRepository
+	repo.APIKeyRepository
+}
+
+type authService struct {
+	repo   AuthRepository
+	tokens *token.Issuer
+
+	// dummyHash сравнивается с паролем неизвестного пользователя, чтобы по времени ответа
+	// нельзя было узнать, зарегистрирован ли email
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+func NewAuthService(repo AuthRepository, tokens *token.Issuer) AuthService {
+	return &authService{repo: repo, tokens: tokens}
+}
+
+func (s *authService) Register(ctx context.Context, email, password string) (*entity.User, error) {
+	email, err := entity.NormalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if err := entity.ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.CreateUser(ctx, &entity.User{Email: email, PasswordHash: string(hash)})
+}
+
+func (s *authService) ClaimUnowned(ctx context.Context, email string) (*entity.User, error) {
+	user, err := s.repo.GetUserByEmail(ctx, normalizeLogin(email))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ClaimUnowned(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *authService) Login(ctx context.Context, email, password string) (*token.Pair, error) {
+	user, err := s.repo.GetUserByEmail(ctx, normalizeLogin(email))
+	if stderrors.Is(err, errors.ErrUserNotFound) {
+		s.dummyOnce.Do(func() {
+			s.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+		})
+		_ = bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return nil, errors.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, errors.ErrInvalidCredentials
+	}
+	return s.tokens.Issue(user.ID.Hex(), entity.TenantFromContext(ctx))
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*token.Pair, error) {
+	user, err := s.userFromToken(ctx, refreshToken, token.Refresh)
+	if err != nil {
+		return nil, err
+	}
+	return s.tokens.Issue(user.ID.Hex(), entity.TenantFromContext(ctx))
+}
+
+func (s *authService) Authenticate(ctx context.Context, accessToken string) (*entity.User, error) {
+	return s.userFromToken(ctx, accessToken, token.Access)
+}
+
+func (s *authService) SetUsername(ctx context.Context, username string) (*entity.User, error) {
+	userID := entity.OwnerFromContext(ctx)
+	if userID == nil {
+		return nil, errors.ErrUnauthorized
+	}
+	username, err := entity.NormalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.SetUsername(ctx, *userID, username)
+}
+
+// userFromToken проверяет токен и находит его пользователя. Токен удаленного пользователя недействителен,
+// как и токен другой организации: id пользователя из чужой базы мог бы совпасть с id в этой
+func (s *authService) userFromToken(ctx context.Context, value string, kind token.Kind) (*entity.User, error) {
+	subject, tenant, err := s.tokens.Parse(value, kind)
+	if err != nil {
+		// текст разбора JWT клиенту ни к чему, он остается в логе сервера
+		log.Printf("Отклонен токен: %v", err)
+		return nil, errors.ErrUnauthorized
+	}
+	if tenant != entity.TenantFromContext(ctx) {
+		return nil, fmt.Errorf("%w: token of another tenant", errors.ErrUnauthorized)
+	}
+	id, err := primitive.ObjectIDFromHex(subject)
+	if err != nil {
+		return nil, errors.ErrUnauthorized
+	}
+
+	user, err := s.repo.GetUserByID(ctx, id)
+	if stderrors.Is(err, errors.ErrUserNotFound) {
+		return nil, errors.ErrUnauthorized
+	}
+	return user, err
+}
+
+func (s *authService) CreateAPIKey(ctx context.Context, name string, scopes []string) (*entity.APIKey, string, error) {
+	ownerID := entity.OwnerFromContext(ctx)
+	if ownerID == nil {
+		return nil, "", errors.ErrUnauthorized
+	}
+
+	parsed, err := entity.ParseScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	key := entity.NewAPIKey(name, parsed)
+	if err := key.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := token.NewAPIKey()
+	if err != nil {
+		return nil, "", err
+	}
+	key.OwnerID = *ownerID
+	key.Hint = secret[:token.APIKeyHintLength]
+	key.Hash = token.HashSecret(secret)
+
+	created, err := s.repo.CreateAPIKey(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+	return created, secret, nil
+}
+
+func (s *authService) GetAPIKeys(ctx context.Context) ([]*entity.APIKey, error) {
+	return s.repo.GetAPIKeys(ctx)
+}
+
+func (s *authService) RevokeAPIKey(ctx context.Context, id primitive.ObjectID) error {
+	return s.repo.DeleteAPIKey(ctx, id)
+}
+
+func (s *authService) AuthenticateAPIKey(ctx context.Context, value string) (*entity.User, *entity.APIKey, error) {
+	key, err := s.repo.GetAPIKeyByHash(ctx, token.HashSecret(value))
+	if stderrors.Is(err, errors.ErrAPIKeyNotFound) {
+		return nil, nil, errors.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, key.OwnerID)
+	if stderrors.Is(err, errors.ErrUserNotFound) {
+		return nil, nil, errors.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if now := time.Now(); key.NeedsTouch(now) {
+		if err := s.repo.TouchAPIKey(ctx, key.ID, now); err != nil {
+			return nil, nil, err
+		}
+		key.LastUsedAt = &now
+	}
+	return user, key, nil
+}
+
+// normalizeLogin приводит email при входе к виду из Register. Некорректный адрес просто не найдется
+func normalizeLogin(email string) string {
+	if normalized, err := entity.NormalizeEmail(email); err == nil {
+		return normalized
+	}
+	return email
+}
@@ -0,0 +1,147 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewAuthService(repo.NewMemoryRepository(), token.NewIssuer([]byte("secret"), time.Minute, time.Hour))
+
+	user, err := s.Register(ctx, " Alice@Example.com ", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.NotEqual(t, "correct horse", user.PasswordHash)
+
+	_, err = s.Register(ctx, "alice@example.com", "another password")
+	assert.ErrorIs(t, err, errors.ErrUserExists)
+	_, err = s.Register(ctx, "Alice <alice@example.com>", "correct horse")
+	assert.ErrorIs(t, err, errors.ErrInvalidEmail)
+	_, err = s.Register(ctx, "bob@example.com", "short")
+	assert.ErrorIs(t, err, errors.ErrInvalidPassword)
+
+	// неизвестный email и неверный пароль неотличимы
+	_, err = s.Login(ctx, "alice@example.com", "wrong password")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+	_, err = s.Login(ctx, "bob@example.com", "correct horse")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+
+	pair, err := s.Login(ctx, "ALICE@example.com", "correct horse")
+	require.NoError(t, err)
+
+	authenticated, err := s.Authenticate(ctx, pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.ID)
+
+	_, err = s.Authenticate(ctx, pair.RefreshToken)
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+
+	refreshed, err := s.Refresh(ctx, pair.RefreshToken)
+	require.NoError(t, err)
+	_, err = s.Authenticate(ctx, refreshed.AccessToken)
+	assert.NoError(t, err)
+	_, err = s.Refresh(ctx, pair.AccessToken)
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
+
+func TestClaimUnownedTasks(t *testing.T) {
+	ctx := context.Background()
+	repository := repo.NewMemoryRepository()
+	todos := services.NewTodoService(repository, services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	auth := services.NewAuthService(repository, token.NewIssuer([]byte("secret"), time.Minute, time.Hour))
+
+	legacy, err := todos.CreateNewTodo(ctx, entity.TodoFields{Title: "Отчет", ActiveAt: time.Now()})
+	require.NoError(t, err)
+
+	alice, err := auth.Register(ctx, "alice@example.com", "correct horse")
+	require.NoError(t, err)
+	bob, err := auth.Register(ctx, "bob@example.com", "battery staple")
+	require.NoError(t, err)
+
+	// регистрация, даже первая, данные без владельца не забирает
+	_, err = todos.GetTaskByID(entity.WithOwner(ctx, alice.ID), legacy.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	_, err = auth.ClaimUnowned(ctx, "carol@example.com")
+	assert.ErrorIs(t, err, errors.ErrUserNotFound)
+	owner, err := auth.ClaimUnowned(ctx, " Bob@Example.com ")
+	require.NoError(t, err)
+	assert.Equal(t, bob.ID, owner.ID)
+
+	_, err = todos.GetTaskByID(entity.WithOwner(ctx, bob.ID), legacy.ID)
+	assert.NoError(t, err)
+	_, err = todos.GetTaskByID(entity.WithOwner(ctx, alice.ID), legacy.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewAuthService(repo.NewMemoryRepository(), token.NewIssuer([]byte("secret"), time.Minute, time.Hour))
+
+	alice, err := s.Register(ctx, "alice@example.com", "correct horse")
+	require.NoError(t, err)
+	owner := entity.WithOwner(ctx, alice.ID)
+
+	_, _, err = s.CreateAPIKey(ctx, "CI", []string{"tasks:read"})
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+	_, _, err = s.CreateAPIKey(owner, "CI", []string{"tasks:admin"})
+	assert.ErrorIs(t, err, errors.ErrInvalidScope)
+	_, _, err = s.CreateAPIKey(owner, "", []string{"tasks:read"})
+	assert.ErrorIs(t, err, errors.ErrInvalidAPIKeyName)
+
+	key, secret, err := s.CreateAPIKey(owner, "CI", []string{"tasks:write", "tasks:read"})
+	require.NoError(t, err)
+	assert.Equal(t, []entity.Scope{entity.ScopeTasksRead, entity.ScopeTasksWrite}, key.Scopes)
+	assert.True(t, strings.HasPrefix(secret, key.Hint))
+	// хранится только хеш
+	assert.NotContains(t, key.Hash, secret)
+
+	user, authenticated, err := s.AuthenticateAPIKey(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+	assert.Equal(t, key.ID, authenticated.ID)
+
+	keys, err := s.GetAPIKeys(owner)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.NotNil(t, keys[0].LastUsedAt)
+
+	_, _, err = s.AuthenticateAPIKey(ctx, secret+"x")
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+
+	require.NoError(t, s.RevokeAPIKey(owner, key.ID))
+	assert.ErrorIs(t, s.RevokeAPIKey(owner, key.ID), errors.ErrAPIKeyNotFound)
+	_, _, err = s.AuthenticateAPIKey(ctx, secret)
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
+
+func TestSetUsername(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewAuthService(repo.NewMemoryRepository(), token.NewIssuer([]byte("secret"), time.Minute, time.Hour))
+	alice, err := s.Register(ctx, "alice@example.com", "correct horse")
+	require.NoError(t, err)
+	bob, err := s.Register(ctx, "bob@example.com", "correct horse")
+	require.NoError(t, err)
+
+	user, err := s.SetUsername(entity.WithOwner(ctx, alice.ID), " Alice ")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	_, err = s.SetUsername(entity.WithOwner(ctx, bob.ID), "ALICE")
+	assert.ErrorIs(t, err, errors.ErrUsernameExists)
+	_, err = s.SetUsername(entity.WithOwner(ctx, bob.ID), "b")
+	assert.ErrorIs(t, err, errors.ErrInvalidUsername)
+	_, err = s.SetUsername(ctx, "bob")
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreateComment оставляет комментарий под задачей taskID от имени пользователя из контекста.
+// В общем списке комментировать может тот, кто может менять задачи
+func (s *todoService) CreateComment(ctx context.Context, taskID primitive.ObjectID, body string) (*entity.Comment, error) {
+	if err := s.authorizeTask(ctx, taskID, true); err != nil {
+		return nil, err
+	}
+	todo, err := s.repo.GetTaskByID(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := entity.NewComment(todo, actor(ctx), body, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.CreateComment(ctx, comment)
+}
+
+// GetComments - страница до limit комментариев задачи taskID по порядку написания.
+// cursor - NextCursor предыдущей страницы, пустой - с первого комментария
+func (s *todoService) GetComments(ctx context.Context, taskID primitive.ObjectID, cursor string, limit int) (*entity.CommentPage, error) {
+	if err := s.authorizeTask(ctx, taskID, false); err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetTaskByID(ctx, taskID); err != nil {
+		return nil, err
+	}
+
+	var after *primitive.ObjectID
+	if cursor != "" {
+		id, err := primitive.ObjectIDFromHex(cursor)
+		if err != nil {
+			return nil, errors.ErrInvalidCursor
+		}
+		after = &id
+	}
+
+	// лишний комментарий показывает, есть ли следующая страница
+	comments, err := s.repo.GetComments(ctx, taskID, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &entity.CommentPage{Comments: comments}
+	if len(comments) > limit {
+		page.Comments = comments[:limit]
+		page.NextCursor = comments[limit-1].ID.Hex()
+	}
+	return page, nil
+}
+
+// UpdateComment меняет текст комментария commentID под задачей taskID. Менять можно только свои комментарии
+func (s *todoService) UpdateComment(ctx context.Context, taskID, commentID primitive.ObjectID, body string) (*entity.Comment, error) {
+	comment, err := s.ownComment(ctx, taskID, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := comment.Edit(body, time.Now()); err != nil {
+		return nil, err
+	}
+	return s.repo.UpdateComment(ctx, comment)
+}
+
+// DeleteComment удаляет комментарий commentID под задачей taskID. Удалять можно только свои комментарии
+func (s *todoService) DeleteComment(ctx context.Context, taskID, commentID primitive.ObjectID) error {
+	if _, err := s.ownComment(ctx, taskID, commentID); err != nil {
+		return err
+	}
+	return s.repo.DeleteComment(ctx, commentID)
+}
+
+// ownComment - комментарий commentID под задачей taskID, если его написал пользователь из контекста.
+// Комментарий под другой задачей для taskID не существует
+func (s *todoService) ownComment(ctx context.Context, taskID, commentID primitive.ObjectID) (*entity.Comment, error) {
+	if err := s.authorizeTask(ctx, taskID, true); err != nil {
+		return nil, err
+	}
+	comment, err := s.repo.GetComment(ctx, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if comment.TaskID != taskID {
+		return nil, errors.ErrCommentNotFound
+	}
+	if !comment.WrittenBy(actor(ctx)) {
+		return nil, fmt.Errorf("%w: only the author can change a comment", errors.ErrForbidden)
+	}
+	return comment, nil
+}
+
+// fillCommentCounts заполняет число комментариев задач одним запросом
+func (s *todoService) fillCommentCounts(ctx context.Context, todos []*entity.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	counts, err := s.repo.CountComments(ctx, todoIDs(todos))
+	if err != nil {
+		return err
+	}
+	for _, todo := range todos {
+		todo.CommentCount = counts[todo.ID]
+	}
+	return nil
+}
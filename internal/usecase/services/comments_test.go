@@ -0,0 +1,122 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCommentPages(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	todo, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Молоко", ActiveAt: time.Now()})
+	require.NoError(t, err)
+
+	for _, body := range []string{"Первый", "Второй", "Третий"} {
+		_, err := s.CreateComment(ctx, todo.ID, body)
+		require.NoError(t, err)
+	}
+	_, err = s.CreateComment(ctx, todo.ID, "  ")
+	assert.ErrorIs(t, err, errors.ErrInvalidComment)
+	_, err = s.CreateComment(ctx, primitive.NewObjectID(), "Куда?")
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	page, err := s.GetComments(ctx, todo.ID, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Comments, 2)
+	assert.Equal(t, "Первый", page.Comments[0].Body)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = s.GetComments(ctx, todo.ID, page.NextCursor, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Comments, 1)
+	assert.Equal(t, "Третий", page.Comments[0].Body)
+	assert.Empty(t, page.NextCursor)
+
+	_, err = s.GetComments(ctx, todo.ID, "not-a-cursor", 2)
+	assert.ErrorIs(t, err, errors.ErrInvalidCursor)
+
+	// число комментариев видно и в списке задач, и в самой задаче
+	all, err := s.GetAllTasks(ctx, entity.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, all.Tasks, 1)
+	assert.Equal(t, 3, all.Tasks[0].CommentCount)
+
+	require.NoError(t, s.DeleteComment(ctx, todo.ID, page.Comments[0].ID))
+	found, err := s.GetTaskByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, found.CommentCount)
+
+	// удаленная задача уносит комментарии с собой
+	require.NoError(t, s.DeleteTodo(ctx, todo.ID))
+	_, err = s.GetComments(ctx, todo.ID, "", 2)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func TestOnlyAuthorChangesComment(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleEditor)
+	todo, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Молоко", ActiveAt: time.Now(), ListID: &f.list.ID})
+	require.NoError(t, err)
+	other, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Хлеб", ActiveAt: time.Now(), ListID: &f.list.ID})
+	require.NoError(t, err)
+
+	own, err := f.service.CreateComment(f.alice, todo.ID, "Купи 2л")
+	require.NoError(t, err)
+	bob := f.open(t)
+	reply, err := f.service.CreateComment(bob, todo.ID, "Купил")
+	require.NoError(t, err)
+	require.NotNil(t, reply.AuthorID)
+	assert.Equal(t, f.bobID, *reply.AuthorID)
+
+	// чужой комментарий не меняет даже владелец списка
+	_, err = f.service.UpdateComment(f.alice, todo.ID, reply.ID, "Не тот")
+	assert.ErrorIs(t, err, errors.ErrForbidden)
+	assert.ErrorIs(t, f.service.DeleteComment(bob, todo.ID, own.ID), errors.ErrForbidden)
+
+	updated, err := f.service.UpdateComment(bob, todo.ID, reply.ID, "Купил, но не тот")
+	require.NoError(t, err)
+	assert.Equal(t, "Купил, но не тот", updated.Body)
+	assert.Equal(t, reply.CreatedAt, updated.CreatedAt)
+
+	// комментарий ищется только под своей задачей
+	_, err = f.service.UpdateComment(bob, other.ID, reply.ID, "Другое")
+	assert.ErrorIs(t, err, errors.ErrCommentNotFound)
+
+	require.NoError(t, f.service.DeleteComment(bob, todo.ID, reply.ID))
+	assert.ErrorIs(t, f.service.DeleteComment(bob, todo.ID, reply.ID), errors.ErrCommentNotFound)
+	page, err := f.service.GetComments(f.alice, todo.ID, "", 10)
+	require.NoError(t, err)
+	require.Len(t, page.Comments, 1)
+	assert.Equal(t, own.ID, page.Comments[0].ID)
+}
+
+func TestViewerReadsComments(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleViewer)
+	todo, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Молоко", ActiveAt: time.Now(), ListID: &f.list.ID})
+	require.NoError(t, err)
+	private, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Подарок", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	_, err = f.service.CreateComment(f.alice, todo.ID, "Купи 2л")
+	require.NoError(t, err)
+	_, err = f.service.CreateComment(f.alice, private.ID, "Спрятать")
+	require.NoError(t, err)
+
+	bob := f.open(t)
+	page, err := f.service.GetComments(bob, todo.ID, "", 10)
+	require.NoError(t, err)
+	assert.Len(t, page.Comments, 1)
+	_, err = f.service.CreateComment(bob, todo.ID, "Купил")
+	assert.ErrorIs(t, err, errors.ErrForbidden)
+
+	// комментарии задач вне списка участнику не видны
+	_, err = f.service.GetComments(bob, private.ID, "", 10)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// checkDependencies проверяет depends_on задачи todo: все зависимости существуют и не замыкают цикл.
+// У новой задачи ID еще нет и от нее никто не зависит, поэтому для нее проверяется только существование
+func (s *todoService) checkDependencies(ctx context.Context, todo *entity.Todo) error {
+	if len(todo.DependsOn) == 0 {
+		return nil
+	}
+
+	found, err := s.repo.GetTasksByIDs(ctx, todo.DependsOn)
+	if err != nil {
+		return err
+	}
+	if missing, ok := missingID(todo.DependsOn, visibleTodos(ctx, found)); ok {
+		return fmt.Errorf("%w: %s", errors.ErrDependencyNotFound, missing.Hex())
+	}
+	if todo.ID.IsZero() {
+		return nil
+	}
+
+	// идем по depends_on от новых зависимостей; если дошли до самой задачи, новые ребра замыкают цикл.
+	// via - из какой задачи мы пришли в данную, по нему восстанавливается путь для ошибки
+	via := make(map[primitive.ObjectID]primitive.ObjectID)
+	for _, dependency := range found {
+		via[dependency.ID] = todo.ID
+	}
+	visited := make(map[primitive.ObjectID]bool)
+	for level := found; len(level) > 0; {
+		var next []primitive.ObjectID
+		for _, current := range level {
+			if current.ID == todo.ID {
+				return fmt.Errorf("%w: %s", errors.ErrDependencyCycle, cyclePath(todo.ID, via))
+			}
+			if visited[current.ID] {
+				continue
+			}
+			visited[current.ID] = true
+
+			for _, dependency := range current.DependsOn {
+				if _, ok := via[dependency]; !ok {
+					via[dependency] = current.ID
+				}
+				if !visited[dependency] {
+					next = append(next, dependency)
+				}
+			}
+		}
+
+		if level, err = s.repo.GetTasksByIDs(ctx, next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cyclePath - цикл через id в виде "a -> b -> a"
+func cyclePath(id primitive.ObjectID, via map[primitive.ObjectID]primitive.ObjectID) string {
+	path := []string{id.Hex()}
+	for current := via[id]; current != id; current = via[current] {
+		path = append(path, current.Hex())
+	}
+	path = append(path, id.Hex())
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return strings.Join(path, " -> ")
+}
+
+// missingID - первый из ids, которого нет среди todos
+func missingID(ids []primitive.ObjectID, todos []*entity.Todo) (primitive.ObjectID, bool) {
+	found := make(map[primitive.ObjectID]bool, len(todos))
+	for _, todo := range todos {
+		found[todo.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			return id, true
+		}
+	}
+	return primitive.NilObjectID, false
+}
+
+// fillBlocked находит у задач незакрытые зависимости одним запросом. Удаленные зависимости задачу не блокируют
+func (s *todoService) fillBlocked(ctx context.Context, todos []*entity.Todo) error {
+	var ids []primitive.ObjectID
+	seen := make(map[primitive.ObjectID]bool)
+	for _, todo := range todos {
+		for _, dependency := range todo.DependsOn {
+			if !seen[dependency] {
+				seen[dependency] = true
+				ids = append(ids, dependency)
+			}
+		}
+	}
+
+	dependencies, err := s.repo.GetTasksByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	open := make(map[primitive.ObjectID]bool, len(dependencies))
+	for _, dependency := range dependencies {
+		open[dependency.ID] = !dependency.Status.IsClosed()
+	}
+	for _, todo := range todos {
+		todo.BlockedBy = nil
+		for _, dependency := range todo.DependsOn {
+			if open[dependency] {
+				todo.BlockedBy = append(todo.BlockedBy, dependency)
+			}
+		}
+	}
+	return nil
+}
+
+// removeTask удаляет задачу и ссылки на нее из depends_on других задач, а исполнителю сообщает об удалении
+func (s *todoService) removeTask(ctx context.Context, todo *entity.Todo) error {
+	if err := s.repo.DeleteTodo(ctx, todo.ID); err != nil {
+		return err
+	}
+	s.notifyDeleted(ctx, todo)
+	return s.repo.RemoveDependency(ctx, todo.ID)
+}
+
+// PlanTasks - задачи ids вместе со всеми их незакрытыми зависимостями в порядке выполнения.
+// Закрытые зависимости уже выполнены, поэтому в план не попадают, как и то, от чего зависят они сами
+func (s *todoService) PlanTasks(ctx context.Context, ids []primitive.ObjectID) ([]*entity.Todo, error) {
+	requested, err := s.repo.GetTasksByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	requested = visibleTodos(ctx, requested)
+	if missing, ok := missingID(ids, requested); ok {
+		return nil, fmt.Errorf("%w: %s", errors.ErrNotFound, missing.Hex())
+	}
+
+	var tasks []*entity.Todo
+	seen := make(map[primitive.ObjectID]bool)
+	for level := requested; len(level) > 0; {
+		var next []primitive.ObjectID
+		for _, todo := range level {
+			if seen[todo.ID] {
+				continue
+			}
+			seen[todo.ID] = true
+			tasks = append(tasks, todo)
+
+			for _, dependency := range todo.DependsOn {
+				if !seen[dependency] {
+					next = append(next, dependency)
+				}
+			}
+		}
+
+		dependencies, err := s.repo.GetTasksByIDs(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		level = openTodos(visibleTodos(ctx, dependencies))
+	}
+
+	plan, err := entity.PlanOrder(tasks)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fillDerived(ctx, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
@@ -0,0 +1,151 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newDependent(t *testing.T, s services.TodoService, title string, dependsOn ...*entity.Todo) *entity.Todo {
+	t.Helper()
+
+	// с полуночи задача уже попадает в активные
+	fields := entity.TodoFields{Title: title, ActiveAt: time.Now().UTC().Truncate(24 * time.Hour)}
+	for _, dependency := range dependsOn {
+		fields.DependsOn = append(fields.DependsOn, dependency.ID)
+	}
+	todo, err := s.CreateNewTodo(context.Background(), fields)
+	require.NoError(t, err)
+	return todo
+}
+
+func titles(todos []*entity.Todo) []string {
+	result := make([]string, 0, len(todos))
+	for _, todo := range todos {
+		result = append(result, todo.Title)
+	}
+	return result
+}
+
+func TestDependencyCycle(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	boxes := newDependent(t, s, "Купить коробки")
+	pack := newDependent(t, s, "Упаковать вещи", boxes)
+	move := newDependent(t, s, "Переезд", pack)
+
+	// коробки -> переезд -> упаковка -> коробки
+	dependsOn := []primitive.ObjectID{move.ID}
+	_, err := s.PatchTodo(ctx, boxes.ID, &entity.TodoPatch{DependsOn: &dependsOn})
+	require.ErrorIs(t, err, errors.ErrDependencyCycle)
+	assert.Contains(t, err.Error(), boxes.ID.Hex()+" -> "+move.ID.Hex()+" -> "+pack.ID.Hex()+" -> "+boxes.ID.Hex())
+
+	_, err = s.UpdateTodo(ctx, boxes.ID, entity.TodoFields{Title: "Купить коробки", ActiveAt: time.Now(), DependsOn: dependsOn})
+	assert.ErrorIs(t, err, errors.ErrDependencyCycle)
+
+	dependsOn = []primitive.ObjectID{boxes.ID}
+	_, err = s.PatchTodo(ctx, boxes.ID, &entity.TodoPatch{DependsOn: &dependsOn})
+	assert.ErrorIs(t, err, errors.ErrDependencyCycle)
+
+	// ромб циклом не считается
+	todo, err := s.UpdateTodo(ctx, move.ID, entity.TodoFields{
+		Title:     "Переезд",
+		ActiveAt:  time.Now(),
+		DependsOn: []primitive.ObjectID{pack.ID, boxes.ID},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []primitive.ObjectID{pack.ID, boxes.ID}, todo.BlockedBy)
+
+	_, err = s.CreateNewTodo(ctx, entity.TodoFields{
+		Title:     "Новоселье",
+		ActiveAt:  time.Now(),
+		DependsOn: []primitive.ObjectID{primitive.NewObjectID()},
+	})
+	assert.ErrorIs(t, err, errors.ErrDependencyNotFound)
+}
+
+func TestBlockedTasks(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	boxes := newDependent(t, s, "Купить коробки")
+	pack := newDependent(t, s, "Упаковать вещи", boxes)
+	assert.True(t, pack.HasOpenDependencies())
+
+	page, err := s.GetTasksByStatus(ctx, entity.StatusFilterActive, entity.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Купить коробки"}, titles(page.Tasks))
+
+	page, err = s.GetTasksByStatus(ctx, entity.StatusFilterActive, entity.ListOptions{IncludeBlocked: true})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Купить коробки", "Упаковать вещи"}, titles(page.Tasks))
+	for _, todo := range page.Tasks {
+		assert.Equal(t, todo.ID == pack.ID, todo.HasOpenDependencies(), todo.Title)
+	}
+
+	require.NoError(t, s.MarkAsCompleted(ctx, boxes.ID))
+	todo, err := s.GetTaskByID(ctx, pack.ID)
+	require.NoError(t, err)
+	assert.False(t, todo.HasOpenDependencies())
+
+	page, err = s.GetTasksByStatus(ctx, entity.StatusFilterActive, entity.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Упаковать вещи"}, titles(page.Tasks))
+}
+
+func TestDeleteRemovesDependency(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	boxes := newDependent(t, s, "Купить коробки")
+	pack := newDependent(t, s, "Упаковать вещи", boxes)
+
+	require.NoError(t, s.DeleteTodo(ctx, boxes.ID))
+	todo, err := s.GetTaskByID(ctx, pack.ID)
+	require.NoError(t, err)
+	assert.Empty(t, todo.DependsOn)
+	assert.False(t, todo.HasOpenDependencies())
+}
+
+func TestPlanTasks(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	keys := newDependent(t, s, "Забрать ключи")
+	boxes := newDependent(t, s, "Купить коробки", keys)
+	pack := newDependent(t, s, "Упаковать вещи", boxes)
+	truck := newDependent(t, s, "Заказать машину")
+	move := newDependent(t, s, "Переезд", pack, truck)
+	newDependent(t, s, "Новоселье", move)
+
+	// выполненные зависимости в план не попадают
+	require.NoError(t, s.MarkAsCompleted(ctx, keys.ID))
+
+	plan, err := s.PlanTasks(ctx, []primitive.ObjectID{move.ID})
+	require.NoError(t, err)
+	require.Len(t, plan, 4)
+	assert.Equal(t, move.ID, plan[3].ID)
+	assert.Less(t, indexOf(plan, boxes), indexOf(plan, pack))
+	assert.Contains(t, titles(plan), "Заказать машину")
+
+	_, err = s.PlanTasks(ctx, []primitive.ObjectID{move.ID, primitive.NewObjectID()})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+}
+
+func indexOf(todos []*entity.Todo, todo *entity.Todo) int {
+	for i := range todos {
+		if todos[i].ID == todo.ID {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"sort"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (s *todoService) CreateList(ctx context.Context, name string) (*entity.List, error) {
+	return s.repo.CreateList(ctx, entity.NewList(name))
+}
+
+func (s *todoService) GetList(ctx context.Context, id primitive.ObjectID) (*entity.List, error) {
+	return s.repo.GetList(ctx, id)
+}
+
+// GetLists - свои списки и чужие, в которых пользователь участник, по имени. У каждого списка - роль в нем
+func (s *todoService) GetLists(ctx context.Context, includeArchived bool) ([]*entity.List, error) {
+	lists, err := s.repo.GetLists(ctx, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	for _, list := range lists {
+		list.Role = entity.RoleOwner
+	}
+
+	userID := entity.OwnerFromContext(ctx)
+	if userID == nil {
+		return lists, nil
+	}
+	members, err := s.repo.GetMemberships(ctx, *userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return lists, nil
+	}
+
+	for _, member := range members {
+		list, err := s.repo.GetList(entity.WithOwner(ctx, member.OwnerID), member.ListID)
+		// список могли удалить между запросами
+		if stderrors.Is(err, errors.ErrListNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if list.IsArchived() && !includeArchived {
+			continue
+		}
+		list.Role = member.Role
+		lists = append(lists, list)
+	}
+
+	sort.SliceStable(lists, func(i, j int) bool {
+		return lists[i].Name < lists[j].Name
+	})
+	return lists, nil
+}
+
+// UpdateList переименовывает список и переносит его в архив или из архива. Это может только владелец списка
+func (s *todoService) UpdateList(ctx context.Context, id primitive.ObjectID, update entity.ListUpdate) (*entity.List, error) {
+	ctx, list, err := s.openOwnList(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	list.Apply(update, time.Now())
+	if list, err = s.repo.UpdateList(ctx, list); err != nil {
+		return nil, err
+	}
+	list.Role = entity.RoleOwner
+	return list, nil
+}
+
+// DeleteList удаляет пустой список. С force задачи списка удаляются вместе с ним, без политики подзадач:
+// все подзадачи лежат в том же списке и удаляются тоже. Участники и приглашения списка удаляются вместе с ним
+func (s *todoService) DeleteList(ctx context.Context, id primitive.ObjectID, force bool) error {
+	ctx, _, err := s.openOwnList(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	page, err := s.repo.GetAllTasks(ctx, entity.ListOptions{ListID: &id})
+	if err != nil {
+		return err
+	}
+	if len(page.Tasks) > 0 && !force {
+		return errors.ErrListNotEmpty
+	}
+
+	for _, todo := range page.Tasks {
+		if err := s.removeTask(ctx, todo); err != nil && !stderrors.Is(err, errors.ErrNotFound) {
+			return err
+		}
+	}
+	if err := s.repo.RemoveMembers(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteList(ctx, id)
+}
+
+// MoveTodo переносит задачу со всеми подзадачами в список listID, nil - в список по умолчанию.
+// Подзадача при переносе отделяется от родителя и становится задачей верхнего уровня
+func (s *todoService) MoveTodo(ctx context.Context, id primitive.ObjectID, listID *primitive.ObjectID) (*entity.Todo, error) {
+	// перенос меняет список задачи, а участнику доступен только один список владельца
+	if err := authorizeOwner(ctx); err != nil {
+		return nil, err
+	}
+	todo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkList(ctx, listID); err != nil {
+		return nil, err
+	}
+	if todo.InList(listID) {
+		return s.withDerived(ctx, todo)
+	}
+
+	descendants, err := s.descendants(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.MoveTasks(ctx, append([]primitive.ObjectID{id}, todoIDs(descendants)...), listID); err != nil {
+		return nil, err
+	}
+	if todo.ParentID != nil {
+		if err := s.repo.SetParent(ctx, []primitive.ObjectID{id}, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	moved, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// перенос меняет и подзадачи, их исполнители тоже узнают о нем
+	s.notify(ctx, todo, moved)
+	for _, descendant := range descendants {
+		after := *descendant
+		after.ListID = listID
+		s.notify(ctx, descendant, &after)
+	}
+	return s.withDerived(ctx, moved)
+}
+
+// checkList проверяет, что в список listID можно добавлять задачи: он есть и не в архиве
+func (s *todoService) checkList(ctx context.Context, listID *primitive.ObjectID) error {
+	if listID == nil {
+		return nil
+	}
+
+	list, err := s.repo.GetList(ctx, *listID)
+	if err != nil {
+		return err
+	}
+	if list.IsArchived() {
+		return errors.ErrListArchived
+	}
+	return nil
+}
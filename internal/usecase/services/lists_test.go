@@ -0,0 +1,119 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestArchivedListIsReadOnly(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	list, err := s.CreateList(ctx, " Работа ")
+	require.NoError(t, err)
+	assert.Equal(t, "Работа", list.Name)
+
+	_, err = s.CreateNewTodo(ctx, entity.TodoFields{Title: "Отчет", ActiveAt: time.Now(), ListID: &primitive.NilObjectID})
+	assert.ErrorIs(t, err, errors.ErrListNotFound)
+
+	parent, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Отчет", ActiveAt: time.Now(), ListID: &list.ID})
+	require.NoError(t, err)
+
+	archived := true
+	list, err = s.UpdateList(ctx, list.ID, entity.ListUpdate{Archived: &archived})
+	require.NoError(t, err)
+	assert.True(t, list.IsArchived())
+
+	_, err = s.CreateNewTodo(ctx, entity.TodoFields{Title: "Деплой", ActiveAt: time.Now(), ListID: &list.ID})
+	assert.ErrorIs(t, err, errors.ErrListArchived)
+	_, err = s.CreateSubtask(ctx, parent.ID, entity.TodoFields{Title: "Таблица", ActiveAt: time.Now()})
+	assert.ErrorIs(t, err, errors.ErrListArchived)
+
+	// из архива список возвращается, а подзадача создается в списке родителя
+	archived = false
+	_, err = s.UpdateList(ctx, list.ID, entity.ListUpdate{Archived: &archived})
+	require.NoError(t, err)
+	child, err := s.CreateSubtask(ctx, parent.ID, entity.TodoFields{Title: "Таблица", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	assert.True(t, child.InList(&list.ID))
+
+	page, err := s.GetSubtasks(ctx, parent.ID, entity.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Таблица"}, titles(page.Tasks))
+}
+
+func TestDeleteList(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	list, err := s.CreateList(ctx, "Работа")
+	require.NoError(t, err)
+	inList, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Отчет", ActiveAt: time.Now(), ListID: &list.ID})
+	require.NoError(t, err)
+	_, err = s.CreateSubtask(ctx, inList.ID, entity.TodoFields{Title: "Таблица", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	dependent, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Отправить отчет", ActiveAt: time.Now(), DependsOn: []primitive.ObjectID{inList.ID}})
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.DeleteList(ctx, list.ID, false), errors.ErrListNotEmpty)
+
+	// с force задачи списка удаляются вместе с подзадачами, а ссылки на них из других списков пропадают
+	require.NoError(t, s.DeleteList(ctx, list.ID, true))
+	_, err = s.GetList(ctx, list.ID)
+	assert.ErrorIs(t, err, errors.ErrListNotFound)
+	page, err := s.GetAllTasks(ctx, entity.ListOptions{ListID: &list.ID})
+	require.NoError(t, err)
+	assert.Empty(t, page.Tasks)
+
+	dependent, err = s.GetTaskByID(ctx, dependent.ID)
+	require.NoError(t, err)
+	assert.Empty(t, dependent.DependsOn)
+}
+
+func TestMoveTodo(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	parent, child, grandchild := tree(t, s)
+
+	list, err := s.CreateList(ctx, "Переезд")
+	require.NoError(t, err)
+
+	// задача переезжает вместе со всеми подзадачами
+	moved, err := s.MoveTodo(ctx, parent.ID, &list.ID)
+	require.NoError(t, err)
+	assert.True(t, moved.InList(&list.ID))
+	require.NotNil(t, moved.Subtasks)
+	assert.Equal(t, 1, moved.Subtasks.Total)
+
+	page, err := s.GetAllTasks(ctx, entity.ListOptions{ListID: &list.ID})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Переезд", "Упаковать вещи", "Купить коробки"}, titles(page.Tasks))
+
+	// подзадача, перенесенная отдельно, отделяется от родителя
+	moved, err = s.MoveTodo(ctx, grandchild.ID, nil)
+	require.NoError(t, err)
+	assert.Nil(t, moved.ListID)
+	assert.Nil(t, moved.ParentID)
+
+	child, err = s.GetTaskByID(ctx, child.ID)
+	require.NoError(t, err)
+	assert.Nil(t, child.Subtasks)
+
+	// в список с такой же задачей перенести нельзя
+	_, err = s.CreateNewTodo(ctx, entity.TodoFields{Title: "Упаковать вещи", ActiveAt: child.ActiveAt})
+	require.NoError(t, err)
+	_, err = s.MoveTodo(ctx, child.ID, nil)
+	assert.ErrorIs(t, err, errors.ErrTodoExists)
+
+	_, err = s.MoveTodo(ctx, child.ID, &primitive.NilObjectID)
+	assert.ErrorIs(t, err, errors.ErrListNotFound)
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OpenList находит список id среди своих и тех, где пользователь участник. Для чужого списка возвращается контекст,
+// в котором владелец - владелец списка, а entity.Access ограничивает запрос этим списком и ролью участника.
+// Без участия чужой список не существует
+func (s *todoService) OpenList(ctx context.Context, id primitive.ObjectID) (context.Context, *entity.List, error) {
+	list, err := s.repo.GetList(ctx, id)
+	if err == nil {
+		list.Role = entity.RoleOwner
+		return ctx, list, nil
+	}
+
+	userID := entity.OwnerFromContext(ctx)
+	if !stderrors.Is(err, errors.ErrListNotFound) || userID == nil || entity.AccessFromContext(ctx) != nil {
+		return nil, nil, err
+	}
+
+	member, err := s.repo.GetMembership(ctx, id, *userID)
+	if stderrors.Is(err, errors.ErrMemberNotFound) {
+		return nil, nil, errors.ErrListNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shared := entity.WithAccess(entity.WithOwner(ctx, member.OwnerID), entity.Access{
+		ListID: id,
+		UserID: *userID,
+		Role:   member.Role,
+	})
+	if list, err = s.repo.GetList(shared, id); err != nil {
+		return nil, nil, err
+	}
+	list.Role = member.Role
+	return shared, list, nil
+}
+
+// openOwnList - OpenList для операций, доступных только владельцу списка
+func (s *todoService) openOwnList(ctx context.Context, id primitive.ObjectID) (context.Context, *entity.List, error) {
+	shared, list, err := s.OpenList(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if list.Role != entity.RoleOwner {
+		return nil, nil, fmt.Errorf("%w: only the list owner can do this", errors.ErrForbidden)
+	}
+	return shared, list, nil
+}
+
+// authorizeEdit - участнику чужого списка менять задачи можно только с ролью, которая это позволяет
+func authorizeEdit(ctx context.Context) error {
+	if access := entity.AccessFromContext(ctx); access != nil && !access.Role.CanEdit() {
+		return fmt.Errorf("%w: role %s is read-only", errors.ErrForbidden, access.Role)
+	}
+	return nil
+}
+
+// authorizeOwner - операции над всеми задачами владельца участнику чужого списка недоступны
+func authorizeOwner(ctx context.Context) error {
+	if entity.AccessFromContext(ctx) != nil {
+		return fmt.Errorf("%w: only the list owner can do this", errors.ErrForbidden)
+	}
+	return nil
+}
+
+// authorizeTask проверяет доступ к задаче id: участнику чужого списка видны только задачи этого списка,
+// остальные задачи владельца для него не существуют. С edit задачу еще и меняют
+func (s *todoService) authorizeTask(ctx context.Context, id primitive.ObjectID, edit bool) error {
+	access := entity.AccessFromContext(ctx)
+	if access == nil {
+		return nil
+	}
+
+	todo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !todo.InList(&access.ListID) {
+		return errors.ErrNotFound
+	}
+	if edit {
+		return authorizeEdit(ctx)
+	}
+	return nil
+}
+
+// accessList - список, которым ограничен запрос участника: listID из запроса заменяется на него
+func accessList(ctx context.Context, listID *primitive.ObjectID) *primitive.ObjectID {
+	if access := entity.AccessFromContext(ctx); access != nil {
+		return &access.ListID
+	}
+	return listID
+}
+
+// visibleTodos - задачи, которые видит запрос: участнику чужого списка - только задачи этого списка
+func visibleTodos(ctx context.Context, todos []*entity.Todo) []*entity.Todo {
+	access := entity.AccessFromContext(ctx)
+	if access == nil {
+		return todos
+	}
+
+	visible := make([]*entity.Todo, 0, len(todos))
+	for _, todo := range todos {
+		if todo.InList(&access.ListID) {
+			visible = append(visible, todo)
+		}
+	}
+	return visible
+}
+
+// GetMembers - владелец списка и участники по дате добавления. Участников видят все, у кого есть доступ к списку
+func (s *todoService) GetMembers(ctx context.Context, listID primitive.ObjectID) ([]*entity.Membership, error) {
+	shared, list, err := s.OpenList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.repo.GetMembers(shared, listID)
+	if err != nil {
+		return nil, err
+	}
+	if list.OwnerID != nil {
+		owner := &entity.Membership{
+			ListID:    listID,
+			OwnerID:   *list.OwnerID,
+			UserID:    *list.OwnerID,
+			Role:      entity.RoleOwner,
+			CreatedAt: list.CreatedAt,
+		}
+		members = append([]*entity.Membership{owner}, members...)
+	}
+
+	for _, member := range members {
+		user, err := s.repo.GetUserByID(ctx, member.UserID)
+		if stderrors.Is(err, errors.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		member.Email = user.Email
+		member.Username = user.Username
+	}
+	return members, nil
+}
+
+// SetMemberRole меняет роль участника. Роли меняет только владелец списка
+func (s *todoService) SetMemberRole(ctx context.Context, listID, userID primitive.ObjectID, role string) (*entity.Membership, error) {
+	parsed, err := entity.ParseMemberRole(role)
+	if err != nil {
+		return nil, err
+	}
+	shared, _, err := s.openOwnList(ctx, listID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.SetMemberRole(shared, listID, userID, parsed)
+}
+
+// RemoveMember исключает участника из списка. Владелец исключает любого, участник - только себя
+func (s *todoService) RemoveMember(ctx context.Context, listID, userID primitive.ObjectID) error {
+	shared, list, err := s.OpenList(ctx, listID)
+	if err != nil {
+		return err
+	}
+	if access := entity.AccessFromContext(shared); list.Role != entity.RoleOwner && access.UserID != userID {
+		return fmt.Errorf("%w: only the list owner can remove other members", errors.ErrForbidden)
+	}
+	return s.repo.RemoveMember(shared, listID, userID)
+}
+
+// CreateInvitation создает приглашение в список с ролью role. Токен показывается один раз, хранится только его хеш
+func (s *todoService) CreateInvitation(ctx context.Context, listID primitive.ObjectID, role string) (*entity.Invitation, string, error) {
+	if entity.OwnerFromContext(ctx) == nil {
+		return nil, "", errors.ErrUnauthorized
+	}
+	parsed, err := entity.ParseMemberRole(role)
+	if err != nil {
+		return nil, "", err
+	}
+	shared, list, err := s.openOwnList(ctx, listID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := token.NewInvitation()
+	if err != nil {
+		return nil, "", err
+	}
+	invitation := entity.NewInvitation(list, parsed, time.Now())
+	invitation.Hash = token.HashSecret(secret)
+
+	created, err := s.repo.CreateInvitation(shared, invitation)
+	if err != nil {
+		return nil, "", err
+	}
+	return created, secret, nil
+}
+
+// AcceptInvitation добавляет пользователя в список по токену приглашения и возвращает этот список
+func (s *todoService) AcceptInvitation(ctx context.Context, secret string) (*entity.List, error) {
+	userID := entity.OwnerFromContext(ctx)
+	if userID == nil {
+		return nil, errors.ErrUnauthorized
+	}
+
+	invitation, err := s.repo.TakeInvitation(ctx, token.HashSecret(secret))
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Expired(time.Now()) {
+		return nil, errors.ErrInvitationNotFound
+	}
+	if invitation.OwnerID == *userID {
+		return nil, errors.ErrMemberExists
+	}
+
+	_, err = s.repo.AddMember(ctx, &entity.Membership{
+		ListID:    invitation.ListID,
+		OwnerID:   invitation.OwnerID,
+		UserID:    *userID,
+		Role:      invitation.Role,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, list, err := s.OpenList(ctx, invitation.ListID)
+	return list, err
+}
@@ -0,0 +1,166 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sharingFixture - список alice, в который приглашен bob с ролью role
+type sharingFixture struct {
+	repository repo.TodoRepository
+	service    services.TodoService
+	alice      context.Context
+	bob        context.Context
+	bobID      primitive.ObjectID
+	list       *entity.List
+}
+
+func newSharingFixture(t *testing.T, role entity.Role) *sharingFixture {
+	t.Helper()
+	repository := repo.NewMemoryRepository()
+	s := services.NewTodoService(repository, services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	users := make([]primitive.ObjectID, 0, 2)
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		user, err := repository.CreateUser(context.Background(), &entity.User{Email: email})
+		require.NoError(t, err)
+		users = append(users, user.ID)
+	}
+	f := &sharingFixture{
+		repository: repository,
+		service:    s,
+		alice:      entity.WithOwner(context.Background(), users[0]),
+		bob:        entity.WithOwner(context.Background(), users[1]),
+		bobID:      users[1],
+	}
+
+	list, err := s.CreateList(f.alice, "Семья")
+	require.NoError(t, err)
+	f.list = list
+
+	_, secret, err := s.CreateInvitation(f.alice, list.ID, string(role))
+	require.NoError(t, err)
+	_, err = s.AcceptInvitation(f.bob, secret)
+	require.NoError(t, err)
+	return f
+}
+
+// open - контекст bob в общем списке, как его получают обработчики задач
+func (f *sharingFixture) open(t *testing.T) context.Context {
+	t.Helper()
+	ctx, list, err := f.service.OpenList(f.bob, f.list.ID)
+	require.NoError(t, err)
+	require.Equal(t, f.list.ID, list.ID)
+	return ctx
+}
+
+func TestViewerCannotChangeTasks(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleViewer)
+	todo, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Молоко", ActiveAt: time.Now(), ListID: &f.list.ID})
+	require.NoError(t, err)
+
+	bob := f.open(t)
+	found, err := f.service.GetTaskByID(bob, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Молоко", found.Title)
+
+	_, err = f.service.UpdateTodo(bob, todo.ID, entity.TodoFields{Title: "Кефир", ActiveAt: time.Now()})
+	assert.ErrorIs(t, err, errors.ErrForbidden)
+	assert.ErrorIs(t, f.service.DeleteTodo(bob, todo.ID), errors.ErrForbidden)
+	assert.ErrorIs(t, f.service.MarkAsCompleted(bob, todo.ID), errors.ErrForbidden)
+	_, err = f.service.CreateNewTodo(bob, entity.TodoFields{Title: "Хлеб", ActiveAt: time.Now(), ListID: &f.list.ID})
+	assert.ErrorIs(t, err, errors.ErrForbidden)
+
+	// после повышения до редактора те же вызовы проходят
+	_, err = f.service.SetMemberRole(f.alice, f.list.ID, f.bobID, "editor")
+	require.NoError(t, err)
+	bob = f.open(t)
+	_, err = f.service.UpdateTodo(bob, todo.ID, entity.TodoFields{Title: "Кефир", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, f.service.MarkAsCompleted(bob, todo.ID))
+
+	// задача создается от имени владельца списка
+	created, err := f.service.CreateNewTodo(bob, entity.TodoFields{Title: "Хлеб", ActiveAt: time.Now(), ListID: &f.list.ID})
+	require.NoError(t, err)
+	page, err := f.service.GetAllTasks(f.alice, entity.ListOptions{ListID: &f.list.ID})
+	require.NoError(t, err)
+	assert.Contains(t, titles(page.Tasks), created.Title)
+	require.NoError(t, f.service.DeleteTodo(bob, created.ID))
+}
+
+func TestMemberSeesOnlySharedList(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleEditor)
+	other, err := f.service.CreateList(f.alice, "Работа")
+	require.NoError(t, err)
+	private, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Отчет", ActiveAt: time.Now(), ListID: &other.ID})
+	require.NoError(t, err)
+	inbox, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Входящие", ActiveAt: time.Now()})
+	require.NoError(t, err)
+
+	bob := f.open(t)
+	_, err = f.service.GetTaskByID(bob, private.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+	assert.ErrorIs(t, f.service.DeleteTodo(bob, inbox.ID), errors.ErrNotFound)
+	_, err = f.service.PlanTasks(bob, []primitive.ObjectID{private.ID})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	// запросы списков ограничены общим списком, что бы ни пришло в opts
+	page, err := f.service.GetAllTasks(bob, entity.ListOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Tasks)
+
+	// зависимость от чужой задачи для участника не существует
+	_, err = f.service.CreateNewTodo(bob, entity.TodoFields{
+		Title: "Купить", ActiveAt: time.Now(), ListID: &f.list.ID, DependsOn: []primitive.ObjectID{private.ID},
+	})
+	assert.ErrorIs(t, err, errors.ErrDependencyNotFound)
+
+	_, err = f.service.MoveTodo(bob, inbox.ID, &f.list.ID)
+	assert.ErrorIs(t, err, errors.ErrForbidden)
+	_, err = f.service.GetTags(bob)
+	assert.ErrorIs(t, err, errors.ErrForbidden)
+
+	_, _, err = f.service.OpenList(f.bob, other.ID)
+	assert.ErrorIs(t, err, errors.ErrListNotFound)
+}
+
+func TestSharedListManagement(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleEditor)
+
+	lists, err := f.service.GetLists(f.bob, false)
+	require.NoError(t, err)
+	require.Len(t, lists, 1)
+	assert.Equal(t, f.list.ID, lists[0].ID)
+	assert.Equal(t, entity.RoleEditor, lists[0].Role)
+
+	_, err = f.service.UpdateList(f.bob, f.list.ID, entity.ListUpdate{})
+	assert.ErrorIs(t, err, errors.ErrForbidden)
+	assert.ErrorIs(t, f.service.DeleteList(f.bob, f.list.ID, true), errors.ErrForbidden)
+	_, _, err = f.service.CreateInvitation(f.bob, f.list.ID, "viewer")
+	assert.ErrorIs(t, err, errors.ErrForbidden)
+	_, err = f.service.SetMemberRole(f.alice, f.list.ID, f.bobID, "owner")
+	assert.ErrorIs(t, err, errors.ErrInvalidRole)
+
+	// владелец не может вступить в свой список по своему же приглашению
+	_, secret, err := f.service.CreateInvitation(f.alice, f.list.ID, "viewer")
+	require.NoError(t, err)
+	_, err = f.service.AcceptInvitation(f.alice, secret)
+	assert.ErrorIs(t, err, errors.ErrMemberExists)
+
+	// вместе со списком пропадает и участие в нем
+	require.NoError(t, f.service.DeleteList(f.alice, f.list.ID, true))
+	lists, err = f.service.GetLists(f.bob, false)
+	require.NoError(t, err)
+	assert.Empty(t, lists)
+	_, _, err = f.service.OpenList(f.bob, f.list.ID)
+	assert.ErrorIs(t, err, errors.ErrListNotFound)
+}
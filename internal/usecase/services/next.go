@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+)
+
+// NextTasks - до limit активных задач, отсортированных по оценке: приоритет, просрочка active_at и возраст задачи.
+// Задачи с незакрытыми зависимостями делать еще нельзя, поэтому они не оцениваются, если opts.IncludeBlocked не задан
+func (s *todoService) NextTasks(ctx context.Context, limit int, opts entity.ListOptions) ([]*entity.RankedTask, error) {
+	opts.Limit, opts.Cursor = 0, ""
+	opts.ListID = accessList(ctx, opts.ListID)
+	page, err := s.repo.GetTasksByStatus(ctx, entity.StatusFilterActive, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := s.ranking.RankTasks(page.Tasks, time.Now())
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	todos := make([]*entity.Todo, 0, len(ranked))
+	for _, task := range ranked {
+		todos = append(todos, task.Task)
+	}
+	if err := s.fillDerived(ctx, todos); err != nil {
+		return nil, err
+	}
+	return ranked, nil
+}
@@ -0,0 +1,59 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNextTasks(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.RankWeights{Priority: 10, Overdue: 2, Age: 0.5})
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	create := func(title string, priority entity.Priority, activeAt time.Time, tags ...string) *entity.Todo {
+		todo, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: title, Priority: priority, ActiveAt: activeAt, Tags: tags})
+		require.NoError(t, err)
+		return todo
+	}
+	review := create("Ревью", entity.PriorityNormal, today, "work")
+	create("Инцидент", entity.PriorityUrgent, today, "work")
+	create("Продукты", entity.PriorityLow, today)
+	create("Отпуск", entity.PriorityUrgent, today.AddDate(0, 0, 7))
+	_, err := s.CreateNewTodo(ctx, entity.TodoFields{
+		Title: "Релиз", Priority: entity.PriorityUrgent, ActiveAt: today, DependsOn: []primitive.ObjectID{review.ID},
+	})
+	require.NoError(t, err)
+
+	titles := func(ranked []*entity.RankedTask) []string {
+		var result []string
+		for _, task := range ranked {
+			result = append(result, task.Task.Title)
+		}
+		return result
+	}
+
+	// будущие и заблокированные задачи делать еще нельзя
+	ranked, err := s.NextTasks(ctx, 10, entity.ListOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Инцидент", "Ревью", "Продукты"}, titles(ranked))
+	require.Len(t, ranked[0].Explanation, 3)
+	assert.Equal(t, entity.RankFactorPriority, ranked[0].Explanation[0].Factor)
+	assert.Equal(t, 30.0, ranked[0].Explanation[0].Points)
+
+	ranked, err = s.NextTasks(ctx, 1, entity.ListOptions{IncludeBlocked: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"Инцидент"}, titles(ranked))
+
+	ranked, err = s.NextTasks(ctx, 10, entity.ListOptions{IncludeBlocked: true, TagsAll: []string{"work"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Инцидент", "Ревью"}, titles(ranked))
+	assert.Nil(t, ranked[0].Task.BlockedBy)
+}
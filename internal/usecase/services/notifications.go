@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetNotifications - до limit уведомлений пользователя из контекста, новые первыми
+func (s *todoService) GetNotifications(ctx context.Context, unreadOnly bool, limit int) ([]*entity.Notification, error) {
+	if entity.OwnerFromContext(ctx) == nil {
+		return nil, errors.ErrUnauthorized
+	}
+	return s.repo.GetNotifications(ctx, unreadOnly, limit)
+}
+
+// SetNotificationRead отмечает уведомление прочитанным или снова непрочитанным
+func (s *todoService) SetNotificationRead(ctx context.Context, id primitive.ObjectID, read bool) (*entity.Notification, error) {
+	if entity.OwnerFromContext(ctx) == nil {
+		return nil, errors.ErrUnauthorized
+	}
+
+	var readAt *time.Time
+	if read {
+		now := time.Now()
+		readAt = &now
+	}
+	return s.repo.SetNotificationRead(ctx, id, readAt)
+}
+
+// actor - пользователь, от имени которого идет запрос: участник чужого списка или сам владелец из контекста
+func actor(ctx context.Context) *primitive.ObjectID {
+	if access := entity.AccessFromContext(ctx); access != nil {
+		return &access.UserID
+	}
+	return entity.OwnerFromContext(ctx)
+}
+
+// hasAccess - userID видит задачи списка listID владельца из контекста: это сам владелец или участник списка.
+// Задачи списка по умолчанию видит только владелец
+func (s *todoService) hasAccess(ctx context.Context, listID *primitive.ObjectID, userID primitive.ObjectID) (bool, error) {
+	if ownerID := entity.OwnerFromContext(ctx); ownerID != nil && *ownerID == userID {
+		return true, nil
+	}
+	if listID == nil {
+		return false, nil
+	}
+
+	_, err := s.repo.GetMembership(ctx, *listID, userID)
+	if stderrors.Is(err, errors.ErrMemberNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// checkAssignee - исполнителем задачи списка listID может быть только тот, кто этот список видит
+func (s *todoService) checkAssignee(ctx context.Context, listID, assigneeID *primitive.ObjectID) error {
+	if assigneeID == nil || assigneeID.IsZero() {
+		return nil
+	}
+
+	ok, err := s.hasAccess(ctx, listID, *assigneeID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", errors.ErrInvalidAssignee, assigneeID.Hex())
+	}
+	return nil
+}
+
+// notify раскладывает по входящим изменение задачи before -> after, у новой задачи before nil. Изменение к этому
+// моменту уже сохранено, поэтому сбой записи уведомлений запрос не отменяет, а только попадает в лог:
+// иначе клиент получил бы ошибку и повторил уже выполненное изменение
+func (s *todoService) notify(ctx context.Context, before, after *entity.Todo) {
+	if err := s.writeNotifications(ctx, before, after); err != nil {
+		log.Printf("Ошибка при записи уведомлений об изменении задачи %s: %v", after.ID.Hex(), err)
+	}
+}
+
+// notifyDeleted сообщает исполнителю удаленной задачи todo, что ее больше нет, если удалил ее кто-то другой.
+// Сбой, как и в notify, только попадает в лог
+func (s *todoService) notifyDeleted(ctx context.Context, todo *entity.Todo) {
+	if err := s.writeDeletedNotification(ctx, todo); err != nil {
+		log.Printf("Ошибка при записи уведомления об удалении задачи %s: %v", todo.ID.Hex(), err)
+	}
+}
+
+// writeNotifications - назначение исполнителем, новые упоминания и изменение задачи кем-то кроме исполнителя.
+// Каждому получателю - одно уведомление, самому себе - ни одного. Упоминания тех, кто не видит задачу, пропускаются
+func (s *todoService) writeNotifications(ctx context.Context, before, after *entity.Todo) error {
+	actorID := actor(ctx)
+	if actorID == nil {
+		return nil
+	}
+
+	now := time.Now()
+	notified := map[primitive.ObjectID]bool{*actorID: true}
+	var notifications []*entity.Notification
+	add := func(kind entity.NotificationKind, userID primitive.ObjectID) error {
+		if notified[userID] {
+			return nil
+		}
+		ok, err := s.hasAccess(ctx, after.ListID, userID)
+		if err != nil || !ok {
+			return err
+		}
+		notified[userID] = true
+		notifications = append(notifications, entity.NewNotification(kind, after, userID, *actorID, now))
+		return nil
+	}
+
+	if after.AssigneeID != nil && (before == nil || before.AssigneeID == nil || *before.AssigneeID != *after.AssigneeID) {
+		if err := add(entity.NotificationAssigned, *after.AssigneeID); err != nil {
+			return err
+		}
+	}
+
+	mentioned := make(map[string]bool)
+	if before != nil {
+		for _, username := range before.Mentions() {
+			mentioned[username] = true
+		}
+	}
+	for _, username := range after.Mentions() {
+		if mentioned[username] {
+			continue
+		}
+		user, err := s.repo.GetUserByUsername(ctx, username)
+		if stderrors.Is(err, errors.ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := add(entity.NotificationMentioned, user.ID); err != nil {
+			return err
+		}
+	}
+
+	if before != nil && after.AssigneeID != nil {
+		if err := add(entity.NotificationTaskChanged, *after.AssigneeID); err != nil {
+			return err
+		}
+	}
+
+	if len(notifications) == 0 {
+		return nil
+	}
+	return s.repo.CreateNotifications(ctx, notifications)
+}
+
+func (s *todoService) writeDeletedNotification(ctx context.Context, todo *entity.Todo) error {
+	actorID := actor(ctx)
+	if actorID == nil || todo.AssigneeID == nil || *todo.AssigneeID == *actorID {
+		return nil
+	}
+
+	ok, err := s.hasAccess(ctx, todo.ListID, *todo.AssigneeID)
+	if err != nil || !ok {
+		return err
+	}
+	notification := entity.NewNotification(entity.NotificationTaskDeleted, todo, *todo.AssigneeID, *actorID, time.Now())
+	return s.repo.CreateNotifications(ctx, []*entity.Notification{notification})
+}
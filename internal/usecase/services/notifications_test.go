@@ -0,0 +1,191 @@
+package services_test
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// inbox - все уведомления пользователя ctx
+func inbox(t *testing.T, f *sharingFixture, ctx context.Context) []*entity.Notification {
+	t.Helper()
+	notifications, err := f.service.GetNotifications(ctx, false, 100)
+	require.NoError(t, err)
+	return notifications
+}
+
+func TestAssigneeNotifications(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleEditor)
+	aliceID := *entity.OwnerFromContext(f.alice)
+
+	todo, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{
+		Title: "Молоко", ActiveAt: time.Now(), ListID: &f.list.ID, AssigneeID: &f.bobID,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, todo.AssigneeID)
+
+	notifications := inbox(t, f, f.bob)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, entity.NotificationAssigned, notifications[0].Kind)
+	assert.Equal(t, todo.ID, notifications[0].TaskID)
+	assert.Equal(t, aliceID, notifications[0].ActorID)
+	assert.Empty(t, inbox(t, f, f.alice))
+
+	// свои изменения в свои входящие не попадают
+	bob := f.open(t)
+	title := "Молоко 2л"
+	_, err = f.service.PatchTodo(bob, todo.ID, &entity.TodoPatch{Title: &title})
+	require.NoError(t, err)
+	assert.Len(t, inbox(t, f, f.bob), 1)
+	assert.Empty(t, inbox(t, f, f.alice))
+
+	require.NoError(t, f.service.MarkAsCompleted(f.alice, todo.ID))
+	notifications = inbox(t, f, f.bob)
+	require.Len(t, notifications, 2)
+	assert.Equal(t, entity.NotificationTaskChanged, notifications[0].Kind)
+	assert.Equal(t, "Молоко 2л", notifications[0].Title)
+
+	// снять исполнителя можно, а назначить - только того, кто видит список
+	none := primitive.NilObjectID
+	_, err = f.service.PatchTodo(f.alice, todo.ID, &entity.TodoPatch{AssigneeID: &none})
+	require.NoError(t, err)
+	stranger := primitive.NewObjectID()
+	_, err = f.service.PatchTodo(f.alice, todo.ID, &entity.TodoPatch{AssigneeID: &stranger})
+	assert.ErrorIs(t, err, errors.ErrInvalidAssignee)
+	_, err = f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Отчет", ActiveAt: time.Now(), AssigneeID: &f.bobID})
+	assert.ErrorIs(t, err, errors.ErrInvalidAssignee)
+	assert.Len(t, inbox(t, f, f.bob), 2)
+}
+
+func TestMentionNotifications(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleEditor)
+	aliceID := *entity.OwnerFromContext(f.alice)
+	_, err := f.repository.SetUsername(context.Background(), aliceID, "alice")
+	require.NoError(t, err)
+	_, err = f.repository.SetUsername(context.Background(), f.bobID, "bob")
+	require.NoError(t, err)
+	carol, err := f.repository.CreateUser(context.Background(), &entity.User{Email: "carol@example.com", Username: "carol"})
+	require.NoError(t, err)
+
+	// carol список не видит, ее упоминание пропускается
+	todo, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{
+		Title: "Спросить @Bob и @carol", ActiveAt: time.Now(), ListID: &f.list.ID,
+	})
+	require.NoError(t, err)
+	notifications := inbox(t, f, f.bob)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, entity.NotificationMentioned, notifications[0].Kind)
+	assert.Empty(t, inbox(t, f, entity.WithOwner(context.Background(), carol.ID)))
+
+	// уведомляют только новые упоминания
+	description := "@bob, @alice посмотри"
+	_, err = f.service.PatchTodo(f.open(t), todo.ID, &entity.TodoPatch{Description: &description})
+	require.NoError(t, err)
+	assert.Len(t, inbox(t, f, f.bob), 1)
+	notifications = inbox(t, f, f.alice)
+	require.Len(t, notifications, 1)
+	assert.Equal(t, f.bobID, notifications[0].ActorID)
+
+	// упоминание в задаче списка по умолчанию участнику не видно
+	_, err = f.service.CreateNewTodo(f.alice, entity.TodoFields{Title: "Личное для @bob", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	assert.Len(t, inbox(t, f, f.bob), 1)
+}
+
+func TestNotificationRead(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleEditor)
+	_, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{
+		Title: "Молоко", ActiveAt: time.Now(), ListID: &f.list.ID, AssigneeID: &f.bobID,
+	})
+	require.NoError(t, err)
+	notification := inbox(t, f, f.bob)[0]
+
+	_, err = f.service.SetNotificationRead(f.alice, notification.ID, true)
+	assert.ErrorIs(t, err, errors.ErrNotificationNotFound)
+
+	read, err := f.service.SetNotificationRead(f.bob, notification.ID, true)
+	require.NoError(t, err)
+	assert.True(t, read.IsRead())
+	unread, err := f.service.GetNotifications(f.bob, true, 100)
+	require.NoError(t, err)
+	assert.Empty(t, unread)
+
+	read, err = f.service.SetNotificationRead(f.bob, notification.ID, false)
+	require.NoError(t, err)
+	assert.False(t, read.IsRead())
+
+	_, err = f.service.GetNotifications(context.Background(), false, 100)
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
+
+func TestMoveAndDeleteNotifications(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleEditor)
+	other, err := f.service.CreateList(f.alice, "Дача")
+	require.NoError(t, err)
+	_, secret, err := f.service.CreateInvitation(f.alice, other.ID, string(entity.RoleEditor))
+	require.NoError(t, err)
+	_, err = f.service.AcceptInvitation(f.bob, secret)
+	require.NoError(t, err)
+
+	todo, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{
+		Title: "Молоко", ActiveAt: time.Now(), ListID: &f.list.ID, AssigneeID: &f.bobID,
+	})
+	require.NoError(t, err)
+	require.Len(t, inbox(t, f, f.bob), 1)
+
+	// перенос в другой список - тоже изменение задачи
+	_, err = f.service.MoveTodo(f.alice, todo.ID, &other.ID)
+	require.NoError(t, err)
+	notifications := inbox(t, f, f.bob)
+	require.Len(t, notifications, 2)
+	assert.Equal(t, entity.NotificationTaskChanged, notifications[0].Kind)
+
+	require.NoError(t, f.service.DeleteTodo(f.alice, todo.ID))
+	notifications = inbox(t, f, f.bob)
+	require.Len(t, notifications, 3)
+	assert.Equal(t, entity.NotificationTaskDeleted, notifications[0].Kind)
+	assert.Equal(t, todo.ID, notifications[0].TaskID)
+	assert.Equal(t, "Молоко", notifications[0].Title)
+
+	// удаление своей задачи исполнителю ничего не присылает
+	own, err := f.service.CreateNewTodo(f.alice, entity.TodoFields{
+		Title: "Хлеб", ActiveAt: time.Now(), ListID: &f.list.ID, AssigneeID: &f.bobID,
+	})
+	require.NoError(t, err)
+	require.NoError(t, f.service.DeleteTodo(f.open(t), own.ID))
+	assert.Len(t, inbox(t, f, f.bob), 4)
+}
+
+// failingNotifications - хранилище, которое не может записать уведомления
+type failingNotifications struct {
+	repo.TodoRepository
+}
+
+func (failingNotifications) CreateNotifications(context.Context, []*entity.Notification) error {
+	return stderrors.New("notifications are down")
+}
+
+func TestNotificationFailureKeepsChange(t *testing.T) {
+	f := newSharingFixture(t, entity.RoleEditor)
+	s := services.NewTodoService(failingNotifications{f.repository}, services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	// изменение уже сохранено, поэтому сбой уведомлений его не отменяет и не превращается в ошибку
+	todo, err := s.CreateNewTodo(f.alice, entity.TodoFields{
+		Title: "Молоко", ActiveAt: time.Now(), ListID: &f.list.ID, AssigneeID: &f.bobID,
+	})
+	require.NoError(t, err)
+	title := "Молоко 2л"
+	_, err = s.PatchTodo(f.alice, todo.ID, &entity.TodoPatch{Title: &title})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteTodo(f.alice, todo.ID))
+	assert.Empty(t, inbox(t, f, f.bob))
+}
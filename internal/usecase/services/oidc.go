@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/oidc"
+	"github.com/nekidaz/todolist/pkg/token"
+)
+
+// OIDCService - вход через провайдера OpenID Connect. Учетная запись провайдера (iss, sub) привязывается
+// к пользователю приложения, и задачи принадлежат ему так же, как при входе по паролю
+type OIDCService interface {
+	// Begin начинает вход: адрес страницы провайдера и значения, которые клиент вернет в Complete
+	Begin(ctx context.Context) (string, oidc.Flow, error)
+	// Complete завершает вход по state и code из callback провайдера и выдает пару токенов приложения
+	Complete(ctx context.Context, flow oidc.Flow, state, code string) (*token.Pair, error)
+	// Authenticate возвращает владельца bearer-токена, выпущенного провайдером
+	Authenticate(ctx context.Context, bearer string) (*entity.User, error)
+}
+
+type oidcService struct {
+	repo     repo.UserRepository
+	provider *oidc.Provider
+	tokens   *token.Issuer
+}
+
+func NewOIDCService(repo repo.UserRepository, provider *oidc.Provider, tokens *token.Issuer) OIDCService {
+	return &oidcService{repo: repo, provider: provider, tokens: tokens}
+}
+
+func (s *oidcService) Begin(ctx context.Context) (string, oidc.Flow, error) {
+	flow, err := oidc.NewFlow()
+	if err != nil {
+		return "", oidc.Flow{}, err
+	}
+	return s.provider.AuthCodeURL(flow), flow, nil
+}
+
+func (s *oidcService) Complete(ctx context.Context, flow oidc.Flow, state, code string) (*token.Pair, error) {
+	// state из callback должен совпасть с сохраненным у клиента, иначе callback подсунут чужим входом
+	if flow.State == "" || state != flow.State || code == "" {
+		return nil, fmt.Errorf("%w: state mismatch", errors.ErrUnauthorized)
+	}
+
+	claims, err := s.provider.Exchange(ctx, code, flow)
+	if err != nil {
+		return nil, providerError(err)
+	}
+	user, err := s.userForClaims(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	return s.tokens.Issue(user.ID.Hex(), entity.TenantFromContext(ctx))
+}
+
+func (s *oidcService) Authenticate(ctx context.Context, bearer string) (*entity.User, error) {
+	claims, err := s.provider.Verify(ctx, bearer)
+	if err != nil {
+		return nil, providerError(err)
+	}
+	return s.userForClaims(ctx, claims)
+}
+
+// userForClaims находит пользователя учетной записи провайдера. Если его нет, привязывает учетную запись
+// к пользователю с тем же email, а если нет и такого - создает пользователя без пароля. И то, и другое - только
+// с подтвержденным провайдером адресом: иначе, указав у провайдера чужой email, можно было бы занять чужой аккаунт
+// или сам адрес, и его настоящий владелец уже не смог бы зарегистрироваться
+func (s *oidcService) userForClaims(ctx context.Context, claims *oidc.Claims) (*entity.User, error) {
+	user, err := s.repo.GetUserByIdentity(ctx, claims.Issuer, claims.Subject)
+	if !stderrors.Is(err, errors.ErrUserNotFound) {
+		return user, err
+	}
+
+	email, err := entity.NormalizeEmail(claims.Email)
+	if err != nil {
+		return nil, fmt.Errorf("%w: provider sent no valid email", errors.ErrUnauthorized)
+	}
+	if !claims.EmailVerified {
+		return nil, fmt.Errorf("%w: provider has not verified the email", errors.ErrUnauthorized)
+	}
+
+	user, err = s.repo.GetUserByEmail(ctx, email)
+	if stderrors.Is(err, errors.ErrUserNotFound) {
+		return s.repo.CreateUser(ctx, &entity.User{Email: email, OIDCIssuer: claims.Issuer, OIDCSubject: claims.Subject})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if user.OIDCSubject != "" {
+		return nil, fmt.Errorf("%w: email belongs to another account", errors.ErrUnauthorized)
+	}
+	if err := s.repo.LinkIdentity(ctx, user.ID, claims.Issuer, claims.Subject); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// providerError - отказ провайдера или неверный токен это 401, сбой сети до провайдера - внутренняя ошибка
+func providerError(err error) error {
+	if stderrors.Is(err, oidc.ErrInvalid) {
+		return fmt.Errorf("%w: %v", errors.ErrUnauthorized, err)
+	}
+	return err
+}
@@ -0,0 +1,149 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/oidc"
+	"github.com/nekidaz/todolist/pkg/oidc/oidctest"
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type oidcFixture struct {
+	idp  *oidctest.Server
+	repo repo.TodoRepository
+	auth services.AuthService
+	oidc services.OIDCService
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+	idp := oidctest.NewServer(t, "todolist", "secret")
+	provider, err := oidc.Discover(context.Background(), idp.Config("http://app.example.com/callback"), nil)
+	require.NoError(t, err)
+
+	repository := repo.NewMemoryRepository()
+	tokens := token.NewIssuer([]byte("secret"), time.Minute, time.Hour)
+	return &oidcFixture{
+		idp:  idp,
+		repo: repository,
+		auth: services.NewAuthService(repository, tokens),
+		oidc: services.NewOIDCService(repository, provider, tokens),
+	}
+}
+
+// signIn проходит вход через провайдер пользователем user и возвращает пользователя приложения
+func (f *oidcFixture) signIn(t *testing.T, user oidctest.User) (*entity.User, error) {
+	t.Helper()
+	ctx := context.Background()
+	f.idp.Login(user)
+
+	authURL, flow, err := f.oidc.Begin(ctx)
+	require.NoError(t, err)
+	code, state := f.idp.Authorize(t, authURL)
+
+	pair, err := f.oidc.Complete(ctx, flow, state, code)
+	if err != nil {
+		return nil, err
+	}
+	return f.auth.Authenticate(ctx, pair.AccessToken)
+}
+
+func TestOIDCSignInCreatesAndReusesUser(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+	todos := services.NewTodoService(f.repo, services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	legacy, err := todos.CreateNewTodo(ctx, entity.TodoFields{Title: "Отчет", ActiveAt: time.Now()})
+	require.NoError(t, err)
+
+	alice := oidctest.User{Subject: "42", Email: "Alice@Example.com", EmailVerified: true}
+	user, err := f.signIn(t, alice)
+	require.NoError(t, err)
+	assert.Equal(t, "alice@example.com", user.Email)
+	assert.Empty(t, user.PasswordHash)
+
+	// вход через провайдера, как и регистрация, задачи без владельца не забирает
+	_, err = todos.GetTaskByID(entity.WithOwner(ctx, user.ID), legacy.ID)
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	// email у провайдера сменился, но учетная запись та же
+	alice.Email = "alice@corp.example.com"
+	again, err := f.signIn(t, alice)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+
+	// пароля нет, войти по нему нельзя
+	_, err = f.auth.Login(ctx, "alice@example.com", "")
+	assert.ErrorIs(t, err, errors.ErrInvalidCredentials)
+}
+
+func TestOIDCLinksVerifiedEmailOnly(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+	local, err := f.auth.Register(ctx, "bob@example.com", "correct horse")
+	require.NoError(t, err)
+
+	_, err = f.signIn(t, oidctest.User{Subject: "7", Email: "bob@example.com"})
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+
+	linked, err := f.signIn(t, oidctest.User{Subject: "7", Email: "bob@example.com", EmailVerified: true})
+	require.NoError(t, err)
+	assert.Equal(t, local.ID, linked.ID)
+
+	// вторая учетная запись провайдера с тем же email не перехватывает уже привязанного пользователя
+	_, err = f.signIn(t, oidctest.User{Subject: "8", Email: "bob@example.com", EmailVerified: true})
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+
+	_, err = f.signIn(t, oidctest.User{Subject: "9"})
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
+
+func TestOIDCCreatesVerifiedEmailOnly(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+
+	// с неподтвержденным адресом пользователь не создается, и email остается свободным для настоящего владельца
+	_, err := f.signIn(t, oidctest.User{Subject: "7", Email: "carol@example.com"})
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+	_, err = f.auth.Register(ctx, "carol@example.com", "correct horse")
+	require.NoError(t, err)
+}
+
+func TestOIDCCompleteChecksState(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+	f.idp.Login(oidctest.User{Subject: "42", Email: "alice@example.com", EmailVerified: true})
+
+	authURL, flow, err := f.oidc.Begin(ctx)
+	require.NoError(t, err)
+	code, _ := f.idp.Authorize(t, authURL)
+
+	_, err = f.oidc.Complete(ctx, flow, "forged", code)
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+	_, err = f.oidc.Complete(ctx, oidc.Flow{}, "", code)
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
+
+func TestOIDCAuthenticateBearer(t *testing.T) {
+	f := newOIDCFixture(t)
+	ctx := context.Background()
+	alice := oidctest.User{Subject: "42", Email: "alice@example.com", EmailVerified: true}
+
+	user, err := f.oidc.Authenticate(ctx, f.idp.Token(t, alice, "todolist", time.Hour))
+	require.NoError(t, err)
+	again, err := f.oidc.Authenticate(ctx, f.idp.Token(t, alice, "todolist", time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, again.ID)
+
+	_, err = f.oidc.Authenticate(ctx, f.idp.Token(t, alice, "todolist", -time.Hour))
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+	_, err = f.oidc.Authenticate(ctx, "not-a-token")
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// spawnNext создает следующее повторение завершенной задачи. Если задача с тем же заголовком и датой уже есть
+// (например, задачу переоткрыли и завершили еще раз), повторение уже создано и второе не нужно
+func (s *todoService) spawnNext(ctx context.Context, todo *entity.Todo) error {
+	next := todo.NextOccurrence(time.Now())
+	if next == nil {
+		return nil
+	}
+
+	if _, err := s.repo.CreateNewTodo(ctx, next); err != nil && !stderrors.Is(err, errors.ErrTodoExists) {
+		return err
+	}
+	return nil
+}
+
+// keepSeries сохраняет начало серии при PUT, если правило повторения не изменилось
+func (s *todoService) keepSeries(ctx context.Context, id primitive.ObjectID, todo *entity.Todo) error {
+	if todo.RRule == "" {
+		return nil
+	}
+
+	existingTodo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existingTodo.RRule == todo.RRule && existingTodo.SeriesStart != nil {
+		todo.SeriesStart = existingTodo.SeriesStart
+	}
+	return nil
+}
+
+// GetOccurrences - до limit следующих повторений задачи после ее active_at
+func (s *todoService) GetOccurrences(ctx context.Context, id primitive.ObjectID, limit int) ([]time.Time, error) {
+	if err := s.authorizeTask(ctx, id, false); err != nil {
+		return nil, err
+	}
+	todo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return todo.Occurrences(limit), nil
+}
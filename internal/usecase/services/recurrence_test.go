@@ -0,0 +1,79 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompletingRecurringTaskSpawnsNext(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	todo, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Полить цветы", ActiveAt: today, RRule: "FREQ=DAILY;INTERVAL=2;COUNT=3"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.MarkAsCompleted(ctx, todo.ID))
+	page, err := s.GetTasksByStatus(ctx, string(entity.StatusTodo), entity.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Tasks, 1)
+	next := page.Tasks[0]
+	assert.Equal(t, today.AddDate(0, 0, 2), next.ActiveAt)
+	assert.Equal(t, todo.RRule, next.RRule)
+	assert.True(t, next.SeriesStart.Equal(today))
+
+	// повторное завершение переоткрытой задачи не создает дубликат
+	_, err = s.ReopenTodo(ctx, todo.ID)
+	require.NoError(t, err)
+	require.NoError(t, s.MarkAsCompleted(ctx, todo.ID))
+	page, err = s.GetAllTasks(ctx, entity.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page.Tasks, 2)
+
+	// третье повторение последнее: после него серия заканчивается
+	done := entity.StatusDone
+	_, err = s.PatchTodo(ctx, next.ID, &entity.TodoPatch{Status: &done})
+	require.NoError(t, err)
+	page, err = s.GetTasksByStatus(ctx, string(entity.StatusTodo), entity.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, page.Tasks, 1)
+	last := page.Tasks[0]
+	assert.Equal(t, today.AddDate(0, 0, 4), last.ActiveAt)
+	assert.Empty(t, last.Occurrences(5))
+
+	_, err = s.TransitionTodo(ctx, last.ID, entity.StatusDone)
+	require.NoError(t, err)
+	page, err = s.GetAllTasks(ctx, entity.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, page.Tasks, 3)
+}
+
+func TestUpdateKeepsSeries(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	todo, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Отчет", ActiveAt: today, RRule: "FREQ=WEEKLY"})
+	require.NoError(t, err)
+
+	// перенос одного повторения не сдвигает серию
+	todo, err = s.UpdateTodo(ctx, todo.ID, entity.TodoFields{Title: "Отчет", ActiveAt: today.AddDate(0, 0, 1), RRule: "FREQ=WEEKLY"})
+	require.NoError(t, err)
+	assert.True(t, todo.SeriesStart.Equal(today))
+
+	occurrences, err := s.GetOccurrences(ctx, todo.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{today.AddDate(0, 0, 7)}, occurrences)
+
+	// новое правило - новая серия
+	todo, err = s.UpdateTodo(ctx, todo.ID, entity.TodoFields{Title: "Отчет", ActiveAt: today.AddDate(0, 0, 1), RRule: "FREQ=MONTHLY"})
+	require.NoError(t, err)
+	assert.True(t, todo.SeriesStart.Equal(today.AddDate(0, 0, 1)))
+}
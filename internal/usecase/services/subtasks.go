@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// deleteSubtasks готовит подзадачи к удалению родителя id по политике s.subtasks.Delete
+func (s *todoService) deleteSubtasks(ctx context.Context, id primitive.ObjectID) error {
+	children, err := s.repo.GetSubtasks(ctx, []primitive.ObjectID{id})
+	if err != nil || len(children) == 0 {
+		return err
+	}
+
+	switch s.subtasks.Delete {
+	case entity.SubtaskOrphan:
+		return s.repo.SetParent(ctx, todoIDs(children), nil)
+	case entity.SubtaskCascade:
+		descendants, err := s.descendants(ctx, id)
+		if err != nil {
+			return err
+		}
+		// с самых глубоких, чтобы при сбое посередине не оставалось подзадач без родителя
+		for i := len(descendants) - 1; i >= 0; i-- {
+			if err := s.removeTask(ctx, descendants[i]); err != nil && !stderrors.Is(err, errors.ErrNotFound) {
+				return err
+			}
+		}
+		return nil
+	default:
+		return errors.ErrHasSubtasks
+	}
+}
+
+// completeSubtasks готовит подзадачи к завершению родителя todo по политике s.subtasks.Complete.
+// Закрытые (выполненные и отмененные) подзадачи завершению не мешают
+func (s *todoService) completeSubtasks(ctx context.Context, todo *entity.Todo) error {
+	switch s.subtasks.Complete {
+	case entity.SubtaskOrphan:
+		children, err := s.repo.GetSubtasks(ctx, []primitive.ObjectID{todo.ID})
+		if err != nil {
+			return err
+		}
+		return s.repo.SetParent(ctx, todoIDs(openTodos(children)), nil)
+	case entity.SubtaskCascade:
+		descendants, err := s.descendants(ctx, todo.ID)
+		if err != nil {
+			return err
+		}
+		open := openTodos(descendants)
+
+		// сначала проверяем все переходы, чтобы не завершить часть подзадач и упасть на заблокированной
+		for _, subtask := range open {
+			if !subtask.Status.CanTransitionTo(entity.StatusDone) {
+				return fmt.Errorf("%w: %s -> %s (%s)", errors.ErrInvalidTransition, subtask.Status, entity.StatusDone, subtask.ID.Hex())
+			}
+		}
+		for i := len(open) - 1; i >= 0; i-- {
+			subtask := open[i]
+			from := subtask.Status
+			if err := subtask.MarkAsCompleted(); err != nil {
+				return err
+			}
+			if _, err := s.repo.SetStatus(ctx, subtask.ID, from, subtask); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		children, err := s.repo.GetSubtasks(ctx, []primitive.ObjectID{todo.ID})
+		if err != nil {
+			return err
+		}
+		if len(openTodos(children)) > 0 {
+			return errors.ErrOpenSubtasks
+		}
+		return nil
+	}
+}
+
+// descendants - все подзадачи id на любой глубине, по уровням: сначала дети, потом внуки
+func (s *todoService) descendants(ctx context.Context, id primitive.ObjectID) ([]*entity.Todo, error) {
+	var result []*entity.Todo
+	visited := map[primitive.ObjectID]bool{id: true}
+
+	level := []primitive.ObjectID{id}
+	for len(level) > 0 {
+		children, err := s.repo.GetSubtasks(ctx, level)
+		if err != nil {
+			return nil, err
+		}
+
+		level = level[:0:0]
+		for _, child := range children {
+			if visited[child.ID] {
+				continue
+			}
+			visited[child.ID] = true
+			result = append(result, child)
+			level = append(level, child.ID)
+		}
+	}
+	return result, nil
+}
+
+// fillProgress считает прогресс для всех задач одним запросом подзадач
+func (s *todoService) fillProgress(ctx context.Context, todos []*entity.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	children, err := s.repo.GetSubtasks(ctx, todoIDs(todos))
+	if err != nil {
+		return err
+	}
+
+	byParent := make(map[primitive.ObjectID][]*entity.Todo)
+	for _, child := range children {
+		byParent[*child.ParentID] = append(byParent[*child.ParentID], child)
+	}
+	for _, todo := range todos {
+		todo.Subtasks = entity.NewSubtaskProgress(byParent[todo.ID])
+	}
+	return nil
+}
+
+func todoIDs(todos []*entity.Todo) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(todos))
+	for _, todo := range todos {
+		ids = append(ids, todo.ID)
+	}
+	return ids
+}
+
+func openTodos(todos []*entity.Todo) []*entity.Todo {
+	var open []*entity.Todo
+	for _, todo := range todos {
+		if !todo.Status.IsClosed() {
+			open = append(open, todo)
+		}
+	}
+	return open
+}
@@ -0,0 +1,168 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tree создает родителя с подзадачей и подзадачей второго уровня
+func tree(t *testing.T, s services.TodoService) (parent, child, grandchild *entity.Todo) {
+	t.Helper()
+	ctx := context.Background()
+
+	parent, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Переезд", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	child, err = s.CreateSubtask(ctx, parent.ID, entity.TodoFields{Title: "Упаковать вещи", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	grandchild, err = s.CreateSubtask(ctx, child.ID, entity.TodoFields{Title: "Купить коробки", ActiveAt: time.Now()})
+	require.NoError(t, err)
+	return parent, child, grandchild
+}
+
+func TestCreateSubtaskRequiresParent(t *testing.T) {
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	parent, _, _ := tree(t, s)
+
+	_, err := s.CreateSubtask(context.Background(), entity.NewTodo("x", time.Now()).ID, entity.TodoFields{Title: "Сирота", ActiveAt: time.Now()})
+	assert.ErrorIs(t, err, errors.ErrNotFound)
+
+	todo, err := s.GetTaskByID(context.Background(), parent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &entity.SubtaskProgress{Total: 1, Done: 0, Percent: 0}, todo.Subtasks)
+}
+
+func TestDeleteSubtaskPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("block", func(t *testing.T) {
+		s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+		parent, child, grandchild := tree(t, s)
+
+		assert.ErrorIs(t, s.DeleteTodo(ctx, parent.ID), errors.ErrHasSubtasks)
+		require.NoError(t, s.DeleteTodo(ctx, grandchild.ID))
+		require.NoError(t, s.DeleteTodo(ctx, child.ID))
+		require.NoError(t, s.DeleteTodo(ctx, parent.ID))
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{Delete: entity.SubtaskCascade}, entity.DefaultRankWeights())
+		parent, child, grandchild := tree(t, s)
+
+		require.NoError(t, s.DeleteTodo(ctx, parent.ID))
+		for _, todo := range []*entity.Todo{child, grandchild} {
+			_, err := s.GetTaskByID(ctx, todo.ID)
+			assert.ErrorIs(t, err, errors.ErrNotFound)
+		}
+	})
+
+	t.Run("orphan", func(t *testing.T) {
+		s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{Delete: entity.SubtaskOrphan}, entity.DefaultRankWeights())
+		parent, child, grandchild := tree(t, s)
+
+		require.NoError(t, s.DeleteTodo(ctx, parent.ID))
+		todo, err := s.GetTaskByID(ctx, child.ID)
+		require.NoError(t, err)
+		assert.Nil(t, todo.ParentID)
+
+		todo, err = s.GetTaskByID(ctx, grandchild.ID)
+		require.NoError(t, err)
+		assert.Equal(t, child.ID, *todo.ParentID)
+	})
+}
+
+func TestCompleteSubtaskPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("block", func(t *testing.T) {
+		s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+		parent, child, grandchild := tree(t, s)
+
+		assert.ErrorIs(t, s.MarkAsCompleted(ctx, parent.ID), errors.ErrOpenSubtasks)
+
+		done := entity.StatusDone
+		_, err := s.PatchTodo(ctx, parent.ID, &entity.TodoPatch{Status: &done})
+		assert.ErrorIs(t, err, errors.ErrOpenSubtasks)
+
+		// отмененная подзадача завершению не мешает
+		_, err = s.TransitionTodo(ctx, grandchild.ID, entity.StatusCancelled)
+		require.NoError(t, err)
+		require.NoError(t, s.MarkAsCompleted(ctx, child.ID))
+
+		todo, err := s.GetTaskByID(ctx, child.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &entity.SubtaskProgress{Total: 0, Done: 0, Percent: 100}, todo.Subtasks)
+
+		todo, err = s.GetTaskByID(ctx, parent.ID)
+		require.NoError(t, err)
+		assert.Equal(t, &entity.SubtaskProgress{Total: 1, Done: 1, Percent: 100}, todo.Subtasks)
+		require.NoError(t, s.MarkAsCompleted(ctx, parent.ID))
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{Complete: entity.SubtaskCascade}, entity.DefaultRankWeights())
+		parent, child, grandchild := tree(t, s)
+
+		require.NoError(t, s.MarkAsCompleted(ctx, parent.ID))
+		for _, todo := range []*entity.Todo{parent, child, grandchild} {
+			todo, err := s.GetTaskByID(ctx, todo.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entity.StatusDone, todo.Status, todo.Title)
+		}
+	})
+
+	t.Run("cascade checks transitions first", func(t *testing.T) {
+		s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{Complete: entity.SubtaskCascade}, entity.DefaultRankWeights())
+		parent, child, grandchild := tree(t, s)
+
+		_, err := s.TransitionTodo(ctx, grandchild.ID, entity.StatusBlocked)
+		require.NoError(t, err)
+
+		// из blocked в done нельзя, поэтому не завершается ни одна подзадача
+		assert.ErrorIs(t, s.MarkAsCompleted(ctx, parent.ID), errors.ErrInvalidTransition)
+		todo, err := s.GetTaskByID(ctx, child.ID)
+		require.NoError(t, err)
+		assert.Equal(t, entity.StatusTodo, todo.Status)
+	})
+
+	t.Run("orphan", func(t *testing.T) {
+		s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{Complete: entity.SubtaskOrphan}, entity.DefaultRankWeights())
+		parent, child, _ := tree(t, s)
+
+		require.NoError(t, s.MarkAsCompleted(ctx, parent.ID))
+		todo, err := s.GetTaskByID(ctx, child.ID)
+		require.NoError(t, err)
+		assert.Nil(t, todo.ParentID)
+		assert.Equal(t, entity.StatusTodo, todo.Status)
+	})
+
+	// отклоненный PATCH родителя подзадачи не трогает ни при какой политике
+	for _, policy := range []entity.SubtaskPolicy{entity.SubtaskCascade, entity.SubtaskOrphan} {
+		t.Run("rejected patch "+string(policy), func(t *testing.T) {
+			s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{Complete: policy}, entity.DefaultRankWeights())
+			parent, child, _ := tree(t, s)
+			_, err := s.TransitionTodo(ctx, parent.ID, entity.StatusBlocked)
+			require.NoError(t, err)
+
+			done := entity.StatusDone
+			_, err = s.PatchTodo(ctx, parent.ID, &entity.TodoPatch{Status: &done})
+			assert.ErrorIs(t, err, errors.ErrInvalidTransition)
+
+			todo, err := s.GetTaskByID(ctx, parent.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entity.StatusBlocked, todo.Status)
+			todo, err = s.GetTaskByID(ctx, child.ID)
+			require.NoError(t, err)
+			assert.Equal(t, entity.StatusTodo, todo.Status)
+			require.NotNil(t, todo.ParentID)
+			assert.Equal(t, parent.ID, *todo.ParentID)
+		})
+	}
+}
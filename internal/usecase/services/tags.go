@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/pkg/errors"
+)
+
+func (s *todoService) GetTags(ctx context.Context) ([]*entity.TagCount, error) {
+	if err := authorizeOwner(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTags(ctx)
+}
+
+// RenameTags заменяет метки from на to во всех задачах. Если метка to уже есть, from сливаются с ней.
+// Каждая метка из from должна быть хотя бы у одной задачи, иначе ErrTagNotFound
+func (s *todoService) RenameTags(ctx context.Context, from []string, to string) (int, error) {
+	if err := authorizeOwner(ctx); err != nil {
+		return 0, err
+	}
+	to, err := entity.NormalizeTag(to)
+	if err != nil {
+		return 0, err
+	}
+	from, err = entity.NormalizeTags(from)
+	if err != nil {
+		return 0, err
+	}
+	if len(from) == 0 {
+		return 0, fmt.Errorf("%w: tags", errors.ErrInvalidFieldValue)
+	}
+
+	existing, err := s.repo.GetTags(ctx)
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[string]bool, len(existing))
+	for _, tag := range existing {
+		used[tag.Name] = true
+	}
+
+	// переименование метки в саму себя ничего не меняет
+	renamed := make([]string, 0, len(from))
+	for _, tag := range from {
+		if !used[tag] {
+			return 0, fmt.Errorf("%w: %s", errors.ErrTagNotFound, tag)
+		}
+		if tag != to {
+			renamed = append(renamed, tag)
+		}
+	}
+	if len(renamed) == 0 {
+		return 0, nil
+	}
+
+	return s.repo.RenameTags(ctx, renamed, to)
+}
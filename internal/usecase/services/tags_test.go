@@ -0,0 +1,58 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenameTags(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+
+	report, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Отчет", ActiveAt: time.Now(), Tags: []string{"job", "urgent"}})
+	require.NoError(t, err)
+	_, err = s.CreateNewTodo(ctx, entity.TodoFields{Title: "Деплой", ActiveAt: time.Now(), Tags: []string{"work"}})
+	require.NoError(t, err)
+
+	_, err = s.RenameTags(ctx, []string{"missing"}, "work")
+	assert.ErrorIs(t, err, errors.ErrTagNotFound)
+	_, err = s.RenameTags(ctx, []string{"job"}, "not valid")
+	assert.ErrorIs(t, err, errors.ErrInvalidTag)
+	_, err = s.RenameTags(ctx, nil, "work")
+	assert.ErrorIs(t, err, errors.ErrInvalidFieldValue)
+
+	// имена нормализуются, а переименование в саму себя ничего не меняет
+	renamed, err := s.RenameTags(ctx, []string{"JOB", "Work"}, " Work")
+	require.NoError(t, err)
+	assert.Equal(t, 1, renamed)
+
+	report, err = s.GetTaskByID(ctx, report.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work", "urgent"}, report.Tags)
+
+	tags, err := s.GetTags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*entity.TagCount{{Name: "work", Count: 2}, {Name: "urgent", Count: 1}}, tags)
+}
+
+func TestRecurringTaskKeepsTags(t *testing.T) {
+	ctx := context.Background()
+	s := services.NewTodoService(repo.NewMemoryRepository(), services.SubtaskPolicies{}, entity.DefaultRankWeights())
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	todo, err := s.CreateNewTodo(ctx, entity.TodoFields{Title: "Полить цветы", ActiveAt: today, Tags: []string{"дом"}, RRule: "FREQ=DAILY"})
+	require.NoError(t, err)
+	require.NoError(t, s.MarkAsCompleted(ctx, todo.ID))
+
+	page, err := s.GetAllTasks(ctx, entity.ListOptions{TagsAll: []string{"дом"}})
+	require.NoError(t, err)
+	assert.Len(t, page.Tasks, 2)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+)
+
+// TenantService - организации: каталог для администратора и организация, в которой работает запрос
+type TenantService interface {
+	// ResolveTenant - организация id из каталога. ErrTenantRequired без id, ErrTenantNotFound - если такой нет
+	ResolveTenant(ctx context.Context, id string) (*entity.Tenant, error)
+	// TokenTenant - организация из access-токена, пустая у недействительного токена и токена без организации
+	TokenTenant(accessToken string) string
+
+	CreateTenant(ctx context.Context, id, name string) (*entity.Tenant, error)
+	GetTenants(ctx context.Context) ([]*entity.Tenant, error)
+	// DeleteTenant удаляет организацию вместе со всеми ее данными
+	DeleteTenant(ctx context.Context, id string) error
+}
+
+type tenantService struct {
+	repo   repo.TenantCatalog
+	tokens *token.Issuer
+}
+
+func NewTenantService(repo repo.TenantCatalog, tokens *token.Issuer) TenantService {
+	return &tenantService{repo: repo, tokens: tokens}
+}
+
+func (s *tenantService) ResolveTenant(ctx context.Context, id string) (*entity.Tenant, error) {
+	if id == "" {
+		return nil, errors.ErrTenantRequired
+	}
+	// некорректного id в каталоге быть не может, искать его незачем
+	id, err := entity.NormalizeTenantID(id)
+	if err != nil {
+		return nil, errors.ErrTenantNotFound
+	}
+	return s.repo.GetTenant(ctx, id)
+}
+
+func (s *tenantService) TokenTenant(accessToken string) string {
+	_, tenant, err := s.tokens.Parse(accessToken, token.Access)
+	if err != nil {
+		return ""
+	}
+	return tenant
+}
+
+func (s *tenantService) CreateTenant(ctx context.Context, id, name string) (*entity.Tenant, error) {
+	tenant, err := entity.NewTenant(id, name, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.CreateTenant(ctx, tenant)
+}
+
+func (s *tenantService) GetTenants(ctx context.Context) ([]*entity.Tenant, error) {
+	return s.repo.GetTenants(ctx)
+}
+
+func (s *tenantService) DeleteTenant(ctx context.Context, id string) error {
+	id, err := entity.NormalizeTenantID(id)
+	if err != nil {
+		return errors.ErrTenantNotFound
+	}
+	return s.repo.DeleteTenant(ctx, id)
+}
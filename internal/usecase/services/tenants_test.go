@@ -0,0 +1,81 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/internal/entity"
+	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/internal/usecase/services"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantCatalog(t *testing.T) {
+	ctx := context.Background()
+	tokens := token.NewIssuer([]byte("secret"), time.Minute, time.Hour)
+	s := services.NewTenantService(repo.NewTenantRepository(repo.NewMemoryTenants()), tokens)
+
+	tenant, err := s.CreateTenant(ctx, " Acme ", "ACME Corp")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenant.ID)
+	_, err = s.CreateTenant(ctx, "ACME", "")
+	assert.ErrorIs(t, err, errors.ErrTenantExists)
+	_, err = s.CreateTenant(ctx, "acme_corp", "")
+	assert.ErrorIs(t, err, errors.ErrInvalidTenant)
+
+	resolved, err := s.ResolveTenant(ctx, "Acme")
+	require.NoError(t, err)
+	assert.Equal(t, "ACME Corp", resolved.Name)
+	_, err = s.ResolveTenant(ctx, "")
+	assert.ErrorIs(t, err, errors.ErrTenantRequired)
+	_, err = s.ResolveTenant(ctx, "../admin")
+	assert.ErrorIs(t, err, errors.ErrTenantNotFound)
+
+	pair, err := tokens.Issue("user-1", "acme")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", s.TokenTenant(pair.AccessToken))
+	assert.Empty(t, s.TokenTenant(pair.RefreshToken))
+
+	require.NoError(t, s.DeleteTenant(ctx, "acme"))
+	_, err = s.ResolveTenant(ctx, "acme")
+	assert.ErrorIs(t, err, errors.ErrTenantNotFound)
+	assert.ErrorIs(t, s.DeleteTenant(ctx, "acme"), errors.ErrTenantNotFound)
+}
+
+func TestTokenOfAnotherTenant(t *testing.T) {
+	repository := repo.NewTenantRepository(repo.NewMemoryTenants())
+	tokens := token.NewIssuer([]byte("secret"), time.Minute, time.Hour)
+	tenants := services.NewTenantService(repository, tokens)
+	auth := services.NewAuthService(repository, tokens)
+
+	acme := entity.WithTenant(context.Background(), "acme")
+	globex := entity.WithTenant(context.Background(), "globex")
+	for _, id := range []string{"acme", "globex"} {
+		_, err := tenants.CreateTenant(context.Background(), id, "")
+		require.NoError(t, err)
+	}
+
+	// один и тот же email в разных организациях - разные пользователи
+	for _, ctx := range []context.Context{acme, globex} {
+		_, err := auth.Register(ctx, "alice@example.com", "correct horse")
+		require.NoError(t, err)
+	}
+	pair, err := auth.Login(acme, "alice@example.com", "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenants.TokenTenant(pair.AccessToken))
+
+	_, err = auth.Authenticate(acme, pair.AccessToken)
+	require.NoError(t, err)
+	_, err = auth.Authenticate(globex, pair.AccessToken)
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+	_, err = auth.Refresh(globex, pair.RefreshToken)
+	assert.ErrorIs(t, err, errors.ErrUnauthorized)
+
+	// без организации в контексте хранилище не отдает ничего
+	_, err = auth.Login(context.Background(), "alice@example.com", "correct horse")
+	assert.ErrorIs(t, err, errors.ErrTenantRequired)
+}
@@ -2,53 +2,385 @@ package services
 
 import (
 	"context"
+	"time"
+
 	"github.com/nekidaz/todolist/internal/entity"
 	"github.com/nekidaz/todolist/internal/usecase/repo"
+	"github.com/nekidaz/todolist/pkg/errors"
+	"github.com/nekidaz/todolist/pkg/textsearch"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"time"
 )
 
 type TodoService interface {
-	CreateNewTodo(ctx context.Context, title string, activeAt time.Time) (*entity.Todo, error)
-	UpdateTodo(ctx context.Context, id primitive.ObjectID, title string, activeAt time.Time) (*entity.Todo, error)
+	CreateNewTodo(ctx context.Context, fields entity.TodoFields) (*entity.Todo, error)
+	CreateSubtask(ctx context.Context, parentID primitive.ObjectID, fields entity.TodoFields) (*entity.Todo, error)
+	UpdateTodo(ctx context.Context, id primitive.ObjectID, fields entity.TodoFields) (*entity.Todo, error)
+	PatchTodo(ctx context.Context, id primitive.ObjectID, patch *entity.TodoPatch) (*entity.Todo, error)
+	SetChecklistItem(ctx context.Context, id primitive.ObjectID, index int, done bool) (*entity.Todo, error)
 	DeleteTodo(ctx context.Context, id primitive.ObjectID) error
 	MarkAsCompleted(ctx context.Context, id primitive.ObjectID) error
-	GetAllTasks(ctx context.Context) ([]*entity.Todo, error)
-	GetTasksByStatus(ctx context.Context, status string) ([]*entity.Todo, error)
+	TransitionTodo(ctx context.Context, id primitive.ObjectID, to entity.TaskStatus) (*entity.Todo, error)
+	ReopenTodo(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error)
+	GetAllTasks(ctx context.Context, opts entity.ListOptions) (*entity.TodoPage, error)
+	GetTaskByID(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error)
+	GetSubtasks(ctx context.Context, parentID primitive.ObjectID, opts entity.ListOptions) (*entity.TodoPage, error)
+	GetTasksByStatus(ctx context.Context, status string, opts entity.ListOptions) (*entity.TodoPage, error)
+	SearchTasks(ctx context.Context, listID *primitive.ObjectID, query string, limit int) ([]*entity.SearchHit, error)
+	PlanTasks(ctx context.Context, ids []primitive.ObjectID) ([]*entity.Todo, error)
+	GetOccurrences(ctx context.Context, id primitive.ObjectID, limit int) ([]time.Time, error)
+	GetTags(ctx context.Context) ([]*entity.TagCount, error)
+	RenameTags(ctx context.Context, from []string, to string) (int, error)
+	NextTasks(ctx context.Context, limit int, opts entity.ListOptions) ([]*entity.RankedTask, error)
+	MoveTodo(ctx context.Context, id primitive.ObjectID, listID *primitive.ObjectID) (*entity.Todo, error)
+	CreateList(ctx context.Context, name string) (*entity.List, error)
+	GetList(ctx context.Context, id primitive.ObjectID) (*entity.List, error)
+	GetLists(ctx context.Context, includeArchived bool) ([]*entity.List, error)
+	UpdateList(ctx context.Context, id primitive.ObjectID, update entity.ListUpdate) (*entity.List, error)
+	DeleteList(ctx context.Context, id primitive.ObjectID, force bool) error
+	OpenList(ctx context.Context, id primitive.ObjectID) (context.Context, *entity.List, error)
+	GetMembers(ctx context.Context, listID primitive.ObjectID) ([]*entity.Membership, error)
+	SetMemberRole(ctx context.Context, listID, userID primitive.ObjectID, role string) (*entity.Membership, error)
+	RemoveMember(ctx context.Context, listID, userID primitive.ObjectID) error
+	CreateInvitation(ctx context.Context, listID primitive.ObjectID, role string) (*entity.Invitation, string, error)
+	AcceptInvitation(ctx context.Context, secret string) (*entity.List, error)
+	GetNotifications(ctx context.Context, unreadOnly bool, limit int) ([]*entity.Notification, error)
+	SetNotificationRead(ctx context.Context, id primitive.ObjectID, read bool) (*entity.Notification, error)
+	CreateComment(ctx context.Context, taskID primitive.ObjectID, body string) (*entity.Comment, error)
+	GetComments(ctx context.Context, taskID primitive.ObjectID, cursor string, limit int) (*entity.CommentPage, error)
+	UpdateComment(ctx context.Context, taskID, commentID primitive.ObjectID, body string) (*entity.Comment, error)
+	DeleteComment(ctx context.Context, taskID, commentID primitive.ObjectID) error
+}
+
+// SubtaskPolicies - что делать с подзадачами при удалении и при завершении родителя. Пустое значение - block
+type SubtaskPolicies struct {
+	Delete   entity.SubtaskPolicy
+	Complete entity.SubtaskPolicy
 }
 
 type todoService struct {
-	repo repo.TodoRepository
+	repo     repo.TodoRepository
+	subtasks SubtaskPolicies
+	// ranking - веса оценки задач в NextTasks
+	ranking entity.RankWeights
 }
 
-func NewTodoService(repo repo.TodoRepository) TodoService {
+func NewTodoService(repo repo.TodoRepository, subtasks SubtaskPolicies, ranking entity.RankWeights) TodoService {
 	return &todoService{
-		repo: repo,
+		repo:     repo,
+		subtasks: subtasks,
+		ranking:  ranking,
 	}
 }
 
-func (s *todoService) CreateNewTodo(ctx context.Context, title string, activeAt time.Time) (*entity.Todo, error) {
-	todo := entity.NewTodo(title, activeAt)
-	return s.repo.CreateNewTodo(ctx, todo)
+func (s *todoService) CreateNewTodo(ctx context.Context, fields entity.TodoFields) (*entity.Todo, error) {
+	if err := authorizeEdit(ctx); err != nil {
+		return nil, err
+	}
+	fields.ListID = accessList(ctx, fields.ListID)
+	if err := s.checkList(ctx, fields.ListID); err != nil {
+		return nil, err
+	}
+	return s.create(ctx, fields.NewTodo())
+}
+
+// CreateSubtask создает задачу под parentID в списке родителя. Родитель должен существовать
+func (s *todoService) CreateSubtask(ctx context.Context, parentID primitive.ObjectID, fields entity.TodoFields) (*entity.Todo, error) {
+	if err := s.authorizeTask(ctx, parentID, true); err != nil {
+		return nil, err
+	}
+	parent, err := s.repo.GetTaskByID(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkList(ctx, parent.ListID); err != nil {
+		return nil, err
+	}
+
+	fields.ListID = parent.ListID
+	fields.ParentID = &parentID
+	return s.create(ctx, fields.NewTodo())
 }
 
-func (s *todoService) UpdateTodo(ctx context.Context, id primitive.ObjectID, title string, activeAt time.Time) (*entity.Todo, error) {
-	todo := entity.NewTodo(title, activeAt)
-	return s.repo.UpdateTodo(ctx, id, todo)
+func (s *todoService) create(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
+	if err := s.checkDependencies(ctx, todo); err != nil {
+		return nil, err
+	}
+	if err := s.checkAssignee(ctx, todo.ListID, todo.AssigneeID); err != nil {
+		return nil, err
+	}
+
+	todo, err := s.repo.CreateNewTodo(ctx, todo)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(ctx, nil, todo)
+	return s.withDerived(ctx, todo)
+}
+
+func (s *todoService) UpdateTodo(ctx context.Context, id primitive.ObjectID, fields entity.TodoFields) (*entity.Todo, error) {
+	if err := s.authorizeTask(ctx, id, true); err != nil {
+		return nil, err
+	}
+	before, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	todo := fields.NewTodo()
+	todo.ID = id
+	if err := s.checkDependencies(ctx, todo); err != nil {
+		return nil, err
+	}
+	if err := s.checkAssignee(ctx, before.ListID, todo.AssigneeID); err != nil {
+		return nil, err
+	}
+	if err := s.keepSeries(ctx, id, todo); err != nil {
+		return nil, err
+	}
+
+	todo, err = s.repo.UpdateTodo(ctx, id, todo)
+	if err != nil {
+		return nil, err
+	}
+	s.notify(ctx, before, todo)
+	return s.withDerived(ctx, todo)
+}
+
+func (s *todoService) PatchTodo(ctx context.Context, id primitive.ObjectID, patch *entity.TodoPatch) (*entity.Todo, error) {
+	if err := s.authorizeTask(ctx, id, true); err != nil {
+		return nil, err
+	}
+	todo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// пустой патч ничего не меняет, просто отдаем текущее состояние
+	if patch.IsEmpty() {
+		return s.withDerived(ctx, todo)
+	}
+
+	// патч проверяется на копии до того, как трогать подзадачи: отклоненный PATCH ничего не меняет
+	patched := *todo
+	if err := patch.Apply(&patched); err != nil {
+		return nil, err
+	}
+	if patch.DependsOn != nil {
+		if err := s.checkDependencies(ctx, &patched); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkAssignee(ctx, todo.ListID, patch.AssigneeID); err != nil {
+		return nil, err
+	}
+
+	// завершение через PATCH подчиняется тем же правилам для подзадач, что и через переходы
+	completing := patched.Status == entity.StatusDone && todo.Status != entity.StatusDone
+	if completing {
+		if err := s.completeSubtasks(ctx, todo); err != nil {
+			return nil, err
+		}
+	}
+
+	before := todo
+	todo, err = s.repo.PatchTodo(ctx, id, patch)
+	if err != nil {
+		return nil, err
+	}
+	if completing {
+		if err := s.spawnNext(ctx, todo); err != nil {
+			return nil, err
+		}
+	}
+	s.notify(ctx, before, todo)
+	return s.withDerived(ctx, todo)
+}
+
+// SetChecklistItem отмечает пункт чек-листа в описании. В базу уходит только новое описание
+func (s *todoService) SetChecklistItem(ctx context.Context, id primitive.ObjectID, index int, done bool) (*entity.Todo, error) {
+	if err := s.authorizeTask(ctx, id, true); err != nil {
+		return nil, err
+	}
+	todo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *todo
+	if err := todo.SetChecklistItem(index, done); err != nil {
+		return nil, err
+	}
+	if todo.Description != before.Description {
+		if todo, err = s.repo.PatchTodo(ctx, id, &entity.TodoPatch{Description: &todo.Description}); err != nil {
+			return nil, err
+		}
+		s.notify(ctx, &before, todo)
+	}
+
+	return s.withDerived(ctx, todo)
 }
 
 func (s *todoService) DeleteTodo(ctx context.Context, id primitive.ObjectID) error {
-	return s.repo.DeleteTodo(ctx, id)
+	if err := s.authorizeTask(ctx, id, true); err != nil {
+		return err
+	}
+	todo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.deleteSubtasks(ctx, id); err != nil {
+		return err
+	}
+	return s.removeTask(ctx, todo)
 }
 
 func (s *todoService) MarkAsCompleted(ctx context.Context, id primitive.ObjectID) error {
-	return s.repo.MarkAsCompleted(ctx, id)
+	_, err := s.changeStatus(ctx, id, (*entity.Todo).MarkAsCompleted)
+	return err
 }
 
-func (s *todoService) GetAllTasks(ctx context.Context) ([]*entity.Todo, error) {
-	return s.repo.GetAllTasks(ctx)
+func (s *todoService) TransitionTodo(ctx context.Context, id primitive.ObjectID, to entity.TaskStatus) (*entity.Todo, error) {
+	todo, err := s.changeStatus(ctx, id, func(todo *entity.Todo) error {
+		return todo.TransitionTo(to)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.withDerived(ctx, todo)
+}
+
+func (s *todoService) ReopenTodo(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error) {
+	todo, err := s.changeStatus(ctx, id, (*entity.Todo).Reopen)
+	if err != nil {
+		return nil, err
+	}
+	return s.withDerived(ctx, todo)
 }
 
-func (s *todoService) GetTasksByStatus(ctx context.Context, status string) ([]*entity.Todo, error) {
-	return s.repo.GetTasksByStatus(ctx, status)
+// changeStatus применяет переход к прочитанной задаче и сохраняет его, только если статус в базе не успел измениться
+func (s *todoService) changeStatus(ctx context.Context, id primitive.ObjectID, transition func(todo *entity.Todo) error) (*entity.Todo, error) {
+	if err := s.authorizeTask(ctx, id, true); err != nil {
+		return nil, err
+	}
+	todo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *todo
+	if err := transition(todo); err != nil {
+		return nil, err
+	}
+
+	// переход ничего не поменял (например, повторная отметка выполненной задачи)
+	if todo.Status == before.Status {
+		return todo, nil
+	}
+
+	completing := todo.Status == entity.StatusDone
+	if completing {
+		if err := s.completeSubtasks(ctx, todo); err != nil {
+			return nil, err
+		}
+	}
+	if todo, err = s.repo.SetStatus(ctx, id, before.Status, todo); err != nil {
+		return nil, err
+	}
+	// у повторяющейся задачи появляется следующее повторение
+	if completing {
+		if err := s.spawnNext(ctx, todo); err != nil {
+			return nil, err
+		}
+	}
+	s.notify(ctx, &before, todo)
+	return todo, nil
+}
+
+func (s *todoService) GetAllTasks(ctx context.Context, opts entity.ListOptions) (*entity.TodoPage, error) {
+	opts.ListID = accessList(ctx, opts.ListID)
+	page, err := s.repo.GetAllTasks(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fillDerived(ctx, page.Tasks); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *todoService) GetTaskByID(ctx context.Context, id primitive.ObjectID) (*entity.Todo, error) {
+	if err := s.authorizeTask(ctx, id, false); err != nil {
+		return nil, err
+	}
+	todo, err := s.repo.GetTaskByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.withDerived(ctx, todo)
+}
+
+// GetSubtasks - страница прямых подзадач parentID
+func (s *todoService) GetSubtasks(ctx context.Context, parentID primitive.ObjectID, opts entity.ListOptions) (*entity.TodoPage, error) {
+	if err := s.authorizeTask(ctx, parentID, false); err != nil {
+		return nil, err
+	}
+	parent, err := s.repo.GetTaskByID(ctx, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// подзадачи всегда в списке родителя
+	opts.ListID = parent.ListID
+	opts.ParentID = &parentID
+	return s.GetAllTasks(ctx, opts)
+}
+
+func (s *todoService) GetTasksByStatus(ctx context.Context, status string, opts entity.ListOptions) (*entity.TodoPage, error) {
+	opts.ListID = accessList(ctx, opts.ListID)
+	page, err := s.repo.GetTasksByStatus(ctx, status, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.fillDerived(ctx, page.Tasks); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func (s *todoService) SearchTasks(ctx context.Context, listID *primitive.ObjectID, query string, limit int) ([]*entity.SearchHit, error) {
+	if textsearch.ParseQuery(query).IsEmpty() {
+		return nil, errors.ErrEmptyQuery
+	}
+
+	hits, err := s.repo.SearchTasks(ctx, accessList(ctx, listID), query, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	todos := make([]*entity.Todo, 0, len(hits))
+	for _, hit := range hits {
+		todos = append(todos, hit.Task)
+	}
+	if err := s.fillDerived(ctx, todos); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+// withDerived дополняет задачу вычисляемыми полями
+func (s *todoService) withDerived(ctx context.Context, todo *entity.Todo) (*entity.Todo, error) {
+	if err := s.fillDerived(ctx, []*entity.Todo{todo}); err != nil {
+		return nil, err
+	}
+	return todo, nil
+}
+
+// fillDerived заполняет прогресс подзадач, незакрытые зависимости и число комментариев, по одному запросу на каждое
+func (s *todoService) fillDerived(ctx context.Context, todos []*entity.Todo) error {
+	if err := s.fillProgress(ctx, todos); err != nil {
+		return err
+	}
+	if err := s.fillBlocked(ctx, todos); err != nil {
+		return err
+	}
+	return s.fillCommentCounts(ctx, todos)
 }
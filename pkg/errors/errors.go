@@ -1,17 +1,159 @@
 package errors
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/nekidaz/todolist/pkg/i18n"
+)
+
+// Kind - категория ошибки. По ней транспортный слой выбирает код ответа, сам пакет про HTTP ничего не знает
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindValidation
+	KindNotFound
+	KindConflict
+	KindUnsupportedMedia
+	KindUnauthorized
+	KindForbidden
+)
+
+// Error - доменная ошибка со стабильным кодом, на который могут опираться клиенты. Code от языка не зависит,
+// текст берется из каталога i18n по Key. Сравнивается по указателю, поэтому errors.Is работает и с обернутыми через %w ошибками
+type Error struct {
+	Kind Kind
+	Code string
+	Key  string
+}
+
+func New(kind Kind, code, key string) *Error {
+	return &Error{Kind: kind, Code: code, Key: key}
+}
+
+// Error возвращает текст на языке по умолчанию, для логов и ответов без выбранного языка
+func (e *Error) Error() string {
+	return e.Localize(i18n.Default)
+}
+
+// Localize возвращает текст ошибки на языке lang
+func (e *Error) Localize(lang i18n.Language) string {
+	return i18n.Message(lang, e.Key)
+}
+
+// From ищет доменную ошибку в цепочке err
+func From(err error) (*Error, bool) {
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return domainErr, true
+	}
+	return nil, false
+}
+
+// Стабильные коды ошибок
+const (
+	CodeInternal             = "internal_error"
+	CodeValidationFailed     = "validation_failed"
+	CodeInvalidID            = "invalid_id"
+	CodeTaskNotFound         = "task_not_found"
+	CodeTaskDuplicate        = "task_duplicate"
+	CodeInvalidTransition    = "invalid_transition"
+	CodeStatusConflict       = "status_conflict"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeChecklistNotFound    = "checklist_item_not_found"
+	CodeTaskHasSubtasks      = "task_has_subtasks"
+	CodeOpenSubtasks         = "open_subtasks"
+	CodeDependencyNotFound   = "dependency_not_found"
+	CodeDependencyCycle      = "dependency_cycle"
+	CodeTagNotFound          = "tag_not_found"
+	CodeListNotFound         = "list_not_found"
+	CodeListDuplicate        = "list_duplicate"
+	CodeListArchived         = "list_archived"
+	CodeListNotEmpty         = "list_not_empty"
+	CodeDefaultList          = "default_list"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeUserDuplicate        = "user_duplicate"
+	CodeUserNotFound         = "user_not_found"
+	CodeInsufficientScope    = "insufficient_scope"
+	CodeAPIKeyNotFound       = "api_key_not_found"
+	CodeForbidden            = "forbidden"
+	CodeMemberNotFound       = "member_not_found"
+	CodeMemberDuplicate      = "member_duplicate"
+	CodeInvitationNotFound   = "invitation_not_found"
+	CodeUsernameDuplicate    = "username_duplicate"
+	CodeNotificationNotFound = "notification_not_found"
+	CodeTenantRequired       = "tenant_required"
+	CodeTenantNotFound       = "tenant_not_found"
+	CodeTenantDuplicate      = "tenant_duplicate"
+	CodeCommentNotFound      = "comment_not_found"
+)
 
 // тут кастомные ошибки
 var (
-	ErrTodoExists          = errors.New("Задача с таким заголовком и датой уже существует")
-	ErrNotFound            = errors.New("Запись не найдена")
-	ErrFailedToGetRecordID = errors.New("Не удалось получить идентификатор записи")
-	ErrTitleEmpty          = errors.New("Заголовок не может быть пустым")
-	ErrTitleLengthExceeded = errors.New("Длина заголовка не может превышать 200 символов")
-	ErrDateNotCurrent      = errors.New("Дата должна быть актуальной и не раньше текущей даты")
-	ErrParseActiveAt       = errors.New("Не удалось преобразовать ActiveAt")
-	ErrInvalidID           = errors.New("Неверный ID")
-	ErrTaskNotFound        = errors.New("Задача не найдена")
-	ErrAlreadyExist        = errors.New("Task already exists")
+	ErrInternal             = New(KindInternal, CodeInternal, "errors.internal")
+	ErrValidation           = New(KindValidation, CodeValidationFailed, "errors.validation")
+	ErrTodoExists           = New(KindConflict, CodeTaskDuplicate, "errors.task_duplicate")
+	ErrNotFound             = New(KindNotFound, CodeTaskNotFound, "errors.task_not_found")
+	ErrFailedToGetRecordID  = New(KindInternal, CodeInternal, "errors.record_id")
+	ErrTitleEmpty           = New(KindValidation, CodeValidationFailed, "errors.title_empty")
+	ErrTitleLengthExceeded  = New(KindValidation, CodeValidationFailed, "errors.title_too_long")
+	ErrDateNotCurrent       = New(KindValidation, CodeValidationFailed, "errors.date_not_current")
+	ErrParseActiveAt        = New(KindValidation, CodeValidationFailed, "errors.parse_active_at")
+	ErrInvalidID            = New(KindValidation, CodeInvalidID, "errors.invalid_id")
+	ErrInvalidPatch         = New(KindValidation, CodeValidationFailed, "errors.invalid_patch")
+	ErrFieldImmutable       = New(KindValidation, CodeValidationFailed, "errors.field_immutable")
+	ErrUnknownField         = New(KindValidation, CodeValidationFailed, "errors.unknown_field")
+	ErrInvalidFieldValue    = New(KindValidation, CodeValidationFailed, "errors.invalid_field_value")
+	ErrUnsupportedMedia     = New(KindUnsupportedMedia, CodeUnsupportedMediaType, "errors.unsupported_media")
+	ErrInvalidStatus        = New(KindValidation, CodeValidationFailed, "errors.invalid_status")
+	ErrInvalidTransition    = New(KindConflict, CodeInvalidTransition, "errors.invalid_transition")
+	ErrStatusConflict       = New(KindConflict, CodeStatusConflict, "errors.status_conflict")
+	ErrInvalidSort          = New(KindValidation, CodeValidationFailed, "errors.invalid_sort")
+	ErrInvalidCursor        = New(KindValidation, CodeValidationFailed, "errors.invalid_cursor")
+	ErrInvalidLimit         = New(KindValidation, CodeValidationFailed, "errors.invalid_limit")
+	ErrInvalidDate          = New(KindValidation, CodeValidationFailed, "errors.invalid_date")
+	ErrEmptyQuery           = New(KindValidation, CodeValidationFailed, "errors.empty_query")
+	ErrDescriptionTooLong   = New(KindValidation, CodeValidationFailed, "errors.description_too_long")
+	ErrChecklistNotFound    = New(KindNotFound, CodeChecklistNotFound, "errors.checklist_item_not_found")
+	ErrHasSubtasks          = New(KindConflict, CodeTaskHasSubtasks, "errors.task_has_subtasks")
+	ErrOpenSubtasks         = New(KindConflict, CodeOpenSubtasks, "errors.open_subtasks")
+	ErrInvalidSubtaskPolicy = New(KindInternal, CodeInternal, "errors.invalid_subtask_policy")
+	ErrDependencyNotFound   = New(KindValidation, CodeDependencyNotFound, "errors.dependency_not_found")
+	ErrDependencyCycle      = New(KindConflict, CodeDependencyCycle, "errors.dependency_cycle")
+	ErrInvalidRRule         = New(KindValidation, CodeValidationFailed, "errors.invalid_rrule")
+	ErrInvalidTag           = New(KindValidation, CodeValidationFailed, "errors.invalid_tag")
+	ErrTagNotFound          = New(KindNotFound, CodeTagNotFound, "errors.tag_not_found")
+	ErrInvalidPriority      = New(KindValidation, CodeValidationFailed, "errors.invalid_priority")
+	ErrInvalidListName      = New(KindValidation, CodeValidationFailed, "errors.invalid_list_name")
+	ErrListNotFound         = New(KindNotFound, CodeListNotFound, "errors.list_not_found")
+	ErrListExists           = New(KindConflict, CodeListDuplicate, "errors.list_duplicate")
+	ErrListArchived         = New(KindConflict, CodeListArchived, "errors.list_archived")
+	ErrListNotEmpty         = New(KindConflict, CodeListNotEmpty, "errors.list_not_empty")
+	ErrDefaultList          = New(KindConflict, CodeDefaultList, "errors.default_list")
+	ErrUnauthorized         = New(KindUnauthorized, CodeUnauthorized, "errors.unauthorized")
+	ErrInvalidCredentials   = New(KindUnauthorized, CodeInvalidCredentials, "errors.invalid_credentials")
+	ErrUserExists           = New(KindConflict, CodeUserDuplicate, "errors.user_duplicate")
+	ErrUserNotFound         = New(KindNotFound, CodeUserNotFound, "errors.user_not_found")
+	ErrInvalidEmail         = New(KindValidation, CodeValidationFailed, "errors.invalid_email")
+	ErrInvalidPassword      = New(KindValidation, CodeValidationFailed, "errors.invalid_password")
+	ErrInsufficientScope    = New(KindForbidden, CodeInsufficientScope, "errors.insufficient_scope")
+	ErrAPIKeyNotFound       = New(KindNotFound, CodeAPIKeyNotFound, "errors.api_key_not_found")
+	ErrInvalidAPIKeyName    = New(KindValidation, CodeValidationFailed, "errors.invalid_api_key_name")
+	ErrInvalidScope         = New(KindValidation, CodeValidationFailed, "errors.invalid_scope")
+	ErrForbidden            = New(KindForbidden, CodeForbidden, "errors.forbidden")
+	ErrMemberNotFound       = New(KindNotFound, CodeMemberNotFound, "errors.member_not_found")
+	ErrMemberExists         = New(KindConflict, CodeMemberDuplicate, "errors.member_duplicate")
+	ErrInvalidRole          = New(KindValidation, CodeValidationFailed, "errors.invalid_role")
+	ErrInvitationNotFound   = New(KindNotFound, CodeInvitationNotFound, "errors.invitation_not_found")
+	ErrInvalidUsername      = New(KindValidation, CodeValidationFailed, "errors.invalid_username")
+	ErrUsernameExists       = New(KindConflict, CodeUsernameDuplicate, "errors.username_duplicate")
+	ErrInvalidAssignee      = New(KindValidation, CodeValidationFailed, "errors.invalid_assignee")
+	ErrNotificationNotFound = New(KindNotFound, CodeNotificationNotFound, "errors.notification_not_found")
+	ErrTenantRequired       = New(KindValidation, CodeTenantRequired, "errors.tenant_required")
+	ErrTenantNotFound       = New(KindNotFound, CodeTenantNotFound, "errors.tenant_not_found")
+	ErrTenantExists         = New(KindConflict, CodeTenantDuplicate, "errors.tenant_duplicate")
+	ErrInvalidTenant        = New(KindValidation, CodeValidationFailed, "errors.invalid_tenant")
+	ErrInvalidComment       = New(KindValidation, CodeValidationFailed, "errors.invalid_comment")
+	ErrCommentNotFound      = New(KindNotFound, CodeCommentNotFound, "errors.comment_not_found")
 )
@@ -0,0 +1,155 @@
+package i18n
+
+// catalog - все тексты API. Ключи одинаковые для всех языков, новый ключ добавляется сразу во все языки
+var catalog = map[Language]map[string]string{
+	Russian: {
+		"errors.internal":                 "Внутренняя ошибка сервера",
+		"errors.validation":               "Некорректные данные запроса",
+		"errors.task_duplicate":           "Задача с таким заголовком и датой уже существует",
+		"errors.task_not_found":           "Задача не найдена",
+		"errors.record_id":                "Не удалось получить идентификатор записи",
+		"errors.title_empty":              "Заголовок не может быть пустым",
+		"errors.title_too_long":           "Длина заголовка не может превышать 200 символов",
+		"errors.date_not_current":         "Дата должна быть актуальной и не раньше текущей даты",
+		"errors.parse_active_at":          "Не удалось преобразовать ActiveAt",
+		"errors.invalid_id":               "Неверный ID",
+		"errors.invalid_patch":            "Тело запроса должно быть JSON-объектом (JSON Merge Patch)",
+		"errors.field_immutable":          "Поле нельзя изменить",
+		"errors.unknown_field":            "Неизвестное поле",
+		"errors.invalid_field_value":      "Некорректное значение поля",
+		"errors.unsupported_media":        "Ожидается Content-Type application/merge-patch+json",
+		"errors.invalid_status":           "Неизвестный статус задачи",
+		"errors.invalid_transition":       "Недопустимый переход статуса задачи",
+		"errors.status_conflict":          "Статус задачи изменился, повторите запрос",
+		"errors.invalid_sort":             "Сортировка возможна только по active_at, created_at, updated_at или title",
+		"errors.invalid_cursor":           "Некорректный курсор",
+		"errors.invalid_limit":            "Некорректный limit",
+		"errors.invalid_date":             "Дата должна быть в формате RFC 3339 или 2006-01-02",
+		"errors.empty_query":              "Поисковый запрос не может быть пустым",
+		"errors.description_too_long":     "Длина описания не может превышать 10000 символов",
+		"errors.checklist_item_not_found": "Пункт чек-листа не найден",
+		"errors.task_has_subtasks":        "У задачи есть подзадачи",
+		"errors.open_subtasks":            "У задачи есть незавершенные подзадачи",
+		"errors.invalid_subtask_policy":   "Политика подзадач должна быть block, cascade или orphan",
+		"errors.dependency_not_found":     "Задача из depends_on не найдена",
+		"errors.dependency_cycle":         "Зависимости образуют цикл",
+		"errors.invalid_rrule":            "Некорректное правило повторения",
+		"errors.invalid_tag":              "Некорректная метка",
+		"errors.tag_not_found":            "Метка не найдена",
+		"errors.invalid_priority":         "Приоритет должен быть low, normal, high или urgent",
+		"errors.invalid_list_name":        "Имя списка не должно быть пустым и длиннее 100 символов",
+		"errors.list_not_found":           "Список не найден",
+		"errors.list_duplicate":           "Список с таким именем уже существует",
+		"errors.list_archived":            "Список в архиве, его задачи можно только читать",
+		"errors.list_not_empty":           "В списке есть задачи",
+		"errors.default_list":             "Список по умолчанию нельзя изменить или удалить",
+		"errors.unauthorized":             "Нужен действующий токен доступа",
+		"errors.invalid_credentials":      "Неверный email или пароль",
+		"errors.user_duplicate":           "Пользователь с таким email уже зарегистрирован",
+		"errors.user_not_found":           "Пользователь не найден",
+		"errors.invalid_email":            "Некорректный email",
+		"errors.invalid_password":         "Пароль должен быть длиной от 8 до 72 байт",
+		"errors.insufficient_scope":       "Ключу API не хватает прав для этого запроса",
+		"errors.api_key_not_found":        "Ключ API не найден",
+		"errors.invalid_api_key_name":     "Имя ключа API не должно быть пустым и длиннее 100 символов",
+		"errors.invalid_scope":            "Права ключа API: tasks:read, tasks:write или tasks:delete, хотя бы одно",
+		"errors.forbidden":                "Вашей роли в списке не хватает прав для этого запроса",
+		"errors.member_not_found":         "Участник списка не найден",
+		"errors.member_duplicate":         "Пользователь уже участвует в списке",
+		"errors.invalid_role":             "Роль участника должна быть editor или viewer",
+		"errors.invitation_not_found":     "Приглашение не найдено, уже использовано или истекло",
+		"errors.invalid_username":         "Имя пользователя - от 3 до 32 латинских букв, цифр и знаков подчеркивания",
+		"errors.username_duplicate":       "Имя пользователя уже занято",
+		"errors.invalid_assignee":         "Исполнитель должен иметь доступ к списку задачи",
+		"errors.notification_not_found":   "Уведомление не найдено",
+		"errors.tenant_required":          "Не указана организация: заголовок X-Tenant-ID, поддомен или токен",
+		"errors.tenant_not_found":         "Организация не найдена",
+		"errors.tenant_duplicate":         "Организация с таким идентификатором уже есть",
+		"errors.invalid_tenant":           "Идентификатор организации - от 2 до 32 строчных латинских букв, цифр и дефисов, без дефиса в начале и в конце",
+		"errors.invalid_comment":          "Комментарий не может быть пустым или длиннее 5000 символов",
+		"errors.comment_not_found":        "Комментарий не найден",
+
+		"validation.invalid_json": "Тело запроса не является корректным JSON",
+		"validation.required":     "Поле %s обязательно",
+		"validation.max":          "Поле %s не может быть длиннее %s",
+		"validation.field":        "Поле %s не прошло проверку %s",
+
+		"tasks.created":        "Задача с заголовком: %s успешно создана",
+		"tasks.weekend_prefix": "ВЫХОДНОЙ - ",
+	},
+	English: {
+		"errors.internal":                 "Internal server error",
+		"errors.validation":               "Invalid request data",
+		"errors.task_duplicate":           "A task with this title and date already exists",
+		"errors.task_not_found":           "Task not found",
+		"errors.record_id":                "Failed to get the record ID",
+		"errors.title_empty":              "Title must not be empty",
+		"errors.title_too_long":           "Title must not exceed 200 characters",
+		"errors.date_not_current":         "Date must not be earlier than today",
+		"errors.parse_active_at":          "Failed to parse activeAt",
+		"errors.invalid_id":               "Invalid ID",
+		"errors.invalid_patch":            "Request body must be a JSON object (JSON Merge Patch)",
+		"errors.field_immutable":          "Field cannot be changed",
+		"errors.unknown_field":            "Unknown field",
+		"errors.invalid_field_value":      "Invalid field value",
+		"errors.unsupported_media":        "Expected Content-Type application/merge-patch+json",
+		"errors.invalid_status":           "Unknown task status",
+		"errors.invalid_transition":       "Task status transition is not allowed",
+		"errors.status_conflict":          "Task status has changed, retry the request",
+		"errors.invalid_sort":             "Sorting is only supported by active_at, created_at, updated_at or title",
+		"errors.invalid_cursor":           "Invalid cursor",
+		"errors.invalid_limit":            "Invalid limit",
+		"errors.invalid_date":             "Date must be in RFC 3339 or 2006-01-02 format",
+		"errors.empty_query":              "Search query must not be empty",
+		"errors.description_too_long":     "Description must not exceed 10000 characters",
+		"errors.checklist_item_not_found": "Checklist item not found",
+		"errors.task_has_subtasks":        "The task has subtasks",
+		"errors.open_subtasks":            "The task has unfinished subtasks",
+		"errors.invalid_subtask_policy":   "Subtask policy must be block, cascade or orphan",
+		"errors.dependency_not_found":     "Task from depends_on not found",
+		"errors.dependency_cycle":         "Dependencies form a cycle",
+		"errors.invalid_rrule":            "Invalid recurrence rule",
+		"errors.invalid_tag":              "Invalid tag",
+		"errors.tag_not_found":            "Tag not found",
+		"errors.invalid_priority":         "Priority must be low, normal, high or urgent",
+		"errors.invalid_list_name":        "List name must be non-empty and at most 100 characters",
+		"errors.list_not_found":           "List not found",
+		"errors.list_duplicate":           "A list with this name already exists",
+		"errors.list_archived":            "The list is archived, its tasks are read-only",
+		"errors.list_not_empty":           "The list has tasks",
+		"errors.default_list":             "The default list cannot be changed or deleted",
+		"errors.unauthorized":             "A valid access token is required",
+		"errors.invalid_credentials":      "Invalid email or password",
+		"errors.user_duplicate":           "A user with this email is already registered",
+		"errors.user_not_found":           "User not found",
+		"errors.invalid_email":            "Invalid email",
+		"errors.invalid_password":         "Password must be 8 to 72 bytes long",
+		"errors.insufficient_scope":       "The API key lacks the scope required for this request",
+		"errors.api_key_not_found":        "API key not found",
+		"errors.invalid_api_key_name":     "API key name must be non-empty and at most 100 characters",
+		"errors.invalid_scope":            "API key scopes: tasks:read, tasks:write or tasks:delete, at least one",
+		"errors.forbidden":                "Your role in this list does not allow this request",
+		"errors.member_not_found":         "List member not found",
+		"errors.member_duplicate":         "The user is already a member of the list",
+		"errors.invalid_role":             "Member role must be editor or viewer",
+		"errors.invitation_not_found":     "Invitation not found, already used or expired",
+		"errors.invalid_username":         "Username must be 3 to 32 latin letters, digits or underscores",
+		"errors.username_duplicate":       "Username is already taken",
+		"errors.invalid_assignee":         "The assignee must have access to the task's list",
+		"errors.notification_not_found":   "Notification not found",
+		"errors.tenant_required":          "No organization given: set the X-Tenant-ID header, a subdomain or a token",
+		"errors.tenant_not_found":         "Organization not found",
+		"errors.tenant_duplicate":         "An organization with this ID already exists",
+		"errors.invalid_tenant":           "An organization ID is 2 to 32 lowercase latin letters, digits and hyphens, not starting or ending with a hyphen",
+		"errors.invalid_comment":          "A comment must not be empty or longer than 5000 characters",
+		"errors.comment_not_found":        "Comment not found",
+
+		"validation.invalid_json": "Request body is not valid JSON",
+		"validation.required":     "Field %s is required",
+		"validation.max":          "Field %s must not be longer than %s",
+		"validation.field":        "Field %s failed the %s check",
+
+		"tasks.created":        "Task with title: %s created successfully",
+		"tasks.weekend_prefix": "WEEKEND - ",
+	},
+}
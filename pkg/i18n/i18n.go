@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Language - язык сообщений API (базовый тег BCP 47 без региона)
+type Language string
+
+const (
+	Russian Language = "ru"
+	English Language = "en"
+
+	// Default - язык, если клиент не указал поддерживаемый
+	Default = Russian
+)
+
+// Parse приводит тег вроде "en-US" к поддерживаемому языку
+func Parse(tag string) (Language, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+
+	lang := Language(tag)
+	if _, ok := catalog[lang]; !ok {
+		return "", false
+	}
+	return lang, true
+}
+
+// Negotiate выбирает язык по заголовку Accept-Language с учетом q-весов
+func Negotiate(acceptLanguage string) Language {
+	type candidate struct {
+		lang Language
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			parsed, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if lang, ok := Parse(tag); ok && q > 0 {
+			candidates = append(candidates, candidate{lang, q})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Default
+	}
+	// при равных весах побеждает тот, что указан раньше
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	return candidates[0].lang
+}
+
+// Message возвращает текст сообщения key на языке lang. Если перевода нет, берется Default, если нет и его - сам key
+func Message(lang Language, key string, args ...interface{}) string {
+	format, ok := catalog[lang][key]
+	if !ok {
+		if format, ok = catalog[Default][key]; !ok {
+			format = key
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
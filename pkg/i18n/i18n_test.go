@@ -0,0 +1,44 @@
+package i18n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Language
+	}{
+		{"", Default},
+		{"en", English},
+		{"en-US,en;q=0.9", English},
+		{"ru-RU", Russian},
+		{"de-DE,en;q=0.5,ru;q=0.8", Russian},
+		{"fr, de", Default},
+		{"en;q=0, ru;q=0.1", Russian},
+		{"EN_gb", English},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Negotiate(tt.header), tt.header)
+	}
+}
+
+func TestCatalogComplete(t *testing.T) {
+	for lang, messages := range catalog {
+		for other, otherMessages := range catalog {
+			for key := range otherMessages {
+				assert.Contains(t, messages, key, "%s: нет перевода %s из %s", lang, key, other)
+			}
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	assert.Equal(t, "Task with title: a created successfully", Message(English, "tasks.created", "a"))
+	assert.Equal(t, "Задача не найдена", Message(Russian, "errors.task_not_found"))
+	assert.Equal(t, "Задача не найдена", Message(Language("de"), "errors.task_not_found"))
+	assert.Equal(t, "missing.key", Message(English, "missing.key"))
+}
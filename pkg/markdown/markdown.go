@@ -0,0 +1,36 @@
+package markdown
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var (
+	// renderer - CommonMark с расширениями GitHub: таблицы, зачеркивание, автоссылки и списки задач
+	renderer = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+	// policy - разметка, которую можно отдавать пользователю: без скриптов, стилей, обработчиков событий и
+	// javascript: ссылок. Из input разрешены только отключенные чекбоксы, которыми goldmark рисует пункты чек-листа
+	policy = newPolicy()
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
+
+// Render превращает Markdown в безопасный HTML. Сырой HTML в исходнике goldmark не пропускает,
+// а результат дополнительно проходит через policy
+func Render(source string) (string, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return policy.Sanitize(buf.String()), nil
+}
@@ -0,0 +1,36 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderChecklist(t *testing.T) {
+	html, err := Render("# План\n\n- [x] купить\n- [ ] приготовить\n")
+	require.NoError(t, err)
+
+	assert.Contains(t, html, "<h1")
+	assert.Contains(t, html, `<input checked="" disabled="" type="checkbox"`)
+	assert.Contains(t, html, `<input disabled="" type="checkbox"`)
+	assert.Contains(t, html, "приготовить")
+}
+
+func TestRenderSanitizes(t *testing.T) {
+	tests := []string{
+		"<script>alert(1)</script>",
+		"[ссылка](javascript:alert(1))",
+		`<img src="x" onerror="alert(1)">`,
+		`<input type="text" value="x">`,
+	}
+
+	for _, source := range tests {
+		html, err := Render(source)
+		require.NoError(t, err)
+		assert.NotContains(t, html, "<script", source)
+		assert.NotContains(t, html, "javascript:", source)
+		assert.NotContains(t, html, "onerror", source)
+		assert.NotContains(t, html, `type="text"`, source)
+	}
+}
@@ -0,0 +1,294 @@
+// Package oidc - клиент OpenID Connect: discovery, вход по authorization code с PKCE
+// и проверка токенов провайдера по его JWKS
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalid - токен или ответ провайдера не прошел проверку
+var ErrInvalid = errors.New("oidc: invalid token")
+
+// signingMethods - асимметричные алгоритмы подписи. HS256 не принимается: секрет клиента не должен
+// превращаться в ключ проверки чужих токенов
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// clockSkew - допустимое расхождение часов с провайдером
+const clockSkew = time.Minute
+
+type Config struct {
+	// IssuerURL - адрес провайдера, от него строится /.well-known/openid-configuration
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL - адрес callback приложения, зарегистрированный у провайдера
+	RedirectURL string
+	Scopes      []string
+	// Audience - aud, с которым провайдер выпускает access-токены для API. Пустой - ClientID
+	Audience string
+}
+
+// Claims - то, что приложению нужно из токена провайдера
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Flow - одноразовые значения одного входа. Хранятся у клиента до callback
+type Flow struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// NewFlow генерирует state, nonce и PKCE code_verifier
+func NewFlow() (Flow, error) {
+	var flow Flow
+	for _, dst := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		value, err := randomString()
+		if err != nil {
+			return Flow{}, err
+		}
+		*dst = value
+	}
+	return flow, nil
+}
+
+// Challenge - code_challenge метода S256 для verifier
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type Provider struct {
+	config   Config
+	client   *http.Client
+	metadata metadata
+	keys     *keySet
+	now      func() time.Time
+}
+
+// Discover читает метаданные провайдера. issuer в них должен совпадать с IssuerURL
+func Discover(ctx context.Context, config Config, client *http.Client) (*Provider, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if config.Audience == "" {
+		config.Audience = config.ClientID
+	}
+
+	discoveryURL := strings.TrimSuffix(config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	var meta metadata
+	if err := getJSON(ctx, client, discoveryURL, &meta); err != nil {
+		return nil, fmt.Errorf("oidc: discovery: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != strings.TrimSuffix(config.IssuerURL, "/") {
+		return nil, fmt.Errorf("oidc: discovery: issuer %q does not match %q", meta.Issuer, config.IssuerURL)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery: incomplete provider metadata")
+	}
+
+	return &Provider{
+		config:   config,
+		client:   client,
+		metadata: meta,
+		keys:     newKeySet(client, meta.JWKSURI),
+		now:      time.Now,
+	}, nil
+}
+
+// WithClock подменяет часы, для тестов
+func (p *Provider) WithClock(now func() time.Time) *Provider {
+	c := *p
+	c.now = now
+	return &c
+}
+
+// AuthCodeURL - адрес провайдера, на который отправляется пользователь
+func (p *Provider) AuthCodeURL(flow Flow) string {
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {flow.State},
+		"nonce":                 {flow.Nonce},
+		"code_challenge":        {Challenge(flow.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(p.metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return p.metadata.AuthorizationEndpoint + separator + query.Encode()
+}
+
+// Exchange меняет code на токены и возвращает claims проверенного ID-токена
+func (p *Provider) Exchange(ctx context.Context, code string, flow Flow) (*Claims, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {flow.Verifier},
+		"client_id":     {p.config.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var response struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := doJSON(p.client, req, &response)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: token endpoint: %w", err)
+	}
+	if status != http.StatusOK || response.IDToken == "" {
+		return nil, fmt.Errorf("%w: token endpoint: %d %s %s", ErrInvalid, status, response.Error, response.ErrorDescription)
+	}
+
+	claims, nonce, err := p.verify(ctx, response.IDToken, p.config.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if nonce != flow.Nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalid)
+	}
+	return claims, nil
+}
+
+// Verify проверяет bearer-токен провайдера: подпись по JWKS, iss, aud из Config.Audience и срок
+func (p *Provider) Verify(ctx context.Context, raw string) (*Claims, error) {
+	claims, _, err := p.verify(ctx, raw, p.config.Audience)
+	return claims, err
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Email         string   `json:"email"`
+	EmailVerified flexBool `json:"email_verified"`
+	Nonce         string   `json:"nonce"`
+}
+
+func (p *Provider) verify(ctx context.Context, raw, audience string) (*Claims, string, error) {
+	var parsed tokenClaims
+	_, err := jwt.ParseWithClaims(raw, &parsed, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.key(ctx, kid, p.now())
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(p.metadata.Issuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(p.now),
+	)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if parsed.Subject == "" {
+		return nil, "", fmt.Errorf("%w: no sub", ErrInvalid)
+	}
+
+	return &Claims{
+		Issuer:        parsed.Issuer,
+		Subject:       parsed.Subject,
+		Email:         parsed.Email,
+		EmailVerified: bool(parsed.EmailVerified),
+	}, parsed.Nonce, nil
+}
+
+// flexBool - email_verified: часть провайдеров присылает его строкой "true"
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	case "false", "null":
+		*b = false
+	default:
+		return fmt.Errorf("oidc: email_verified: %s", data)
+	}
+	return nil
+}
+
+func randomString() (string, error) {
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, dst interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	status, err := doJSON(client, req, dst)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, status)
+	}
+	return nil
+}
+
+// maxResponseSize - ответы провайдера больше этого не читаются
+const maxResponseSize = 1 << 20
+
+func doJSON(client *http.Client, req *http.Request, dst interface{}) (int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, dst); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,137 @@
+package oidc_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/oidc"
+	"github.com/nekidaz/todolist/pkg/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const redirectURL = "http://app.example.com/auth/oidc/callback"
+
+func discover(t *testing.T, idp *oidctest.Server) *oidc.Provider {
+	t.Helper()
+	provider, err := oidc.Discover(context.Background(), idp.Config(redirectURL), nil)
+	require.NoError(t, err)
+	return provider
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	ctx := context.Background()
+	idp := oidctest.NewServer(t, "todolist", "s3cret/+")
+	provider := discover(t, idp)
+	idp.Login(oidctest.User{Subject: "42", Email: "alice@example.com", EmailVerified: true})
+
+	flow, err := oidc.NewFlow()
+	require.NoError(t, err)
+	authURL := provider.AuthCodeURL(flow)
+	parsed, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, oidc.Challenge(flow.Verifier), parsed.Query().Get("code_challenge"))
+	assert.Empty(t, parsed.Query().Get("code_verifier"))
+
+	code, state := idp.Authorize(t, authURL)
+	assert.Equal(t, flow.State, state)
+
+	claims, err := provider.Exchange(ctx, code, flow)
+	require.NoError(t, err)
+	assert.Equal(t, &oidc.Claims{Issuer: idp.URL, Subject: "42", Email: "alice@example.com", EmailVerified: true}, claims)
+
+	// code одноразовый
+	_, err = provider.Exchange(ctx, code, flow)
+	assert.ErrorIs(t, err, oidc.ErrInvalid)
+}
+
+func TestExchangeRequiresVerifierAndNonce(t *testing.T) {
+	ctx := context.Background()
+	idp := oidctest.NewServer(t, "todolist", "secret")
+	provider := discover(t, idp)
+	idp.Login(oidctest.User{Subject: "42"})
+
+	flow, err := oidc.NewFlow()
+	require.NoError(t, err)
+
+	// перехваченный code без verifier бесполезен
+	code, _ := idp.Authorize(t, provider.AuthCodeURL(flow))
+	stolen := flow
+	stolen.Verifier = "guess"
+	_, err = provider.Exchange(ctx, code, stolen)
+	assert.ErrorIs(t, err, oidc.ErrInvalid)
+
+	code, _ = idp.Authorize(t, provider.AuthCodeURL(flow))
+	replayed := flow
+	replayed.Nonce = "other"
+	_, err = provider.Exchange(ctx, code, replayed)
+	assert.ErrorIs(t, err, oidc.ErrInvalid)
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	idp := oidctest.NewServer(t, "todolist", "secret")
+	provider := discover(t, idp)
+	alice := oidctest.User{Subject: "42", Email: "alice@example.com"}
+
+	claims, err := provider.Verify(ctx, idp.Token(t, alice, "todolist", time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, "42", claims.Subject)
+	assert.False(t, claims.EmailVerified)
+
+	for name, raw := range map[string]string{
+		"чужая аудитория": idp.Token(t, alice, "other-app", time.Hour),
+		"просрочен":       idp.Token(t, alice, "todolist", -time.Hour),
+		"без sub":         idp.Token(t, oidctest.User{}, "todolist", time.Hour),
+		"мусор":           "not.a.token",
+	} {
+		_, err := provider.Verify(ctx, raw)
+		assert.ErrorIs(t, err, oidc.ErrInvalid, name)
+	}
+
+}
+
+func TestVerifyFollowsKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	idp := oidctest.NewServer(t, "todolist", "secret")
+	provider := discover(t, idp)
+	alice := oidctest.User{Subject: "42"}
+
+	_, err := provider.Verify(ctx, idp.Token(t, alice, "todolist", time.Hour))
+	require.NoError(t, err)
+
+	idp.RotateKey(t)
+	rotated := idp.Token(t, alice, "todolist", time.Hour)
+	// сразу после чтения JWKS неизвестный kid не гоняет сервер к провайдеру
+	_, err = provider.Verify(ctx, rotated)
+	assert.ErrorIs(t, err, oidc.ErrInvalid)
+
+	later := provider.WithClock(func() time.Time { return time.Now().Add(time.Minute) })
+	_, err = later.Verify(ctx, rotated)
+	assert.NoError(t, err)
+}
+
+func TestDiscoverChecksIssuer(t *testing.T) {
+	idp := oidctest.NewServer(t, "todolist", "secret")
+	// провайдер, который выдает себя за idp
+	impostor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := http.Get(idp.URL + r.URL.Path)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	defer impostor.Close()
+
+	config := idp.Config(redirectURL)
+	config.IssuerURL = impostor.URL
+	_, err := oidc.Discover(context.Background(), config, http.DefaultClient)
+	assert.ErrorContains(t, err, "does not match")
+}
@@ -0,0 +1,334 @@
+// Package rrule - подмножество правил повторения RRULE из RFC 5545: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY),
+// INTERVAL, BYDAY, COUNT и UNTIL. Неделя начинается с понедельника (WKST=MO)
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// WeekdayNum - день недели из BYDAY. Ordinal бывает только у MONTHLY: 1MO - первый понедельник месяца,
+// -1FR - последняя пятница; 0 - каждый такой день
+type WeekdayNum struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// Rule - разобранное правило. Count и Until не задаются вместе; 0 и nil - без ограничения
+type Rule struct {
+	Freq     Frequency
+	Interval int
+	ByDay    []WeekdayNum
+	Count    int
+	Until    *time.Time
+}
+
+// maxPeriods ограничивает перебор периодов, чтобы правило без подходящих дат (например, 5MO с большим INTERVAL)
+// не крутилось бесконечно
+const maxPeriods = 10000
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+var weekdayNames = map[time.Weekday]string{
+	time.Monday:    "MO",
+	time.Tuesday:   "TU",
+	time.Wednesday: "WE",
+	time.Thursday:  "TH",
+	time.Friday:    "FR",
+	time.Saturday:  "SA",
+	time.Sunday:    "SU",
+}
+
+// Parse разбирает правило вида "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10", префикс "RRULE:" допускается.
+// Неподдерживаемые части (BYMONTH, BYSETPOS, WKST...) - ошибка, а не молчаливый пропуск
+func Parse(value string) (*Rule, error) {
+	value = strings.TrimSpace(value)
+	if len(value) >= 6 && strings.EqualFold(value[:6], "RRULE:") {
+		value = value[6:]
+	}
+
+	rule := &Rule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		name, arg, ok := strings.Cut(strings.TrimSpace(part), "=")
+		name = strings.ToUpper(name)
+		if !ok || arg == "" {
+			return nil, fmt.Errorf("invalid part %q", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate %s", name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(arg))
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported FREQ=%s", arg)
+			}
+		case "INTERVAL":
+			rule.Interval, err = positive(name, arg)
+		case "COUNT":
+			rule.Count, err = positive(name, arg)
+		case "UNTIL":
+			rule.Until, err = parseUntil(arg)
+		case "BYDAY":
+			rule.ByDay, err = parseByDay(arg)
+		default:
+			err = fmt.Errorf("unsupported %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("FREQ is required")
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL are mutually exclusive")
+	}
+	for _, day := range rule.ByDay {
+		if rule.Freq == Yearly {
+			return nil, fmt.Errorf("BYDAY is not supported with FREQ=YEARLY")
+		}
+		if day.Ordinal != 0 && rule.Freq != Monthly {
+			return nil, fmt.Errorf("BYDAY ordinals are supported only with FREQ=MONTHLY")
+		}
+	}
+	return rule, nil
+}
+
+func positive(name, value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", name)
+	}
+	return n, nil
+}
+
+// parseUntil принимает дату (20301231, граница включительно до конца дня) или время UTC (20301231T235959Z)
+func parseUntil(value string) (*time.Time, error) {
+	if until, err := time.Parse("20060102T150405Z", value); err == nil {
+		return &until, nil
+	}
+	if until, err := time.Parse("20060102", value); err == nil {
+		until = until.Add(24*time.Hour - time.Nanosecond)
+		return &until, nil
+	}
+	return nil, fmt.Errorf("invalid UNTIL=%s", value)
+}
+
+// parseByDay разбирает список вида "MO,WE" или "1MO,-1FR", повторы отбрасываются
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	seen := make(map[WeekdayNum]bool)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid BYDAY=%s", value)
+		}
+
+		weekday, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid BYDAY=%s", value)
+		}
+		day := WeekdayNum{Weekday: weekday}
+		if ordinal := item[:len(item)-2]; ordinal != "" {
+			n, err := strconv.Atoi(ordinal)
+			if err != nil || n == 0 || n < -5 || n > 5 {
+				return nil, fmt.Errorf("invalid BYDAY=%s", value)
+			}
+			day.Ordinal = n
+		}
+		if !seen[day] {
+			seen[day] = true
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// String - правило в каноническом виде: части всегда в одном порядке, INTERVAL=1 не пишется
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			name := weekdayNames[day.Weekday]
+			if day.Ordinal != 0 {
+				name = strconv.Itoa(day.Ordinal) + name
+			}
+			days = append(days, name)
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Each перебирает повторения по возрастанию, начиная с самого start (по RFC 5545 DTSTART всегда первое
+// повторение и учитывается в COUNT), пока fn возвращает true и правило не исчерпано
+func (r *Rule) Each(start time.Time, fn func(occurrence time.Time) bool) {
+	n := 0
+	emit := func(occurrence time.Time) bool {
+		if r.Until != nil && occurrence.After(*r.Until) {
+			return false
+		}
+		n++
+		return fn(occurrence) && (r.Count == 0 || n < r.Count)
+	}
+
+	if !emit(start) {
+		return
+	}
+	for period := 0; period < maxPeriods; period++ {
+		for _, occurrence := range r.expand(start, period) {
+			if !occurrence.After(start) {
+				continue
+			}
+			if !emit(occurrence) {
+				return
+			}
+		}
+	}
+}
+
+// After - первое повторение строго позже after
+func (r *Rule) After(start, after time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	r.Each(start, func(occurrence time.Time) bool {
+		if occurrence.After(after) {
+			next, found = occurrence, true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// Next - до limit повторений строго позже after
+func (r *Rule) Next(start, after time.Time, limit int) []time.Time {
+	occurrences := []time.Time{}
+	if limit < 1 {
+		return occurrences
+	}
+	r.Each(start, func(occurrence time.Time) bool {
+		if occurrence.After(after) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return len(occurrences) < limit
+	})
+	return occurrences
+}
+
+// expand - кандидаты одного периода (дня, недели, месяца или года) по возрастанию, время суток берется из start
+func (r *Rule) expand(start time.Time, period int) []time.Time {
+	year, month, day := start.Date()
+	step := period * r.Interval
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location())
+	}
+
+	switch r.Freq {
+	case Daily:
+		candidate := at(year, month, day+step)
+		if len(r.ByDay) > 0 && !r.matchesWeekday(candidate) {
+			return nil
+		}
+		return []time.Time{candidate}
+	case Weekly:
+		monday := day - (int(start.Weekday())+6)%7 + step*7
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(year, month, day+step*7)}
+		}
+		var candidates []time.Time
+		for _, weekday := range r.ByDay {
+			candidates = append(candidates, at(year, month, monday+(int(weekday.Weekday)+6)%7))
+		}
+		sortTimes(candidates)
+		return candidates
+	case Monthly:
+		first := at(year, month+time.Month(step), 1)
+		if len(r.ByDay) == 0 {
+			// в месяцах без такого числа (31 февраля) повторения нет
+			candidate := at(first.Year(), first.Month(), day)
+			if candidate.Month() != first.Month() {
+				return nil
+			}
+			return []time.Time{candidate}
+		}
+		return r.monthlyByDay(first)
+	default:
+		candidate := at(year+step, month, day)
+		if candidate.Month() != month {
+			return nil
+		}
+		return []time.Time{candidate}
+	}
+}
+
+// monthlyByDay - дни месяца first, подходящие под BYDAY с учетом порядковых номеров
+func (r *Rule) monthlyByDay(first time.Time) []time.Time {
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+	var candidates []time.Time
+	for day := 1; day <= daysInMonth; day++ {
+		candidate := first.AddDate(0, 0, day-1)
+		// номер этого дня недели в месяце с начала и с конца
+		fromStart := (day-1)/7 + 1
+		fromEnd := -((daysInMonth-day)/7 + 1)
+		for _, weekday := range r.ByDay {
+			if weekday.Weekday == candidate.Weekday() &&
+				(weekday.Ordinal == 0 || weekday.Ordinal == fromStart || weekday.Ordinal == fromEnd) {
+				candidates = append(candidates, candidate)
+				break
+			}
+		}
+	}
+	return candidates
+}
+
+func (r *Rule) matchesWeekday(t time.Time) bool {
+	for _, weekday := range r.ByDay {
+		if weekday.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func sortTimes(times []time.Time) {
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+}
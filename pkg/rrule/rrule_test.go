@@ -0,0 +1,96 @@
+package rrule_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nekidaz/todolist/pkg/rrule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func dates(times []time.Time) []string {
+	result := make([]string, 0, len(times))
+	for _, t := range times {
+		result = append(result, t.Format("2006-01-02"))
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	rule, err := rrule.Parse("RRULE:freq=weekly;INTERVAL=2;BYDAY=TH,mo,MO;COUNT=4")
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=TH,MO;COUNT=4", rule.String())
+
+	rule, err = rrule.Parse("FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20301231")
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=MONTHLY;BYDAY=-1FR;UNTIL=20301231T235959Z", rule.String())
+
+	for _, value := range []string{
+		"",
+		"INTERVAL=2",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20300101",
+		"FREQ=DAILY;FREQ=WEEKLY",
+		"FREQ=WEEKLY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=MONTHLY;BYMONTHDAY=1",
+		"FREQ=DAILY;UNTIL=tomorrow",
+	} {
+		_, err := rrule.Parse(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		rule  string
+		start time.Time
+		want  []string
+	}{
+		{"FREQ=DAILY;INTERVAL=3", date(2030, 1, 30), []string{"2030-02-02", "2030-02-05", "2030-02-08"}},
+		{"FREQ=DAILY;BYDAY=SA,SU", date(2030, 1, 1), []string{"2030-01-05", "2030-01-06", "2030-01-12"}},
+		// 2030-01-02 - среда: в первую неделю остается только пятница
+		{"FREQ=WEEKLY;BYDAY=MO,FR", date(2030, 1, 2), []string{"2030-01-04", "2030-01-07", "2030-01-11"}},
+		{"FREQ=WEEKLY;INTERVAL=2", date(2030, 1, 2), []string{"2030-01-16", "2030-01-30", "2030-02-13"}},
+		// 31-го числа бывает не каждый месяц
+		{"FREQ=MONTHLY", date(2030, 1, 31), []string{"2030-03-31", "2030-05-31", "2030-07-31"}},
+		{"FREQ=MONTHLY;BYDAY=1MO", date(2030, 1, 7), []string{"2030-02-04", "2030-03-04", "2030-04-01"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR", date(2030, 1, 25), []string{"2030-02-22", "2030-03-29", "2030-04-26"}},
+		{"FREQ=YEARLY", date(2028, 2, 29), []string{"2032-02-29", "2036-02-29", "2040-02-29"}},
+		// DTSTART - первое из COUNT повторений
+		{"FREQ=DAILY;COUNT=3", date(2030, 1, 1), []string{"2030-01-02", "2030-01-03"}},
+		{"FREQ=WEEKLY;UNTIL=20300115", date(2030, 1, 1), []string{"2030-01-08", "2030-01-15"}},
+	}
+
+	for _, test := range tests {
+		rule, err := rrule.Parse(test.rule)
+		require.NoError(t, err, test.rule)
+		assert.Equal(t, test.want, dates(rule.Next(test.start, test.start, 3)), test.rule)
+	}
+}
+
+func TestAfter(t *testing.T) {
+	rule, err := rrule.Parse("FREQ=WEEKLY;BYDAY=MO;COUNT=3")
+	require.NoError(t, err)
+	start := date(2030, 1, 7)
+
+	next, ok := rule.After(start, date(2030, 1, 9))
+	require.True(t, ok)
+	assert.Equal(t, date(2030, 1, 14), next)
+
+	// время суток берется из DTSTART
+	next, ok = rule.After(start.Add(9*time.Hour), start.Add(9*time.Hour))
+	require.True(t, ok)
+	assert.Equal(t, date(2030, 1, 14).Add(9*time.Hour), next)
+
+	_, ok = rule.After(start, date(2030, 1, 21))
+	assert.False(t, ok)
+}
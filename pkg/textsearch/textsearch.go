@@ -0,0 +1,182 @@
+// Package textsearch - токенизация, стемминг (русский и английский), оценка релевантности и подсветка совпадений.
+// Используется хранилищами без собственного полнотекстового поиска и для подсветки результатов Mongo
+package textsearch
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/kljensen/snowball/english"
+	"github.com/kljensen/snowball/russian"
+)
+
+// Языки в терминах Mongo text index
+const (
+	LanguageRussian = "russian"
+	LanguageEnglish = "english"
+)
+
+// Token - слово текста и его позиция в байтах
+type Token struct {
+	Word  string
+	Stem  string
+	Start int
+	End   int
+}
+
+// Tokenize режет текст на слова (буквы и цифры) и стеммит каждое слово по его алфавиту:
+// кириллица - русским стеммером, остальное - английским. Стоп-слова пропускаются
+func Tokenize(text string) []Token {
+	var tokens []Token
+
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := strings.ToLower(text[start:end])
+		if stem := Stem(word); stem != "" {
+			tokens = append(tokens, Token{Word: word, Stem: stem, Start: start, End: end})
+		}
+		start = -1
+	}
+
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+
+	return tokens
+}
+
+// Stem возвращает основу слова в нижнем регистре или "", если это стоп-слово
+func Stem(word string) string {
+	word = strings.ToLower(word)
+	if isCyrillic(word) {
+		if russian.IsStopWord(word) {
+			return ""
+		}
+		return russian.Stem(word, true)
+	}
+
+	if english.IsStopWord(word) {
+		return ""
+	}
+	return english.Stem(word, true)
+}
+
+// DetectLanguage определяет язык текста по преобладающему алфавиту
+func DetectLanguage(text string) string {
+	var cyrillic, latin int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Latin, r):
+			latin++
+		}
+	}
+
+	if latin > cyrillic {
+		return LanguageEnglish
+	}
+	return LanguageRussian
+}
+
+// Query - разобранный поисковый запрос
+type Query struct {
+	// Words - слова запроса без стоп-слов и синтаксиса, Terms - их уникальные основы в порядке появления
+	Words []string
+	Terms []string
+}
+
+// ParseQuery разбирает запрос так же, как индексируемый текст
+func ParseQuery(q string) Query {
+	var query Query
+	seen := make(map[string]bool)
+	for _, token := range Tokenize(q) {
+		query.Words = append(query.Words, token.Word)
+		if !seen[token.Stem] {
+			seen[token.Stem] = true
+			query.Terms = append(query.Terms, token.Stem)
+		}
+	}
+	return query
+}
+
+// IsEmpty - в запросе не осталось слов (пустой или только стоп-слова)
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0
+}
+
+// String - запрос из одних слов, без синтаксиса фраз и исключений
+func (q Query) String() string {
+	return strings.Join(q.Words, " ")
+}
+
+// Language - язык запроса для стемминга на стороне Mongo
+func (q Query) Language() string {
+	return DetectLanguage(q.String())
+}
+
+// Score оценивает релевантность текста запросу по формуле, близкой к textScore Mongo:
+// каждое вхождение слова запроса весит больше в коротком тексте. 0 - совпадений нет
+func (q Query) Score(text string) float64 {
+	tokens := Tokenize(text)
+	if len(tokens) == 0 {
+		return 0
+	}
+
+	counts := make(map[string]int)
+	for _, token := range tokens {
+		counts[token.Stem]++
+	}
+
+	var score float64
+	for _, term := range q.Terms {
+		if freq := counts[term]; freq > 0 {
+			score += float64(freq) * (0.5 + 0.5*float64(freq)/float64(len(tokens)))
+		}
+	}
+	return score
+}
+
+// Highlight экранирует текст для HTML и оборачивает совпавшие слова в <mark>
+func (q Query) Highlight(text string) string {
+	terms := make(map[string]bool, len(q.Terms))
+	for _, term := range q.Terms {
+		terms[term] = true
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, token := range Tokenize(text) {
+		if !terms[token.Stem] {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[last:token.Start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[token.Start:token.End]))
+		b.WriteString("</mark>")
+		last = token.End
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+
+	return b.String()
+}
+
+func isCyrillic(word string) bool {
+	for _, r := range word {
+		if unicode.Is(unicode.Cyrillic, r) {
+			return true
+		}
+	}
+	return false
+}
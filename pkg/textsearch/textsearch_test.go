@@ -0,0 +1,39 @@
+package textsearch_test
+
+import (
+	"testing"
+
+	"github.com/nekidaz/todolist/pkg/textsearch"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStemRussianAndEnglish(t *testing.T) {
+	assert.Equal(t, textsearch.Stem("молоко"), textsearch.Stem("молока"))
+	assert.Equal(t, textsearch.Stem("Buying"), textsearch.Stem("buy"))
+	// стоп-слова не индексируются
+	assert.Empty(t, textsearch.Stem("и"))
+	assert.Empty(t, textsearch.Stem("the"))
+}
+
+func TestDetectLanguage(t *testing.T) {
+	assert.Equal(t, textsearch.LanguageRussian, textsearch.DetectLanguage("Купить молоко"))
+	assert.Equal(t, textsearch.LanguageEnglish, textsearch.DetectLanguage("Buy milk"))
+	assert.Equal(t, textsearch.LanguageRussian, textsearch.DetectLanguage("Починить CI"))
+}
+
+func TestQueryScoreAndHighlight(t *testing.T) {
+	query := textsearch.ParseQuery("молока купить")
+	assert.Equal(t, []string{textsearch.Stem("молока"), textsearch.Stem("купить")}, query.Terms)
+
+	assert.Zero(t, query.Score("Позвонить маме"))
+	one := query.Score("Купить хлеб и сыр на неделю")
+	both := query.Score("Купить молоко")
+	assert.Greater(t, both, one)
+
+	assert.Equal(t, "<mark>Купить</mark> &lt;b&gt;<mark>молоко</mark>", query.Highlight("Купить <b>молоко"))
+}
+
+func TestParseQueryDropsStopWords(t *testing.T) {
+	assert.True(t, textsearch.ParseQuery("и the").IsEmpty())
+	assert.Equal(t, "deploy", textsearch.ParseQuery(`"deploy" -the`).String())
+}
@@ -0,0 +1,48 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// APIKeyPrefix - начало каждого ключа API. По нему ключ отличается от JWT в заголовке Authorization
+const APIKeyPrefix = "tdl_"
+
+// APIKeyHintLength - сколько первых символов ключа хранится открыто, чтобы пользователь узнал ключ в списке
+const APIKeyHintLength = len(APIKeyPrefix) + 8
+
+// InvitationPrefix - начало токена приглашения в список
+const InvitationPrefix = "tdi_"
+
+// NewAPIKey генерирует ключ API: префикс и 32 случайных байта
+func NewAPIKey() (string, error) {
+	return newSecret(APIKeyPrefix)
+}
+
+// NewInvitation генерирует токен приглашения в список, устроенный так же, как ключ API
+func NewInvitation() (string, error) {
+	return newSecret(InvitationPrefix)
+}
+
+func newSecret(prefix string) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// IsAPIKey - value похоже на ключ API, а не на JWT
+func IsAPIKey(value string) bool {
+	return strings.HasPrefix(value, APIKeyPrefix)
+}
+
+// HashSecret - хеш, под которым хранятся ключ API и токен приглашения. В них 256 случайных бит, перебором
+// не подобрать, поэтому медленный хеш вроде bcrypt не нужен и искать можно по хешу напрямую
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
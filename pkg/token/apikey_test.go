@@ -0,0 +1,35 @@
+package token_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	key, err := token.NewAPIKey()
+	require.NoError(t, err)
+	assert.True(t, token.IsAPIKey(key))
+	assert.Len(t, key, len(token.APIKeyPrefix)+43)
+
+	other, err := token.NewAPIKey()
+	require.NoError(t, err)
+	assert.NotEqual(t, key, other)
+
+	assert.Equal(t, token.HashSecret(key), token.HashSecret(key))
+	assert.NotEqual(t, token.HashSecret(key), token.HashSecret(other))
+	assert.NotContains(t, token.HashSecret(key), key)
+
+	assert.False(t, token.IsAPIKey("eyJhbGciOiJIUzI1NiJ9.e30.sig"))
+}
+
+func TestNewInvitation(t *testing.T) {
+	invitation, err := token.NewInvitation()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(invitation, token.InvitationPrefix))
+	assert.False(t, token.IsAPIKey(invitation))
+	assert.NotEqual(t, token.HashSecret(invitation), invitation)
+}
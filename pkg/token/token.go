@@ -0,0 +1,111 @@
+// Package token выпускает и проверяет JWT (HS256): короткоживущий access для запросов к API
+// и долгоживущий refresh, которым обновляется пара
+package token
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Kind - назначение токена. Refresh не принимается вместо access и наоборот
+type Kind string
+
+const (
+	Access  Kind = "access"
+	Refresh Kind = "refresh"
+)
+
+// ErrInvalid - токен не выпущен этим Issuer, испорчен, просрочен или другого назначения
+var ErrInvalid = errors.New("token: invalid token")
+
+// Pair - ответ на вход и обновление токенов
+type Pair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn - срок жизни access в секундах
+	ExpiresIn int `json:"expires_in"`
+}
+
+type claims struct {
+	Kind Kind `json:"typ"`
+	// Tenant - организация, в базе которой заведен пользователь. Пустая, если организаций нет
+	Tenant string `json:"tnt,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type Issuer struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+}
+
+func NewIssuer(secret []byte, accessTTL, refreshTTL time.Duration) *Issuer {
+	return &Issuer{secret: secret, accessTTL: accessTTL, refreshTTL: refreshTTL, now: time.Now}
+}
+
+// WithClock подменяет часы, для тестов
+func (i *Issuer) WithClock(now func() time.Time) *Issuer {
+	c := *i
+	c.now = now
+	return &c
+}
+
+// Issue выпускает пару токенов для subject из организации tenant
+func (i *Issuer) Issue(subject, tenant string) (*Pair, error) {
+	access, err := i.sign(subject, tenant, Access, i.accessTTL)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := i.sign(subject, tenant, Refresh, i.refreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(i.accessTTL / time.Second),
+	}, nil
+}
+
+// Parse проверяет подпись, срок и назначение токена и возвращает его subject и организацию
+func (i *Issuer) Parse(value string, kind Kind) (subject, tenant string, err error) {
+	var parsed claims
+	_, err = jwt.ParseWithClaims(value, &parsed, func(*jwt.Token) (interface{}, error) {
+		return i.secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithTimeFunc(i.now), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if parsed.Kind != kind || parsed.Subject == "" {
+		return "", "", ErrInvalid
+	}
+	return parsed.Subject, parsed.Tenant, nil
+}
+
+func (i *Issuer) sign(subject, tenant string, kind Kind, ttl time.Duration) (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+
+	now := i.now()
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		Kind:   kind,
+		Tenant: tenant,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        hex.EncodeToString(id),
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}).SignedString(i.secret)
+}
@@ -0,0 +1,77 @@
+package token_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/nekidaz/todolist/pkg/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueAndParse(t *testing.T) {
+	issuer := token.NewIssuer([]byte("secret"), 15*time.Minute, 24*time.Hour)
+
+	pair, err := issuer.Issue("user-1", "")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", pair.TokenType)
+	assert.Equal(t, 900, pair.ExpiresIn)
+
+	subject, tenant, err := issuer.Parse(pair.AccessToken, token.Access)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", subject)
+	assert.Empty(t, tenant)
+
+	subject, _, err = issuer.Parse(pair.RefreshToken, token.Refresh)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", subject)
+
+	// токены разного назначения не взаимозаменяемы
+	_, _, err = issuer.Parse(pair.RefreshToken, token.Access)
+	assert.ErrorIs(t, err, token.ErrInvalid)
+	_, _, err = issuer.Parse(pair.AccessToken, token.Refresh)
+	assert.ErrorIs(t, err, token.ErrInvalid)
+}
+
+func TestTenantClaim(t *testing.T) {
+	issuer := token.NewIssuer([]byte("secret"), time.Minute, time.Hour)
+	pair, err := issuer.Issue("user-1", "acme")
+	require.NoError(t, err)
+
+	subject, tenant, err := issuer.Parse(pair.AccessToken, token.Access)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", subject)
+	assert.Equal(t, "acme", tenant)
+
+	// refresh тоже несет организацию, новая пара выпускается для нее же
+	_, tenant, err = issuer.Parse(pair.RefreshToken, token.Refresh)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenant)
+}
+
+func TestParseRejects(t *testing.T) {
+	issuer := token.NewIssuer([]byte("secret"), time.Minute, time.Hour)
+	pair, err := issuer.Issue("user-1", "")
+	require.NoError(t, err)
+
+	later := issuer.WithClock(func() time.Time { return time.Now().Add(2 * time.Minute) })
+	_, _, err = later.Parse(pair.AccessToken, token.Access)
+	assert.ErrorIs(t, err, token.ErrInvalid, "просроченный access")
+	_, _, err = later.Parse(pair.RefreshToken, token.Refresh)
+	assert.NoError(t, err, "refresh живет дольше")
+
+	other := token.NewIssuer([]byte("other"), time.Minute, time.Hour)
+	_, _, err = other.Parse(pair.AccessToken, token.Access)
+	assert.ErrorIs(t, err, token.ErrInvalid, "чужая подпись")
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"sub": "user-1", "typ": "access", "exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, _, err = issuer.Parse(unsigned, token.Access)
+	assert.ErrorIs(t, err, token.ErrInvalid, "alg none")
+
+	_, _, err = issuer.Parse("not a token", token.Access)
+	assert.ErrorIs(t, err, token.ErrInvalid)
+}